@@ -38,6 +38,11 @@ func (c *Client) Run(ctx context.Context, testTool string) error {
 		return fmt.Errorf("failed to start SSE client: %v", err)
 	}
 
+	// Log notifications such as progress updates and streamed command output
+	sseClient.OnNotification(func(notification mcp.JSONRPCNotification) {
+		log.Printf("Notification %s: %v", notification.Method, notification.Params.AdditionalFields)
+	})
+
 	// Store the client
 	c.mcpClient = sseClient
 
@@ -134,10 +139,26 @@ func (c *Client) testTool(ctx context.Context, testTool string, toolsResult *mcp
 		return tools.TestCalculator(ctx, c.mcpClient)
 	case "filesearch":
 		return tools.TestFileSearch(ctx, c.mcpClient)
+	case "grep":
+		return tools.TestGrep(ctx, c.mcpClient)
 	case "cmdexec":
 		return tools.TestCommandExecution(ctx, c.mcpClient)
 	case "shell":
 		return tools.TestShell(ctx, c.mcpClient)
+	case "jobs":
+		return tools.TestJobs(ctx, c.mcpClient)
+	case "testrun":
+		return tools.TestTestRun(ctx, c.mcpClient)
+	case "build":
+		return tools.TestBuild(ctx, c.mcpClient)
+	case "lsp":
+		return tools.TestLSP(ctx, c.mcpClient)
+	case "rename":
+		return tools.TestRename(ctx, c.mcpClient)
+	case "refactor":
+		return tools.TestRefactor(ctx, c.mcpClient)
+	case "imports":
+		return tools.TestImports(ctx, c.mcpClient)
 	case "searchreplace":
 		return tools.TestSearchReplace(ctx, c.mcpClient)
 	case "screenshot":
@@ -182,8 +203,16 @@ func (c *Client) testAllTools(ctx context.Context, toolsResult *mcp.ListToolsRes
 		"workspace", // Test workspace first as other tools may depend on it
 		"calculator",
 		"filesearch",
+		"grep",
 		"cmdexec",
 		"shell",
+		"jobs",
+		"testrun",
+		"build",
+		"lsp",
+		"rename",
+		"refactor",
+		"imports",
 		"searchreplace",
 		"screenshot",
 		"websearch",
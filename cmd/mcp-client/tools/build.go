@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// buildFiles is a small Go module with two packages
+var buildFiles = map[string]string{
+	"go.mod": "module example.com/buildsample\n\ngo 1.21\n",
+	"greet/greet.go": `package greet
+
+import "fmt"
+
+func Greet(name string) string {
+	return fmt.Sprintf("Hello, %s", name)
+}
+`,
+	"count/count.go": `package count
+
+func Count(items []string) int {
+	return len(items)
+}
+`,
+}
+
+// TestBuild tests the build tool on a sample Go module, checking the whole
+// module and then only the package changed by an edit
+func TestBuild(ctx context.Context, c client.MCPClient) error {
+	// Create a session ID for testing
+	sessionID := "build-test-session-" + time.Now().Format("20060102-150405")
+
+	// Write the sample module to a temporary directory
+	rootDir, err := os.MkdirTemp("", "build-sample-")
+	if err != nil {
+		log.Printf("Failed to create sample directory: %v", err)
+		return err
+	}
+	defer os.RemoveAll(rootDir)
+
+	for name, content := range buildFiles {
+		path := filepath.Join(rootDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Printf("Failed to create sample directory: %v", err)
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			log.Printf("Failed to write sample file: %v", err)
+			return err
+		}
+	}
+
+	// Initialize a workspace rooted at the sample module
+	workspaceReq := mcp.CallToolRequest{}
+	workspaceReq.Params.Name = "workspace"
+	workspaceReq.Params.Arguments = map[string]interface{}{
+		"operation":  "initialize",
+		"root_dir":   rootDir,
+		"user_task":  "Testing the build tool",
+		"session_id": sessionID,
+	}
+
+	if _, err := c.CallTool(ctx, workspaceReq); err != nil {
+		log.Printf("Failed to initialize workspace: %v", err)
+		return err
+	}
+
+	// Build the module before any edits
+	callBuild(ctx, c, "Build the whole module", map[string]interface{}{
+		"session_id": sessionID,
+	})
+
+	// Break the greet package with an edit: a wrong format verb for go vet,
+	// and a type error for the compiler
+	editReq := mcp.CallToolRequest{}
+	editReq.Params.Name = "searchreplace"
+	editReq.Params.Arguments = map[string]interface{}{
+		"directory":      "greet",
+		"file_pattern":   "*.go",
+		"search_pattern": `fmt.Sprintf("Hello, %s", name)`,
+		"replacement":    `fmt.Sprintf("Hello, %d", name)`,
+		"session_id":     sessionID,
+	}
+	if _, err := c.CallTool(ctx, editReq); err != nil {
+		log.Printf("Failed to edit sample file: %v", err)
+		return err
+	}
+
+	callBuild(ctx, c, "Vet the package changed by the last edit", map[string]interface{}{
+		"session_id": sessionID,
+		"scope":      "last_edit",
+	})
+
+	editReq.Params.Arguments["search_pattern"] = `fmt.Sprintf("Hello, %d", name)`
+	editReq.Params.Arguments["replacement"] = `fmt.Sprintf("Hello, %s", nme)`
+	if _, err := c.CallTool(ctx, editReq); err != nil {
+		log.Printf("Failed to edit sample file: %v", err)
+		return err
+	}
+
+	callBuild(ctx, c, "Build the package changed by the last edit as JSON", map[string]interface{}{
+		"session_id": sessionID,
+		"scope":      "last_edit",
+		"format":     "json",
+	})
+
+	callBuild(ctx, c, "Unsupported check", map[string]interface{}{
+		"session_id": sessionID,
+		"checks":     []interface{}{"lint"},
+	})
+
+	return nil
+}
+
+// callBuild calls the build tool and logs the result
+func callBuild(ctx context.Context, c client.MCPClient, name string, arguments map[string]interface{}) {
+	log.Printf("Running build test: %s", name)
+
+	callReq := mcp.CallToolRequest{}
+	callReq.Params.Name = "build"
+	callReq.Params.Arguments = arguments
+
+	result, err := c.CallTool(ctx, callReq)
+	if err != nil {
+		log.Printf("Failed to call build: %v", err)
+		return
+	}
+
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			log.Printf("Build result:\n%s", textContent.Text)
+		}
+	}
+}
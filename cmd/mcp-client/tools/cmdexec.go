@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"log"
+	"os"
+	"runtime"
 	"time"
 
 	"github.com/mark3labs/mcp-go/client"
@@ -44,6 +46,39 @@ func TestCommandExecution(ctx context.Context, c client.MCPClient) error {
 				"timeout": 2.0,                    // But we set timeout to 2 seconds
 			},
 		},
+		{
+			name: "Environment variables",
+			arguments: map[string]interface{}{
+				"command": "echo %GREETING% $GREETING",
+				"env":     map[string]interface{}{"GREETING": "hello"},
+				"timeout": 5.0,
+			},
+		},
+		{
+			name: "Cleared environment",
+			arguments: map[string]interface{}{
+				"command":   "set",
+				"env":       map[string]interface{}{"ONLY_VARIABLE": "1"},
+				"env_clear": true,
+				"timeout":   5.0,
+			},
+		},
+		{
+			name: "Standard input",
+			arguments: map[string]interface{}{
+				"command": "sort",
+				"stdin":   "banana\napple\ncherry\n",
+				"timeout": 5.0,
+			},
+		},
+		{
+			name: "Arguments without a shell",
+			arguments: map[string]interface{}{
+				"shell":   "none",
+				"argv":    []interface{}{"git", "log", "-1", "--format=%s (it's $quoted)"},
+				"timeout": 5.0,
+			},
+		},
 	}
 
 	// Run test cases
@@ -70,5 +105,103 @@ func TestCommandExecution(ctx context.Context, c client.MCPClient) error {
 		time.Sleep(500 * time.Millisecond)
 	}
 
+	// Request progress so that partial output is streamed as notifications
+	log.Printf("Running command execution test: Streaming output with a progress token")
+
+	callReq := mcp.CallToolRequest{}
+	callReq.Params.Name = "cmdexec"
+	callReq.Params.Arguments = map[string]interface{}{
+		"command": "echo first && sleep 1 && echo second",
+		"timeout": 5.0,
+	}
+	callReq.Params.Meta = &struct {
+		ProgressToken mcp.ProgressToken `json:"progressToken,omitempty"`
+	}{ProgressToken: "cmdexec-stream-test"}
+
+	result, err := c.CallTool(ctx, callReq)
+	if err != nil {
+		log.Printf("Failed to call cmdexec: %v", err)
+		return nil
+	}
+
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			log.Printf("Command execution result:\n%s", textContent.Text)
+		}
+	}
+
+	// Sandboxing is only available on Linux
+	if runtime.GOOS == "linux" {
+		testSandboxedCommands(ctx, c)
+	}
+
 	return nil
 }
+
+// testSandboxedCommands runs commands in a workspace that overrides the
+// server's sandbox limits
+func testSandboxedCommands(ctx context.Context, c client.MCPClient) {
+	sessionID := "cmdexec-sandbox-session-" + time.Now().Format("20060102-150405")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Printf("Failed to get current working directory: %v", err)
+		return
+	}
+
+	workspaceReq := mcp.CallToolRequest{}
+	workspaceReq.Params.Name = "workspace"
+	workspaceReq.Params.Arguments = map[string]interface{}{
+		"operation":  "initialize",
+		"root_dir":   cwd,
+		"user_task":  "Testing sandboxed command execution",
+		"session_id": sessionID,
+		"sandbox": map[string]interface{}{
+			"cpu_seconds":   2.0,
+			"memory_mb":     512.0,
+			"max_processes": 256.0,
+			"no_network":    true,
+			"read_only_fs":  true,
+		},
+	}
+
+	if _, err := c.CallTool(ctx, workspaceReq); err != nil {
+		log.Printf("Failed to initialize workspace: %v", err)
+		return
+	}
+
+	testCases := []struct {
+		name    string
+		command string
+	}{
+		{name: "Write inside the workspace root", command: "touch sandbox-test.tmp && rm sandbox-test.tmp && echo written"},
+		{name: "Write outside the workspace root", command: "touch /tmp/sandbox-test.tmp"},
+		{name: "Reach the network", command: "getent hosts example.com || echo no network"},
+		{name: "Exceed the CPU time limit", command: "while :; do :; done"},
+	}
+
+	for _, tc := range testCases {
+		log.Printf("Running command execution test: Sandbox - %s", tc.name)
+
+		callReq := mcp.CallToolRequest{}
+		callReq.Params.Name = "cmdexec"
+		callReq.Params.Arguments = map[string]interface{}{
+			"command":           tc.command,
+			"working_directory": cwd,
+			"session_id":        sessionID,
+			"timeout":           10.0,
+		}
+
+		result, err := c.CallTool(ctx, callReq)
+		if err != nil {
+			log.Printf("Failed to call cmdexec: %v", err)
+			continue
+		}
+
+		if len(result.Content) > 0 {
+			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+				log.Printf("Command execution result:\n%s", textContent.Text)
+			}
+		}
+	}
+}
@@ -41,6 +41,42 @@ func TestCodeAnalysis(ctx context.Context, c client.MCPClient) error {
 				"severity":    "medium",
 			},
 		},
+		{
+			name: "Find unused code",
+			arguments: map[string]interface{}{
+				"operation":   "find_issues",
+				"target_path": "pkg",
+				"issue_types": []interface{}{"unused"},
+			},
+		},
+		{
+			name: "Find issues with linters",
+			arguments: map[string]interface{}{
+				"operation":   "find_issues",
+				"target_path": "pkg/codeanalysis",
+				"issue_types": []interface{}{"complexity"},
+				"linters":     []interface{}{"auto"},
+			},
+		},
+		{
+			name: "Find issues as SARIF",
+			arguments: map[string]interface{}{
+				"operation":   "find_issues",
+				"target_path": "pkg/serverinfo",
+				"issue_types": []interface{}{"naming", "comments"},
+				"format":      "sarif",
+			},
+		},
+		{
+			name: "Find issues on changed lines",
+			arguments: map[string]interface{}{
+				"operation":    "find_issues",
+				"target_path":  "pkg",
+				"issue_types":  []interface{}{"complexity", "naming"},
+				"changed_only": true,
+				"base_ref":     "HEAD",
+			},
+		},
 		{
 			name: "Suggest improvements",
 			arguments: map[string]interface{}{
@@ -49,6 +85,22 @@ func TestCodeAnalysis(ctx context.Context, c client.MCPClient) error {
 				"improvement_types": []interface{}{"readability", "maintainability"},
 			},
 		},
+		{
+			name: "Find duplicates",
+			arguments: map[string]interface{}{
+				"operation":      "find_duplicates",
+				"directory_path": "pkg",
+				"file_patterns":  []interface{}{"*.go"},
+				"min_tokens":     100,
+			},
+		},
+		{
+			name: "Dependencies",
+			arguments: map[string]interface{}{
+				"operation": "dependencies",
+				"rules":     []interface{}{"pkg/* must not import cmd/*"},
+			},
+		},
 	}
 
 	// Run test cases
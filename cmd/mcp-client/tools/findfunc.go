@@ -78,6 +78,23 @@ func calculateSum(a, b int) int {
 func multiplyNumbers(a, b int) int {
 	return a * b
 }
+`,
+		"test_cs.cs": `// C# test file
+public class Calculator<T> where T : class
+{
+    public int calculateSum(int a, int b) { return a + b; }
+
+    public int this[int i] => i;
+
+    public static implicit operator int(Calculator<T> c) => 0;
+}
+`,
+		"test_cpp.cpp": `// C++ test file
+struct Vector {
+    int calculateSum(int a, int b) { return a + b; }
+    int operator[](int i) const { return i; }
+    operator bool() const { return true; }
+};
 `,
 	}
 
@@ -123,6 +140,32 @@ func multiplyNumbers(a, b int) int {
 				"use_relative_paths": true,
 			},
 		},
+		{
+			name: "Find method of a constrained generic C# class",
+			arguments: map[string]interface{}{
+				"function_name":      "Calculator.calculateSum",
+				"search_directory":   testDir,
+				"language":           "C#",
+				"use_relative_paths": true,
+			},
+		},
+		{
+			name: "Find C# indexer",
+			arguments: map[string]interface{}{
+				"function_name":      "Calculator.this",
+				"search_directory":   testDir,
+				"use_relative_paths": true,
+			},
+		},
+		{
+			name: "Find C++ operator",
+			arguments: map[string]interface{}{
+				"function_name":      "operator[]",
+				"search_directory":   testDir,
+				"language":           "C++",
+				"use_relative_paths": true,
+			},
+		},
 		{
 			name: "Find function that doesn't exist",
 			arguments: map[string]interface{}{
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// TestGrep tests the grep tool with various search options
+func TestGrep(ctx context.Context, c client.MCPClient) error {
+	// Define test cases
+	testCases := []struct {
+		name      string
+		arguments map[string]interface{}
+	}{
+		{
+			name: "Regex search with context",
+			arguments: map[string]interface{}{
+				"directory": ".",
+				"pattern":   `func\s+Test\w+`,
+				"include":   []interface{}{"*.go"},
+				"context":   2.0,
+			},
+		},
+		{
+			name: "Fixed string search",
+			arguments: map[string]interface{}{
+				"directory":     ".",
+				"pattern":       "c.CallTool(ctx,",
+				"fixed_strings": true,
+				"include":       []interface{}{"*.go"},
+			},
+		},
+		{
+			name: "Multiline search",
+			arguments: map[string]interface{}{
+				"directory": ".",
+				"pattern":   `if err != nil \{\s*return`,
+				"multiline": true,
+				"include":   []interface{}{"*.go"},
+			},
+		},
+		{
+			name: "Capped search with exclusions",
+			arguments: map[string]interface{}{
+				"directory":            ".",
+				"pattern":              "log.Printf",
+				"exclude":              []interface{}{"vendor", "*_test.go"},
+				"max_matches_per_file": 2.0,
+				"max_total_matches":    10.0,
+			},
+		},
+	}
+
+	// Run test cases
+	for _, tc := range testCases {
+		log.Printf("Running grep test: %s", tc.name)
+
+		callReq := mcp.CallToolRequest{}
+		callReq.Params.Name = "grep"
+		callReq.Params.Arguments = tc.arguments
+
+		result, err := c.CallTool(ctx, callReq)
+		if err != nil {
+			log.Printf("Failed to call grep: %v", err)
+			continue
+		}
+
+		if len(result.Content) > 0 {
+			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+				log.Printf("Grep result:\n%s", textContent.Text)
+			}
+		}
+
+		// Add a small delay between tests
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// importsFiles is a small Go module whose main file uses packages it
+// doesn't import and imports a package it doesn't use
+var importsFiles = map[string]string{
+	"go.mod": "module example.com/importssample\n\ngo 1.21\n",
+	"greet/greet.go": `package greet
+
+// Hello returns a greeting for name
+func Hello(name string) string {
+	return "Hello, " + name
+}
+`,
+	"main.go": `package main
+
+import (
+	"os"
+	"fmt"
+)
+
+func main() {
+	names := strings.Fields("ada grace")
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(greet.Hello(name))
+	}
+}
+`,
+}
+
+// TestImports tests the imports tool on a sample module
+func TestImports(ctx context.Context, c client.MCPClient) error {
+	// Create a session ID for testing
+	sessionID := "imports-test-session-" + time.Now().Format("20060102-150405")
+
+	// Write the sample module to a temporary directory
+	rootDir, err := os.MkdirTemp("", "imports-sample-")
+	if err != nil {
+		log.Printf("Failed to create sample directory: %v", err)
+		return err
+	}
+	defer os.RemoveAll(rootDir)
+
+	for name, content := range importsFiles {
+		path := filepath.Join(rootDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Printf("Failed to create sample directory: %v", err)
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			log.Printf("Failed to write sample file: %v", err)
+			return err
+		}
+	}
+
+	// Initialize a workspace rooted at the sample module
+	workspaceReq := mcp.CallToolRequest{}
+	workspaceReq.Params.Name = "workspace"
+	workspaceReq.Params.Arguments = map[string]interface{}{
+		"operation":  "initialize",
+		"root_dir":   rootDir,
+		"user_task":  "Testing the imports tool",
+		"session_id": sessionID,
+	}
+
+	if _, err := c.CallTool(ctx, workspaceReq); err != nil {
+		log.Printf("Failed to initialize workspace: %v", err)
+		return err
+	}
+
+	callImports(ctx, c, "Add imports", map[string]interface{}{
+		"operation":  "add",
+		"session_id": sessionID,
+		"file_path":  "main.go",
+		"paths":      []interface{}{"strings", "g example.com/importssample/greet"},
+		"preview":    true,
+	})
+
+	callImports(ctx, c, "Remove an import", map[string]interface{}{
+		"operation":  "remove",
+		"session_id": sessionID,
+		"file_path":  "main.go",
+		"paths":      []interface{}{"os"},
+		"preview":    true,
+	})
+
+	callImports(ctx, c, "Organize imports", map[string]interface{}{
+		"operation":  "organize",
+		"session_id": sessionID,
+		"file_path":  "main.go",
+		"preview":    true,
+	})
+
+	callImports(ctx, c, "Fix imports", map[string]interface{}{
+		"operation":  "fix",
+		"session_id": sessionID,
+		"file_path":  "main.go",
+	})
+
+	return nil
+}
+
+// callImports calls the imports tool and logs the result
+func callImports(ctx context.Context, c client.MCPClient, name string, arguments map[string]interface{}) {
+	log.Printf("Running imports test: %s", name)
+
+	callReq := mcp.CallToolRequest{}
+	callReq.Params.Name = "imports"
+	callReq.Params.Arguments = arguments
+
+	result, err := c.CallTool(ctx, callReq)
+	if err != nil {
+		log.Printf("Failed to call imports: %v", err)
+		return
+	}
+
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			log.Printf("Imports result:\n%s", textContent.Text)
+		}
+	}
+}
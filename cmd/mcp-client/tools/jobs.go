@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// TestJobs tests the jobs tool by starting background jobs and following them
+func TestJobs(ctx context.Context, c client.MCPClient) error {
+	// Create a session ID for testing
+	sessionID := "jobs-test-session-" + time.Now().Format("20060102-150405")
+
+	// Initialize workspace first
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Printf("Failed to get current working directory: %v", err)
+		return err
+	}
+
+	workspaceReq := mcp.CallToolRequest{}
+	workspaceReq.Params.Name = "workspace"
+	workspaceReq.Params.Arguments = map[string]interface{}{
+		"operation":  "initialize",
+		"root_dir":   cwd,
+		"user_task":  "Testing the jobs tool",
+		"session_id": sessionID,
+	}
+
+	if _, err := c.CallTool(ctx, workspaceReq); err != nil {
+		log.Printf("Failed to initialize workspace: %v", err)
+		return err
+	}
+
+	// Start a job that produces output over a few seconds
+	slowJobID := startTestJob(ctx, c, sessionID, "for i in 1 2 3; do echo line $i; sleep 1; done", 0)
+
+	// Start a job that runs until it is killed
+	longJobID := startTestJob(ctx, c, sessionID, "sleep 60", 0)
+
+	// Start a job that exceeds its timeout
+	startTestJob(ctx, c, sessionID, "sleep 10", 1.0)
+
+	// Define test cases
+	testCases := []struct {
+		name      string
+		arguments map[string]interface{}
+	}{
+		{
+			name: "Status of running job",
+			arguments: map[string]interface{}{
+				"operation":  "status",
+				"session_id": sessionID,
+				"job_id":     slowJobID,
+			},
+		},
+		{
+			name: "Tail output from the beginning",
+			arguments: map[string]interface{}{
+				"operation":  "tail",
+				"session_id": sessionID,
+				"job_id":     slowJobID,
+				"offset":     0.0,
+			},
+		},
+		{
+			name: "Wait for job to finish",
+			arguments: map[string]interface{}{
+				"operation":  "wait",
+				"session_id": sessionID,
+				"job_id":     slowJobID,
+				"timeout":    10.0,
+			},
+		},
+		{
+			name: "Wait with a short timeout",
+			arguments: map[string]interface{}{
+				"operation":  "wait",
+				"session_id": sessionID,
+				"job_id":     longJobID,
+				"timeout":    1.0,
+			},
+		},
+		{
+			name: "Kill running job",
+			arguments: map[string]interface{}{
+				"operation":  "kill",
+				"session_id": sessionID,
+				"job_id":     longJobID,
+			},
+		},
+		{
+			name: "List jobs",
+			arguments: map[string]interface{}{
+				"operation":  "list",
+				"session_id": sessionID,
+			},
+		},
+		{
+			name: "Unknown job",
+			arguments: map[string]interface{}{
+				"operation":  "status",
+				"session_id": sessionID,
+				"job_id":     "job-does-not-exist",
+			},
+		},
+	}
+
+	// Run test cases
+	for _, tc := range testCases {
+		log.Printf("Running jobs test: %s", tc.name)
+
+		callReq := mcp.CallToolRequest{}
+		callReq.Params.Name = "jobs"
+		callReq.Params.Arguments = tc.arguments
+
+		result, err := c.CallTool(ctx, callReq)
+		if err != nil {
+			log.Printf("Failed to call jobs: %v", err)
+			continue
+		}
+
+		if len(result.Content) > 0 {
+			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+				log.Printf("Jobs result:\n%s", textContent.Text)
+			}
+		}
+
+		// Add a small delay between tests
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	// Read the jobs resource for the session
+	resourceReq := mcp.ReadResourceRequest{}
+	resourceReq.Params.URI = "jobs://" + sessionID
+
+	resourceResult, err := c.ReadResource(ctx, resourceReq)
+	if err != nil {
+		log.Printf("Failed to read jobs resource: %v", err)
+		return nil
+	}
+
+	for _, content := range resourceResult.Contents {
+		if textContent, ok := content.(mcp.TextResourceContents); ok {
+			log.Printf("Jobs resource:\n%s", textContent.Text)
+		}
+	}
+
+	return nil
+}
+
+// startTestJob starts a job and returns its ID
+func startTestJob(ctx context.Context, c client.MCPClient, sessionID, command string, timeout float64) string {
+	log.Printf("Starting job: %s", command)
+
+	callReq := mcp.CallToolRequest{}
+	callReq.Params.Name = "jobs"
+	callReq.Params.Arguments = map[string]interface{}{
+		"operation":  "start",
+		"session_id": sessionID,
+		"command":    command,
+		"timeout":    timeout,
+	}
+
+	result, err := c.CallTool(ctx, callReq)
+	if err != nil {
+		log.Printf("Failed to start job: %v", err)
+		return ""
+	}
+
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			log.Printf("Start result:\n%s", textContent.Text)
+			if match := regexp.MustCompile(`Job ID: (\S+)`).FindStringSubmatch(textContent.Text); match != nil {
+				return match[1]
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// lspFiles is a small Go module and a Python script. The Go files use a
+// language server if the server was started with -lsp gopls; the Python
+// script uses the regex fallbacks.
+var lspFiles = map[string]string{
+	"go.mod": "module example.com/lspsample\n\ngo 1.21\n",
+	"greet/greet.go": `package greet
+
+// Greet returns a greeting for name
+func Greet(name string) string {
+	return "Hello, " + name
+}
+`,
+	"main.go": `package main
+
+import "example.com/lspsample/greet"
+
+func main() {
+	println(greet.Greet("world"))
+}
+`,
+	"tool.py": `def helper(value):
+    return value * 2
+
+
+def main():
+    print(helper(21))
+`,
+}
+
+// TestLSP tests the lsp tool on a sample project
+func TestLSP(ctx context.Context, c client.MCPClient) error {
+	// Create a session ID for testing
+	sessionID := "lsp-test-session-" + time.Now().Format("20060102-150405")
+
+	// Write the sample project to a temporary directory
+	rootDir, err := os.MkdirTemp("", "lsp-sample-")
+	if err != nil {
+		log.Printf("Failed to create sample directory: %v", err)
+		return err
+	}
+	defer os.RemoveAll(rootDir)
+
+	for name, content := range lspFiles {
+		path := filepath.Join(rootDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Printf("Failed to create sample directory: %v", err)
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			log.Printf("Failed to write sample file: %v", err)
+			return err
+		}
+	}
+
+	// Initialize a workspace rooted at the sample project
+	workspaceReq := mcp.CallToolRequest{}
+	workspaceReq.Params.Name = "workspace"
+	workspaceReq.Params.Arguments = map[string]interface{}{
+		"operation":  "initialize",
+		"root_dir":   rootDir,
+		"user_task":  "Testing the lsp tool",
+		"session_id": sessionID,
+	}
+
+	if _, err := c.CallTool(ctx, workspaceReq); err != nil {
+		log.Printf("Failed to initialize workspace: %v", err)
+		return err
+	}
+
+	callLSP(ctx, c, "Server status", map[string]interface{}{
+		"operation": "status",
+	})
+
+	callLSP(ctx, c, "Go to definition", map[string]interface{}{
+		"operation":  "definition",
+		"session_id": sessionID,
+		"file_path":  "main.go",
+		"line":       6,
+		"symbol":     "Greet",
+	})
+
+	callLSP(ctx, c, "Find references", map[string]interface{}{
+		"operation":  "references",
+		"session_id": sessionID,
+		"file_path":  "greet/greet.go",
+		"line":       4,
+		"symbol":     "Greet",
+	})
+
+	callLSP(ctx, c, "Hover", map[string]interface{}{
+		"operation":  "hover",
+		"session_id": sessionID,
+		"file_path":  "greet/greet.go",
+		"line":       4,
+		"column":     6,
+	})
+
+	callLSP(ctx, c, "Document symbols", map[string]interface{}{
+		"operation":  "symbols",
+		"session_id": sessionID,
+		"file_path":  "tool.py",
+	})
+
+	callLSP(ctx, c, "References with the regex fallback", map[string]interface{}{
+		"operation":  "references",
+		"session_id": sessionID,
+		"file_path":  "tool.py",
+		"line":       1,
+		"symbol":     "helper",
+	})
+
+	callLSP(ctx, c, "Rename preview", map[string]interface{}{
+		"operation":  "rename",
+		"session_id": sessionID,
+		"file_path":  "greet/greet.go",
+		"line":       4,
+		"symbol":     "Greet",
+		"new_name":   "Welcome",
+		"dry_run":    true,
+	})
+
+	callLSP(ctx, c, "Diagnostics", map[string]interface{}{
+		"operation":  "diagnostics",
+		"session_id": sessionID,
+		"file_path":  "main.go",
+	})
+
+	return nil
+}
+
+// callLSP calls the lsp tool and logs the result
+func callLSP(ctx context.Context, c client.MCPClient, name string, arguments map[string]interface{}) {
+	log.Printf("Running lsp test: %s", name)
+
+	callReq := mcp.CallToolRequest{}
+	callReq.Params.Name = "lsp"
+	callReq.Params.Arguments = arguments
+
+	result, err := c.CallTool(ctx, callReq)
+	if err != nil {
+		log.Printf("Failed to call lsp: %v", err)
+		return
+	}
+
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			log.Printf("LSP result:\n%s", textContent.Text)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// refactorFiles is a small Go module with a loop to extract and helper
+// functions to inline
+var refactorFiles = map[string]string{
+	"go.mod": "module example.com/refactorsample\n\ngo 1.21\n",
+	"stats/stats.go": `package stats
+
+import "fmt"
+
+// square returns v squared
+func square(v float64) float64 {
+	return v * v
+}
+
+// report prints a labeled value
+func report(label string, value float64) {
+	fmt.Printf("%s: %.2f\n", label, value)
+}
+
+// Summarize prints the mean and variance of values
+func Summarize(values []float64) {
+	sum := 0.0
+	sumSquares := 0.0
+	for _, v := range values {
+		// accumulate both sums
+		sum += v
+		sumSquares += square(v)
+	}
+	mean := sum / float64(len(values))
+	report("mean", mean)
+	report("variance", sumSquares/float64(len(values))-square(mean))
+}
+`,
+}
+
+// TestRefactor tests the refactor tool on a sample module
+func TestRefactor(ctx context.Context, c client.MCPClient) error {
+	// Create a session ID for testing
+	sessionID := "refactor-test-session-" + time.Now().Format("20060102-150405")
+
+	// Write the sample module to a temporary directory
+	rootDir, err := os.MkdirTemp("", "refactor-sample-")
+	if err != nil {
+		log.Printf("Failed to create sample directory: %v", err)
+		return err
+	}
+	defer os.RemoveAll(rootDir)
+
+	for name, content := range refactorFiles {
+		path := filepath.Join(rootDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Printf("Failed to create sample directory: %v", err)
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			log.Printf("Failed to write sample file: %v", err)
+			return err
+		}
+	}
+
+	// Initialize a workspace rooted at the sample module
+	workspaceReq := mcp.CallToolRequest{}
+	workspaceReq.Params.Name = "workspace"
+	workspaceReq.Params.Arguments = map[string]interface{}{
+		"operation":  "initialize",
+		"root_dir":   rootDir,
+		"user_task":  "Testing the refactor tool",
+		"session_id": sessionID,
+	}
+
+	if _, err := c.CallTool(ctx, workspaceReq); err != nil {
+		log.Printf("Failed to initialize workspace: %v", err)
+		return err
+	}
+
+	callRefactor(ctx, c, "Extract the loop into a function", map[string]interface{}{
+		"operation":     "extract",
+		"session_id":    sessionID,
+		"file_path":     "stats/stats.go",
+		"start_line":    19,
+		"end_line":      23,
+		"function_name": "sums",
+	})
+
+	callRefactor(ctx, c, "Extract lines that return", map[string]interface{}{
+		"operation":     "extract",
+		"session_id":    sessionID,
+		"file_path":     "stats/stats.go",
+		"start_line":    7,
+		"function_name": "invalid",
+	})
+
+	callRefactor(ctx, c, "Inline a function with a single return by name", map[string]interface{}{
+		"operation":  "inline",
+		"session_id": sessionID,
+		"directory":  ".",
+		"function":   "stats.square",
+	})
+
+	callRefactor(ctx, c, "Inline one call of a function without results and apply it", map[string]interface{}{
+		"operation":  "inline",
+		"session_id": sessionID,
+		"file_path":  "stats/stats.go",
+		"line":       25,
+		"column":     2,
+		"mode":       "apply",
+	})
+
+	return nil
+}
+
+// callRefactor calls the refactor tool and logs the result
+func callRefactor(ctx context.Context, c client.MCPClient, name string, arguments map[string]interface{}) {
+	log.Printf("Running refactor test: %s", name)
+
+	callReq := mcp.CallToolRequest{}
+	callReq.Params.Name = "refactor"
+	callReq.Params.Arguments = arguments
+
+	result, err := c.CallTool(ctx, callReq)
+	if err != nil {
+		log.Printf("Failed to call refactor: %v", err)
+		return
+	}
+
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			log.Printf("Refactor result:\n%s", textContent.Text)
+		}
+	}
+}
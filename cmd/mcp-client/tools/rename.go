@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// renameFiles is a small Go module with an interface and two types that
+// implement it, so that renaming the interface method renames theirs too
+var renameFiles = map[string]string{
+	"go.mod": "module example.com/renamesample\n\ngo 1.21\n",
+	"shapes/shapes.go": `package shapes
+
+// Shape is a plane figure
+type Shape interface {
+	Area() float64
+}
+
+// Square is a square with sides of length Side
+type Square struct {
+	Side float64
+}
+
+// Area returns the area of the square
+func (s Square) Area() float64 {
+	return s.Side * s.Side
+}
+
+// Circle is a circle with radius Radius
+type Circle struct {
+	Radius float64
+}
+
+// Area returns the area of the circle
+func (c Circle) Area() float64 {
+	return 3.14159 * c.Radius * c.Radius
+}
+`,
+	"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/renamesample/shapes"
+)
+
+func main() {
+	// Area is printed for every shape
+	for _, shape := range []shapes.Shape{shapes.Square{Side: 2}, shapes.Circle{Radius: 1}} {
+		fmt.Println(shape.Area())
+	}
+}
+`,
+}
+
+// TestRename tests the rename tool on a sample module
+func TestRename(ctx context.Context, c client.MCPClient) error {
+	// Create a session ID for testing
+	sessionID := "rename-test-session-" + time.Now().Format("20060102-150405")
+
+	// Write the sample module to a temporary directory
+	rootDir, err := os.MkdirTemp("", "rename-sample-")
+	if err != nil {
+		log.Printf("Failed to create sample directory: %v", err)
+		return err
+	}
+	defer os.RemoveAll(rootDir)
+
+	for name, content := range renameFiles {
+		path := filepath.Join(rootDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Printf("Failed to create sample directory: %v", err)
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			log.Printf("Failed to write sample file: %v", err)
+			return err
+		}
+	}
+
+	// Initialize a workspace rooted at the sample module
+	workspaceReq := mcp.CallToolRequest{}
+	workspaceReq.Params.Name = "workspace"
+	workspaceReq.Params.Arguments = map[string]interface{}{
+		"operation":  "initialize",
+		"root_dir":   rootDir,
+		"user_task":  "Testing the rename tool",
+		"session_id": sessionID,
+	}
+
+	if _, err := c.CallTool(ctx, workspaceReq); err != nil {
+		log.Printf("Failed to initialize workspace: %v", err)
+		return err
+	}
+
+	callRename(ctx, c, "Preview renaming an interface method by position", map[string]interface{}{
+		"session_id": sessionID,
+		"file_path":  "shapes/shapes.go",
+		"line":       5,
+		"column":     2,
+		"new_name":   "Surface",
+	})
+
+	callRename(ctx, c, "Rename that would conflict with an existing field", map[string]interface{}{
+		"session_id": sessionID,
+		"name":       "shapes.Square.Area",
+		"new_name":   "Side",
+	})
+
+	callRename(ctx, c, "Apply renaming a type by qualified name", map[string]interface{}{
+		"session_id": sessionID,
+		"name":       "example.com/renamesample/shapes.Circle",
+		"new_name":   "Disc",
+		"mode":       "apply",
+	})
+
+	return nil
+}
+
+// callRename calls the rename tool and logs the result
+func callRename(ctx context.Context, c client.MCPClient, name string, arguments map[string]interface{}) {
+	log.Printf("Running rename test: %s", name)
+
+	callReq := mcp.CallToolRequest{}
+	callReq.Params.Name = "rename"
+	callReq.Params.Arguments = arguments
+
+	result, err := c.CallTool(ctx, callReq)
+	if err != nil {
+		log.Printf("Failed to call rename: %v", err)
+		return
+	}
+
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			log.Printf("Rename result:\n%s", textContent.Text)
+		}
+	}
+}
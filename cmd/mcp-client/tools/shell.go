@@ -122,6 +122,24 @@ func TestShell(ctx context.Context, c client.MCPClient) error {
 				"timeout":    5.0,
 			},
 		},
+		{
+			name: "Execute command that prints after a delay",
+			arguments: map[string]interface{}{
+				"operation":  "execute",
+				"session_id": sessionID,
+				"command":    "sleep 1; echo hi",
+				"timeout":    5.0,
+			},
+		},
+		{
+			name: "Execute command that exceeds the timeout",
+			arguments: map[string]interface{}{
+				"operation":  "execute",
+				"session_id": sessionID,
+				"command":    "echo started; sleep 30; echo not reached",
+				"timeout":    2.0,
+			},
+		},
 		{
 			name: "Check bash shell session status",
 			arguments: map[string]interface{}{
@@ -218,6 +236,249 @@ func TestShell(ctx context.Context, c client.MCPClient) error {
 		time.Sleep(500 * time.Millisecond)
 	}
 
+	// Test PTY shell
+	log.Printf("\n=== TESTING PTY SHELL ===\n")
+	ptySessionID := "shell-test-pty-" + time.Now().Format("20060102-150405")
+
+	// Define test cases for a bash shell on a pseudo-terminal
+	ptyTestCases := []struct {
+		name      string
+		arguments map[string]interface{}
+	}{
+		{
+			name: "Initialize PTY shell session",
+			arguments: map[string]interface{}{
+				"operation":  "initialize",
+				"session_id": ptySessionID,
+				"shell_type": "bash",
+				"pty":        true,
+				"rows":       24.0,
+				"cols":       80.0,
+			},
+		},
+		{
+			name: "Execute command that checks for a terminal",
+			arguments: map[string]interface{}{
+				"operation":  "execute",
+				"session_id": ptySessionID,
+				"command":    "test -t 1 && echo stdout is a terminal",
+				"timeout":    5.0,
+			},
+		},
+		{
+			name: "Start an interactive program",
+			arguments: map[string]interface{}{
+				"operation":  "send",
+				"session_id": ptySessionID,
+				"input":      "cat -n",
+				"keys":       []interface{}{"enter"},
+			},
+		},
+		{
+			name: "Type a line into the interactive program",
+			arguments: map[string]interface{}{
+				"operation":  "send",
+				"session_id": ptySessionID,
+				"input":      "hello from the terminal",
+				"keys":       []interface{}{"enter"},
+			},
+		},
+		{
+			name: "Read output with escape sequences stripped",
+			arguments: map[string]interface{}{
+				"operation":  "read",
+				"session_id": ptySessionID,
+				"mode":       "text",
+			},
+		},
+		{
+			name: "Send Ctrl-C to stop the program",
+			arguments: map[string]interface{}{
+				"operation":  "send",
+				"session_id": ptySessionID,
+				"keys":       []interface{}{"ctrl-c"},
+			},
+		},
+		{
+			name: "Resize the terminal",
+			arguments: map[string]interface{}{
+				"operation":  "resize",
+				"session_id": ptySessionID,
+				"rows":       40.0,
+				"cols":       120.0,
+			},
+		},
+		{
+			name: "Check the new terminal size",
+			arguments: map[string]interface{}{
+				"operation":  "execute",
+				"session_id": ptySessionID,
+				"command":    "stty size",
+				"timeout":    5.0,
+			},
+		},
+		{
+			name: "Read the rendered screen",
+			arguments: map[string]interface{}{
+				"operation":  "read",
+				"session_id": ptySessionID,
+				"mode":       "screen",
+			},
+		},
+		{
+			name: "Close PTY shell session",
+			arguments: map[string]interface{}{
+				"operation":  "close",
+				"session_id": ptySessionID,
+			},
+		},
+	}
+
+	// Run PTY test cases
+	for _, tc := range ptyTestCases {
+		log.Printf("\nRunning PTY shell test: %s", tc.name)
+
+		callReq := mcp.CallToolRequest{}
+		callReq.Params.Name = "shell"
+		callReq.Params.Arguments = tc.arguments
+
+		result, err := c.CallTool(ctx, callReq)
+		if err != nil {
+			log.Printf("Failed to call shell for PTY: %v", err)
+			continue
+		}
+
+		if len(result.Content) > 0 {
+			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+				log.Printf("PTY shell result:\n%s", textContent.Text)
+			}
+		}
+
+		// Add a small delay between tests
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	// Test several named shells in one workspace session
+	log.Printf("\n=== TESTING NAMED SHELLS ===\n")
+	namedSessionID := "shell-test-named-" + time.Now().Format("20060102-150405")
+
+	// Define test cases for named shells
+	namedTestCases := []struct {
+		name      string
+		arguments map[string]interface{}
+	}{
+		{
+			name: "Initialize a shell for a server",
+			arguments: map[string]interface{}{
+				"operation":  "initialize",
+				"session_id": namedSessionID,
+				"shell_name": "server",
+				"shell_type": "bash",
+			},
+		},
+		{
+			name: "Initialize a shell for tests",
+			arguments: map[string]interface{}{
+				"operation":  "initialize",
+				"session_id": namedSessionID,
+				"shell_name": "tests",
+				"shell_type": "bash",
+			},
+		},
+		{
+			name: "Set environment and directory in the server shell",
+			arguments: map[string]interface{}{
+				"operation":  "execute",
+				"session_id": namedSessionID,
+				"shell_name": "server",
+				"command":    "export ROLE=server; cd /tmp",
+				"timeout":    5.0,
+			},
+		},
+		{
+			name: "Check that the tests shell is unaffected",
+			arguments: map[string]interface{}{
+				"operation":  "execute",
+				"session_id": namedSessionID,
+				"shell_name": "tests",
+				"command":    "echo role=${ROLE:-none}; pwd",
+				"timeout":    5.0,
+			},
+		},
+		{
+			name: "List shells",
+			arguments: map[string]interface{}{
+				"operation":  "list",
+				"session_id": namedSessionID,
+			},
+		},
+		{
+			name: "Switch to the server shell",
+			arguments: map[string]interface{}{
+				"operation":  "switch",
+				"session_id": namedSessionID,
+				"shell_name": "server",
+			},
+		},
+		{
+			name: "Execute in the active shell",
+			arguments: map[string]interface{}{
+				"operation":  "execute",
+				"session_id": namedSessionID,
+				"command":    "echo role=$ROLE; pwd",
+				"timeout":    5.0,
+			},
+		},
+		{
+			name: "Show the server shell history",
+			arguments: map[string]interface{}{
+				"operation":  "history",
+				"session_id": namedSessionID,
+				"shell_name": "server",
+			},
+		},
+		{
+			name: "Close the server shell",
+			arguments: map[string]interface{}{
+				"operation":  "close",
+				"session_id": namedSessionID,
+				"shell_name": "server",
+			},
+		},
+		{
+			name: "Close the tests shell",
+			arguments: map[string]interface{}{
+				"operation":  "close",
+				"session_id": namedSessionID,
+				"shell_name": "tests",
+			},
+		},
+	}
+
+	// Run named shell test cases
+	for _, tc := range namedTestCases {
+		log.Printf("\nRunning named shell test: %s", tc.name)
+
+		callReq := mcp.CallToolRequest{}
+		callReq.Params.Name = "shell"
+		callReq.Params.Arguments = tc.arguments
+
+		result, err := c.CallTool(ctx, callReq)
+		if err != nil {
+			log.Printf("Failed to call shell for named shells: %v", err)
+			continue
+		}
+
+		if len(result.Content) > 0 {
+			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+				log.Printf("Named shell result:\n%s", textContent.Text)
+			}
+		}
+
+		// Add a small delay between tests
+		time.Sleep(500 * time.Millisecond)
+	}
+
 	// Test PowerShell
 	log.Printf("\n=== TESTING POWERSHELL SHELL ===\n")
 	powershellSessionID := "shell-test-powershell-" + time.Now().Format("20060102-150405")
@@ -138,6 +138,14 @@ func displayMessge(text string) {
 				"use_relative_paths": true,
 			},
 		},
+		{
+			name: "Check as SARIF",
+			arguments: map[string]interface{}{
+				"path":           filepath.Join(testDir, "test_identifiers.go"),
+				"check_comments": true,
+				"format":         "sarif",
+			},
+		},
 	}
 
 	// Run test cases
@@ -9,9 +9,12 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/build"
 	"github.com/Code-Monger/CodeSpinneret/pkg/calculator"
 	"github.com/Code-Monger/CodeSpinneret/pkg/cmdexec"
 	"github.com/Code-Monger/CodeSpinneret/pkg/codeanalysis"
@@ -19,15 +22,23 @@ import (
 	"github.com/Code-Monger/CodeSpinneret/pkg/findcallers"
 	"github.com/Code-Monger/CodeSpinneret/pkg/findfunc"
 	"github.com/Code-Monger/CodeSpinneret/pkg/funcdef"
+	"github.com/Code-Monger/CodeSpinneret/pkg/imports"
+	"github.com/Code-Monger/CodeSpinneret/pkg/jobs"
 	"github.com/Code-Monger/CodeSpinneret/pkg/linecount"
+	"github.com/Code-Monger/CodeSpinneret/pkg/lsp"
 	"github.com/Code-Monger/CodeSpinneret/pkg/patch"
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
 	"github.com/Code-Monger/CodeSpinneret/pkg/rag"
+	"github.com/Code-Monger/CodeSpinneret/pkg/refactor"
+	"github.com/Code-Monger/CodeSpinneret/pkg/rename"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
 	"github.com/Code-Monger/CodeSpinneret/pkg/screenshot"
 	"github.com/Code-Monger/CodeSpinneret/pkg/searchreplace"
 	"github.com/Code-Monger/CodeSpinneret/pkg/serverinfo"
 	"github.com/Code-Monger/CodeSpinneret/pkg/shell"
 	"github.com/Code-Monger/CodeSpinneret/pkg/spellcheck"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/testrun"
 	"github.com/Code-Monger/CodeSpinneret/pkg/webfetch"
 	"github.com/Code-Monger/CodeSpinneret/pkg/websearch"
 	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
@@ -42,6 +53,18 @@ var (
 	timeoutSecs  = flag.Int("timeout", 300, "Server timeout in seconds")
 	instructions = flag.String("instructions", "This is a Model Context Protocol server implementation.", "Server instructions")
 	dataDir      = flag.String("data-dir", filepath.Join(".", "data"), "Directory to store data files")
+	maxResult    = flag.Int("max-result-size", artifact.DefaultMaxResultSize, "Bytes of command output and other large tool results returned inline; the full text is saved as an artifact")
+	policyFile   = flag.String("policy", "", "Command policy file (JSON) for the cmdexec, shell and jobs tools")
+	lspServers   = flag.String("lsp", "", "Comma-separated language servers for the lsp tool: "+strings.Join(lsp.PresetNames(), ", "))
+	lspConfig    = flag.String("lsp-config", "", "Language server configuration file (JSON) for the lsp tool")
+
+	// Sandbox limits for the cmdexec and jobs tools (Linux only), which workspaces can override
+	sandboxCPU       = flag.Int("sandbox-cpu", 0, "CPU time limit in seconds for executed commands (0: no limit)")
+	sandboxMemory    = flag.Int("sandbox-memory", 0, "Memory limit in MB for executed commands (0: no limit)")
+	sandboxProcesses = flag.Int("sandbox-procs", 0, "Process count limit for executed commands (0: no limit)")
+	sandboxNoNetwork = flag.Bool("sandbox-no-network", false, "Run executed commands without network access")
+	sandboxReadOnly  = flag.Bool("sandbox-readonly", false, "Make the filesystem read-only for executed commands, except for the workspace root")
+	sandboxWritable  = flag.String("sandbox-writable", "", "Comma-separated paths that stay writable with -sandbox-readonly, besides the workspace root")
 )
 
 func main() {
@@ -68,13 +91,47 @@ func main() {
 		log.Fatalf("Failed to initialize stats manager: %v", err)
 	}
 
+	// Initialize artifact storage for large tool results
+	if err := artifact.InitArtifacts(*dataDir, *maxResult); err != nil {
+		log.Fatalf("Failed to initialize artifact storage: %v", err)
+	}
+
+	// Load the command policy
+	if err := policy.InitPolicy(*policyFile, *dataDir); err != nil {
+		log.Fatalf("Failed to initialize command policy: %v", err)
+	}
+
+	// Set the sandbox limits for executed commands
+	sandboxConfig := sandbox.Config{
+		CPUSeconds:   *sandboxCPU,
+		MemoryMB:     *sandboxMemory,
+		MaxProcesses: *sandboxProcesses,
+		NoNetwork:    *sandboxNoNetwork,
+		ReadOnlyFS:   *sandboxReadOnly,
+	}
+	if *sandboxWritable != "" {
+		sandboxConfig.WritablePaths = strings.Split(*sandboxWritable, ",")
+	}
+	sandbox.SetDefaultConfig(sandboxConfig)
+	log.Printf("[Server] Sandbox limits: %s", sandboxConfig)
+
+	// Configure the language servers, which are started on first use
+	if err := lsp.InitLSP(*lspServers, *lspConfig); err != nil {
+		log.Fatalf("Failed to configure language servers: %v", err)
+	}
+
 	// Register tools and resources
 	workspace.RegisterWorkspace(mcpServer) // Register workspace first as other tools may depend on it
 	calculator.RegisterCalculator(mcpServer)
 	serverinfo.RegisterServerInfo(mcpServer)
 	filesearch.RegisterFileSearch(mcpServer)
+	filesearch.RegisterGrep(mcpServer)
+	artifact.RegisterArtifacts(mcpServer)
 	cmdexec.RegisterCommandExecution(mcpServer)
 	shell.RegisterShell(mcpServer) // Register shell tool
+	jobs.RegisterJobs(mcpServer)
+	testrun.RegisterTestRun(mcpServer)
+	build.RegisterBuild(mcpServer)
 	searchreplace.RegisterSearchReplace(mcpServer)
 	screenshot.RegisterScreenshot(mcpServer)
 	websearch.RegisterWebSearch(mcpServer)
@@ -87,6 +144,10 @@ func main() {
 	findfunc.RegisterFindFunc(mcpServer)
 	spellcheck.RegisterSpellCheck(mcpServer)
 	funcdef.RegisterFuncDef(mcpServer)
+	lsp.RegisterLSP(mcpServer)
+	rename.RegisterRename(mcpServer)
+	refactor.RegisterRefactor(mcpServer)
+	imports.RegisterImports(mcpServer)
 
 	// Register stats tool
 	if err := stats.RegisterStats(mcpServer, *dataDir); err != nil {
@@ -144,6 +205,12 @@ func main() {
 		log.Printf("[Server] Final server statistics:\n%s", statsText)
 	}
 
+	// Kill any background jobs that are still running
+	jobs.StopAll()
+
+	// Shut down the language servers
+	lsp.StopAll()
+
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("[Server] Server shutdown failed: %v", err)
 	}
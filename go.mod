@@ -1,8 +1,16 @@
 module github.com/Code-Monger/CodeSpinneret
 
-go 1.23.2
+go 1.25.0
 
-require github.com/mark3labs/mcp-go v0.19.0
+require (
+	github.com/PuerkitoBio/goquery v1.13.0
+	github.com/mark3labs/mcp-go v0.19.0
+)
+
+require (
+	github.com/andybalholm/cascadia v1.3.4 // indirect
+	golang.org/x/net v0.58.0 // indirect
+)
 
 require (
 	github.com/google/uuid v1.6.0 // indirect
@@ -0,0 +1,283 @@
+package artifact
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultMaxResultSize is the default number of bytes of a tool result that
+// is returned inline before it is truncated
+const DefaultMaxResultSize = 32 * 1024
+
+// DefaultPageSize is the number of bytes returned when reading an artifact
+// without a limit
+const DefaultPageSize = 64 * 1024
+
+// maxArtifacts is the number of artifacts kept on disk. The oldest are
+// deleted first.
+const maxArtifacts = 500
+
+// validID matches the artifact IDs that are generated, which keeps reads
+// inside the artifact directory
+var validID = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Global artifact store settings
+var (
+	artifactDir   string
+	maxResultSize = DefaultMaxResultSize
+	storeMutex    sync.Mutex
+)
+
+// InitArtifacts creates the artifact directory under the data directory and
+// sets the size above which tool results are truncated
+func InitArtifacts(dataDir string, maxSize int) error {
+	dir := filepath.Join(dataDir, "artifacts")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create artifact directory: %v", err)
+	}
+
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	artifactDir = dir
+	if maxSize > 0 {
+		maxResultSize = maxSize
+	}
+	return nil
+}
+
+// MaxResultSize returns the number of bytes of a result returned inline
+func MaxResultSize() int {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	return maxResultSize
+}
+
+// URI returns the resource URI of an artifact
+func URI(id string) string {
+	return "artifact://" + id
+}
+
+// Save stores text as a new artifact and returns its ID
+func Save(source, text string) (string, error) {
+	storeMutex.Lock()
+	defer storeMutex.Unlock()
+
+	if artifactDir == "" {
+		return "", fmt.Errorf("artifact storage is not initialized")
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	id := fmt.Sprintf("%s-%s-%s", sanitize(source), time.Now().Format("20060102-150405"), hex.EncodeToString(suffix))
+
+	if err := os.WriteFile(filepath.Join(artifactDir, id+".txt"), []byte(text), 0644); err != nil {
+		return "", fmt.Errorf("failed to write artifact: %v", err)
+	}
+
+	pruneArtifacts()
+	return id, nil
+}
+
+// Page is part of the text of an artifact
+type Page struct {
+	Text       string
+	Offset     int64 // Where the text starts, which may be after the requested offset
+	NextOffset int64 // Where the next page starts, the size at the end of the artifact
+	Size       int64 // Total size of the artifact
+}
+
+// Read returns up to limit bytes of an artifact starting at offset. The range
+// is adjusted to whole UTF-8 characters, so the page says where it actually
+// starts and where the next one does.
+func Read(id string, offset, limit int64) (Page, error) {
+	if !validID.MatchString(id) {
+		return Page{}, fmt.Errorf("invalid artifact ID: %s", id)
+	}
+
+	storeMutex.Lock()
+	dir := artifactDir
+	storeMutex.Unlock()
+	if dir == "" {
+		return Page{}, fmt.Errorf("artifact storage is not initialized")
+	}
+
+	file, err := os.Open(filepath.Join(dir, id+".txt"))
+	if err != nil {
+		return Page{}, fmt.Errorf("artifact not found: %s", id)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to read artifact: %v", err)
+	}
+	size := info.Size()
+
+	if offset < 0 || offset > size {
+		return Page{Size: size}, fmt.Errorf("offset %d is outside the artifact (size %d)", offset, size)
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, size-offset)
+
+	// Read a few extra bytes so that characters split by the range can be completed
+	buf := make([]byte, min(limit+utf8.UTFMax, size-offset))
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && int64(n) < int64(len(buf)) {
+		return Page{Size: size}, fmt.Errorf("failed to read artifact: %v", err)
+	}
+	buf = buf[:n]
+
+	// Skip the continuation bytes of a character that starts before the offset
+	start := 0
+	for start < len(buf) && start < utf8.UTFMax && !utf8.RuneStart(buf[start]) {
+		start++
+	}
+	end := min(int(limit), len(buf))
+	for end > start && end < len(buf) && !utf8.RuneStart(buf[end]) {
+		end--
+	}
+	if end == start && start < len(buf) {
+		// A character longer than the limit still makes up a page, so
+		// that reading goes on
+		for end++; end < len(buf) && !utf8.RuneStart(buf[end]); end++ {
+		}
+	}
+
+	return Page{
+		Text:       string(buf[start:end]),
+		Offset:     offset + int64(start),
+		NextOffset: offset + int64(end),
+		Size:       size,
+	}, nil
+}
+
+// Limit returns text unchanged if it fits within the result size limit.
+// Otherwise the full text is saved as an artifact, and the beginning and end
+// of the text are returned with a marker in between that says how much was
+// omitted and where to find the rest.
+func Limit(source, text string) string {
+	limit := MaxResultSize()
+	if len(text) <= limit {
+		return text
+	}
+
+	head, tail := splitHeadTail(text, limit)
+	omitted := len(text) - len(head) - len(tail)
+
+	marker := fmt.Sprintf("\n[... %d bytes omitted", omitted)
+	if id, err := Save(source, text); err != nil {
+		log.Printf("[Artifact] Failed to save %s output: %v", source, err)
+		marker += "; the full output could not be saved ...]\n"
+	} else {
+		marker += fmt.Sprintf("; full output (%d bytes) saved as %s, read it with %s?offset=%d&limit=%d ...]\n",
+			len(text), URI(id), URI(id), len(head), DefaultPageSize)
+	}
+
+	return head + marker + tail
+}
+
+// LimitWhole returns text unchanged if it fits within the result size limit.
+// Otherwise the full text is saved as an artifact, and only the summary and
+// where to find the text are returned. It is meant for machine-readable
+// output, such as JSON, DOT, or SARIF, which is of no use once cut in the
+// middle; if the artifact can't be saved, the text is returned whole.
+func LimitWhole(source, text, summary string) string {
+	if len(text) <= MaxResultSize() {
+		return text
+	}
+
+	id, err := Save(source, text)
+	if err != nil {
+		log.Printf("[Artifact] Failed to save %s output: %v", source, err)
+		return text
+	}
+	return fmt.Sprintf("%s\n[Output (%d bytes) too large to return inline; saved as %s, read it in pages with %s?offset=0&limit=%d]\n",
+		summary, len(text), URI(id), URI(id), DefaultPageSize)
+}
+
+// splitHeadTail returns the beginning and end of text that together fit
+// within limit bytes, cut at line boundaries where possible. The beginning
+// gets the larger share, since it usually says what the output is about.
+func splitHeadTail(text string, limit int) (string, string) {
+	headSize := limit * 2 / 3
+	tailSize := limit - headSize
+
+	head := text[:headSize]
+	if i := strings.LastIndexByte(head, '\n'); i >= headSize/2 {
+		head = head[:i+1]
+	}
+	// Don't split a character, but leave output that isn't text alone
+	for i := 0; i < utf8.UTFMax-1 && len(head) > 0; i++ {
+		if r, size := utf8.DecodeLastRuneInString(head); r != utf8.RuneError || size != 1 {
+			break
+		}
+		head = head[:len(head)-1]
+	}
+
+	tail := text[len(text)-tailSize:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < tailSize/2 {
+		tail = tail[i+1:]
+	}
+	for i := 0; i < utf8.UTFMax-1 && len(tail) > 0 && !utf8.RuneStart(tail[0]); i++ {
+		tail = tail[1:]
+	}
+
+	return head, tail
+}
+
+// sanitize turns a tool name into a part of an artifact ID
+func sanitize(source string) string {
+	var result strings.Builder
+	for _, r := range strings.ToLower(source) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			result.WriteRune(r)
+		} else {
+			result.WriteRune('-')
+		}
+	}
+	if result.Len() == 0 {
+		return "output"
+	}
+	return result.String()
+}
+
+// pruneArtifacts deletes the oldest artifacts beyond the maximum number kept.
+// The caller must hold the store mutex.
+func pruneArtifacts() {
+	entries, err := os.ReadDir(artifactDir)
+	if err != nil || len(entries) <= maxArtifacts {
+		return
+	}
+
+	type artifactFile struct {
+		name    string
+		modTime time.Time
+	}
+	var files []artifactFile
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			files = append(files, artifactFile{name: entry.Name(), modTime: info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, file := range files[:max(len(files)-maxArtifacts, 0)] {
+		os.Remove(filepath.Join(artifactDir, file.name))
+	}
+}
@@ -0,0 +1,51 @@
+package artifact
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestReadLargeLimit(t *testing.T) {
+	if err := InitArtifacts(t.TempDir(), 0); err != nil {
+		t.Fatal(err)
+	}
+	id, err := Save("test", "héllo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := Read(id, 1, math.MaxInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Text != "éllo" || page.Offset != 1 || page.NextOffset != page.Size || page.Size != 6 {
+		t.Errorf("page = %+v", page)
+	}
+}
+
+func TestLimitWhole(t *testing.T) {
+	if err := InitArtifacts(t.TempDir(), 16); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { maxResultSize = DefaultMaxResultSize }()
+
+	if text := LimitWhole("test", `{"a": 1}`, "summary"); text != `{"a": 1}` {
+		t.Errorf("small output changed: %q", text)
+	}
+
+	large := `{"items": [` + strings.Repeat(`"x", `, 10) + `"x"]}`
+	text := LimitWhole("test", large, "10 items")
+	if !strings.HasPrefix(text, "10 items\n") || strings.Contains(text, `"x"`) {
+		t.Fatalf("large output not replaced by its summary: %q", text)
+	}
+	id := strings.TrimPrefix(strings.Fields(text[strings.Index(text, "artifact://"):])[0], "artifact://")
+	id = strings.TrimSuffix(id, ",")
+	page, err := Read(id, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Text != large {
+		t.Errorf("artifact = %q, want %q", page.Text, large)
+	}
+}
@@ -0,0 +1,97 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HandleArtifactResource is the handler function for the artifact resource.
+// It returns one page of an artifact's text.
+func HandleArtifactResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	// Format: artifact://id?offset=N&limit=M
+	uri := request.Params.URI
+	rest := strings.TrimPrefix(uri, "artifact://")
+	if rest == uri || rest == "" {
+		return nil, fmt.Errorf("invalid artifact resource URI: %s", uri)
+	}
+
+	id, rawQuery, _ := strings.Cut(rest, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifact resource URI: %s", uri)
+	}
+
+	// Extract offset and limit (optional)
+	var offset, limit int64
+	if value := query.Get("offset"); value != "" {
+		if offset, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("offset must be a number")
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("limit must be a number")
+		}
+	}
+
+	page, err := Read(id, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Describe the page, so that the client can read the next one
+	pageInfo := map[string]interface{}{
+		"offset":      page.Offset,
+		"next_offset": page.NextOffset,
+		"total_size":  page.Size,
+	}
+	if page.NextOffset < page.Size {
+		nextQuery := url.Values{"offset": {strconv.FormatInt(page.NextOffset, 10)}}
+		if limit > 0 {
+			nextQuery.Set("limit", strconv.FormatInt(limit, 10))
+		}
+		pageInfo["next_uri"] = URI(id) + "?" + nextQuery.Encode()
+	}
+	pageJSON, err := json.Marshal(pageInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode page info: %v", err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "text/plain",
+			Text:     page.Text,
+		},
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     string(pageJSON),
+		},
+	}, nil
+}
+
+// RegisterArtifacts registers the artifact resource with the MCP server
+func RegisterArtifacts(mcpServer *server.MCPServer) {
+	// Register the artifact resource template, with optional paging parameters
+	mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(
+			"artifact://{id}{?offset,limit}",
+			"Tool Output Artifacts",
+			mcp.WithTemplateMIMEType("text/plain"),
+			mcp.WithTemplateDescription(fmt.Sprintf("Full output of a tool result that was truncated. Read it in pages with the byte offset and limit query parameters (default limit: %d bytes); pages end on character boundaries. Each page comes with a JSON item giving its offset, next_offset, total_size, and next_uri if there is more.", DefaultPageSize)),
+		),
+		HandleArtifactResource,
+	)
+
+	// Log the registration
+	log.Printf("[Artifact] Registered artifact resource")
+}
@@ -0,0 +1,313 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
+)
+
+// Severity is how serious a diagnostic is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem reported by a compiler or checker
+type Diagnostic struct {
+	File     string   `json:"file,omitempty"` // Relative to the project directory
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Code     string   `json:"code,omitempty"` // Checker-specific code, such as TS2322
+	Source   string   `json:"source"`         // The check that reported it, such as build or vet
+}
+
+// String formats the diagnostic like a compiler would
+func (d Diagnostic) String() string {
+	var position string
+	if d.File != "" {
+		position = d.File
+		if d.Line > 0 {
+			position += fmt.Sprintf(":%d", d.Line)
+			if d.Column > 0 {
+				position += fmt.Sprintf(":%d", d.Column)
+			}
+		}
+		position += ": "
+	}
+
+	text := fmt.Sprintf("%s%s: %s", position, d.Severity, d.Message)
+	if d.Code != "" {
+		text += fmt.Sprintf(" (%s)", d.Code)
+	}
+	return text + fmt.Sprintf(" [%s]", d.Source)
+}
+
+// Step is one command run by a build, such as go build or go vet
+type Step struct {
+	Name     string   `json:"name"`
+	Command  string   `json:"command"`
+	ExitCode int      `json:"exit_code"`
+	Skipped  string   `json:"skipped,omitempty"` // Why the step was not run
+	Duration float64  `json:"duration_seconds"`
+	args     []string // The command line to run
+}
+
+// Result is the outcome of a build
+type Result struct {
+	Project     string       `json:"project"`
+	Directory   string       `json:"directory"`
+	Targets     []string     `json:"targets,omitempty"`
+	Steps       []*Step      `json:"steps"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Success reports whether the build had no errors. Warnings, such as those
+// from go vet, don't make it fail.
+func (r *Result) Success() bool {
+	return r.Errors == 0
+}
+
+// builder runs the build and checks of one kind of project
+type builder struct {
+	name string
+	// checks lists the checks the builder supports, in the order they run
+	checks []string
+	// detect reports whether a directory holds a project of this kind
+	detect func(dir string) bool
+	// command returns the command line of a check
+	command func(dir, check string, targets []string) []string
+	// parse turns the output of a check into diagnostics
+	parse func(dir, check string, output []byte) []Diagnostic
+	// targets returns the targets that cover the changed files, or nil if
+	// none of the files belong to the project. The second result is false
+	// if the targets can't be narrowed and the whole project must be checked.
+	targets func(dir string, files []string) ([]string, bool)
+	// filterByFile is set if the checks always cover the whole project, so
+	// that targets are files whose diagnostics are reported rather than
+	// arguments of the command
+	filterByFile bool
+}
+
+// builders in detection order
+var builders = []*builder{goBuilder, typescriptBuilder}
+
+// projectNames lists the supported project types
+func projectNames() string {
+	var names []string
+	for _, b := range builders {
+		names = append(names, b.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// findBuilder returns the builder with the given name, or detects it from
+// the files in dir if the name is empty or "auto"
+func findBuilder(name, dir string) (*builder, error) {
+	if name == "" || name == "auto" {
+		for _, b := range builders {
+			if b.detect(dir) {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("no supported project found in %s (supported: %s)", dir, projectNames())
+	}
+
+	for _, b := range builders {
+		if b.name == name {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported project type: %s (supported: %s)", name, projectNames())
+}
+
+// plan is a build that is ready to run
+type plan struct {
+	builder   *builder
+	dir       string
+	targets   []string
+	steps     []*Step
+	onlyFiles []string // Report only diagnostics in these files, if set
+}
+
+// newPlan builds the command lines of the requested checks. Unknown checks
+// are an error, and checks the project type doesn't have are skipped.
+func newPlan(b *builder, dir string, checks []string, targets []string) (*plan, error) {
+	p := &plan{builder: b, dir: dir, targets: targets}
+	if b.filterByFile {
+		p.onlyFiles = targets
+	}
+
+	for _, check := range checks {
+		if check != "build" && check != "vet" {
+			return nil, fmt.Errorf("unsupported check: %s (supported: build, vet)", check)
+		}
+	}
+
+	for _, check := range b.checks {
+		if !containsString(checks, check) {
+			continue
+		}
+		args := b.command(dir, check, targets)
+		p.steps = append(p.steps, &Step{
+			Name:    check,
+			Command: policy.QuoteCommandLine(args),
+			args:    args,
+		})
+	}
+	if len(p.steps) == 0 {
+		return nil, fmt.Errorf("%s projects support these checks: %s", b.name, strings.Join(b.checks, ", "))
+	}
+
+	return p, nil
+}
+
+// commandLine returns all the steps as one command line, for the command policy
+func (p *plan) commandLine() string {
+	var commands []string
+	for _, step := range p.steps {
+		commands = append(commands, step.Command)
+	}
+	return strings.Join(commands, " && ")
+}
+
+// run runs the steps in order and collects their diagnostics. Later checks
+// are skipped once the build fails, since they would report the same errors.
+func (p *plan) run(ctx context.Context, limits sandbox.Config, writableRoot string) (*Result, error) {
+	result := &Result{
+		Project:   p.builder.name,
+		Directory: p.dir,
+		Targets:   p.targets,
+		Steps:     p.steps,
+	}
+
+	buildFailed := false
+	for _, step := range p.steps {
+		if buildFailed {
+			step.Skipped = "the build failed"
+			continue
+		}
+
+		cmd := exec.CommandContext(ctx, step.args[0], step.args[1:]...)
+		cmd.Dir = p.dir
+		if err := sandbox.Wrap(cmd, limits, writableRoot); err != nil {
+			return nil, fmt.Errorf("failed to set up sandbox: %v", err)
+		}
+
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+
+		startTime := time.Now()
+		err := cmd.Run()
+		step.Duration = time.Since(startTime).Seconds()
+
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s timed out after %v", step.Name, time.Since(startTime).Round(time.Second))
+		}
+		if err != nil {
+			exitErr, ok := err.(*exec.ExitError)
+			if !ok {
+				return nil, fmt.Errorf("failed to run %s: %v", step.args[0], err)
+			}
+			step.ExitCode = exitErr.ExitCode()
+		}
+
+		diagnostics := p.builder.parse(p.dir, step.Name, output.Bytes())
+		if step.ExitCode != 0 && len(diagnostics) == 0 {
+			// Failures that aren't about the code, such as a missing toolchain
+			message := strings.TrimSpace(output.String())
+			if message == "" {
+				message = fmt.Sprintf("%s exited with code %d", step.args[0], step.ExitCode)
+			}
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Message: message, Source: step.Name})
+		}
+
+		for _, diagnostic := range diagnostics {
+			if len(p.onlyFiles) > 0 && diagnostic.File != "" && !containsString(p.onlyFiles, diagnostic.File) {
+				continue
+			}
+			result.Diagnostics = append(result.Diagnostics, diagnostic)
+			if diagnostic.Severity == SeverityError {
+				result.Errors++
+			} else {
+				result.Warnings++
+			}
+		}
+
+		if step.Name == "build" && step.ExitCode != 0 {
+			buildFailed = true
+		}
+	}
+
+	return result, nil
+}
+
+// relativePath returns a path from a compiler message relative to the
+// project directory, with forward slashes
+func relativePath(dir, path string) string {
+	path = strings.TrimPrefix(path, "./")
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// relativeFiles returns the files inside dir, relative to it
+func relativeFiles(dir string, files []string) []string {
+	var result []string
+	for _, file := range files {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		result = append(result, filepath.ToSlash(rel))
+	}
+	return result
+}
+
+// fileExists reports whether a regular file exists
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// containsString reports whether a list contains a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueSorted returns the distinct strings in sorted order
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,106 @@
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goBuilder compiles Go packages with go build and checks them with go vet
+var goBuilder = &builder{
+	name:    "go",
+	checks:  []string{"build", "vet"},
+	detect:  detectGo,
+	command: goCommand,
+	parse:   parseGoDiagnostics,
+	targets: goTargets,
+}
+
+// goDiagnosticRegex matches file:line:column: message, as printed by the
+// compiler and go vet. go vet prefixes errors from type checking with "vet: ".
+var goDiagnosticRegex = regexp.MustCompile(`^(vet: )?((?:[A-Za-z]:)?[^:\s][^:]*\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// detectGo reports whether dir is inside a Go module
+func detectGo(dir string) bool {
+	for {
+		if fileExists(filepath.Join(dir, "go.mod")) {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// goCommand returns the go build or go vet command line. Build results are
+// written to the null device, so that building a main package doesn't
+// leave a binary in the project.
+func goCommand(dir, check string, targets []string) []string {
+	if len(targets) == 0 {
+		targets = []string{"./..."}
+	}
+	if check == "vet" {
+		return append([]string{"go", "vet"}, targets...)
+	}
+	return append([]string{"go", "build", "-o", os.DevNull}, targets...)
+}
+
+// parseGoDiagnostics parses the output of go build and go vet. Indented
+// lines continue the message before them, and lines that name no file, such
+// as errors from the go command itself, become diagnostics without a
+// position.
+func parseGoDiagnostics(dir, check string, output []byte) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") || line == "too many errors" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "\t") && len(diagnostics) > 0 {
+			diagnostics[len(diagnostics)-1].Message += "\n" + strings.TrimPrefix(line, "\t")
+			continue
+		}
+
+		diagnostic := Diagnostic{Severity: SeverityError, Source: check, Message: line}
+		if match := goDiagnosticRegex.FindStringSubmatch(line); match != nil {
+			diagnostic.File = relativePath(dir, match[2])
+			diagnostic.Line, _ = strconv.Atoi(match[3])
+			diagnostic.Column, _ = strconv.Atoi(match[4])
+			diagnostic.Message = match[5]
+
+			// What vet itself finds are warnings; only code that doesn't
+			// type check is an error
+			if check == "vet" && match[1] == "" {
+				diagnostic.Severity = SeverityWarning
+			}
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	return diagnostics
+}
+
+// goTargets returns the packages that contain the changed Go files. A
+// changed go.mod or go.sum affects every package.
+func goTargets(dir string, files []string) ([]string, bool) {
+	var targets []string
+	for _, file := range relativeFiles(dir, files) {
+		switch {
+		case filepath.Base(file) == "go.mod" || filepath.Base(file) == "go.sum":
+			return nil, false
+		case strings.HasSuffix(file, ".go"):
+			targets = append(targets, "./"+filepath.ToSlash(filepath.Dir(file)))
+		}
+	}
+	return uniqueSorted(targets), true
+}
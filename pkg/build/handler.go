@@ -0,0 +1,253 @@
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
+	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HandleBuild is the handler function for the build tool
+func HandleBuild(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+
+	// Extract session ID (optional, selects the workspace root, its sandbox limits and its last edit)
+	sessionID, _ := arguments["session_id"].(string)
+
+	// Resolve the directory against the workspace root, which is also the default
+	directory, _ := arguments["directory"].(string)
+	directory, err := filepath.Abs(workspace.ResolveRelativePath(directory, sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory: %v", err)
+	}
+	if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("directory not found: %s", directory)
+	}
+
+	// Extract output format (optional)
+	format, _ := arguments["format"].(string)
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "json" {
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	// Find the project type
+	projectType, _ := arguments["project"].(string)
+	b, err := findBuilder(projectType, directory)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract the checks to run (optional, default: all checks of the project type)
+	checks, err := getStringArrayArgument(arguments, "checks")
+	if err != nil {
+		return nil, err
+	}
+	if len(checks) == 0 {
+		checks = b.checks
+	}
+
+	// Select the targets, either given or from the files of the last edit
+	scope, _ := arguments["scope"].(string)
+	if scope == "" {
+		scope = "all"
+	}
+	targets, err := getStringArrayArgument(arguments, "packages")
+	if err != nil {
+		return nil, err
+	}
+	scopeText := "all"
+	switch scope {
+	case "all":
+		if len(targets) > 0 {
+			scopeText = strings.Join(targets, ", ")
+		}
+	case "last_edit":
+		edit, exists := workspace.LastEdit(sessionID)
+		if !exists {
+			return nil, fmt.Errorf("no edits have been recorded for session %s", sessionID)
+		}
+
+		var narrowed bool
+		targets, narrowed = b.targets(directory, edit.Files)
+		scopeText = fmt.Sprintf("last edit (%s, %d files at %s)", edit.Tool, len(edit.Files), edit.Time.Format(time.RFC3339))
+		if !narrowed {
+			scopeText += ", which affects the whole project"
+		} else if len(targets) == 0 {
+			resultText := fmt.Sprintf("Nothing to check: the last edit (%s) changed no %s files in %s\n", edit.Tool, b.name, directory)
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					mcp.TextContent{
+						Type: "text",
+						Text: resultText,
+					},
+				},
+			}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported scope: %s", scope)
+	}
+
+	// Extract timeout (optional)
+	timeoutSec, ok := arguments["timeout"].(float64)
+	if !ok {
+		// Default timeout: 5 minutes
+		timeoutSec = 300
+	}
+
+	p, err := newPlan(b, directory, checks, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the commands against the command policy
+	if result, err := policy.Enforce("build", sessionID, p.commandLine(), arguments); result != nil || err != nil {
+		return result, err
+	}
+
+	// Apply the sandbox limits, which keep the workspace root writable, or
+	// the server's working directory if there is no workspace
+	limits := sandbox.ConfigForSession(sessionID)
+	writableRoot := workspace.GetRootDir(sessionID)
+
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec*float64(time.Second)))
+	defer cancel()
+
+	result, err := p.run(runCtx, limits, writableRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultText string
+	if format == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode results: %v", err)
+		}
+		summary := fmt.Sprintf("Build results (JSON): %d errors, %d warnings", result.Errors, result.Warnings)
+		resultText = artifact.LimitWhole("build", string(data), summary)
+	} else {
+		resultText = formatResult(result, scopeText)
+		if limits.Enabled() {
+			resultText += fmt.Sprintf("\nSandbox: %s\n", limits)
+		}
+		resultText = artifact.Limit("build", resultText)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: resultText,
+			},
+		},
+	}, nil
+}
+
+// getStringArrayArgument extracts an optional array of non-empty strings
+func getStringArrayArgument(arguments map[string]interface{}, name string) ([]string, error) {
+	values, ok := arguments[name].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var result []string
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("%s must be an array of non-empty strings", name)
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// formatResult formats a build with its diagnostics
+func formatResult(result *Result, scope string) string {
+	status := "OK"
+	if !result.Success() {
+		status = "FAILED"
+	}
+
+	resultText := fmt.Sprintf("Build: %s\n\n", status)
+	resultText += fmt.Sprintf("Project: %s\n", result.Project)
+	resultText += fmt.Sprintf("Directory: %s\n", result.Directory)
+	resultText += fmt.Sprintf("Scope: %s\n", scope)
+	if len(result.Targets) > 0 {
+		resultText += fmt.Sprintf("Targets: %s\n", strings.Join(result.Targets, " "))
+	}
+
+	resultText += "\nSteps:\n"
+	for _, step := range result.Steps {
+		if step.Skipped != "" {
+			resultText += fmt.Sprintf("- %s: skipped, %s\n", step.Command, step.Skipped)
+		} else {
+			resultText += fmt.Sprintf("- %s: exit code %d (%.2fs)\n", step.Command, step.ExitCode, step.Duration)
+		}
+	}
+
+	resultText += fmt.Sprintf("\nDiagnostics: %d errors, %d warnings\n", result.Errors, result.Warnings)
+	for _, diagnostic := range result.Diagnostics {
+		resultText += fmt.Sprintf("%s\n", diagnostic)
+	}
+
+	return resultText
+}
+
+// RegisterBuild registers the build tool with the MCP server
+func RegisterBuild(mcpServer *server.MCPServer) {
+	// Create the tool definition
+	buildTool := mcp.NewTool("build",
+		mcp.WithDescription("Builds a project and runs its checks, returning the diagnostics as file, line, column, severity and message. Supports Go (go build and go vet) and TypeScript (tsc). Checks can be limited to the packages changed by the last edit."),
+		mcp.WithString("session_id",
+			mcp.Description("Workspace session ID, whose root is the default directory, whose sandbox limits apply, and whose last edit is used by scope 'last_edit' (optional)"),
+		),
+		mcp.WithString("directory",
+			mcp.Description("Directory of the project, relative to the workspace root (optional, default: the workspace root)"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Project type: 'auto', 'go' or 'typescript' (default: 'auto', detected from the project files)"),
+		),
+		mcp.WithArray("checks",
+			mcp.Description("Checks to run: 'build' and, for Go, 'vet' (default: all checks of the project type)"),
+		),
+		mcp.WithString("scope",
+			mcp.Description("'all' to check the whole project or the given packages, or 'last_edit' to check only the packages changed by the last patch or searchreplace call in the session (default: 'all')"),
+		),
+		mcp.WithArray("packages",
+			mcp.Description("Go packages to check, such as ./pkg/..., or TypeScript files to report diagnostics for (for scope 'all', default: the whole project)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'text' or 'json' (default: 'text')"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Timeout in seconds (default: 300)"),
+		),
+		mcp.WithString("confirm",
+			mcp.Description("Confirmation token from an earlier call, for build commands that the command policy requires to be confirmed"),
+		),
+	)
+
+	// Wrap the handler with stats tracking
+	wrappedHandler := stats.WrapHandler("build", HandleBuild)
+
+	// Register the tool with the wrapped handler
+	mcpServer.AddTool(buildTool, wrappedHandler)
+
+	// Log the registration
+	log.Printf("[Build] Registered build tool")
+}
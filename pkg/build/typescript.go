@@ -0,0 +1,99 @@
+package build
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// typescriptBuilder type checks TypeScript projects with tsc
+var typescriptBuilder = &builder{
+	name:         "typescript",
+	checks:       []string{"build"},
+	detect:       detectTypeScript,
+	command:      typescriptCommand,
+	parse:        parseTypeScriptDiagnostics,
+	targets:      typescriptTargets,
+	filterByFile: true,
+}
+
+// tscDiagnosticRegex matches file(line,column): severity TSnnnn: message, as
+// printed by tsc with --pretty false
+var tscDiagnosticRegex = regexp.MustCompile(`^(.+)\((\d+),(\d+)\): (error|warning) (TS\d+): (.*)$`)
+
+// tscGlobalRegex matches diagnostics without a position, such as errors in
+// the compiler options
+var tscGlobalRegex = regexp.MustCompile(`^(error|warning) (TS\d+): (.*)$`)
+
+// detectTypeScript reports whether dir holds a TypeScript project
+func detectTypeScript(dir string) bool {
+	return fileExists(filepath.Join(dir, "tsconfig.json"))
+}
+
+// typescriptCommand returns the tsc command line, preferring the project's
+// own compiler over one that npx would download. tsc checks the whole
+// project, so targets are not passed to it.
+func typescriptCommand(dir, check string, targets []string) []string {
+	tsc := []string{"npx", "tsc"}
+	if local := filepath.Join(dir, "node_modules", ".bin", "tsc"); fileExists(local) {
+		tsc = []string{local}
+	}
+	return append(tsc, "--noEmit", "--pretty", "false", "-p", ".")
+}
+
+// parseTypeScriptDiagnostics parses the output of tsc. Indented lines
+// continue the message before them.
+func parseTypeScriptDiagnostics(dir, check string, output []byte) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	for _, line := range strings.Split(strings.ReplaceAll(string(output), "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, " ") && len(diagnostics) > 0 {
+			diagnostics[len(diagnostics)-1].Message += "\n" + strings.TrimSpace(line)
+			continue
+		}
+
+		if match := tscDiagnosticRegex.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[2])
+			column, _ := strconv.Atoi(match[3])
+			diagnostics = append(diagnostics, Diagnostic{
+				File:     relativePath(dir, match[1]),
+				Line:     lineNumber,
+				Column:   column,
+				Severity: Severity(match[4]),
+				Code:     match[5],
+				Message:  match[6],
+				Source:   check,
+			})
+		} else if match := tscGlobalRegex.FindStringSubmatch(line); match != nil {
+			diagnostics = append(diagnostics, Diagnostic{
+				Severity: Severity(match[1]),
+				Code:     match[2],
+				Message:  match[3],
+				Source:   check,
+			})
+		}
+	}
+
+	return diagnostics
+}
+
+// typescriptTargets returns the changed TypeScript files. tsc still checks
+// the whole project, and the diagnostics are limited to these files.
+func typescriptTargets(dir string, files []string) ([]string, bool) {
+	var targets []string
+	for _, file := range relativeFiles(dir, files) {
+		switch {
+		case filepath.Base(file) == "tsconfig.json" || filepath.Base(file) == "package.json":
+			return nil, false
+		case strings.HasSuffix(file, ".ts") || strings.HasSuffix(file, ".tsx") ||
+			strings.HasSuffix(file, ".mts") || strings.HasSuffix(file, ".cts"):
+			targets = append(targets, file)
+		}
+	}
+	return uniqueSorted(targets), true
+}
@@ -4,11 +4,20 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
@@ -16,16 +25,53 @@ import (
 // HandleCommandExecution is the handler function for the command execution tool
 func HandleCommandExecution(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	arguments := request.Params.Arguments
-	// Extract command
-	command, ok := arguments["command"].(string)
-	if !ok {
-		return nil, fmt.Errorf("command must be a string")
+	// Extract shell mode (optional)
+	shellMode, _ := arguments["shell"].(string)
+	if shellMode == "" {
+		shellMode = "default"
+	}
+	
+	// Extract the command, or the arguments when no shell is used
+	var command string
+	var argv []string
+	switch shellMode {
+	case "default":
+		var ok bool
+		command, ok = arguments["command"].(string)
+		if !ok {
+			return nil, fmt.Errorf("command must be a string")
+		}
+	case "none":
+		var err error
+		argv, err = getArgvArgument(arguments)
+		if err != nil {
+			return nil, err
+		}
+		command = policy.QuoteCommandLine(argv)
+	default:
+		return nil, fmt.Errorf("unsupported shell: %s", shellMode)
 	}
 	
 	// Extract working directory (optional)
 	workingDir, ok := arguments["working_directory"].(string)
 	// No need to check ok here since an empty string is a valid default
 	
+	// Extract session ID (optional, selects the workspace root and its sandbox limits)
+	sessionID, _ := arguments["session_id"].(string)
+	
+	// Resolve the working directory against the workspace root, which is
+	// also the default
+	if workingDir != "" || sessionID != "" {
+		workingDir = workspace.ResolveRelativePath(workingDir, sessionID)
+	}
+	
+	// Extract environment variables (optional)
+	envVars, err := getEnvArgument(arguments)
+	if err != nil {
+		return nil, err
+	}
+	envClear, _ := arguments["env_clear"].(bool)
+	
 	// Extract timeout (optional)
 	timeoutSec, ok := arguments["timeout"].(float64)
 	if !ok {
@@ -33,30 +79,69 @@ func HandleCommandExecution(ctx context.Context, request mcp.CallToolRequest) (*
 		timeoutSec = 30
 	}
 	
+	// Check the command against the command policy
+	if result, err := policy.Enforce("cmdexec", sessionID, command, arguments); result != nil || err != nil {
+		return result, err
+	}
+	
 	// Create a context with timeout
 	execCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second)
 	defer cancel()
 	
-	// Prepare the command based on the OS
+	// Prepare the command based on the OS, or run it directly without a shell
 	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
+	if argv != nil {
+		cmd = exec.CommandContext(execCtx, argv[0], argv[1:]...)
+	} else if runtime.GOOS == "windows" {
 		cmd = exec.CommandContext(execCtx, "cmd", "/C", command)
 	} else {
 		cmd = exec.CommandContext(execCtx, "sh", "-c", command)
 	}
 	
+	// Set the environment, starting from the server's unless it is cleared
+	if envClear || len(envVars) > 0 {
+		env := []string{}
+		if !envClear {
+			env = os.Environ()
+		}
+		names := make([]string, 0, len(envVars))
+		for name := range envVars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			env = append(env, name+"="+envVars[name])
+		}
+		cmd.Env = env
+	}
+	
+	// Feed stdin if provided, otherwise the command reads nothing
+	if stdin, ok := arguments["stdin"].(string); ok {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	
 	// Set working directory if provided
 	if workingDir != "" {
 		cmd.Dir = workingDir
 	}
 	
-	// Capture stdout and stderr
+	// Apply the sandbox limits, which keep the workspace root writable, or
+	// the server's working directory if there is no workspace. The writable
+	// root never comes from the arguments, so a working directory can't
+	// widen it.
+	limits := sandbox.ConfigForSession(sessionID)
+	if err := sandbox.Wrap(cmd, limits, workspace.GetRootDir(sessionID)); err != nil {
+		return nil, fmt.Errorf("failed to set up sandbox: %v", err)
+	}
+	
+	// Capture stdout and stderr, streaming partial output to the client if it asked for progress
+	reporter := progress.NewReporter(ctx, "cmdexec", request)
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	cmd.Stdout = io.MultiWriter(&stdout, reporter.Writer("stdout"))
+	cmd.Stderr = io.MultiWriter(&stderr, reporter.Writer("stderr"))
 	
 	// Execute the command
-	err := cmd.Run()
+	err = cmd.Run()
 	
 	// Prepare the result
 	var resultText string
@@ -72,12 +157,12 @@ func HandleCommandExecution(ctx context.Context, request mcp.CallToolRequest) (*
 		resultText = "Command executed successfully\n\n"
 	}
 	
-	// Add stdout and stderr to the result
+	// Add stdout and stderr to the result, keeping the full output as an artifact if it is too large
 	if stdout.Len() > 0 {
-		resultText += fmt.Sprintf("Standard Output:\n%s\n", stdout.String())
+		resultText += fmt.Sprintf("Standard Output:\n%s\n", artifact.Limit("cmdexec", stdout.String()))
 	}
 	if stderr.Len() > 0 {
-		resultText += fmt.Sprintf("Standard Error:\n%s\n", stderr.String())
+		resultText += fmt.Sprintf("Standard Error:\n%s\n", artifact.Limit("cmdexec", stderr.String()))
 	}
 	
 	// Add command information
@@ -85,6 +170,11 @@ func HandleCommandExecution(ctx context.Context, request mcp.CallToolRequest) (*
 	if workingDir != "" {
 		resultText += fmt.Sprintf("Working Directory: %s\n", workingDir)
 	}
+	if envClear {
+		resultText += fmt.Sprintf("Environment: cleared, %d variables set\n", len(envVars))
+	} else if len(envVars) > 0 {
+		resultText += fmt.Sprintf("Environment: inherited, %d variables set\n", len(envVars))
+	}
 	
 	// Add exit code
 	exitCode := 0
@@ -96,6 +186,9 @@ func HandleCommandExecution(ctx context.Context, request mcp.CallToolRequest) (*
 		}
 	}
 	resultText += fmt.Sprintf("Exit Code: %d\n", exitCode)
+	if limits.Enabled() {
+		resultText += fmt.Sprintf("Sandbox: %s\n", limits)
+	}
 	
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
@@ -107,21 +200,93 @@ func HandleCommandExecution(ctx context.Context, request mcp.CallToolRequest) (*
 	}, nil
 }
 
+// getArgvArgument extracts the program and its arguments for running without a shell
+func getArgvArgument(arguments map[string]interface{}) ([]string, error) {
+	values, ok := arguments["argv"].([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("argv must be a non-empty array of strings when shell is 'none'")
+	}
+	
+	argv := make([]string, len(values))
+	for i, value := range values {
+		arg, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("argv must be an array of strings")
+		}
+		argv[i] = arg
+	}
+	if argv[0] == "" {
+		return nil, fmt.Errorf("argv must start with the program to run")
+	}
+	
+	return argv, nil
+}
+
+// getEnvArgument extracts the environment variables to set for the command
+func getEnvArgument(arguments map[string]interface{}) (map[string]string, error) {
+	value, exists := arguments["env"]
+	if !exists || value == nil {
+		return nil, nil
+	}
+	
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("env must be an object of variable names and values")
+	}
+	
+	env := make(map[string]string, len(values))
+	for name, value := range values {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return nil, fmt.Errorf("invalid environment variable name: %q", name)
+		}
+		switch v := value.(type) {
+		case string:
+			env[name] = v
+		case float64, bool:
+			env[name] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("environment variable %s must be a string", name)
+		}
+	}
+	
+	return env, nil
+}
+
 // RegisterCommandExecution registers the command execution tool with the MCP server
 func RegisterCommandExecution(mcpServer *server.MCPServer) {
 	// Create the tool definition
 	cmdexecTool := mcp.NewTool("cmdexec",
 		mcp.WithDescription("Execute commands on the system, such as running scripts, compiling code, or starting applications"),
 		mcp.WithString("command",
-			mcp.Description("The command to execute"),
-			mcp.Required(),
+			mcp.Description("The command to execute, run by sh -c (cmd /C on Windows). Required unless shell is 'none'"),
+		),
+		mcp.WithString("shell",
+			mcp.Description("'default' to run command through the shell, or 'none' to run argv directly without any shell quoting (default: 'default')"),
+		),
+		mcp.WithArray("argv",
+			mcp.Description("The program to run and its arguments, one element each (required when shell is 'none')"),
 		),
 		mcp.WithString("working_directory",
-			mcp.Description("The working directory for the command (optional)"),
+			mcp.Description("The working directory for the command, relative to the workspace root of session_id (optional, default: the workspace root)"),
+		),
+		mcp.WithObject("env",
+			mcp.Description("Environment variables to set for the command, as an object of names and values (optional)"),
+		),
+		mcp.WithBoolean("env_clear",
+			mcp.Description("Start from an empty environment instead of the server's, so that only the variables in env are set (default: false)"),
+		),
+		mcp.WithString("stdin",
+			mcp.Description("Text to pass to the command on standard input (optional, default: no input)"),
 		),
 		mcp.WithNumber("timeout",
 			mcp.Description("Timeout in seconds (default: 30)"),
 		),
+		mcp.WithString("session_id",
+			mcp.Description("Workspace session ID, whose root is the default working directory and whose sandbox limits apply to the command (optional)"),
+		),
+		mcp.WithString("confirm",
+			mcp.Description("Confirmation token from an earlier call, for commands that the command policy requires to be confirmed"),
+		),
 	)
 	
 	// Wrap the handler with stats tracking
@@ -1,6 +1,8 @@
 package codeanalysis
 
 import (
+	"context"
+	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/token"
@@ -11,6 +13,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
 )
 
 // analyzeFile analyzes a single file
@@ -51,26 +56,20 @@ func analyzeFile(filePath string) (*FileAnalysisResult, error) {
 	switch language {
 	case "Go":
 		analyzeGoFile(filePath, string(content), result)
-	case "JavaScript", "TypeScript":
-		analyzeJSFile(string(content), result)
-	case "Python":
-		analyzePythonFile(string(content), result)
-	case "Java":
-		analyzeJavaFile(string(content), result)
-	case "C", "C++":
-		analyzeCFile(string(content), result)
-	case "C#":
-		analyzeCSharpFile(string(content), result)
 	default:
-		// Generic analysis for other languages
-		analyzeGenericFile(string(content), result)
+		if syntaxLanguage, ok := syntax.LanguageByName(language); ok {
+			analyzeSourceFile(string(content), syntaxLanguage, result)
+		} else {
+			// Generic analysis for other languages
+			analyzeGenericFile(string(content), result)
+		}
 	}
 
 	return result, nil
 }
 
-// analyzeDirectory analyzes a directory of code files
-func analyzeDirectory(dirPath string, filePatterns []string, recursive bool) (*DirectoryAnalysisResult, error) {
+// analyzeDirectory analyzes a directory of code files, reporting progress as each file completes
+func analyzeDirectory(dirPath string, filePatterns []string, recursive bool, reporter *progress.Reporter) (*DirectoryAnalysisResult, error) {
 	startTime := time.Now()
 
 	// Check if directory exists
@@ -89,43 +88,17 @@ func analyzeDirectory(dirPath string, filePatterns []string, recursive bool) (*D
 	}
 
 	// Find all files matching the patterns
-	var files []string
-	for _, pattern := range filePatterns {
-		walkFunc := func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			// Skip directories
-			if info.IsDir() {
-				if !recursive && path != dirPath {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-
-			// Check if file matches the pattern
-			matched, err := filepath.Match(pattern, filepath.Base(path))
-			if err != nil {
-				return err
-			}
-			if matched {
-				files = append(files, path)
-			}
-
-			return nil
-		}
-
-		err := filepath.Walk(dirPath, walkFunc)
-		if err != nil {
-			return nil, err
-		}
+	files, err := findFiles(dirPath, filePatterns, recursive)
+	if err != nil {
+		return nil, err
 	}
 
 	// Analyze each file
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	fileResults := make(map[string]*FileAnalysisResult)
+	filesDone := 0
+	totalFiles := float64(len(files))
 
 	for _, file := range files {
 		wg.Add(1)
@@ -133,13 +106,16 @@ func analyzeDirectory(dirPath string, filePatterns []string, recursive bool) (*D
 			defer wg.Done()
 
 			fileResult, err := analyzeFile(filePath)
-			if err != nil {
-				return
-			}
 
 			mu.Lock()
-			fileResults[filePath] = fileResult
+			if err == nil {
+				fileResults[filePath] = fileResult
+			}
+			filesDone++
+			done := filesDone
 			mu.Unlock()
+
+			reporter.Report(float64(done), totalFiles, filePath)
 		}(file)
 	}
 
@@ -186,8 +162,53 @@ func analyzeDirectory(dirPath string, filePatterns []string, recursive bool) (*D
 	return result, nil
 }
 
+// vcsDirectories are skipped while looking for files
+var vcsDirectories = map[string]bool{
+	".git": true,
+	".hg":  true,
+	".svn": true,
+}
+
+// findFiles finds the files in a directory that match any of the patterns,
+// skipping version control directories
+func findFiles(dirPath string, filePatterns []string, recursive bool) ([]string, error) {
+	var files []string
+	walkFunc := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip directories
+		if info.IsDir() {
+			if path != dirPath && (!recursive || vcsDirectories[info.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Check if file matches any pattern
+		for _, pattern := range filePatterns {
+			matched, err := filepath.Match(pattern, filepath.Base(path))
+			if err != nil {
+				return err
+			}
+			if matched {
+				files = append(files, path)
+				break
+			}
+		}
+
+		return nil
+	}
+
+	if err := filepath.Walk(dirPath, walkFunc); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
 // findIssues finds issues in code
-func findIssues(targetPath string, issueTypes []string, severityLevel string) (*IssuesResult, error) {
+func findIssues(ctx context.Context, targetPath string, issueTypes []string, options IssueOptions) (*IssuesResult, error) {
 	startTime := time.Now()
 
 	// Initialize result
@@ -204,36 +225,99 @@ func findIssues(targetPath string, issueTypes []string, severityLevel string) (*
 
 	if fileInfo.IsDir() {
 		// Analyze directory
-		dirResult, err := analyzeDirectory(targetPath, []string{"*.go", "*.js", "*.ts", "*.py", "*.java", "*.c", "*.cpp", "*.h", "*.cs"}, true)
+		dirResult, err := analyzeDirectory(targetPath, []string{"*.go", "*.js", "*.ts", "*.py", "*.java", "*.c", "*.cpp", "*.h", "*.cs"}, true, nil)
 		if err != nil {
 			return nil, err
 		}
 
 		// Find issues in each file
 		for _, fileInfo := range dirResult.TopComplexFiles {
-			fileIssues := findFileIssues(fileInfo.Path, issueTypes, severityLevel)
-			for _, issue := range fileIssues {
-				result.Issues = append(result.Issues, issue)
-				result.IssuesByType[issue.Type]++
-			}
+			fileIssues := findFileIssues(fileInfo.Path, issueTypes, options)
+			result.Issues = append(result.Issues, fileIssues...)
 		}
 	} else {
 		// Analyze single file
-		fileIssues := findFileIssues(targetPath, issueTypes, severityLevel)
-		for _, issue := range fileIssues {
-			result.Issues = append(result.Issues, issue)
-			result.IssuesByType[issue.Type]++
+		fileIssues := findFileIssues(targetPath, issueTypes, options)
+		result.Issues = append(result.Issues, fileIssues...)
+	}
+
+	// Unused Go code is found across the whole module
+	if containsString(issueTypes, "unused") {
+		deadCodeIssues, err := findDeadCode(ctx, targetPath, options.IncludeExported)
+		if err != nil {
+			return nil, fmt.Errorf("dead code analysis failed: %v", err)
+		}
+		result.Issues = append(result.Issues, deadCodeIssues...)
+	}
+	for i := range result.Issues {
+		result.Issues[i].Source = builtinSource
+	}
+
+	// External linters, whose issues are merged with the built-in ones
+	if len(options.Linters) > 0 {
+		linterIssues, warnings, err := runLinters(ctx, options.Linters, options.Sandbox, options.WritableRoot)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range options.Linters {
+			result.Linters = append(result.Linters, run.name)
+		}
+		result.Warnings = append(result.Warnings, warnings...)
+		for _, issue := range linterIssues {
+			if withinTarget(issue.FilePath, targetPath, fileInfo.IsDir()) && severityRank(issue.Severity) >= severityRank(options.Severity) {
+				result.Issues = append(result.Issues, issue)
+			}
 		}
+		result.Issues = mergeIssues(result.Issues)
 	}
 
+	// Only the issues on lines changed since the base ref
+	if options.ChangedOnly {
+		changesDir := targetPath
+		if !fileInfo.IsDir() {
+			changesDir = filepath.Dir(targetPath)
+		}
+		changes, err := findChanges(ctx, changesDir, options.BaseRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find changed lines: %v", err)
+		}
+		changed := []IssueInfo{}
+		for _, issue := range result.Issues {
+			if changes.contains(issue) {
+				changed = append(changed, issue)
+			}
+		}
+		result.Unchanged = len(result.Issues) - len(changed)
+		result.Issues = changed
+	}
+
+	// Save the issues as the baseline, or leave out those it has
+	if options.Baseline != "" {
+		if options.UpdateBaseline {
+			if err := saveBaseline(options.Baseline, result.Issues, options.Root); err != nil {
+				return nil, err
+			}
+		} else {
+			baseline, err := loadBaseline(options.Baseline)
+			if err != nil {
+				return nil, err
+			}
+			result.Issues, result.Baselined = filterBaseline(result.Issues, baseline, options.Root)
+		}
+	}
+
+	for _, issue := range result.Issues {
+		result.IssuesByType[issue.Type]++
+	}
 	result.TotalIssues = len(result.Issues)
 	result.TimeTaken = time.Since(startTime)
 	return result, nil
 }
 
 // findFileIssues finds issues in a single file
-func findFileIssues(filePath string, issueTypes []string, severityLevel string) []IssueInfo {
+func findFileIssues(filePath string, issueTypes []string, options IssueOptions) []IssueInfo {
 	var issues []IssueInfo
+	severityLevel := options.Severity
 
 	// Read file content
 	content, err := ioutil.ReadFile(filePath)
@@ -249,7 +333,7 @@ func findFileIssues(filePath string, issueTypes []string, severityLevel string)
 	for _, issueType := range issueTypes {
 		switch issueType {
 		case "complexity":
-			complexityIssues := findComplexityIssues(filePath, string(content), language, severityLevel)
+			complexityIssues := findComplexityIssues(filePath, string(content), language, severityLevel, options.Thresholds)
 			issues = append(issues, complexityIssues...)
 		case "duplication":
 			duplicationIssues := findDuplicationIssues(filePath, string(content), language, severityLevel)
@@ -261,6 +345,10 @@ func findFileIssues(filePath string, issueTypes []string, severityLevel string)
 			commentIssues := findCommentIssues(filePath, string(content), language, severityLevel)
 			issues = append(issues, commentIssues...)
 		case "unused":
+			if language == "Go" {
+				// Found by findDeadCode
+				continue
+			}
 			unusedIssues := findUnusedIssues(filePath, string(content), language, severityLevel)
 			issues = append(issues, unusedIssues...)
 		}
@@ -287,7 +375,7 @@ func suggestImprovements(targetPath string, improvementTypes []string) (*Improve
 
 	if fileInfo.IsDir() {
 		// Analyze directory
-		dirResult, err := analyzeDirectory(targetPath, []string{"*.go", "*.js", "*.ts", "*.py", "*.java", "*.c", "*.cpp", "*.h", "*.cs"}, true)
+		dirResult, err := analyzeDirectory(targetPath, []string{"*.go", "*.js", "*.ts", "*.py", "*.java", "*.c", "*.cpp", "*.h", "*.cs"}, true, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -309,6 +397,7 @@ func suggestImprovements(targetPath string, improvementTypes []string) (*Improve
 		}
 	}
 
+	assignSuggestionIDs(result.Suggestions)
 	result.TotalSuggestions = len(result.Suggestions)
 	result.TimeTaken = time.Since(startTime)
 	return result, nil
@@ -360,11 +449,11 @@ func analyzeGoFile(filePath, content string, result *FileAnalysisResult) {
 		return
 	}
 
-	// Count functions and methods
-	functionCount := 0
+	// Measure functions and methods, and count structs
+	codeLines := goCodeLines(token.NewFileSet(), filePath, []byte(content))
+	functions := []FunctionInfo{}
 	typeCount := 0
 	commentLines := 0
-	topFunctions := []FunctionInfo{}
 
 	// Count comments
 	for _, commentGroup := range f.Comments {
@@ -375,17 +464,7 @@ func analyzeGoFile(filePath, content string, result *FileAnalysisResult) {
 	ast.Inspect(f, func(n ast.Node) bool {
 		switch x := n.(type) {
 		case *ast.FuncDecl:
-			functionCount++
-
-			// Calculate function complexity (simplified)
-			complexity := calculateGoFunctionComplexity(x)
-
-			// Add to top functions
-			topFunctions = append(topFunctions, FunctionInfo{
-				Name:       x.Name.Name,
-				Line:       fset.Position(x.Pos()).Line,
-				Complexity: complexity,
-			})
+			functions = append(functions, goFunctionMetrics(fset, x, codeLines))
 		case *ast.TypeSpec:
 			if _, isStruct := x.Type.(*ast.StructType); isStruct {
 				typeCount++
@@ -403,29 +482,10 @@ func analyzeGoFile(filePath, content string, result *FileAnalysisResult) {
 		}
 	}
 
-	// Sort top functions by complexity (descending)
-	sort.Slice(topFunctions, func(i, j int) bool {
-		return topFunctions[i].Complexity > topFunctions[j].Complexity
-	})
-
-	// Limit to top 5
-	if len(topFunctions) > 5 {
-		topFunctions = topFunctions[:5]
-	}
-
-	// Calculate overall complexity (simplified)
-	complexityScore := float64(functionCount) * 0.1
-	for _, fn := range topFunctions {
-		complexityScore += fn.Complexity
-	}
-	complexityScore = complexityScore / float64(max(1, functionCount))
-
 	// Update result
-	result.FunctionCount = functionCount
+	setFunctions(result, functions)
 	result.ClassCount = typeCount
 	result.CommentLines = commentLines
-	result.ComplexityScore = complexityScore
-	result.TopFunctions = topFunctions
 	result.Dependencies = dependencies
 }
 
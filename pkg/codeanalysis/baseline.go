@@ -0,0 +1,95 @@
+package codeanalysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/sarif"
+)
+
+// baselineVersion is the version of the baseline file format
+const baselineVersion = 1
+
+// issueFingerprints returns the fingerprint of each issue, the same as in
+// SARIF logs, with file paths relative to root. Fingerprints hash the text
+// of the line rather than its number, so they survive edits elsewhere in
+// the file.
+func issueFingerprints(issues []IssueInfo, root string) []string {
+	fingerprinter := sarif.NewFingerprinter()
+	fingerprints := make([]string, len(issues))
+	for i, issue := range issues {
+		source, ruleID := issueRule(issue)
+		uri := sarif.NewArtifactLocation(issue.FilePath, root).URI
+		fingerprints[i] = fingerprinter.Fingerprints(source+"/"+ruleID, issue.FilePath, uri, issue.Line)[sarif.FingerprintKey]
+	}
+	return fingerprints
+}
+
+// saveBaseline writes the issues to a baseline file
+func saveBaseline(path string, issues []IssueInfo, root string) error {
+	baseline := Baseline{Version: baselineVersion, Created: time.Now().UTC(), Issues: []BaselineFinding{}}
+	for i, fingerprint := range issueFingerprints(issues, root) {
+		filePath := issues[i].FilePath
+		if rel, err := filepath.Rel(root, filePath); err == nil {
+			filePath = filepath.ToSlash(rel)
+		}
+		_, ruleID := issueRule(issues[i])
+		baseline.Issues = append(baseline.Issues, BaselineFinding{
+			Fingerprint: fingerprint,
+			FilePath:    filePath,
+			Line:        issues[i].Line,
+			Rule:        ruleID,
+			Message:     issues[i].Message,
+		})
+	}
+
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create baseline directory: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write baseline: %v", err)
+	}
+	return nil
+}
+
+// loadBaseline reads a baseline file
+func loadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("baseline not found: %s (create it with update_baseline)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %v", err)
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %v", path, err)
+	}
+	if baseline.Version != baselineVersion {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s", baseline.Version, path)
+	}
+	return &baseline, nil
+}
+
+// filterBaseline leaves out the issues whose fingerprints are in the
+// baseline, and returns the others with the number left out
+func filterBaseline(issues []IssueInfo, baseline *Baseline, root string) ([]IssueInfo, int) {
+	known := make(map[string]bool)
+	for _, finding := range baseline.Issues {
+		known[finding.Fingerprint] = true
+	}
+	kept := []IssueInfo{}
+	for i, fingerprint := range issueFingerprints(issues, root) {
+		if !known[fingerprint] {
+			kept = append(kept, issues[i])
+		}
+	}
+	return kept, len(issues) - len(kept)
+}
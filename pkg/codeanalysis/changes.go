@@ -0,0 +1,137 @@
+package codeanalysis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitTimeout limits the git commands that find the changed lines
+const gitTimeout = time.Minute
+
+// hunkRegex matches the header of a hunk of a unified diff, capturing the
+// start and the number of the lines in the new version
+var hunkRegex = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// changeSet is the lines of a working tree that changed since a git ref
+type changeSet struct {
+	lines    map[string]map[int]bool // Changed lines by absolute path
+	newFiles map[string]bool         // Untracked files, in which all lines are new
+	resolved map[string]string       // Paths of the issues with symbolic links resolved
+}
+
+// findChanges reads the changes of the git working tree that contains dir
+// since ref: the lines that git diff reports as added or modified, and the
+// untracked files that aren't ignored
+func findChanges(ctx context.Context, dir, ref string) (*changeSet, error) {
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git ref: %s", ref)
+	}
+
+	gitCtx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	output, err := runGit(gitCtx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Clean(strings.TrimSpace(string(output)))
+
+	changes := &changeSet{
+		lines:    make(map[string]map[int]bool),
+		newFiles: make(map[string]bool),
+		resolved: make(map[string]string),
+	}
+
+	diff, err := runGit(gitCtx, root, "-c", "core.quotePath=false", "diff", "--unified=0", "--no-color", "--no-ext-diff",
+		"--src-prefix=a/", "--dst-prefix=b/", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	var current map[int]bool
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			// git ends the line with a tab if the name has a space
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "+++ "), "\t")
+			if unquoted, err := strconv.Unquote(name); err == nil {
+				name = unquoted
+			}
+			if !strings.HasPrefix(name, "b/") {
+				// A deleted file, +++ /dev/null
+				current = nil
+				continue
+			}
+			current = make(map[int]bool)
+			changes.lines[filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(name, "b/")))] = current
+		case strings.HasPrefix(line, "@@"):
+			match := hunkRegex.FindStringSubmatch(line)
+			if match == nil || current == nil {
+				continue
+			}
+			start, _ := strconv.Atoi(match[1])
+			count := 1
+			if match[2] != "" {
+				count, _ = strconv.Atoi(match[2])
+			}
+			for n := start; n < start+count; n++ {
+				current[n] = true
+			}
+		}
+	}
+
+	untracked, err := runGit(gitCtx, root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(string(untracked), "\x00") {
+		if name != "" {
+			changes.newFiles[filepath.Join(root, filepath.FromSlash(name))] = true
+		}
+	}
+	return changes, nil
+}
+
+// runGit runs a git command in dir and returns its output
+func runGit(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// contains reports whether an issue is on a changed line. Issues about a
+// whole file, without a line, count if the file changed at all.
+func (c *changeSet) contains(issue IssueInfo) bool {
+	path, exists := c.resolved[issue.FilePath]
+	if !exists {
+		path = issue.FilePath
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			path = resolved
+		}
+		c.resolved[issue.FilePath] = path
+	}
+	if c.newFiles[path] {
+		return true
+	}
+	lines, changed := c.lines[path]
+	if !changed {
+		return false
+	}
+	return issue.Line == 0 && len(lines) > 0 || lines[issue.Line]
+}
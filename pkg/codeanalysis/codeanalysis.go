@@ -2,10 +2,19 @@ package codeanalysis
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
@@ -24,11 +33,17 @@ func HandleCodeAnalysis(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 	case "analyze_file":
 		return handleAnalyzeFile(arguments)
 	case "analyze_directory":
-		return handleAnalyzeDirectory(arguments)
+		return handleAnalyzeDirectory(arguments, progress.NewReporter(ctx, "codeanalysis", request))
 	case "find_issues":
-		return handleFindIssues(arguments)
+		return handleFindIssues(ctx, arguments)
 	case "suggest_improvements":
 		return handleSuggestImprovements(arguments)
+	case "apply_fixes":
+		return handleApplyFixes(ctx, arguments)
+	case "find_duplicates":
+		return handleFindDuplicates(arguments, progress.NewReporter(ctx, "codeanalysis", request))
+	case "dependencies":
+		return handleDependencies(ctx, arguments)
 	default:
 		return nil, fmt.Errorf("unsupported operation: %s", operation)
 	}
@@ -55,14 +70,14 @@ func handleAnalyzeFile(arguments map[string]interface{}) (*mcp.CallToolResult, e
 	resultText += fmt.Sprintf("Functions/methods: %d\n", analysisResult.FunctionCount)
 	resultText += fmt.Sprintf("Classes/structs: %d\n", analysisResult.ClassCount)
 	resultText += fmt.Sprintf("Comments: %d lines\n", analysisResult.CommentLines)
-	resultText += fmt.Sprintf("Complexity score: %.2f\n", analysisResult.ComplexityScore)
+	resultText += fmt.Sprintf("Complexity score: %.2f (average cyclomatic complexity)\n", analysisResult.ComplexityScore)
 	resultText += fmt.Sprintf("Time taken: %s\n\n", analysisResult.TimeTaken)
 
 	if len(analysisResult.TopFunctions) > 0 {
 		resultText += "Top complex functions:\n"
 		for _, function := range analysisResult.TopFunctions {
-			resultText += fmt.Sprintf("- %s (complexity: %.2f)\n", function.Name, function.Complexity)
-			resultText += fmt.Sprintf("  Line: %d\n", function.Line)
+			resultText += fmt.Sprintf("- %s (cyclomatic: %d, cognitive: %d)\n", function.Name, function.Cyclomatic, function.Cognitive)
+			resultText += fmt.Sprintf("  Line: %d, lines of code: %d, parameters: %d, nesting depth: %d\n", function.Line, function.LinesOfCode, function.Parameters, function.Nesting)
 		}
 		resultText += "\n"
 	}
@@ -86,7 +101,7 @@ func handleAnalyzeFile(arguments map[string]interface{}) (*mcp.CallToolResult, e
 }
 
 // handleAnalyzeDirectory handles the analyze_directory operation
-func handleAnalyzeDirectory(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+func handleAnalyzeDirectory(arguments map[string]interface{}, reporter *progress.Reporter) (*mcp.CallToolResult, error) {
 	// Extract directory path
 	dirPath, ok := arguments["directory_path"].(string)
 	if !ok {
@@ -114,7 +129,7 @@ func handleAnalyzeDirectory(arguments map[string]interface{}) (*mcp.CallToolResu
 	}
 
 	// Analyze the directory
-	analysisResult, err := analyzeDirectory(dirPath, filePatterns, recursive)
+	analysisResult, err := analyzeDirectory(dirPath, filePatterns, recursive, reporter)
 	if err != nil {
 		return nil, fmt.Errorf("error analyzing directory: %v", err)
 	}
@@ -157,7 +172,7 @@ func handleAnalyzeDirectory(arguments map[string]interface{}) (*mcp.CallToolResu
 }
 
 // handleFindIssues handles the find_issues operation
-func handleFindIssues(arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+func handleFindIssues(ctx context.Context, arguments map[string]interface{}) (*mcp.CallToolResult, error) {
 	// Extract target path (file or directory)
 	targetPath, ok := arguments["target_path"].(string)
 	if !ok {
@@ -184,18 +199,162 @@ func handleFindIssues(arguments map[string]interface{}) (*mcp.CallToolResult, er
 		severityLevel = severity
 	}
 
+	// Extract complexity thresholds (optional)
+	thresholds := DefaultThresholds()
+	if overrides, ok := arguments["thresholds"].(map[string]interface{}); ok {
+		var err error
+		thresholds, err = applyThresholds(thresholds, overrides)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Extract include exported flag
+	includeExported := false
+	if includeExportedBool, ok := arguments["include_exported"].(bool); ok {
+		includeExported = includeExportedBool
+	}
+
+	// Extract external linters (optional)
+	var requestedLinters []string
+	if lintersArg, ok := arguments["linters"].([]interface{}); ok {
+		for _, l := range lintersArg {
+			if linterStr, ok := l.(string); ok && strings.TrimSpace(linterStr) != "" {
+				requestedLinters = append(requestedLinters, linterStr)
+			}
+		}
+	}
+
+	// Extract session ID (optional, selects the sandbox limits)
+	sessionID, _ := arguments["session_id"].(string)
+
+	// Extract output format (optional)
+	format, _ := arguments["format"].(string)
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "sarif" {
+		return nil, fmt.Errorf("unsupported format for find_issues: %s (supported: text, sarif)", format)
+	}
+
+	// Extract baseline options (optional)
+	baselinePath, _ := arguments["baseline"].(string)
+	updateBaseline, _ := arguments["update_baseline"].(bool)
+	if updateBaseline && baselinePath == "" {
+		return nil, fmt.Errorf("update_baseline requires baseline")
+	}
+	if baselinePath != "" {
+		baselinePath = workspace.ResolveRelativePath(baselinePath, sessionID)
+	}
+
+	// Extract changed only options (optional)
+	changedOnly, _ := arguments["changed_only"].(bool)
+	baseRef, _ := arguments["base_ref"].(string)
+	if baseRef == "" {
+		baseRef = "HEAD"
+	}
+
+	options := IssueOptions{
+		Severity:        severityLevel,
+		Thresholds:      thresholds,
+		IncludeExported: includeExported,
+		Baseline:        baselinePath,
+		UpdateBaseline:  updateBaseline,
+		ChangedOnly:     changedOnly,
+		BaseRef:         baseRef,
+	}
+	var linterWarnings []string
+	if len(requestedLinters) > 0 || format == "sarif" || baselinePath != "" || changedOnly {
+		absPath, err := filepath.Abs(targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target path: %v", err)
+		}
+		targetPath = absPath
+
+		// Paths in SARIF logs and baselines are relative to the workspace
+		// root, or else to the target
+		options.Root = targetPath
+		if info, err := os.Stat(targetPath); err == nil && !info.IsDir() {
+			options.Root = filepath.Dir(targetPath)
+		}
+		if rootDir, err := filepath.Abs(workspace.GetRootDir(sessionID)); err == nil && withinTarget(targetPath, rootDir, true) {
+			options.Root = rootDir
+		}
+	}
+	if len(requestedLinters) > 0 {
+		runs, warnings, err := prepareLinters(requestedLinters, targetPath)
+		if err != nil {
+			return nil, err
+		}
+		linterWarnings = warnings
+
+		// Check the linter commands against the command policy
+		if len(runs) > 0 {
+			if result, err := policy.Enforce("codeanalysis", sessionID, linterCommandLine(runs), arguments); result != nil || err != nil {
+				return result, err
+			}
+		}
+
+		// Apply the sandbox limits, which keep the workspace root writable, or
+		// the server's working directory if there is no workspace
+		options.Linters = runs
+		options.Sandbox = sandbox.ConfigForSession(sessionID)
+		options.WritableRoot = workspace.GetRootDir(sessionID)
+	}
+
 	// Find issues
-	issuesResult, err := findIssues(targetPath, issueTypes, severityLevel)
+	issuesResult, err := findIssues(ctx, targetPath, issueTypes, options)
 	if err != nil {
 		return nil, fmt.Errorf("error finding issues: %v", err)
 	}
+	issuesResult.Warnings = append(linterWarnings, issuesResult.Warnings...)
+
+	if format == "sarif" {
+		sarifText, err := formatIssuesSARIF(issuesResult, options.Root)
+		if err != nil {
+			return nil, err
+		}
+		// A cut SARIF log is invalid, so a large one is saved whole
+		summary := fmt.Sprintf("SARIF log of %s: %d issues", targetPath, issuesResult.TotalIssues)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: artifact.LimitWhole("codeanalysis", sarifText, summary),
+				},
+			},
+		}, nil
+	}
 
 	// Format the result
 	resultText := fmt.Sprintf("Code Issues Found in: %s\n\n", targetPath)
 	resultText += fmt.Sprintf("Total issues: %d\n", issuesResult.TotalIssues)
 	resultText += fmt.Sprintf("Severity level: %s\n", severityLevel)
+	if containsString(issueTypes, "complexity") {
+		resultText += fmt.Sprintf("Complexity thresholds: cyclomatic %d, cognitive %d, lines %d, parameters %d, nesting %d\n",
+			thresholds.Cyclomatic, thresholds.Cognitive, thresholds.Lines, thresholds.Parameters, thresholds.Nesting)
+	}
+	if len(issuesResult.Linters) > 0 {
+		resultText += fmt.Sprintf("Linters: %s\n", strings.Join(issuesResult.Linters, ", "))
+	}
+	if changedOnly {
+		resultText += fmt.Sprintf("Changed lines only: since %s (%d issues on unchanged lines left out)\n", baseRef, issuesResult.Unchanged)
+	}
+	if updateBaseline {
+		resultText += fmt.Sprintf("Baseline saved: %s (%d issues)\n", baselinePath, issuesResult.TotalIssues)
+	} else if baselinePath != "" {
+		resultText += fmt.Sprintf("Baseline: %s (%d known issues left out)\n", baselinePath, issuesResult.Baselined)
+	}
 	resultText += fmt.Sprintf("Time taken: %s\n\n", issuesResult.TimeTaken)
 
+	if len(issuesResult.Warnings) > 0 {
+		resultText += "Warnings:\n"
+		for _, warning := range issuesResult.Warnings {
+			resultText += fmt.Sprintf("- %s\n", warning)
+		}
+		resultText += "\n"
+	}
+
 	if len(issuesResult.IssuesByType) > 0 {
 		resultText += "Issues by type:\n"
 		for issueType, count := range issuesResult.IssuesByType {
@@ -207,11 +366,25 @@ func handleFindIssues(arguments map[string]interface{}) (*mcp.CallToolResult, er
 	if len(issuesResult.Issues) > 0 {
 		resultText += "Issues:\n"
 		for i, issue := range issuesResult.Issues {
-			resultText += fmt.Sprintf("%d. [%s] %s\n", i+1, issue.Type, issue.Message)
+			switch {
+			case issue.Severity != "":
+				resultText += fmt.Sprintf("%d. [%s, %s] %s\n", i+1, issue.Type, issue.Severity, issue.Message)
+			case issue.Confidence != "":
+				resultText += fmt.Sprintf("%d. [%s, %s confidence] %s\n", i+1, issue.Type, issue.Confidence, issue.Message)
+			default:
+				resultText += fmt.Sprintf("%d. [%s] %s\n", i+1, issue.Type, issue.Message)
+			}
 			resultText += fmt.Sprintf("   File: %s, Line: %d\n", issue.FilePath, issue.Line)
 			if issue.Snippet != "" {
 				resultText += fmt.Sprintf("   Code: %s\n", issue.Snippet)
 			}
+			if len(issuesResult.Linters) > 0 {
+				if issue.Rule != "" {
+					resultText += fmt.Sprintf("   Source: %s (%s)\n", issue.Source, issue.Rule)
+				} else {
+					resultText += fmt.Sprintf("   Source: %s\n", issue.Source)
+				}
+			}
 			resultText += "\n"
 		}
 	}
@@ -220,7 +393,7 @@ func handleFindIssues(arguments map[string]interface{}) (*mcp.CallToolResult, er
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: resultText,
+				Text: artifact.Limit("codeanalysis", resultText),
 			},
 		},
 	}, nil
@@ -271,8 +444,12 @@ func handleSuggestImprovements(arguments map[string]interface{}) (*mcp.CallToolR
 		resultText += "Suggestions:\n"
 		for i, suggestion := range improvementsResult.Suggestions {
 			resultText += fmt.Sprintf("%d. [%s] %s\n", i+1, suggestion.Type, suggestion.Title)
+			resultText += fmt.Sprintf("   ID: %s\n", suggestion.ID)
 			resultText += fmt.Sprintf("   File: %s, Line: %d\n", suggestion.FilePath, suggestion.Line)
 			resultText += fmt.Sprintf("   Description: %s\n", suggestion.Description)
+			if suggestion.Fix != nil {
+				resultText += "   Fix: available (apply with apply_fixes)\n"
+			}
 			if suggestion.Before != "" && suggestion.After != "" {
 				resultText += "   Before:\n```\n" + suggestion.Before + "\n```\n"
 				resultText += "   After:\n```\n" + suggestion.After + "\n```\n"
@@ -291,20 +468,294 @@ func handleSuggestImprovements(arguments map[string]interface{}) (*mcp.CallToolR
 	}, nil
 }
 
+// handleApplyFixes handles the apply_fixes operation
+func handleApplyFixes(ctx context.Context, arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	// Extract session ID (optional, selects the workspace root)
+	sessionID, _ := arguments["session_id"].(string)
+
+	// Extract target path, the same as for suggest_improvements
+	targetPath, ok := arguments["target_path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("target_path must be a string")
+	}
+	targetPath = workspace.ResolveRelativePath(targetPath, sessionID)
+
+	// Extract suggestion IDs
+	var ids []string
+	if values, ok := arguments["suggestion_ids"].([]interface{}); ok {
+		for _, v := range values {
+			if id, ok := v.(string); ok && id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("suggestion_ids must list the IDs of the suggestions to apply")
+	}
+
+	// Find the suggestions again, so the fixes match the files as they are
+	improvementsResult, err := suggestImprovements(targetPath, []string{"refactoring", "performance", "readability", "maintainability"})
+	if err != nil {
+		return nil, fmt.Errorf("error suggesting improvements: %v", err)
+	}
+
+	// Paths in the diff are relative to the workspace root, or else to the
+	// target
+	root, err := filepath.Abs(targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target path: %v", err)
+	}
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		root = filepath.Dir(root)
+	}
+	if rootDir, err := filepath.Abs(workspace.GetRootDir(sessionID)); err == nil && withinTarget(root, rootDir, true) {
+		root = rootDir
+	}
+
+	fixCtx, cancel := context.WithTimeout(ctx, fixTimeout)
+	defer cancel()
+	fixesResult, err := applyFixes(fixCtx, improvementsResult.Suggestions, ids, root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the fixed files for tools that check the last edit
+	if len(fixesResult.Files) > 0 {
+		workspace.RecordEdit(sessionID, "codeanalysis", fixesResult.Files)
+	}
+
+	resultText := fmt.Sprintf("Applied Fixes for: %s\n\n", targetPath)
+	resultText += fmt.Sprintf("Applied: %d\n", len(fixesResult.Applied))
+	for _, title := range fixesResult.Applied {
+		resultText += fmt.Sprintf("- %s\n", title)
+	}
+	if len(fixesResult.Skipped) > 0 {
+		resultText += fmt.Sprintf("Skipped: %d\n", len(fixesResult.Skipped))
+		for _, reason := range fixesResult.Skipped {
+			resultText += fmt.Sprintf("- %s\n", reason)
+		}
+	}
+	resultText += fmt.Sprintf("Files changed: %d\n", len(fixesResult.Files))
+	for _, warning := range fixesResult.Warnings {
+		resultText += fmt.Sprintf("Warning: %s\n", warning)
+	}
+	if fixesResult.Diff != "" {
+		resultText += "\n" + fixesResult.Diff
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: artifact.Limit("codeanalysis", resultText),
+			},
+		},
+	}, nil
+}
+
+// handleFindDuplicates handles the find_duplicates operation
+func handleFindDuplicates(arguments map[string]interface{}, reporter *progress.Reporter) (*mcp.CallToolResult, error) {
+	// Extract session ID (optional, selects the workspace root)
+	sessionID, _ := arguments["session_id"].(string)
+
+	// Extract directory path, defaulting to the workspace root
+	dirPath := workspace.GetRootDir(sessionID)
+	if dirArg, ok := arguments["directory_path"].(string); ok && dirArg != "" {
+		dirPath = workspace.ResolveRelativePath(dirArg, sessionID)
+	}
+	if info, err := os.Stat(dirPath); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", dirPath)
+	}
+
+	// Extract file patterns
+	var filePatterns []string
+	if patterns, ok := arguments["file_patterns"].([]interface{}); ok {
+		for _, pattern := range patterns {
+			if patternStr, ok := pattern.(string); ok {
+				filePatterns = append(filePatterns, patternStr)
+			}
+		}
+	}
+	if len(filePatterns) == 0 {
+		// Default to common code file patterns
+		filePatterns = []string{"*.go", "*.js", "*.ts", "*.py", "*.java", "*.c", "*.cpp", "*.h", "*.cs"}
+	}
+
+	// Extract recursive flag
+	recursive := true // Default to recursive
+	if recursiveBool, ok := arguments["recursive"].(bool); ok {
+		recursive = recursiveBool
+	}
+
+	// Extract minimum clone length
+	minTokens := DefaultMinTokens
+	if minTokensFloat, ok := arguments["min_tokens"].(float64); ok {
+		if minTokensFloat < 10 {
+			return nil, fmt.Errorf("min_tokens must be at least 10")
+		}
+		minTokens = int(minTokensFloat)
+	}
+
+	// Find duplicates
+	duplicatesResult, err := findDuplicates(dirPath, filePatterns, recursive, minTokens, reporter)
+	if err != nil {
+		return nil, fmt.Errorf("error finding duplicates: %v", err)
+	}
+
+	// Format the result
+	resultText := fmt.Sprintf("Duplicate Code in: %s\n\n", dirPath)
+	resultText += fmt.Sprintf("Files analyzed: %d\n", duplicatesResult.FilesAnalyzed)
+	resultText += fmt.Sprintf("Minimum clone length: %d tokens\n", duplicatesResult.MinTokens)
+	resultText += fmt.Sprintf("Clone groups: %d\n", len(duplicatesResult.Groups))
+	if duplicatesResult.TotalLines > 0 {
+		resultText += fmt.Sprintf("Duplicated lines: %d of %d (%.1f%%)\n", duplicatesResult.DuplicatedLines, duplicatesResult.TotalLines,
+			100*float64(duplicatesResult.DuplicatedLines)/float64(duplicatesResult.TotalLines))
+	}
+	resultText += fmt.Sprintf("Time taken: %s\n\n", duplicatesResult.TimeTaken)
+
+	if len(duplicatesResult.Groups) > 0 {
+		resultText += "Clone groups:\n"
+		for i, group := range duplicatesResult.Groups {
+			resultText += fmt.Sprintf("%d. %d tokens, %d lines, %d copies, %.1f%% similar\n", i+1, group.Tokens, group.Lines, len(group.Locations), group.Similarity)
+			for _, location := range group.Locations {
+				path := location.FilePath
+				if rel, err := filepath.Rel(dirPath, path); err == nil {
+					path = rel
+				}
+				resultText += fmt.Sprintf("   - %s:%d-%d\n", path, location.StartLine, location.EndLine)
+			}
+		}
+		resultText += "\n"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: artifact.Limit("codeanalysis", resultText),
+			},
+		},
+	}, nil
+}
+
+// handleDependencies handles the dependencies operation
+func handleDependencies(ctx context.Context, arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	// Extract session ID (optional, selects the workspace root)
+	sessionID, _ := arguments["session_id"].(string)
+
+	// Extract directory path, defaulting to the workspace root
+	dirPath := workspace.GetRootDir(sessionID)
+	if dirArg, ok := arguments["directory_path"].(string); ok && dirArg != "" {
+		dirPath = workspace.ResolveRelativePath(dirArg, sessionID)
+	}
+	dirPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory path: %v", err)
+	}
+	if info, err := os.Stat(dirPath); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", dirPath)
+	}
+
+	// Extract output format (optional)
+	format, _ := arguments["format"].(string)
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "json" && format != "dot" {
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	// Extract layering rules (optional)
+	var ruleTexts []string
+	if rulesArg, ok := arguments["rules"].([]interface{}); ok {
+		for _, rule := range rulesArg {
+			if ruleStr, ok := rule.(string); ok && strings.TrimSpace(ruleStr) != "" {
+				ruleTexts = append(ruleTexts, ruleStr)
+			}
+		}
+	}
+	rules, err := parseLayerRules(ruleTexts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the graph
+	graph, err := analyzeDependencies(ctx, dirPath, rules)
+	if err != nil {
+		return nil, fmt.Errorf("error analyzing dependencies: %v", err)
+	}
+
+	// Format the result. JSON and DOT are saved whole as an artifact if
+	// they are too large, rather than cut.
+	summary := fmt.Sprintf("Package dependencies of %s (%s): %d packages, %d import cycles, %d layering violations",
+		graph.ModulePath, format, len(graph.Packages), len(graph.Cycles), len(graph.Violations))
+	var resultText string
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode result: %v", err)
+		}
+		resultText = artifact.LimitWhole("codeanalysis", string(data), summary)
+	case "dot":
+		resultText = artifact.LimitWhole("codeanalysis", formatDependenciesDOT(graph), summary)
+	default:
+		resultText = fmt.Sprintf("Package Dependencies of: %s\n\n", graph.ModulePath)
+		resultText += fmt.Sprintf("Packages: %d\n", len(graph.Packages))
+		resultText += fmt.Sprintf("Import cycles: %d\n", len(graph.Cycles))
+		resultText += fmt.Sprintf("Layering violations: %d\n", len(graph.Violations))
+		resultText += fmt.Sprintf("Time taken: %s\n\n", graph.TimeTaken)
+
+		if len(graph.Cycles) > 0 {
+			resultText += "Import cycles:\n"
+			for _, cycle := range graph.Cycles {
+				resultText += fmt.Sprintf("- %s\n", strings.Join(cycle.Path, " -> "))
+			}
+			resultText += "\n"
+		}
+
+		if len(graph.Violations) > 0 {
+			resultText += "Layering violations:\n"
+			for _, violation := range graph.Violations {
+				resultText += fmt.Sprintf("- %s imports %s (rule: %s)\n", violation.From, violation.To, violation.Rule)
+			}
+			resultText += "\n"
+		}
+
+		resultText += "Packages (fan-in, fan-out, depth):\n"
+		for _, node := range graph.Packages {
+			resultText += fmt.Sprintf("- %s (%d, %d, %d)\n", node.Path, node.FanIn, node.FanOut, node.Depth)
+			if len(node.Imports) > 0 {
+				resultText += fmt.Sprintf("  Imports: %s\n", strings.Join(node.Imports, ", "))
+			}
+		}
+		resultText = artifact.Limit("codeanalysis", resultText)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: resultText,
+			},
+		},
+	}, nil
+}
+
 // RegisterCodeAnalysis registers the code analysis tool with the MCP server
 func RegisterCodeAnalysis(mcpServer *server.MCPServer) {
 	// Create the tool definition
 	codeAnalysisTool := mcp.NewTool("codeanalysis",
 		mcp.WithDescription("Analyzes code to provide insights, metrics, and suggestions for improvement"),
 		mcp.WithString("operation",
-			mcp.Description("Operation to perform: 'analyze_file', 'analyze_directory', 'find_issues', or 'suggest_improvements'"),
+			mcp.Description("Operation to perform: 'analyze_file', 'analyze_directory', 'find_issues', 'suggest_improvements', 'apply_fixes', 'find_duplicates', or 'dependencies'"),
 			mcp.Required(),
 		),
 		mcp.WithString("file_path",
 			mcp.Description("Path to the file to analyze (for 'analyze_file' operation)"),
 		),
 		mcp.WithString("directory_path",
-			mcp.Description("Path to the directory to analyze (for 'analyze_directory' operation)"),
+			mcp.Description("Path to the directory to analyze (for 'analyze_directory', 'find_duplicates', and 'dependencies' operations; 'find_duplicates' and 'dependencies' default to the workspace root)"),
 		),
 		mcp.WithArray("file_patterns",
 			mcp.Description("File patterns to include in the analysis (e.g., ['*.go', '*.js'])"),
@@ -313,17 +764,56 @@ func RegisterCodeAnalysis(mcpServer *server.MCPServer) {
 			mcp.Description("Whether to analyze subdirectories recursively (for 'analyze_directory' operation)"),
 		),
 		mcp.WithString("target_path",
-			mcp.Description("Path to the file or directory to analyze (for 'find_issues' and 'suggest_improvements' operations)"),
+			mcp.Description("Path to the file or directory to analyze (for 'find_issues', 'suggest_improvements', and 'apply_fixes' operations)"),
 		),
 		mcp.WithArray("issue_types",
-			mcp.Description("Types of issues to look for (e.g., ['complexity', 'duplication', 'naming'])"),
+			mcp.Description("Types of issues to look for: 'complexity', 'duplication', 'naming', 'comments', and 'unused' (default: all). For Go, 'unused' finds the functions, methods, types, constants, variables, and struct fields that can't be reached from main functions, tests, or the exported API, each with a confidence level"),
 		),
 		mcp.WithString("severity",
 			mcp.Description("Minimum severity level of issues to report ('low', 'medium', 'high')"),
 		),
+		mcp.WithObject("thresholds",
+			mcp.Description("Limits above which a function is reported by the 'complexity' check of 'find_issues'. Keys: cyclomatic (default 10), cognitive (default 15), lines (lines of code, default 60), parameters (default 5), nesting (nesting depth, default 4). A function over twice a limit is high severity, over one and a half times medium, else low"),
+		),
+		mcp.WithBoolean("include_exported",
+			mcp.Description("Also report exported Go declarations that no code of the module uses (for the 'unused' check of 'find_issues'; default: false). Without it, the exported API of importable packages counts as used"),
+		),
+		mcp.WithArray("linters",
+			mcp.Description("External linters whose issues 'find_issues' merges with its own, when installed: 'go vet', 'staticcheck', 'golangci-lint', 'eslint', 'ruff', 'auto' for those installed that apply to the target, or 'sarif: <command>' for a command that prints SARIF 2.1.0. The commands are subject to the command policy and the sandbox"),
+		),
+		mcp.WithString("confirm",
+			mcp.Description("Confirmation token, to run linter commands that the command policy asks to confirm (for 'find_issues' with linters)"),
+		),
+		mcp.WithString("baseline",
+			mcp.Description("Baseline file of known issues, relative to the workspace root (for 'find_issues'). Issues in the baseline are left out, so only new ones are reported. Issues are matched by fingerprints of their rule, file, and line text, not by line number"),
+		),
+		mcp.WithBoolean("update_baseline",
+			mcp.Description("Save the issues found to the baseline file instead of leaving them out (for 'find_issues'; default: false)"),
+		),
+		mcp.WithBoolean("changed_only",
+			mcp.Description("Report only the issues on lines that git diff shows as changed since base_ref, or in untracked files (for 'find_issues'; default: false)"),
+		),
+		mcp.WithString("base_ref",
+			mcp.Description("Git ref that changed_only compares the working tree to, such as 'main' (default: 'HEAD')"),
+		),
 		mcp.WithArray("improvement_types",
 			mcp.Description("Types of improvements to suggest (e.g., ['refactoring', 'performance', 'readability'])"),
 		),
+		mcp.WithArray("suggestion_ids",
+			mcp.Description("IDs of the suggestions of 'suggest_improvements' to fix (for 'apply_fixes' operation, with the same target_path). Only suggestions listed with an available fix apply: Go names with underscores, renamed with their references; errors.New(fmt.Sprintf(...)), replaced with fmt.Errorf; and strings built with += in a loop, built with a strings.Builder instead. Returns the diff of the changes"),
+		),
+		mcp.WithNumber("min_tokens",
+			mcp.Description("Minimum length in tokens of the duplicate fragments to report (for 'find_duplicates' operation; default: 50). Identifiers and literals are abstracted, so fragments that differ only in names and values count as duplicates"),
+		),
+		mcp.WithArray("rules",
+			mcp.Description("Layering rules that the imports between the module's packages must follow (for 'dependencies' operation), e.g. ['pkg/* must not import cmd/*']. Patterns match package paths relative to the module, or full import paths, and also match the packages below a matching path"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'text', 'json', or 'dot' for Graphviz for 'dependencies', and 'text' or 'sarif' for a SARIF 2.1.0 log for 'find_issues' (default: 'text')"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Workspace session ID, whose root resolves relative paths (optional)"),
+		),
 	)
 
 	// Wrap the handler with stats tracking
@@ -0,0 +1,414 @@
+package codeanalysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// DefaultThresholds returns the limits above which find_issues reports the
+// complexity of a function
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Cyclomatic: 10,
+		Cognitive:  15,
+		Lines:      60,
+		Parameters: 5,
+		Nesting:    4,
+	}
+}
+
+// applyThresholds returns base with the thresholds given in overrides, which
+// maps the names cyclomatic, cognitive, lines, parameters, and nesting to
+// numbers
+func applyThresholds(base Thresholds, overrides map[string]interface{}) (Thresholds, error) {
+	thresholds := base
+	for key, value := range overrides {
+		number, ok := value.(float64)
+		if !ok || number < 1 {
+			return thresholds, fmt.Errorf("threshold %s must be a positive number", key)
+		}
+		switch key {
+		case "cyclomatic":
+			thresholds.Cyclomatic = int(number)
+		case "cognitive":
+			thresholds.Cognitive = int(number)
+		case "lines":
+			thresholds.Lines = int(number)
+		case "parameters":
+			thresholds.Parameters = int(number)
+		case "nesting":
+			thresholds.Nesting = int(number)
+		default:
+			return thresholds, fmt.Errorf("unknown threshold: %s", key)
+		}
+	}
+	return thresholds, nil
+}
+
+// severityRank orders severity levels, with unknown levels as medium
+func severityRank(severity string) int {
+	switch severity {
+	case "low":
+		return 1
+	case "high":
+		return 3
+	default:
+		return 2
+	}
+}
+
+// exceededThresholds describes the metrics of a function that exceed the
+// thresholds, and returns the severity of the worst: high at more than
+// twice the threshold, medium at more than one and a half times, else low
+func exceededThresholds(fn FunctionInfo, thresholds Thresholds) ([]string, string) {
+	metrics := []struct {
+		name      string
+		value     int
+		threshold int
+	}{
+		{"cyclomatic complexity", fn.Cyclomatic, thresholds.Cyclomatic},
+		{"cognitive complexity", fn.Cognitive, thresholds.Cognitive},
+		{"lines of code", fn.LinesOfCode, thresholds.Lines},
+		{"parameters", fn.Parameters, thresholds.Parameters},
+		{"nesting depth", fn.Nesting, thresholds.Nesting},
+	}
+
+	var exceeded []string
+	severity := ""
+	for _, metric := range metrics {
+		if metric.threshold <= 0 || metric.value <= metric.threshold {
+			continue
+		}
+		exceeded = append(exceeded, fmt.Sprintf("%s %d > %d", metric.name, metric.value, metric.threshold))
+
+		ratio := float64(metric.value) / float64(metric.threshold)
+		metricSeverity := "low"
+		if ratio > 2 {
+			metricSeverity = "high"
+		} else if ratio > 1.5 {
+			metricSeverity = "medium"
+		}
+		if severity == "" || severityRank(metricSeverity) > severityRank(severity) {
+			severity = metricSeverity
+		}
+	}
+	return exceeded, severity
+}
+
+// setFunctions stores the metrics of a file's functions in its result: the
+// five most complex as its top functions, and their average cyclomatic
+// complexity as its complexity score
+func setFunctions(result *FileAnalysisResult, functions []FunctionInfo) {
+	result.Functions = functions
+	result.FunctionCount = len(functions)
+
+	result.ComplexityScore = 1.0
+	if len(functions) > 0 {
+		total := 0
+		for _, fn := range functions {
+			total += fn.Cyclomatic
+		}
+		result.ComplexityScore = float64(total) / float64(len(functions))
+	}
+
+	top := append([]FunctionInfo(nil), functions...)
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].Cyclomatic != top[j].Cyclomatic {
+			return top[i].Cyclomatic > top[j].Cyclomatic
+		}
+		return top[i].Cognitive > top[j].Cognitive
+	})
+	if len(top) > 5 {
+		top = top[:5]
+	}
+	result.TopFunctions = top
+}
+
+// goFunctionMetrics measures a Go function or method. codeLines holds the
+// lines of the file that contain code rather than only comments.
+func goFunctionMetrics(fset *token.FileSet, decl *ast.FuncDecl, codeLines map[int]bool) FunctionInfo {
+	fn := FunctionInfo{
+		Name: goFunctionName(decl),
+		Line: fset.Position(decl.Pos()).Line,
+	}
+
+	for _, field := range decl.Type.Params.List {
+		fn.Parameters += max(1, len(field.Names))
+	}
+
+	if decl.Body == nil {
+		// Declared without a body, e.g. implemented in assembly
+		fn.Cyclomatic = 1
+		fn.Complexity = 1
+		fn.LinesOfCode = 1
+		return fn
+	}
+
+	for line := fn.Line; line <= fset.Position(decl.End()).Line; line++ {
+		if codeLines[line] {
+			fn.LinesOfCode++
+		}
+	}
+
+	fn.Cyclomatic = goCyclomatic(decl.Body)
+	fn.Complexity = float64(fn.Cyclomatic)
+
+	cognitive := &goCognitive{name: decl.Name.Name, method: decl.Recv != nil}
+	cognitive.visit(decl.Body)
+	fn.Cognitive = cognitive.complexity
+	fn.Nesting = cognitive.maxNesting
+
+	return fn
+}
+
+// goFunctionName returns the name of a function, qualified by its receiver
+// type for methods, e.g. Server.Start
+func goFunctionName(decl *ast.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+	recv := decl.Recv.List[0].Type
+	for {
+		switch t := recv.(type) {
+		case *ast.StarExpr:
+			recv = t.X
+			continue
+		case *ast.IndexExpr:
+			recv = t.X
+			continue
+		case *ast.IndexListExpr:
+			recv = t.X
+			continue
+		case *ast.ParenExpr:
+			recv = t.X
+			continue
+		case *ast.Ident:
+			return t.Name + "." + decl.Name.Name
+		}
+		return decl.Name.Name
+	}
+}
+
+// goCodeLines returns the lines of a Go file that contain tokens other than
+// comments
+func goCodeLines(fset *token.FileSet, filePath string, content []byte) map[int]bool {
+	lines := make(map[int]bool)
+	file := fset.AddFile(filePath, -1, len(content))
+	var s scanner.Scanner
+	s.Init(file, content, nil, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			// Inserted at the end of a line that has other tokens
+			continue
+		}
+		start := file.Line(pos)
+		end := start
+		if tok == token.STRING {
+			end += strings.Count(lit, "\n")
+		}
+		for line := start; line <= end; line++ {
+			lines[line] = true
+		}
+	}
+	return lines
+}
+
+// goCyclomatic returns the McCabe cyclomatic complexity of a function body:
+// one plus the number of decision points, which are if and for statements,
+// non-default cases of switch and select statements, and the && and ||
+// operators. Function literals count towards the enclosing function.
+func goCyclomatic(body *ast.BlockStmt) int {
+	complexity := 1
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			complexity++
+		case *ast.CaseClause:
+			if n.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				complexity++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
+
+// goCognitive computes the cognitive complexity of a Go function: each
+// if, switch, select, and loop adds one plus its nesting level, else
+// branches, labeled jumps, recursive calls, and each sequence of like
+// boolean operators add one, and function literals increase the nesting
+// of the code they contain
+type goCognitive struct {
+	name       string
+	method     bool
+	nesting    int
+	maxNesting int
+	complexity int
+}
+
+// visit adds the complexity of a node and the nodes it contains
+func (c *goCognitive) visit(node ast.Node) {
+	if node == nil {
+		return
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt:
+			c.complexity += 1 + c.nesting
+			c.ifStmt(n)
+			return false
+
+		case *ast.ForStmt:
+			c.complexity += 1 + c.nesting
+			c.visit(n.Init)
+			c.visit(n.Cond)
+			c.visit(n.Post)
+			c.nested(n.Body)
+			return false
+
+		case *ast.RangeStmt:
+			c.complexity += 1 + c.nesting
+			c.visit(n.X)
+			c.nested(n.Body)
+			return false
+
+		case *ast.SwitchStmt:
+			c.complexity += 1 + c.nesting
+			c.visit(n.Init)
+			c.visit(n.Tag)
+			c.nested(n.Body)
+			return false
+
+		case *ast.TypeSwitchStmt:
+			c.complexity += 1 + c.nesting
+			c.visit(n.Init)
+			c.visit(n.Assign)
+			c.nested(n.Body)
+			return false
+
+		case *ast.SelectStmt:
+			c.complexity += 1 + c.nesting
+			c.nested(n.Body)
+			return false
+
+		case *ast.FuncLit:
+			c.nested(n.Body)
+			return false
+
+		case *ast.BranchStmt:
+			if n.Label != nil || n.Tok == token.GOTO {
+				c.complexity++
+			}
+
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				c.complexity += logicalSequences(n)
+				c.visitOperands(n)
+				return false
+			}
+
+		case *ast.CallExpr:
+			if c.isRecursive(n) {
+				c.complexity++
+			}
+		}
+		return true
+	})
+}
+
+// ifStmt visits an if statement, whose else if branches add one each
+// without nesting
+func (c *goCognitive) ifStmt(n *ast.IfStmt) {
+	c.visit(n.Init)
+	c.visit(n.Cond)
+	c.nested(n.Body)
+	switch elseStmt := n.Else.(type) {
+	case *ast.IfStmt:
+		c.complexity++
+		c.ifStmt(elseStmt)
+	case *ast.BlockStmt:
+		c.complexity++
+		c.nested(elseStmt)
+	}
+}
+
+// nested visits a node one level deeper
+func (c *goCognitive) nested(node ast.Node) {
+	c.nesting++
+	c.maxNesting = max(c.maxNesting, c.nesting)
+	c.visit(node)
+	c.nesting--
+}
+
+// visitOperands visits the operands of a chain of boolean operators, whose
+// sequences logicalSequences has counted
+func (c *goCognitive) visitOperands(expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		if e.Op == token.LAND || e.Op == token.LOR {
+			c.visitOperands(e.X)
+			c.visitOperands(e.Y)
+			return
+		}
+	case *ast.ParenExpr:
+		c.visitOperands(e.X)
+		return
+	}
+	c.visit(expr)
+}
+
+// isRecursive reports whether a call calls the function being measured
+func (c *goCognitive) isRecursive(call *ast.CallExpr) bool {
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		return !c.method && fun.Name == c.name
+	case *ast.SelectorExpr:
+		_, isIdent := fun.X.(*ast.Ident)
+		return c.method && isIdent && fun.Sel.Name == c.name
+	}
+	return false
+}
+
+// logicalSequences counts the sequences of like operators in a chain of
+// && and || operators, so that a && b && c counts one and a && b || c two
+func logicalSequences(expr *ast.BinaryExpr) int {
+	var ops []token.Token
+	var flatten func(e ast.Expr)
+	flatten = func(e ast.Expr) {
+		switch e := e.(type) {
+		case *ast.BinaryExpr:
+			if e.Op == token.LAND || e.Op == token.LOR {
+				flatten(e.X)
+				ops = append(ops, e.Op)
+				flatten(e.Y)
+			}
+		case *ast.ParenExpr:
+			flatten(e.X)
+		}
+	}
+	flatten(expr)
+
+	sequences := 0
+	for i, op := range ops {
+		if i == 0 || op != ops[i-1] {
+			sequences++
+		}
+	}
+	return sequences
+}
@@ -0,0 +1,478 @@
+package codeanalysis
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/goload"
+)
+
+// deadCodeTimeout limits loading and type-checking a module
+const deadCodeTimeout = 2 * time.Minute
+
+// deadCandidate is a declaration that may be unused
+type deadCandidate struct {
+	obj       types.Object
+	kind      string // function, method, type, constant, variable, or field
+	name      string // Qualified by the type for methods and fields
+	pkg       *goload.Package
+	parent    types.Object // Type of a method or field
+	tagged    bool         // Field of a struct with tags, which may be used by reflection
+	generated bool
+	referrers map[types.Object]bool
+}
+
+// deadCodeGraph holds the declarations of a module and what they reference
+type deadCodeGraph struct {
+	candidates map[types.Object]*deadCandidate
+	refs       map[types.Object][]types.Object
+	roots      []types.Object
+	methods    map[types.Object][]types.Object // Methods by receiver type
+	interfaces map[*types.Package]map[string]bool
+}
+
+// findDeadCode finds the unused declarations of the Go module that contains
+// targetPath, and reports those in targetPath. Code is live if it can be
+// reached from the main functions, init functions, tests, and the exported
+// API of the module's packages; with includeExported, exported declarations
+// are only live if code of the module reaches them.
+func findDeadCode(ctx context.Context, targetPath string, includeExported bool) ([]IssueInfo, error) {
+	absTarget, err := filepath.Abs(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absTarget)
+	if err != nil {
+		return nil, err
+	}
+	dir := absTarget
+	if !info.IsDir() {
+		if !strings.HasSuffix(absTarget, ".go") {
+			return nil, nil
+		}
+		dir = filepath.Dir(absTarget)
+	}
+	root := moduleRoot(dir)
+	if root == "" {
+		return nil, nil
+	}
+
+	loadCtx, cancel := context.WithTimeout(ctx, deadCodeTimeout)
+	defer cancel()
+	prog, err := goload.Load(loadCtx, root, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := buildDeadCodeGraph(prog, includeExported)
+	reachable := graph.reachable()
+
+	var issues []IssueInfo
+	for obj, candidate := range graph.candidates {
+		if reachable[obj] || candidate.generated {
+			continue
+		}
+		if candidate.parent != nil && !reachable[candidate.parent] {
+			// Reported with its type
+			continue
+		}
+		if candidate.kind == "field" && obj.Exported() && !includeExported {
+			continue
+		}
+
+		position := prog.Fset.Position(obj.Pos())
+		if info.IsDir() {
+			if !strings.HasPrefix(position.Filename, absTarget+string(filepath.Separator)) {
+				continue
+			}
+		} else if position.Filename != absTarget {
+			continue
+		}
+
+		var referrers []string
+		for referrer := range candidate.referrers {
+			if other, exists := graph.candidates[referrer]; exists {
+				referrers = append(referrers, other.name)
+			} else {
+				referrers = append(referrers, referrer.Name())
+			}
+		}
+		sort.Strings(referrers)
+
+		message := fmt.Sprintf("%s '%s' is unused", strings.ToUpper(candidate.kind[:1])+candidate.kind[1:], candidate.name)
+		confidence := "high"
+		if len(referrers) > 0 {
+			if len(referrers) > 3 {
+				referrers = append(referrers[:3], "...")
+			}
+			message = fmt.Sprintf("%s '%s' is only used by unused code (%s)", strings.ToUpper(candidate.kind[:1])+candidate.kind[1:], candidate.name, strings.Join(referrers, ", "))
+			confidence = "medium"
+		}
+		switch {
+		case len(candidate.pkg.Errors) > 0:
+			message += "; the package has type errors"
+			confidence = "low"
+		case candidate.tagged:
+			message += "; the struct has tags, so it may be used by reflection"
+			confidence = "low"
+		case obj.Exported() && isAPI(candidate.pkg, position.Filename):
+			message += "; it is exported, so other modules may use it"
+			confidence = "low"
+		}
+
+		issues = append(issues, IssueInfo{
+			Type:       "unused",
+			Message:    message,
+			FilePath:   position.Filename,
+			Line:       position.Line,
+			Confidence: confidence,
+		})
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].FilePath != issues[j].FilePath {
+			return issues[i].FilePath < issues[j].FilePath
+		}
+		return issues[i].Line < issues[j].Line
+	})
+	return issues, nil
+}
+
+// moduleRoot returns the directory of the go.mod file for dir, or ""
+func moduleRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// isAPI reports whether the declarations of a file are part of the API
+// that other modules can import
+func isAPI(pkg *goload.Package, filename string) bool {
+	if pkg.Name == "main" || pkg.XTest || strings.HasSuffix(filename, "_test.go") {
+		return false
+	}
+	for _, element := range strings.Split(pkg.ImportPath, "/") {
+		if element == "internal" {
+			return false
+		}
+	}
+	return true
+}
+
+// buildDeadCodeGraph collects the declarations of a program, the roots,
+// and the references of each declaration
+func buildDeadCodeGraph(prog *goload.Program, includeExported bool) *deadCodeGraph {
+	graph := &deadCodeGraph{
+		candidates: make(map[types.Object]*deadCandidate),
+		refs:       make(map[types.Object][]types.Object),
+		methods:    make(map[types.Object][]types.Object),
+		interfaces: make(map[*types.Package]map[string]bool),
+	}
+
+	for _, pkg := range prog.Packages {
+		if pkg.Types == nil {
+			continue
+		}
+		graph.collectInterfaces(pkg)
+		for i, file := range pkg.Files {
+			filename := pkg.Filenames[i]
+			generated := ast.IsGenerated(file)
+			for _, decl := range file.Decls {
+				switch decl := decl.(type) {
+				case *ast.FuncDecl:
+					graph.addFunc(pkg, filename, decl, generated, includeExported)
+				case *ast.GenDecl:
+					graph.addGenDecl(pkg, filename, decl, generated, includeExported)
+				}
+			}
+		}
+	}
+
+	// Record who references each declaration, other than itself and, for
+	// types, their own methods and fields
+	for owner, refs := range graph.refs {
+		if owner == nil {
+			continue
+		}
+		for _, ref := range refs {
+			candidate, exists := graph.candidates[ref]
+			if !exists || ref == owner {
+				continue
+			}
+			if member, isCandidate := graph.candidates[owner]; isCandidate && member.parent == ref {
+				continue
+			}
+			candidate.referrers[owner] = true
+		}
+	}
+	return graph
+}
+
+// collectInterfaces records the unexported method names of the interfaces
+// of a package, which methods of the package's types may implement
+func (g *deadCodeGraph) collectInterfaces(pkg *goload.Package) {
+	names := make(map[string]bool)
+	for _, tv := range pkg.Info.Types {
+		if tv.Type == nil {
+			continue
+		}
+		if iface, ok := tv.Type.Underlying().(*types.Interface); ok {
+			for i := 0; i < iface.NumMethods(); i++ {
+				if method := iface.Method(i); !method.Exported() {
+					names[method.Name()] = true
+				}
+			}
+		}
+	}
+	g.interfaces[pkg.Types] = names
+}
+
+// addCandidate records a declaration that may be unused, or a root
+func (g *deadCodeGraph) addCandidate(candidate *deadCandidate, root bool) {
+	if root {
+		g.roots = append(g.roots, candidate.obj)
+		return
+	}
+	candidate.referrers = make(map[types.Object]bool)
+	g.candidates[candidate.obj] = candidate
+}
+
+// addFunc adds a function or method declaration
+func (g *deadCodeGraph) addFunc(pkg *goload.Package, filename string, decl *ast.FuncDecl, generated, includeExported bool) {
+	fn, ok := pkg.Info.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return
+	}
+	g.refs[fn] = append(g.refs[fn], g.references(pkg, decl)...)
+	if decl.Name.Name == "_" {
+		g.roots = append(g.roots, fn)
+		return
+	}
+
+	candidate := &deadCandidate{obj: fn, kind: "function", name: fn.Name(), pkg: pkg, generated: generated}
+	root := decl.Body == nil || hasDirective(decl.Doc)
+	if decl.Recv == nil {
+		isTest := strings.HasSuffix(filename, "_test.go")
+		switch {
+		case fn.Name() == "init":
+			root = true
+		case fn.Name() == "main" && pkg.Name == "main":
+			root = true
+		case isTest && isTestFunc(fn.Name()):
+			root = true
+		case fn.Exported() && !includeExported && isAPI(pkg, filename):
+			root = true
+		}
+	} else if recv := receiverType(fn); recv != nil {
+		candidate.kind = "method"
+		candidate.name = recv.Name() + "." + fn.Name()
+		candidate.parent = recv
+		g.methods[recv] = append(g.methods[recv], fn)
+	}
+	g.addCandidate(candidate, root)
+}
+
+// addGenDecl adds the types, constants, and variables of a declaration
+func (g *deadCodeGraph) addGenDecl(pkg *goload.Package, filename string, decl *ast.GenDecl, generated, includeExported bool) {
+	for _, spec := range decl.Specs {
+		switch spec := spec.(type) {
+		case *ast.TypeSpec:
+			obj := pkg.Info.Defs[spec.Name]
+			if obj == nil {
+				continue
+			}
+			g.refs[obj] = append(g.refs[obj], g.references(pkg, spec)...)
+			root := spec.Name.Name == "_" || (obj.Exported() && !includeExported && isAPI(pkg, filename))
+			g.addCandidate(&deadCandidate{obj: obj, kind: "type", name: obj.Name(), pkg: pkg, generated: generated}, root)
+
+			// Fields of struct types, but not embedded ones, which are
+			// used implicitly by promotion
+			structType, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			tagged := false
+			for _, field := range structType.Fields.List {
+				if field.Tag != nil {
+					tagged = true
+				}
+			}
+			for _, field := range structType.Fields.List {
+				for _, name := range field.Names {
+					if fieldObj := pkg.Info.Defs[name]; fieldObj != nil && name.Name != "_" {
+						g.addCandidate(&deadCandidate{
+							obj:       fieldObj,
+							kind:      "field",
+							name:      obj.Name() + "." + name.Name,
+							pkg:       pkg,
+							parent:    obj,
+							tagged:    tagged,
+							generated: generated,
+						}, false)
+					}
+				}
+			}
+
+		case *ast.ValueSpec:
+			refs := g.references(pkg, spec)
+			kind := "variable"
+			if decl.Tok == token.CONST {
+				kind = "constant"
+			}
+			for _, name := range spec.Names {
+				obj := pkg.Info.Defs[name]
+				if obj == nil {
+					continue
+				}
+				g.refs[obj] = append(g.refs[obj], refs...)
+				root := name.Name == "_" || (obj.Exported() && !includeExported && isAPI(pkg, filename))
+				g.addCandidate(&deadCandidate{obj: obj, kind: kind, name: obj.Name(), pkg: pkg, generated: generated}, root)
+			}
+
+			// Initializers that call functions run whether or not the
+			// variables are used
+			if decl.Tok == token.VAR && hasCall(pkg, spec.Values) {
+				g.refs[nil] = append(g.refs[nil], refs...)
+			}
+		}
+	}
+}
+
+// references returns the declarations of the module that a node references
+func (g *deadCodeGraph) references(pkg *goload.Package, node ast.Node) []types.Object {
+	var refs []types.Object
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if obj := pkg.Info.Uses[n]; obj != nil {
+				refs = append(refs, origin(obj))
+			}
+		case *ast.CompositeLit:
+			// An unkeyed struct literal sets all the fields
+			if len(n.Elts) == 0 {
+				break
+			}
+			if _, keyed := n.Elts[0].(*ast.KeyValueExpr); keyed {
+				break
+			}
+			if tv, ok := pkg.Info.Types[n]; ok && tv.Type != nil {
+				if structType, ok := tv.Type.Underlying().(*types.Struct); ok {
+					for i := 0; i < structType.NumFields(); i++ {
+						refs = append(refs, origin(structType.Field(i)))
+					}
+				}
+			}
+		}
+		return true
+	})
+	return refs
+}
+
+// reachable returns the declarations reachable from the roots. Exported
+// methods of reachable types are reachable, since they may implement
+// interfaces of other packages, as are unexported methods named like a
+// method of an interface of their package.
+func (g *deadCodeGraph) reachable() map[types.Object]bool {
+	reachable := make(map[types.Object]bool)
+	queue := append([]types.Object{}, g.roots...)
+	queue = append(queue, g.refs[nil]...)
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+		if reachable[obj] {
+			continue
+		}
+		reachable[obj] = true
+		queue = append(queue, g.refs[obj]...)
+		for _, method := range g.methods[obj] {
+			if method.Exported() || g.interfaces[method.Pkg()][method.Name()] {
+				queue = append(queue, method)
+			}
+		}
+	}
+	return reachable
+}
+
+// origin returns the generic declaration of an instantiated function,
+// method, or field
+func origin(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+// receiverType returns the named type of a method's receiver
+func receiverType(fn *types.Func) *types.TypeName {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	t := recv.Type()
+	if pointer, ok := t.(*types.Pointer); ok {
+		t = pointer.Elem()
+	}
+	if named, ok := t.(*types.Named); ok {
+		return named.Origin().Obj()
+	}
+	return nil
+}
+
+// isTestFunc reports whether a function of a test file is run by go test
+func isTestFunc(name string) bool {
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDirective reports whether a doc comment has a directive that uses the
+// function from outside Go code, such as //go:linkname or //export
+func hasDirective(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.HasPrefix(comment.Text, "//go:linkname") || strings.HasPrefix(comment.Text, "//export ") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCall reports whether expressions call a function, rather than only
+// convert values
+func hasCall(pkg *goload.Package, exprs []ast.Expr) bool {
+	found := false
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if tv, ok := pkg.Info.Types[call.Fun]; !ok || !tv.IsType() {
+					found = true
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
@@ -0,0 +1,308 @@
+package codeanalysis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dependenciesTimeout limits listing the packages of a module
+const dependenciesTimeout = time.Minute
+
+// layerRuleRegex matches a layering rule, such as pkg/* must not import cmd/*
+var layerRuleRegex = regexp.MustCompile(`^\s*(\S+)\s+must\s+not\s+import\s+(\S+)\s*$`)
+
+// layerRule forbids the packages matching from to import those matching to
+type layerRule struct {
+	text string
+	from string
+	to   string
+}
+
+// parseLayerRules parses rules of the form "<pattern> must not import
+// <pattern>"
+func parseLayerRules(texts []string) ([]layerRule, error) {
+	var rules []layerRule
+	for _, text := range texts {
+		match := layerRuleRegex.FindStringSubmatch(text)
+		if match == nil {
+			return nil, fmt.Errorf("invalid rule %q: expected '<pattern> must not import <pattern>'", text)
+		}
+		for _, pattern := range match[1:] {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q in rule %q: %v", pattern, text, err)
+			}
+		}
+		rules = append(rules, layerRule{text: strings.TrimSpace(text), from: match[1], to: match[2]})
+	}
+	return rules, nil
+}
+
+// matchesPackage reports whether a package, given by its path relative to
+// the module, or a directory that contains it matches a pattern, so that
+// pkg/* matches pkg/a and pkg/a/b. Patterns may also be full import paths.
+func matchesPackage(pattern, relPath, modulePath string) bool {
+	for _, p := range []string{relPath, path.Join(modulePath, relPath)} {
+		for {
+			if matched, _ := path.Match(pattern, p); matched {
+				return true
+			}
+			parent := path.Dir(p)
+			if parent == p || parent == "." || parent == "/" {
+				break
+			}
+			p = parent
+		}
+	}
+	return false
+}
+
+// analyzeDependencies builds the import graph of the packages of the Go
+// module that contains dir, without test files, and checks it for cycles
+// and against the layering rules
+func analyzeDependencies(ctx context.Context, dir string, rules []layerRule) (*DependencyGraph, error) {
+	startTime := time.Now()
+
+	root := moduleRoot(dir)
+	if root == "" {
+		return nil, fmt.Errorf("no go.mod found for %s", dir)
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, dependenciesTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(listCtx, "go", "list", "-e", "-f", "{{.ImportPath}}\t{{with .Module}}{{.Path}}{{end}}\t{{join .Imports \" \"}}", "./...")
+	cmd.Dir = root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	graph := &DependencyGraph{ModuleDir: root, Cycles: []ImportCycle{}, Violations: []LayerViolation{}}
+	imports := make(map[string][]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		graph.ModulePath = fields[1]
+		imports[fields[0]] = strings.Fields(fields[2])
+	}
+	if len(imports) == 0 {
+		return nil, fmt.Errorf("no Go packages found in %s", root)
+	}
+
+	// relative returns the path of a package of the module relative to it
+	relative := func(importPath string) string {
+		if importPath == graph.ModulePath {
+			return "."
+		}
+		return strings.TrimPrefix(importPath, graph.ModulePath+"/")
+	}
+
+	// Nodes and the edges between packages of the module
+	nodes := make(map[string]*PackageNode)
+	var names []string
+	for importPath := range imports {
+		name := relative(importPath)
+		nodes[name] = &PackageNode{Path: name, ImportPath: importPath, Imports: []string{}, ExternalImports: []string{}}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for importPath, deps := range imports {
+		node := nodes[relative(importPath)]
+		for _, dep := range deps {
+			if _, internal := imports[dep]; internal {
+				node.Imports = append(node.Imports, relative(dep))
+			} else {
+				node.ExternalImports = append(node.ExternalImports, dep)
+			}
+		}
+		sort.Strings(node.Imports)
+		sort.Strings(node.ExternalImports)
+		node.FanOut = len(node.Imports)
+		for _, dep := range node.Imports {
+			nodes[dep].FanIn++
+		}
+	}
+
+	// Cycles, as the strongly connected components of more than one
+	// package, each with a cycle through its first package
+	components := stronglyConnected(names, nodes)
+	component := make(map[string]int)
+	for i, members := range components {
+		for _, member := range members {
+			component[member] = i
+		}
+	}
+	for i, members := range components {
+		if len(members) > 1 {
+			graph.Cycles = append(graph.Cycles, ImportCycle{
+				Packages: members,
+				Path:     findCycle(members, nodes, component, i),
+			})
+		}
+	}
+
+	// Depth, the longest chain of imports of the module's packages below
+	// each package, with each cycle counted as one step
+	componentDepth := make([]int, len(components))
+	for i, members := range components {
+		// Components are in reverse topological order, so those imported
+		// come first
+		depth := 0
+		for _, member := range members {
+			for _, dep := range nodes[member].Imports {
+				if j := component[dep]; j != i {
+					depth = max(depth, componentDepth[j]+1)
+				}
+			}
+		}
+		componentDepth[i] = depth
+	}
+
+	// Layering violations
+	for _, name := range names {
+		node := nodes[name]
+		node.Depth = componentDepth[component[name]]
+		for _, dep := range node.Imports {
+			for _, rule := range rules {
+				if matchesPackage(rule.from, name, graph.ModulePath) && matchesPackage(rule.to, dep, graph.ModulePath) {
+					graph.Violations = append(graph.Violations, LayerViolation{Rule: rule.text, From: name, To: dep})
+				}
+			}
+		}
+		graph.Packages = append(graph.Packages, *node)
+	}
+
+	graph.TimeTaken = time.Since(startTime)
+	return graph, nil
+}
+
+// stronglyConnected returns the strongly connected components of the
+// import graph in reverse topological order, using Tarjan's algorithm
+func stronglyConnected(names []string, nodes map[string]*PackageNode) [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var visit func(name string)
+	visit = func(name string) {
+		index[name] = len(index)
+		lowLink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, dep := range nodes[name].Imports {
+			if _, visited := index[dep]; !visited {
+				visit(dep)
+				lowLink[name] = min(lowLink[name], lowLink[dep])
+			} else if onStack[dep] {
+				lowLink[name] = min(lowLink[name], index[dep])
+			}
+		}
+
+		if lowLink[name] == index[name] {
+			var members []string
+			for {
+				member := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[member] = false
+				members = append(members, member)
+				if member == name {
+					break
+				}
+			}
+			sort.Strings(members)
+			components = append(components, members)
+		}
+	}
+
+	for _, name := range names {
+		if _, visited := index[name]; !visited {
+			visit(name)
+		}
+	}
+	return components
+}
+
+// findCycle returns a shortest cycle of imports from the first package of
+// a strongly connected component back to it, such as [a b a]
+func findCycle(members []string, nodes map[string]*PackageNode, component map[string]int, id int) []string {
+	start := members[0]
+	previous := map[string]string{start: ""}
+	queue := []string{start}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dep := range nodes[name].Imports {
+			if component[dep] != id {
+				continue
+			}
+			if dep == start {
+				cycle := []string{start}
+				for p := name; p != start; p = previous[p] {
+					cycle = append(cycle, p)
+				}
+				cycle = append(cycle, start)
+				// Reverse the path found from the end
+				for i, j := 1, len(cycle)-2; i < j; i, j = i+1, j-1 {
+					cycle[i], cycle[j] = cycle[j], cycle[i]
+				}
+				return cycle
+			}
+			if _, seen := previous[dep]; !seen {
+				previous[dep] = name
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return members
+}
+
+// formatDependenciesDOT renders the import graph in the DOT language of
+// Graphviz, with the imports that form cycles in red and those that break
+// a layering rule in orange
+func formatDependenciesDOT(graph *DependencyGraph) string {
+	cycleEdges := make(map[[2]string]bool)
+	for _, cycle := range graph.Cycles {
+		for i := 0; i+1 < len(cycle.Path); i++ {
+			cycleEdges[[2]string{cycle.Path[i], cycle.Path[i+1]}] = true
+		}
+	}
+	violationEdges := make(map[[2]string]bool)
+	for _, violation := range graph.Violations {
+		violationEdges[[2]string{violation.From, violation.To}] = true
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", graph.ModulePath)
+	b.WriteString("\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, node := range graph.Packages {
+		fmt.Fprintf(&b, "\t%q [tooltip=%q];\n", node.Path, fmt.Sprintf("fan-in %d, fan-out %d, depth %d", node.FanIn, node.FanOut, node.Depth))
+	}
+	for _, node := range graph.Packages {
+		for _, dep := range node.Imports {
+			edge := [2]string{node.Path, dep}
+			switch {
+			case violationEdges[edge]:
+				fmt.Fprintf(&b, "\t%q -> %q [color=orange];\n", node.Path, dep)
+			case cycleEdges[edge]:
+				fmt.Fprintf(&b, "\t%q -> %q [color=red];\n", node.Path, dep)
+			default:
+				fmt.Fprintf(&b, "\t%q -> %q;\n", node.Path, dep)
+			}
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,333 @@
+package codeanalysis
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
+)
+
+// DefaultMinTokens is the default minimum length of a clone in tokens
+const DefaultMinTokens = 50
+
+// maxWindowOccurrences is the number of occurrences above which a sequence
+// of tokens is taken for boilerplate, such as a table, and not compared
+const maxWindowOccurrences = 100
+
+// cloneToken is a token of a file, normalized so that clones that differ
+// only in identifiers and literals compare equal
+type cloneToken struct {
+	kind int    // Interned normalized text
+	text string // Source text, to measure similarity
+	line int
+}
+
+// cloneFile is a tokenized file
+type cloneFile struct {
+	path     string
+	language string
+	tokens   []cloneToken
+}
+
+// clonePosition is the position of a token in the files
+type clonePosition struct {
+	file  int
+	index int
+}
+
+// findDuplicates finds the clones among the code files in a directory,
+// reporting progress as each file is tokenized
+func findDuplicates(dirPath string, filePatterns []string, recursive bool, minTokens int, reporter *progress.Reporter) (*DuplicatesResult, error) {
+	startTime := time.Now()
+
+	paths, err := findFiles(dirPath, filePatterns, recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokenize the files, with normalized tokens interned across files
+	kinds := make(map[string]int)
+	var files []cloneFile
+	for i, path := range paths {
+		reporter.Report(float64(i), float64(len(paths)), path)
+		language, ok := syntax.LanguageForFile(path)
+		if !ok {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, cloneFile{
+			path:     path,
+			language: language.Name,
+			tokens:   cloneTokens(string(content), language, kinds),
+		})
+	}
+
+	result := &DuplicatesResult{
+		FilesAnalyzed: len(files),
+		MinTokens:     minTokens,
+		Groups:        findClones(files, minTokens),
+	}
+
+	// Measure how much of the code the clones cover
+	duplicated := make(map[string]map[int]bool)
+	for _, group := range result.Groups {
+		for _, location := range group.Locations {
+			if duplicated[location.FilePath] == nil {
+				duplicated[location.FilePath] = make(map[int]bool)
+			}
+			for line := location.StartLine; line <= location.EndLine; line++ {
+				duplicated[location.FilePath][line] = true
+			}
+		}
+	}
+	for _, file := range files {
+		lines := make(map[int]bool)
+		for _, tok := range file.tokens {
+			lines[tok.line] = true
+		}
+		result.TotalLines += len(lines)
+		for line := range duplicated[file.path] {
+			if lines[line] {
+				result.DuplicatedLines++
+			}
+		}
+	}
+
+	result.TimeTaken = time.Since(startTime)
+	return result, nil
+}
+
+// cloneTokens splits a file into tokens, abstracting identifiers and
+// literals and leaving out comments. kinds interns the normalized texts.
+func cloneTokens(content string, language syntax.Language, kinds map[string]int) []cloneToken {
+	intern := func(text string) int {
+		kind, ok := kinds[text]
+		if !ok {
+			kind = len(kinds)
+			kinds[text] = kind
+		}
+		return kind
+	}
+
+	var tokens []cloneToken
+	for _, tok := range syntax.Tokenize(content, language) {
+		normalized := tok.Text
+		switch {
+		case tok.Kind == syntax.Comment || tok.Kind == syntax.Directive:
+			continue
+		case tok.Kind == syntax.String || tok.Kind == syntax.Number:
+			normalized = "$lit"
+		case tok.Kind == syntax.Identifier && !language.IsKeyword(tok.Text):
+			normalized = "$id"
+		}
+		tokens = append(tokens, cloneToken{kind: intern(normalized), text: tok.Text, line: tok.Line})
+	}
+	return tokens
+}
+
+// findClones finds the groups of code fragments of at least minTokens
+// tokens that are equal once identifiers and literals are abstracted. The
+// windows of minTokens tokens are hashed with a rolling hash to find the
+// pairs of positions where clones may start, and each pair is extended as
+// far as its tokens match. Fragments in different languages are not
+// compared.
+func findClones(files []cloneFile, minTokens int) []CloneGroup {
+	const base = 1000003
+
+	type windowKey struct {
+		language string
+		hash     uint64
+	}
+	windows := make(map[windowKey][]clonePosition)
+
+	// power is base^(minTokens-1), to remove the first token of a window
+	power := uint64(1)
+	for i := 1; i < minTokens; i++ {
+		power *= base
+	}
+	for f, file := range files {
+		var hash uint64
+		for i, tok := range file.tokens {
+			if i >= minTokens {
+				hash -= uint64(file.tokens[i-minTokens].kind+1) * power
+			}
+			hash = hash*base + uint64(tok.kind+1)
+			if i >= minTokens-1 {
+				key := windowKey{language: file.language, hash: hash}
+				windows[key] = append(windows[key], clonePosition{file: f, index: i - minTokens + 1})
+			}
+		}
+	}
+
+	// Extend the pairs of equal windows that don't continue an earlier
+	// match into maximal matches, grouped by the fragment they share
+	type groupKey struct {
+		language string
+		hash     uint64
+		length   int
+	}
+	groups := make(map[groupKey]map[clonePosition]bool)
+	var keys []groupKey
+	for key, positions := range windows {
+		if len(positions) < 2 || len(positions) > maxWindowOccurrences {
+			continue
+		}
+		for i, a := range positions {
+			for _, b := range positions[i+1:] {
+				length := matchLength(files, a, b, minTokens)
+				if length == 0 {
+					continue
+				}
+				group := groupKey{language: key.language, hash: fragmentHash(files[a.file].tokens[a.index : a.index+length]), length: length}
+				if groups[group] == nil {
+					groups[group] = make(map[clonePosition]bool)
+					keys = append(keys, group)
+				}
+				groups[group][a] = true
+				groups[group][b] = true
+			}
+		}
+	}
+
+	var clones []CloneGroup
+	var starts [][]clonePosition
+	for _, key := range keys {
+		var positions []clonePosition
+		for position := range groups[key] {
+			positions = append(positions, position)
+		}
+		sort.Slice(positions, func(i, j int) bool {
+			if files[positions[i].file].path != files[positions[j].file].path {
+				return files[positions[i].file].path < files[positions[j].file].path
+			}
+			return positions[i].index < positions[j].index
+		})
+		clones = append(clones, cloneGroup(files, positions, key.length))
+		starts = append(starts, positions)
+	}
+
+	// Sort by size, and drop the groups whose fragments all lie within the
+	// fragments of a larger group
+	order := make([]int, len(clones))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := clones[order[i]], clones[order[j]]
+		if a.Tokens != b.Tokens {
+			return a.Tokens > b.Tokens
+		}
+		if len(a.Locations) != len(b.Locations) {
+			return len(a.Locations) > len(b.Locations)
+		}
+		if a.Locations[0].FilePath != b.Locations[0].FilePath {
+			return a.Locations[0].FilePath < b.Locations[0].FilePath
+		}
+		return a.Locations[0].StartLine < b.Locations[0].StartLine
+	})
+	var result []CloneGroup
+	var kept []int
+	for _, i := range order {
+		subsumed := false
+		for _, k := range kept {
+			if len(starts[k]) >= len(starts[i]) && containsFragments(starts[k], clones[k].Tokens, starts[i], clones[i].Tokens) {
+				subsumed = true
+				break
+			}
+		}
+		if !subsumed {
+			kept = append(kept, i)
+			result = append(result, clones[i])
+		}
+	}
+	return result
+}
+
+// matchLength returns the number of tokens that match from two positions,
+// or 0 if the match continues one that starts earlier or is shorter than
+// minTokens. Fragments of the same file don't overlap.
+func matchLength(files []cloneFile, a, b clonePosition, minTokens int) int {
+	tokensA, tokensB := files[a.file].tokens, files[b.file].tokens
+	if a.index > 0 && b.index > 0 && tokensA[a.index-1].kind == tokensB[b.index-1].kind {
+		return 0
+	}
+	limit := min(len(tokensA)-a.index, len(tokensB)-b.index)
+	if a.file == b.file {
+		if a.index > b.index {
+			a, b = b, a
+		}
+		limit = min(limit, b.index-a.index)
+	}
+	length := 0
+	for length < limit && tokensA[a.index+length].kind == tokensB[b.index+length].kind {
+		length++
+	}
+	if length < minTokens {
+		return 0
+	}
+	return length
+}
+
+// fragmentHash hashes the normalized tokens of a fragment
+func fragmentHash(tokens []cloneToken) uint64 {
+	hash := uint64(14695981039346656037)
+	for _, tok := range tokens {
+		hash = (hash ^ uint64(tok.kind)) * 1099511628211
+	}
+	return hash
+}
+
+// cloneGroup describes the fragments of length tokens at positions, with
+// the share of tokens whose source text matches that of the first fragment
+// as their similarity
+func cloneGroup(files []cloneFile, positions []clonePosition, length int) CloneGroup {
+	group := CloneGroup{Tokens: length, Similarity: 100}
+	first := files[positions[0].file].tokens[positions[0].index:]
+	same, compared := 0, 0
+	for i, position := range positions {
+		tokens := files[position.file].tokens[position.index : position.index+length]
+		group.Locations = append(group.Locations, CloneLocation{
+			FilePath:  files[position.file].path,
+			StartLine: tokens[0].line,
+			EndLine:   tokens[length-1].line,
+		})
+		if i == 0 {
+			continue
+		}
+		for j, tok := range tokens {
+			compared++
+			if tok.text == first[j].text {
+				same++
+			}
+		}
+	}
+	if compared > 0 {
+		group.Similarity = 100 * float64(same) / float64(compared)
+	}
+	first = first[:length]
+	group.Lines = first[length-1].line - first[0].line + 1
+	return group
+}
+
+// containsFragments reports whether each fragment of inner lies within a
+// fragment of outer
+func containsFragments(outer []clonePosition, outerLength int, inner []clonePosition, innerLength int) bool {
+	for _, in := range inner {
+		contained := false
+		for _, out := range outer {
+			if in.file == out.file && in.index >= out.index && in.index+innerLength <= out.index+outerLength {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			return false
+		}
+	}
+	return true
+}
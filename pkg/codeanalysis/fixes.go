@@ -0,0 +1,695 @@
+package codeanalysis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/diff"
+	"github.com/Code-Monger/CodeSpinneret/pkg/goedit"
+	"github.com/Code-Monger/CodeSpinneret/pkg/imports"
+	"github.com/Code-Monger/CodeSpinneret/pkg/rename"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sarif"
+)
+
+// fixTimeout limits the type checking of the renames of apply_fixes
+const fixTimeout = 2 * time.Minute
+
+// assignSuggestionIDs gives each suggestion an ID that stays the same while
+// the line it is on doesn't change, even if lines are added above it
+func assignSuggestionIDs(suggestions []SuggestionInfo) {
+	fingerprinter := sarif.NewFingerprinter()
+	for i := range suggestions {
+		path := suggestions[i].FilePath
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+		fingerprint := fingerprinter.Fingerprints(suggestions[i].Type+"/"+suggestions[i].Title, path, path, suggestions[i].Line)
+		suggestions[i].ID = fingerprint[sarif.FingerprintKey][:12]
+	}
+}
+
+// parseGoSource parses a Go file for the fix finders, with the objects of
+// identifiers resolved within the file
+func parseGoSource(filePath, content string) (*token.FileSet, *ast.File, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, content, parser.ParseComments)
+	return fset, file, err
+}
+
+// importedAs returns the name under which a file imports a package, or ""
+// if it doesn't import it by name
+func importedAs(file *ast.File, importPath string) string {
+	for _, spec := range file.Imports {
+		if strings.Trim(spec.Path.Value, "`\"") != importPath {
+			continue
+		}
+		if spec.Name != nil {
+			if spec.Name.Name == "_" || spec.Name.Name == "." {
+				return ""
+			}
+			return spec.Name.Name
+		}
+		return importPath[strings.LastIndex(importPath, "/")+1:]
+	}
+	return ""
+}
+
+// isPackageCall reports whether a call is of a function of the package
+// imported as pkgName, rather than of a local variable of that name
+func isPackageCall(call *ast.CallExpr, pkgName, funcName string) bool {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || pkgName == "" {
+		return false
+	}
+	ident, ok := selector.X.(*ast.Ident)
+	return ok && ident.Name == pkgName && ident.Obj == nil && selector.Sel.Name == funcName
+}
+
+// lineIndent returns the indentation of the line that contains offset
+func lineIndent(content string, offset int) string {
+	start := strings.LastIndex(content[:offset], "\n") + 1
+	end := start
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return content[start:end]
+}
+
+// newline returns the line ending of a file
+func newline(content string) string {
+	if strings.Contains(content, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// suggestErrorf finds errors.New(fmt.Sprintf(...)) calls, which
+// fmt.Errorf(...) replaces
+func suggestErrorf(filePath, content string) []SuggestionInfo {
+	fset, file, err := parseGoSource(filePath, content)
+	if err != nil {
+		return nil
+	}
+	errorsName, fmtName := importedAs(file, "errors"), importedAs(file, "fmt")
+	if errorsName == "" || fmtName == "" {
+		return nil
+	}
+
+	var suggestions []SuggestionInfo
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !isPackageCall(call, errorsName, "New") || len(call.Args) != 1 {
+			return true
+		}
+		inner, ok := call.Args[0].(*ast.CallExpr)
+		if !ok || !isPackageCall(inner, fmtName, "Sprintf") || len(inner.Args) == 0 {
+			return true
+		}
+		start, end := fset.Position(call.Pos()).Offset, fset.Position(call.End()).Offset
+		arguments := content[fset.Position(inner.Lparen).Offset+1 : fset.Position(inner.Rparen).Offset]
+		newText := fmtName + ".Errorf(" + arguments + ")"
+		suggestions = append(suggestions, SuggestionInfo{
+			Type:        "refactoring",
+			Title:       "Use fmt.Errorf instead of errors.New(fmt.Sprintf(...))",
+			Description: "fmt.Errorf formats the message and creates the error in one call, and can wrap errors with %w.",
+			FilePath:    filePath,
+			Line:        fset.Position(call.Pos()).Line,
+			Before:      content[start:end],
+			After:       newText,
+			Fix: &SuggestionFix{
+				Kind:  "errorf",
+				Edits: []TextEdit{{Start: start, End: end, NewText: newText}},
+			},
+		})
+		return false
+	})
+	return suggestions
+}
+
+// suggestStringBuilders finds string variables built with += in a loop,
+// which a strings.Builder builds in linear time. The variable must be
+// declared in the same block as the loop, with an empty type string or a
+// string literal, and only appended to until the loop ends. Afterwards it
+// is declared again with the builder's result.
+func suggestStringBuilders(filePath, content string) []SuggestionInfo {
+	fset, file, err := parseGoSource(filePath, content)
+	if err != nil {
+		return nil
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+
+	// Uses of each object, and every name in the file
+	uses := make(map[*ast.Object][]*ast.Ident)
+	names := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			names[ident.Name] = true
+			if ident.Obj != nil {
+				uses[ident.Obj] = append(uses[ident.Obj], ident)
+			}
+		}
+		return true
+	})
+	stringsName := importedAs(file, "strings")
+	var imported []string
+	if stringsName == "" {
+		if names["strings"] {
+			return nil
+		}
+		stringsName = "strings"
+		imported = []string{"strings"}
+	}
+
+	var suggestions []SuggestionInfo
+	checkBlock := func(stmts []ast.Stmt) {
+		for i, stmt := range stmts {
+			// A declaration of a string variable
+			var ident *ast.Ident
+			initial := ""
+			switch decl := stmt.(type) {
+			case *ast.AssignStmt:
+				if decl.Tok != token.DEFINE || len(decl.Lhs) != 1 || len(decl.Rhs) != 1 {
+					continue
+				}
+				lit, ok := decl.Rhs[0].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				ident, _ = decl.Lhs[0].(*ast.Ident)
+				initial = lit.Value
+			case *ast.DeclStmt:
+				genDecl, ok := decl.Decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.VAR || len(genDecl.Specs) != 1 {
+					continue
+				}
+				spec := genDecl.Specs[0].(*ast.ValueSpec)
+				if len(spec.Names) != 1 || len(spec.Values) > 1 {
+					continue
+				}
+				if len(spec.Values) == 1 {
+					lit, ok := spec.Values[0].(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING || spec.Type != nil && !isIdent(spec.Type, "string") {
+						continue
+					}
+					initial = lit.Value
+				} else if !isIdent(spec.Type, "string") {
+					continue
+				}
+				ident = spec.Names[0]
+			}
+			if ident == nil || ident.Obj == nil || ident.Name == "_" {
+				continue
+			}
+			if initial == `""` || initial == "``" {
+				initial = ""
+			}
+
+			// The loop that follows, with the other uses of the variable
+			// until the loop ends only as the target of +=
+			for _, next := range stmts[i+1:] {
+				loop := next
+				if labeled, ok := loop.(*ast.LabeledStmt); ok {
+					loop = labeled.Stmt
+				}
+				var body *ast.BlockStmt
+				switch l := loop.(type) {
+				case *ast.ForStmt:
+					body = l.Body
+				case *ast.RangeStmt:
+					body = l.Body
+				}
+
+				var appends []*ast.AssignStmt
+				if body != nil {
+					ast.Inspect(body, func(n ast.Node) bool {
+						if assign, ok := n.(*ast.AssignStmt); ok && assign.Tok == token.ADD_ASSIGN && len(assign.Lhs) == 1 {
+							if lhs, ok := assign.Lhs[0].(*ast.Ident); ok && lhs.Obj == ident.Obj {
+								appends = append(appends, assign)
+							}
+						}
+						return true
+					})
+				}
+				allowed := map[*ast.Ident]bool{ident: true}
+				for _, assign := range appends {
+					allowed[assign.Lhs[0].(*ast.Ident)] = true
+				}
+				usedElsewhere, usedAfter := false, false
+				for _, use := range uses[ident.Obj] {
+					switch {
+					case use.Pos() >= next.End():
+						usedAfter = true
+					case !allowed[use]:
+						usedElsewhere = true
+					}
+				}
+				if usedElsewhere {
+					break
+				}
+				if len(appends) == 0 {
+					continue
+				}
+				if !usedAfter {
+					break
+				}
+
+				// The builder, named after the variable
+				builder := ident.Name + "Builder"
+				for n := 2; names[builder]; n++ {
+					builder = fmt.Sprintf("%sBuilder%d", ident.Name, n)
+				}
+				nl := newline(content)
+				indent := lineIndent(content, offset(stmt.Pos()))
+				declText := fmt.Sprintf("var %s %s.Builder", builder, stringsName)
+				if initial != "" {
+					declText += fmt.Sprintf("%s%s%s.WriteString(%s)", nl, indent, builder, initial)
+				}
+				edits := []TextEdit{{Start: offset(stmt.Pos()), End: offset(stmt.End()), NewText: declText}}
+				for _, assign := range appends {
+					edits = append(edits, TextEdit{
+						Start:   offset(assign.Pos()),
+						End:     offset(assign.End()),
+						NewText: fmt.Sprintf("%s.WriteString(%s)", builder, content[offset(assign.Rhs[0].Pos()):offset(assign.Rhs[0].End())]),
+					})
+				}
+				resultText := fmt.Sprintf("%s := %s.String()", ident.Name, builder)
+				edits = append(edits, TextEdit{Start: offset(next.End()), End: offset(next.End()), NewText: nl + indent + resultText})
+
+				suggestions = append(suggestions, SuggestionInfo{
+					Type:        "performance",
+					Title:       fmt.Sprintf("Use strings.Builder to build '%s'", ident.Name),
+					Description: fmt.Sprintf("'%s' is concatenated with += in a loop, which copies the string each time. A strings.Builder appends in place.", ident.Name),
+					FilePath:    filePath,
+					Line:        fset.Position(appends[0].Pos()).Line,
+					Before:      content[offset(appends[0].Pos()):offset(appends[0].End())],
+					After:       edits[1].NewText,
+					Fix:         &SuggestionFix{Kind: "builder", Edits: edits, Imports: imported},
+				})
+				break
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch block := n.(type) {
+		case *ast.BlockStmt:
+			checkBlock(block.List)
+		case *ast.CaseClause:
+			checkBlock(block.Body)
+		case *ast.CommClause:
+			checkBlock(block.Body)
+		}
+		return true
+	})
+	return suggestions
+}
+
+// isIdent reports whether an expression is the identifier name
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+// suggestGoNames finds the identifiers declared with underscores, which Go
+// names in mixedCaps, and renames them with all their references
+func suggestGoNames(filePath, content string) []SuggestionInfo {
+	fset, file, err := parseGoSource(filePath, content)
+	if err != nil || importedAs(file, "C") != "" {
+		return nil
+	}
+	isTest := strings.HasSuffix(filePath, "_test.go")
+
+	var declared []*ast.Ident
+	addFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			declared = append(declared, field.Names...)
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.FuncDecl:
+			// Tests and examples such as Example_suffix use underscores
+			name := node.Name.Name
+			if !(isTest && node.Recv == nil && (strings.HasPrefix(name, "Test") || strings.HasPrefix(name, "Benchmark") ||
+				strings.HasPrefix(name, "Example") || strings.HasPrefix(name, "Fuzz"))) {
+				declared = append(declared, node.Name)
+			}
+		case *ast.FuncType:
+			addFields(node.Params)
+			addFields(node.Results)
+		case *ast.StructType:
+			addFields(node.Fields)
+		case *ast.ValueSpec:
+			declared = append(declared, node.Names...)
+		case *ast.TypeSpec:
+			declared = append(declared, node.Name)
+		case *ast.AssignStmt:
+			if node.Tok == token.DEFINE {
+				for _, lhs := range node.Lhs {
+					// Only new variables, not those := assigns again
+					if ident, ok := lhs.(*ast.Ident); ok && ident.Obj != nil && ident.Obj.Decl == node {
+						declared = append(declared, ident)
+					}
+				}
+			}
+		case *ast.RangeStmt:
+			if node.Tok == token.DEFINE {
+				for _, expr := range []ast.Expr{node.Key, node.Value} {
+					if ident, ok := expr.(*ast.Ident); ok {
+						declared = append(declared, ident)
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	var suggestions []SuggestionInfo
+	for _, ident := range declared {
+		newName := mixedCaps(ident.Name)
+		if newName == "" || newName == ident.Name {
+			continue
+		}
+		position := fset.Position(ident.Pos())
+		lineStart := position.Offset - (position.Column - 1)
+		suggestions = append(suggestions, SuggestionInfo{
+			Type:        "readability",
+			Title:       fmt.Sprintf("Rename '%s' to '%s'", ident.Name, newName),
+			Description: fmt.Sprintf("Go names use mixedCaps rather than underscores. The rename updates every reference to '%s' in the module.", ident.Name),
+			FilePath:    filePath,
+			Line:        position.Line,
+			Before:      ident.Name,
+			After:       newName,
+			Fix: &SuggestionFix{
+				Kind: "rename",
+				Rename: &RenameFix{
+					Line:    position.Line,
+					Column:  utf8.RuneCountInString(content[lineStart:position.Offset]) + 1,
+					OldName: ident.Name,
+					NewName: newName,
+				},
+			},
+		})
+	}
+	return suggestions
+}
+
+// mixedCaps converts a name with underscores to mixedCaps, keeping whether
+// it is exported: max_size becomes maxSize, MAX_SIZE MaxSize, and http_URL
+// httpURL. It returns "" for names it leaves alone, such as _ and _x.
+func mixedCaps(name string) string {
+	if !strings.Contains(name, "_") || strings.HasPrefix(name, "_") || strings.HasSuffix(name, "_") || strings.Contains(name, "__") {
+		return ""
+	}
+	allCaps := strings.ToUpper(name) == name
+	var b strings.Builder
+	for i, part := range strings.Split(name, "_") {
+		if allCaps {
+			part = strings.ToLower(part)
+			if i == 0 {
+				// Keep an exported name exported
+				first, size := utf8.DecodeRuneInString(part)
+				part = string(unicode.ToUpper(first)) + part[size:]
+			}
+		}
+		if i > 0 {
+			first, size := utf8.DecodeRuneInString(part)
+			part = string(unicode.ToUpper(first)) + part[size:]
+		}
+		b.WriteString(part)
+	}
+	if !token.IsIdentifier(b.String()) {
+		return ""
+	}
+	return b.String()
+}
+
+// fixKey identifies a fix by its kind, file, line, and order on the line,
+// which renames don't change
+type fixKey struct {
+	kind  string
+	path  string
+	line  int
+	index int
+}
+
+// keyFixes returns the keys of suggestions with text edits
+func keyFixes(suggestions []SuggestionInfo) []fixKey {
+	keys := make([]fixKey, len(suggestions))
+	counts := make(map[fixKey]int)
+	for i, suggestion := range suggestions {
+		key := fixKey{kind: suggestion.Fix.Kind, path: suggestion.FilePath, line: suggestion.Line}
+		keys[i] = key
+		keys[i].index = counts[key]
+		counts[key]++
+	}
+	return keys
+}
+
+// textFixes finds the suggestions of a Go file that edit its text
+func textFixes(filePath, content string) []SuggestionInfo {
+	return append(suggestErrorf(filePath, content), suggestStringBuilders(filePath, content)...)
+}
+
+// applyFixes applies the fixes of the suggestions with the given IDs. The
+// renames go first, since they don't move lines; the edits of each file
+// are then found again in its renamed content, matched by kind and line,
+// and applied together. Imports are fixed and files that were formatted
+// are formatted again. root gives the paths of the diff.
+func applyFixes(ctx context.Context, suggestions []SuggestionInfo, ids []string, root string) (*FixesResult, error) {
+	byID := make(map[string]SuggestionInfo)
+	for _, suggestion := range suggestions {
+		byID[suggestion.ID] = suggestion
+	}
+	var renames, edits []SuggestionInfo
+	for _, id := range ids {
+		suggestion, exists := byID[id]
+		switch {
+		case !exists:
+			return nil, fmt.Errorf("unknown suggestion ID: %s (run suggest_improvements on the same target for the current IDs)", id)
+		case suggestion.Fix == nil:
+			return nil, fmt.Errorf("suggestion %s has no automatic fix: %s", id, suggestion.Title)
+		case suggestion.Fix.Rename != nil:
+			renames = append(renames, suggestion)
+		default:
+			edits = append(edits, suggestion)
+		}
+	}
+
+	result := &FixesResult{}
+	originals := make(map[string]string)
+	remember := func(path string) error {
+		if _, exists := originals[path]; exists {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		originals[path] = string(content)
+		return nil
+	}
+
+	// Renames, each resolved again from its line since earlier renames may
+	// have moved it within the line
+	for _, suggestion := range renames {
+		fix := suggestion.Fix.Rename
+		path, err := filepath.Abs(suggestion.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		column := identifierColumn(string(content), fix.Line, fix.OldName)
+		if column == 0 {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s: '%s' is no longer on line %d", suggestion.Title, fix.OldName, fix.Line))
+			continue
+		}
+		renamed, err := rename.Compute(ctx, filepath.Dir(path), rename.Target{File: path, Line: fix.Line, Column: column}, fix.NewName)
+		if err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %v", suggestion.Title, err))
+			continue
+		}
+		if renamed.OldName != fix.OldName || len(renamed.TypeErrors) > 0 {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s: the renamed code does not type-check", suggestion.Title))
+			continue
+		}
+		for _, change := range renamed.Changes {
+			if err := remember(change.Path); err != nil {
+				return nil, err
+			}
+		}
+		if err := renamed.Apply(); err != nil {
+			return nil, err
+		}
+		result.Applied = append(result.Applied, suggestion.Title)
+		result.Warnings = append(result.Warnings, renamed.Warnings...)
+	}
+
+	// Text edits, grouped by file
+	keys := keyFixes(edits)
+	selected := make(map[fixKey]SuggestionInfo)
+	var paths []string
+	for i := range keys {
+		path, err := filepath.Abs(keys[i].path)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(paths, path) {
+			paths = append(paths, path)
+		}
+		keys[i].path = path
+		selected[keys[i]] = edits[i]
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := remember(path); err != nil {
+			return nil, err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		// Edit with LF line endings, which imports and gofmt write
+		crlf := bytes.Contains(content, []byte("\r\n"))
+		if crlf {
+			content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		}
+
+		var fileEdits []TextEdit
+		add := make(map[string]string)
+		current := textFixes(path, string(content))
+		found := make(map[fixKey]bool)
+		for i, key := range keyFixes(current) {
+			suggestion, exists := selected[key]
+			if !exists {
+				continue
+			}
+			found[key] = true
+			if overlapsEdits(fileEdits, current[i].Fix.Edits) {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: overlaps another fix", suggestion.Title))
+				continue
+			}
+			fileEdits = append(fileEdits, current[i].Fix.Edits...)
+			for _, importPath := range current[i].Fix.Imports {
+				add[importPath] = importPath[strings.LastIndex(importPath, "/")+1:]
+			}
+			result.Applied = append(result.Applied, suggestion.Title)
+		}
+		for i, key := range keys {
+			if key.path == path && !found[key] {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s: no longer applies", edits[i].Title))
+			}
+		}
+		if len(fileEdits) == 0 {
+			continue
+		}
+
+		newContent := applyTextEdits([]byte(content), fileEdits)
+		newContent, err = imports.Fix(newContent, nil, add)
+		if err != nil {
+			return nil, fmt.Errorf("the fixed %s does not parse: %v", filepath.Base(path), err)
+		}
+		newContent = goedit.KeepFormatted(content, newContent)
+		if crlf {
+			newContent = bytes.ReplaceAll(newContent, []byte("\n"), []byte("\r\n"))
+		}
+		if err := goedit.WriteFile(path, newContent); err != nil {
+			return nil, err
+		}
+	}
+
+	// The diff of all the changes to each file
+	var changed []string
+	for path := range originals {
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	for _, path := range changed {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		if string(content) == originals[path] {
+			continue
+		}
+		result.Files = append(result.Files, path)
+		oldName, newName := path, path
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			oldName, newName = "a/"+filepath.ToSlash(rel), "b/"+filepath.ToSlash(rel)
+		}
+		result.Diff += diff.Unified(oldName, newName, originals[path], string(content), diff.DefaultContext)
+	}
+	return result, nil
+}
+
+// identifierColumn returns the one-based column, in characters, of the
+// first occurrence of an identifier on a line, or 0 if it isn't there
+func identifierColumn(content string, line int, name string) int {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return 0
+	}
+	text := lines[line-1]
+	for start := 0; ; {
+		i := strings.Index(text[start:], name)
+		if i < 0 {
+			return 0
+		}
+		i += start
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[i+len(name):])
+		if !isIdentRune(before) && !isIdentRune(after) {
+			return utf8.RuneCountInString(text[:i]) + 1
+		}
+		start = i + len(name)
+	}
+}
+
+// isIdentRune reports whether a rune can be part of an identifier
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// overlapsEdits reports whether any of the new edits overlaps the others
+func overlapsEdits(edits, newEdits []TextEdit) bool {
+	for _, a := range edits {
+		for _, b := range newEdits {
+			if a.Start < b.End && b.Start < a.End || a.Start == b.Start {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyTextEdits applies non-overlapping edits to content
+func applyTextEdits(content []byte, edits []TextEdit) []byte {
+	sorted := append([]TextEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var buffer bytes.Buffer
+	last := 0
+	for _, e := range sorted {
+		buffer.Write(content[last:e.Start])
+		buffer.WriteString(e.NewText)
+		last = e.End
+	}
+	buffer.Write(content[last:])
+	return buffer.Bytes()
+}
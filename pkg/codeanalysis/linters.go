@@ -0,0 +1,729 @@
+package codeanalysis
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sarif"
+)
+
+// linterTimeout limits each run of an external linter
+const linterTimeout = 5 * time.Minute
+
+// sarifLinterPrefix introduces a custom linter command whose output is SARIF
+const sarifLinterPrefix = "sarif:"
+
+// builtinSource is the source of the issues found by this package
+const builtinSource = "codeanalysis"
+
+// linter is an external linter that find_issues can run
+type linter struct {
+	name      string
+	languages []string // Languages of the files it checks
+	// find returns the linter's binary for a target, or "" if it isn't
+	// installed or doesn't apply
+	find func(targetDir string) string
+	// command returns the arguments and working directory of a run
+	command func(binary, targetPath, targetDir string, isDir bool) ([]string, string)
+	// parse turns the linter's output into issues
+	parse func(stdout, stderr []byte, dir string) ([]IssueInfo, error)
+}
+
+// linters are the supported external linters, in the order they run
+var linters = []linter{
+	{
+		name:      "go vet",
+		languages: []string{"Go"},
+		find:      findGoTool("go"),
+		command: func(binary, targetPath, targetDir string, isDir bool) ([]string, string) {
+			root := moduleRoot(targetDir)
+			return []string{binary, "vet", "-json", goPackagePattern(root, targetDir, isDir)}, root
+		},
+		parse: parseGoVet,
+	},
+	{
+		name:      "staticcheck",
+		languages: []string{"Go"},
+		find:      findGoTool("staticcheck"),
+		command: func(binary, targetPath, targetDir string, isDir bool) ([]string, string) {
+			root := moduleRoot(targetDir)
+			return []string{binary, "-f", "json", goPackagePattern(root, targetDir, isDir)}, root
+		},
+		parse: parseStaticcheck,
+	},
+	{
+		name:      "golangci-lint",
+		languages: []string{"Go"},
+		find:      findGoTool("golangci-lint"),
+		command: func(binary, targetPath, targetDir string, isDir bool) ([]string, string) {
+			root := moduleRoot(targetDir)
+			pattern := goPackagePattern(root, targetDir, isDir)
+			if golangciMajorVersion(binary) >= 2 {
+				return []string{binary, "run", "--output.json.path", "stdout", "--show-stats=false", pattern}, root
+			}
+			return []string{binary, "run", "--out-format", "json", pattern}, root
+		},
+		parse: parseGolangci,
+	},
+	{
+		name:      "eslint",
+		languages: []string{"JavaScript", "TypeScript"},
+		find:      findESLint,
+		command: func(binary, targetPath, targetDir string, isDir bool) ([]string, string) {
+			return []string{binary, "-f", "json", targetPath}, eslintProjectDir(targetDir)
+		},
+		parse: parseESLint,
+	},
+	{
+		name:      "ruff",
+		languages: []string{"Python"},
+		find: func(targetDir string) string {
+			path, _ := exec.LookPath("ruff")
+			return path
+		},
+		command: func(binary, targetPath, targetDir string, isDir bool) ([]string, string) {
+			return []string{binary, "check", "--output-format", "json", "--exit-zero", targetPath}, targetDir
+		},
+		parse: parseRuff,
+	},
+}
+
+// linterNames returns the names of the supported linters
+func linterNames() []string {
+	var names []string
+	for _, l := range linters {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+// linterRun is a linter command prepared for a target
+type linterRun struct {
+	name  string
+	args  []string
+	dir   string
+	parse func(stdout, stderr []byte, dir string) ([]IssueInfo, error)
+}
+
+// prepareLinters builds the commands of the requested linters for a target.
+// "auto" selects the installed linters that apply to the target, and
+// "sarif: <command>" runs a custom command that prints SARIF. Linters that
+// aren't installed are skipped with a warning.
+func prepareLinters(names []string, targetPath string) ([]*linterRun, []string, error) {
+	info, err := os.Stat(targetPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetDir := targetPath
+	language := ""
+	if !info.IsDir() {
+		targetDir = filepath.Dir(targetPath)
+		language = getLanguageFromExtension(filepath.Ext(targetPath))
+	}
+
+	var runs []*linterRun
+	var warnings []string
+	seen := make(map[string]bool)
+	add := func(l linter, explicit bool) {
+		if seen[l.name] {
+			return
+		}
+		if language != "" && !containsString(l.languages, language) {
+			if explicit {
+				seen[l.name] = true
+				warnings = append(warnings, fmt.Sprintf("%s skipped: it doesn't check %s files", l.name, language))
+			}
+			return
+		}
+		binary := l.find(targetDir)
+		if binary == "" {
+			if explicit {
+				seen[l.name] = true
+				warnings = append(warnings, fmt.Sprintf("%s skipped: not installed or not applicable to %s", l.name, targetPath))
+			}
+			return
+		}
+		seen[l.name] = true
+		args, dir := l.command(binary, targetPath, targetDir, info.IsDir())
+		runs = append(runs, &linterRun{name: l.name, args: args, dir: dir, parse: l.parse})
+	}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "auto":
+			for _, l := range linters {
+				add(l, false)
+			}
+		case strings.HasPrefix(name, sarifLinterPrefix):
+			commands := policy.ParseCommandLine(strings.TrimSpace(strings.TrimPrefix(name, sarifLinterPrefix)))
+			if len(commands) != 1 || len(commands[0].Redirects) > 0 {
+				return nil, nil, fmt.Errorf("invalid linter %q: expected a single command without redirections", name)
+			}
+			args := append([]string{commands[0].Name}, commands[0].Args...)
+			runs = append(runs, &linterRun{name: commands[0].Binary(), args: args, dir: targetDir, parse: parseSARIF})
+		default:
+			found := false
+			for _, l := range linters {
+				if l.name == name {
+					add(l, true)
+					found = true
+				}
+			}
+			if !found {
+				return nil, nil, fmt.Errorf("unsupported linter: %s (supported: %s, auto, or '%s <command>')", name, strings.Join(linterNames(), ", "), sarifLinterPrefix)
+			}
+		}
+	}
+	return runs, warnings, nil
+}
+
+// linterCommandLine returns the commands of the linters as one command line,
+// for the command policy
+func linterCommandLine(runs []*linterRun) string {
+	var commands []string
+	for _, run := range runs {
+		commands = append(commands, policy.QuoteCommandLine(run.args))
+	}
+	return strings.Join(commands, " && ")
+}
+
+// runLinters runs the linters in turn and collects their issues. A linter
+// that fails without output that can be parsed is reported as a warning.
+func runLinters(ctx context.Context, runs []*linterRun, limits sandbox.Config, writableRoot string) ([]IssueInfo, []string, error) {
+	var issues []IssueInfo
+	var warnings []string
+	for _, run := range runs {
+		runCtx, cancel := context.WithTimeout(ctx, linterTimeout)
+		cmd := exec.CommandContext(runCtx, run.args[0], run.args[1:]...)
+		cmd.Dir = run.dir
+		if err := sandbox.Wrap(cmd, limits, writableRoot); err != nil {
+			cancel()
+			return nil, nil, fmt.Errorf("failed to set up sandbox: %v", err)
+		}
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		err := cmd.Run()
+		timedOut := runCtx.Err() == context.DeadlineExceeded
+		cancel()
+		if timedOut {
+			warnings = append(warnings, fmt.Sprintf("%s timed out after %v", run.name, linterTimeout))
+			continue
+		}
+		if _, ok := err.(*exec.ExitError); err != nil && !ok {
+			warnings = append(warnings, fmt.Sprintf("failed to run %s: %v", run.name, err))
+			continue
+		}
+
+		// Linters exit with an error when they find issues, so only output
+		// that can't be parsed counts as a failure
+		found, parseErr := run.parse(stdout.Bytes(), stderr.Bytes(), run.dir)
+		if parseErr != nil {
+			message := strings.TrimSpace(stderr.String())
+			if message == "" {
+				message = parseErr.Error()
+			}
+			if len(message) > 500 {
+				message = message[:500] + "..."
+			}
+			warnings = append(warnings, fmt.Sprintf("%s failed: %s", run.name, message))
+			continue
+		}
+		for i := range found {
+			if found[i].Source == "" {
+				found[i].Source = run.name
+			}
+			if !filepath.IsAbs(found[i].FilePath) {
+				found[i].FilePath = filepath.Join(run.dir, found[i].FilePath)
+			}
+		}
+		sort.SliceStable(found, func(i, j int) bool {
+			if found[i].FilePath != found[j].FilePath {
+				return found[i].FilePath < found[j].FilePath
+			}
+			return found[i].Line < found[j].Line
+		})
+		issues = append(issues, found...)
+	}
+	return issues, warnings, nil
+}
+
+// mergeIssues drops the issues reported more than once, by the built-in
+// checks or by several linters. Issues are the same if they are on the
+// same line and have the same type, or for lint issues the same message.
+// The issue kept has the highest severity and lists every source.
+func mergeIssues(issues []IssueInfo) []IssueInfo {
+	var merged []IssueInfo
+	index := make(map[string]int)
+	for _, issue := range issues {
+		key := fmt.Sprintf("%s:%d:%s", issue.FilePath, issue.Line, issue.Type)
+		if issue.Type == "lint" {
+			key += ":" + strings.ToLower(issue.Message)
+		}
+		i, exists := index[key]
+		if !exists {
+			index[key] = len(merged)
+			merged = append(merged, issue)
+			continue
+		}
+		kept := &merged[i]
+		if kept.Severity != "" && severityRank(issue.Severity) > severityRank(kept.Severity) {
+			kept.Severity = issue.Severity
+		}
+		if kept.Rule == "" {
+			kept.Rule = issue.Rule
+		}
+		if !containsString(strings.Split(kept.Source, ", "), issue.Source) {
+			kept.Source += ", " + issue.Source
+		}
+	}
+	return merged
+}
+
+// withinTarget reports whether a file is the target file or lies in the
+// target directory
+func withinTarget(filePath, targetPath string, isDir bool) bool {
+	if !isDir {
+		return filepath.Clean(filePath) == filepath.Clean(targetPath)
+	}
+	rel, err := filepath.Rel(targetPath, filePath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// linterIssueType maps a linter rule to an issue type of the built-in
+// checks, so that the same issue found both ways is reported once. Other
+// rules are of type lint.
+func linterIssueType(rule string) string {
+	switch rule {
+	case "U1000", "unused", "deadcode", "varcheck", "structcheck", "no-unused-vars",
+		"@typescript-eslint/no-unused-vars", "F401", "F811", "F841":
+		return "unused"
+	case "gocyclo", "gocognit", "cyclop", "funlen", "nestif", "complexity",
+		"max-depth", "max-params", "max-lines-per-function", "C901":
+		return "complexity"
+	case "dupl":
+		return "duplication"
+	}
+	return "lint"
+}
+
+// levelSeverity maps the level of a diagnostic to a severity
+func levelSeverity(level string) string {
+	switch strings.ToLower(level) {
+	case "error", "fatal":
+		return "high"
+	case "info", "information", "note", "hint", "none":
+		return "low"
+	default:
+		return "medium"
+	}
+}
+
+// findGoTool returns a find function for a Go tool, which applies to
+// targets inside a Go module
+func findGoTool(name string) func(targetDir string) string {
+	return func(targetDir string) string {
+		if moduleRoot(targetDir) == "" {
+			return ""
+		}
+		path, _ := exec.LookPath(name)
+		return path
+	}
+}
+
+// goPackagePattern returns the package pattern of a target relative to the
+// module root: the target directory and below, or the package of a file
+func goPackagePattern(root, targetDir string, isDir bool) string {
+	rel, err := filepath.Rel(root, targetDir)
+	if err != nil {
+		rel = "."
+	}
+	pattern := "./" + filepath.ToSlash(rel)
+	if rel == "." {
+		pattern = "."
+	}
+	if isDir {
+		pattern = strings.TrimSuffix(pattern, "/.") + "/..."
+	}
+	return pattern
+}
+
+// golangciVersionRegex matches the version that golangci-lint prints
+var golangciVersionRegex = regexp.MustCompile(`version v?(\d+)\.`)
+
+// golangciMajorVersion returns the major version of golangci-lint, whose
+// output flags changed in version 2
+func golangciMajorVersion(binary string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, binary, "--version").Output()
+	if err != nil {
+		return 1
+	}
+	match := golangciVersionRegex.FindSubmatch(output)
+	if match == nil {
+		return 1
+	}
+	major, _ := strconv.Atoi(string(match[1]))
+	return major
+}
+
+// eslintConfigFiles are the names of ESLint configuration files
+var eslintConfigFiles = []string{
+	"eslint.config.js", "eslint.config.mjs", "eslint.config.cjs", "eslint.config.ts",
+	".eslintrc", ".eslintrc.js", ".eslintrc.cjs", ".eslintrc.json", ".eslintrc.yml", ".eslintrc.yaml",
+}
+
+// eslintProjectDir returns the nearest directory from targetDir up that has
+// an ESLint configuration, or "" if there is none
+func eslintProjectDir(targetDir string) string {
+	for dir := targetDir; ; dir = filepath.Dir(dir) {
+		for _, name := range eslintConfigFiles {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return dir
+			}
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+// findESLint returns the ESLint of the project, or else one on the path.
+// ESLint only applies to projects with a configuration.
+func findESLint(targetDir string) string {
+	projectDir := eslintProjectDir(targetDir)
+	if projectDir == "" {
+		return ""
+	}
+	for dir := targetDir; ; dir = filepath.Dir(dir) {
+		if path, err := exec.LookPath(filepath.Join(dir, "node_modules", ".bin", "eslint")); err == nil {
+			return path
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	path, _ := exec.LookPath("eslint")
+	return path
+}
+
+// goDiagnosticRegex matches a compiler error in the output of go vet
+var goDiagnosticRegex = regexp.MustCompile(`^(?:vet: )?(.+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// parseGoVet parses the output of go vet -json, which is a JSON object per
+// package, keyed by package and analyzer, and the text of the errors that
+// stopped the analysis. Depending on the Go version the objects are written
+// to stdout or, mixed with the errors, to stderr.
+func parseGoVet(stdout, stderr []byte, dir string) ([]IssueInfo, error) {
+	type vetDiagnostic struct {
+		Posn    string `json:"posn"`
+		Message string `json:"message"`
+	}
+	var issues []IssueInfo
+	var block []string
+	output := append(append([]byte{}, stdout...), stderr...)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case block != nil || line == "{":
+			block = append(block, line)
+			if line != "}" {
+				continue
+			}
+			var packages map[string]map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(strings.Join(block, "\n")), &packages); err != nil {
+				return nil, fmt.Errorf("invalid go vet output: %v", err)
+			}
+			block = nil
+			for _, analyzers := range packages {
+				for analyzer, raw := range analyzers {
+					var diagnostics []vetDiagnostic
+					if err := json.Unmarshal(raw, &diagnostics); err != nil {
+						// An analyzer that failed, as {"error": "..."}
+						continue
+					}
+					for _, diagnostic := range diagnostics {
+						file, lineNumber, column := splitPosition(diagnostic.Posn)
+						issues = append(issues, IssueInfo{
+							Type:     linterIssueType(analyzer),
+							Message:  diagnostic.Message,
+							FilePath: file,
+							Line:     lineNumber,
+							Column:   column,
+							Severity: "medium",
+							Rule:     analyzer,
+						})
+					}
+				}
+			}
+		case strings.HasPrefix(line, "#") || line == "":
+			continue
+		default:
+			if match := goDiagnosticRegex.FindStringSubmatch(line); match != nil {
+				lineNumber, _ := strconv.Atoi(match[2])
+				column, _ := strconv.Atoi(match[3])
+				issues = append(issues, IssueInfo{
+					Type:     "lint",
+					Message:  match[4],
+					FilePath: match[1],
+					Line:     lineNumber,
+					Column:   column,
+					Severity: "high",
+					Rule:     "compile",
+				})
+			}
+		}
+	}
+	if len(issues) == 0 && len(bytes.TrimSpace(stderr)) > 0 && !bytes.Contains(output, []byte("{")) {
+		// Nothing but an error, such as no packages matching the pattern
+		return nil, fmt.Errorf("go vet failed")
+	}
+	return issues, nil
+}
+
+// splitPosition splits a position such as file.go:12:5
+func splitPosition(position string) (string, int, int) {
+	parts := strings.Split(position, ":")
+	numbers := []int{}
+	for len(parts) > 1 && len(numbers) < 2 {
+		n, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			break
+		}
+		numbers = append([]int{n}, numbers...)
+		parts = parts[:len(parts)-1]
+	}
+	file := strings.Join(parts, ":")
+	switch len(numbers) {
+	case 2:
+		return file, numbers[0], numbers[1]
+	case 1:
+		return file, numbers[0], 0
+	}
+	return file, 0, 0
+}
+
+// parseStaticcheck parses the output of staticcheck -f json, a JSON
+// object per line
+func parseStaticcheck(stdout, stderr []byte, dir string) ([]IssueInfo, error) {
+	type staticcheckIssue struct {
+		Code     string `json:"code"`
+		Severity string `json:"severity"`
+		Location struct {
+			File   string `json:"file"`
+			Line   int    `json:"line"`
+			Column int    `json:"column"`
+		} `json:"location"`
+		Message string `json:"message"`
+	}
+	issues := []IssueInfo{}
+	for _, line := range strings.Split(string(stdout), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var found staticcheckIssue
+		if err := json.Unmarshal([]byte(line), &found); err != nil {
+			return nil, fmt.Errorf("invalid staticcheck output: %v", err)
+		}
+		if found.Severity == "ignored" {
+			continue
+		}
+		severity := levelSeverity(found.Severity)
+		if found.Code == "compile" {
+			severity = "high"
+		}
+		issues = append(issues, IssueInfo{
+			Type:     linterIssueType(found.Code),
+			Message:  found.Message,
+			FilePath: found.Location.File,
+			Line:     found.Location.Line,
+			Column:   found.Location.Column,
+			Severity: severity,
+			Rule:     found.Code,
+		})
+	}
+	if len(issues) == 0 && len(bytes.TrimSpace(stderr)) > 0 {
+		return nil, fmt.Errorf("staticcheck failed")
+	}
+	return issues, nil
+}
+
+// parseGolangci parses the JSON report of golangci-lint
+func parseGolangci(stdout, stderr []byte, dir string) ([]IssueInfo, error) {
+	var report struct {
+		Issues []struct {
+			FromLinter  string   `json:"FromLinter"`
+			Text        string   `json:"Text"`
+			Severity    string   `json:"Severity"`
+			SourceLines []string `json:"SourceLines"`
+			Pos         struct {
+				Filename string `json:"Filename"`
+				Line     int    `json:"Line"`
+				Column   int    `json:"Column"`
+			} `json:"Pos"`
+		} `json:"Issues"`
+	}
+	start := bytes.IndexByte(stdout, '{')
+	if start < 0 {
+		return nil, fmt.Errorf("no report from golangci-lint")
+	}
+	if err := json.NewDecoder(bytes.NewReader(stdout[start:])).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid golangci-lint output: %v", err)
+	}
+	issues := []IssueInfo{}
+	for _, found := range report.Issues {
+		severity := levelSeverity(found.Severity)
+		if found.Severity == "" && found.FromLinter == "typecheck" {
+			severity = "high"
+		}
+		snippet := ""
+		if len(found.SourceLines) > 0 {
+			snippet = strings.TrimSpace(found.SourceLines[0])
+		}
+		issues = append(issues, IssueInfo{
+			Type:     linterIssueType(found.FromLinter),
+			Message:  found.Text,
+			FilePath: found.Pos.Filename,
+			Line:     found.Pos.Line,
+			Column:   found.Pos.Column,
+			Snippet:  snippet,
+			Severity: severity,
+			Rule:     found.FromLinter,
+		})
+	}
+	return issues, nil
+}
+
+// parseESLint parses the output of eslint -f json, where severity 2 is an
+// error and 1 a warning
+func parseESLint(stdout, stderr []byte, dir string) ([]IssueInfo, error) {
+	var files []struct {
+		FilePath string `json:"filePath"`
+		Messages []struct {
+			RuleID   string `json:"ruleId"`
+			Severity int    `json:"severity"`
+			Fatal    bool   `json:"fatal"`
+			Message  string `json:"message"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(stdout, &files); err != nil {
+		return nil, fmt.Errorf("invalid eslint output: %v", err)
+	}
+	issues := []IssueInfo{}
+	for _, file := range files {
+		for _, message := range file.Messages {
+			severity := "medium"
+			if message.Severity == 2 || message.Fatal {
+				severity = "high"
+			}
+			issues = append(issues, IssueInfo{
+				Type:     linterIssueType(message.RuleID),
+				Message:  message.Message,
+				FilePath: file.FilePath,
+				Line:     message.Line,
+				Column:   message.Column,
+				Severity: severity,
+				Rule:     message.RuleID,
+			})
+		}
+	}
+	return issues, nil
+}
+
+// parseRuff parses the output of ruff check --output-format json. Syntax
+// errors, which have no code, are high severity, likely bugs (pyflakes
+// and bugbear) medium, and style rules low.
+func parseRuff(stdout, stderr []byte, dir string) ([]IssueInfo, error) {
+	var diagnostics []struct {
+		Code     *string `json:"code"`
+		Message  string  `json:"message"`
+		Filename string  `json:"filename"`
+		Location struct {
+			Row    int `json:"row"`
+			Column int `json:"column"`
+		} `json:"location"`
+	}
+	if err := json.Unmarshal(stdout, &diagnostics); err != nil {
+		return nil, fmt.Errorf("invalid ruff output: %v", err)
+	}
+	issues := []IssueInfo{}
+	for _, diagnostic := range diagnostics {
+		code := ""
+		if diagnostic.Code != nil {
+			code = *diagnostic.Code
+		}
+		severity := "low"
+		switch {
+		case code == "" || code == "E999":
+			severity = "high"
+		case strings.HasPrefix(code, "F"), strings.HasPrefix(code, "B"):
+			severity = "medium"
+		}
+		issues = append(issues, IssueInfo{
+			Type:     linterIssueType(code),
+			Message:  diagnostic.Message,
+			FilePath: diagnostic.Filename,
+			Line:     diagnostic.Location.Row,
+			Column:   diagnostic.Location.Column,
+			Severity: severity,
+			Rule:     code,
+		})
+	}
+	return issues, nil
+}
+
+// parseSARIF parses the SARIF log that a custom linter prints, taking each
+// run's tool as the source of its results
+func parseSARIF(stdout, stderr []byte, dir string) ([]IssueInfo, error) {
+	log, err := sarif.Parse(stdout)
+	if err != nil {
+		return nil, err
+	}
+	issues := []IssueInfo{}
+	for _, run := range log.Runs {
+		for _, result := range run.Results {
+			if len(result.Locations) == 0 {
+				continue
+			}
+			location := result.Locations[0].PhysicalLocation
+			issue := IssueInfo{
+				Type:     linterIssueType(result.RuleID),
+				Message:  result.Message.Text,
+				FilePath: run.FilePath(location.ArtifactLocation, dir),
+				Severity: levelSeverity(result.Level),
+				Source:   run.Tool.Driver.Name,
+				Rule:     result.RuleID,
+			}
+			if region := location.Region; region != nil {
+				issue.Line = region.StartLine
+				issue.Column = region.StartColumn
+				if region.Snippet != nil {
+					issue.Snippet = strings.TrimSpace(region.Snippet.Text)
+				}
+			}
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
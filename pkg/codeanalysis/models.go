@@ -1,6 +1,10 @@
 package codeanalysis
 
-import "time"
+import (
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
+)
 
 // FileAnalysisResult represents the result of analyzing a single file
 type FileAnalysisResult struct {
@@ -9,7 +13,8 @@ type FileAnalysisResult struct {
 	FunctionCount   int
 	ClassCount      int
 	CommentLines    int
-	ComplexityScore float64
+	ComplexityScore float64 // Average cyclomatic complexity of the functions
+	Functions       []FunctionInfo
 	TopFunctions    []FunctionInfo
 	Dependencies    []string
 	TimeTaken       time.Duration
@@ -17,9 +22,24 @@ type FileAnalysisResult struct {
 
 // FunctionInfo represents information about a function
 type FunctionInfo struct {
-	Name       string
-	Line       int
-	Complexity float64
+	Name        string
+	Line        int
+	Complexity  float64 // Cyclomatic complexity, for ranking
+	Cyclomatic  int
+	Cognitive   int
+	LinesOfCode int // Lines with code, without blank and comment lines
+	Parameters  int
+	Nesting     int // Maximum nesting depth of control structures
+}
+
+// Thresholds are the limits on the metrics of a function above which
+// find_issues reports it as too complex
+type Thresholds struct {
+	Cyclomatic int
+	Cognitive  int
+	Lines      int
+	Parameters int
+	Nesting    int
 }
 
 // DirectoryAnalysisResult represents the result of analyzing a directory
@@ -47,16 +67,118 @@ type IssuesResult struct {
 	TotalIssues  int
 	IssuesByType map[string]int
 	Issues       []IssueInfo
+	Linters      []string // External linters that ran
+	Warnings     []string // Linters that were skipped or failed
+	Baselined    int      // Issues left out because the baseline has them
+	Unchanged    int      // Issues left out because they aren't on changed lines
 	TimeTaken    time.Duration
 }
 
 // IssueInfo represents information about an issue
 type IssueInfo struct {
-	Type     string
-	Message  string
-	FilePath string
-	Line     int
-	Snippet  string
+	Type       string
+	Message    string
+	FilePath   string
+	Line       int
+	Column     int // Column of the issue, if known
+	Snippet    string
+	Severity   string // low, medium, or high, if the check rates its issues
+	Confidence string // low, medium, or high, for issues that may be false positives
+	Source     string // codeanalysis, or the linters that reported the issue
+	Rule       string // Rule of the linter, such as SA4006
+}
+
+// IssueOptions holds the options of find_issues
+type IssueOptions struct {
+	Severity        string // Minimum severity of the issues to report
+	Thresholds      Thresholds
+	IncludeExported bool // Also report unused exported Go declarations
+	Linters         []*linterRun
+	Sandbox         sandbox.Config // Limits of the linters
+	WritableRoot    string         // Directory the linters may write to
+	Root            string         // Directory that baselines give paths relative to
+	Baseline        string         // Baseline file of issues to leave out, if set
+	UpdateBaseline  bool           // Save the issues found to the baseline instead
+	ChangedOnly     bool           // Report only issues on lines changed since BaseRef
+	BaseRef         string         // Git ref that changes are relative to
+}
+
+// Baseline is a file of known issues, which later runs leave out. Issues
+// are identified by fingerprints that don't depend on their line numbers.
+type Baseline struct {
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	Issues  []BaselineFinding `json:"issues"`
+}
+
+// BaselineFinding is an issue saved in a baseline
+type BaselineFinding struct {
+	Fingerprint string `json:"fingerprint"`
+	FilePath    string `json:"file"` // Relative to the root of the run that saved it
+	Line        int    `json:"line"`
+	Rule        string `json:"rule"`
+	Message     string `json:"message"`
+}
+
+// DuplicatesResult represents the result of finding duplicate code
+type DuplicatesResult struct {
+	FilesAnalyzed   int
+	MinTokens       int
+	TotalLines      int // Lines with code in the files analyzed
+	DuplicatedLines int // Lines with code inside a clone
+	Groups          []CloneGroup
+	TimeTaken       time.Duration
+}
+
+// CloneGroup represents fragments of code that are the same once
+// identifiers and literals are abstracted
+type CloneGroup struct {
+	Tokens     int
+	Lines      int
+	Similarity float64 // Percentage of tokens whose source text is the same in each fragment
+	Locations  []CloneLocation
+}
+
+// CloneLocation represents the location of a fragment of a clone group
+type CloneLocation struct {
+	FilePath  string
+	StartLine int
+	EndLine   int
+}
+
+// DependencyGraph represents the import graph of the packages of a Go module
+type DependencyGraph struct {
+	ModulePath string           `json:"module"`
+	ModuleDir  string           `json:"module_dir"`
+	Packages   []PackageNode    `json:"packages"`
+	Cycles     []ImportCycle    `json:"cycles"`
+	Violations []LayerViolation `json:"violations"`
+	TimeTaken  time.Duration    `json:"-"`
+}
+
+// PackageNode represents a package of the module, with paths relative to
+// the module
+type PackageNode struct {
+	Path            string   `json:"path"`
+	ImportPath      string   `json:"import_path"`
+	Imports         []string `json:"imports"` // Packages of the module it imports
+	ExternalImports []string `json:"external_imports"`
+	FanIn           int      `json:"fan_in"`  // Packages of the module that import it
+	FanOut          int      `json:"fan_out"` // Packages of the module it imports
+	Depth           int      `json:"depth"`   // Longest chain of imports of the module's packages below it
+}
+
+// ImportCycle represents packages that import each other
+type ImportCycle struct {
+	Packages []string `json:"packages"`
+	Path     []string `json:"path"` // A cycle through the packages, such as [a b a]
+}
+
+// LayerViolation represents an import that breaks a layering rule
+type LayerViolation struct {
+	Rule string `json:"rule"`
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 // ImprovementsResult represents the result of suggesting improvements
@@ -69,6 +191,7 @@ type ImprovementsResult struct {
 
 // SuggestionInfo represents information about a suggestion
 type SuggestionInfo struct {
+	ID          string // Identifies the suggestion for apply_fixes
 	Type        string
 	Title       string
 	Description string
@@ -76,4 +199,38 @@ type SuggestionInfo struct {
 	Line        int
 	Before      string
 	After       string
+	Fix         *SuggestionFix // Mechanical fix, if the suggestion has one
+}
+
+// SuggestionFix is a mechanical fix of a suggestion: edits of its file, or
+// the rename of an identifier with all its references
+type SuggestionFix struct {
+	Kind    string     // errorf, builder, or rename
+	Edits   []TextEdit // Edits of the suggestion's file
+	Imports []string   // Import paths that the edits use
+	Rename  *RenameFix
+}
+
+// TextEdit replaces the bytes from Start to End of a file with NewText
+type TextEdit struct {
+	Start   int
+	End     int
+	NewText string
+}
+
+// RenameFix renames the identifier declared on a line
+type RenameFix struct {
+	Line    int
+	Column  int // One-based column, counted in characters
+	OldName string
+	NewName string
+}
+
+// FixesResult represents the result of applying the fixes of suggestions
+type FixesResult struct {
+	Applied  []string // Titles of the suggestions fixed
+	Skipped  []string // Suggestions that couldn't be fixed, and why
+	Files    []string // Files changed
+	Diff     string
+	Warnings []string
 }
@@ -0,0 +1,253 @@
+package codeanalysis
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
+)
+
+// pythonLine is a logical line of Python, joined across brackets and
+// backslashes, with comments removed and strings replaced by ""
+type pythonLine struct {
+	text    string
+	indent  int
+	line    int // First physical line
+	endLine int // Last physical line
+	first   int // Index of the first token
+}
+
+var (
+	pythonKeywordRegex = regexp.MustCompile(`\b(if|elif|for|while|except|and|or|case)\b`)
+	pythonStartRegex   = regexp.MustCompile(`^(?:async\s+)?(if|elif|else|for|while|try|except|finally|with|match|case)\b`)
+)
+
+// pythonLines joins the tokens of parsed Python source into logical lines
+func pythonLines(f *syntax.File) []pythonLine {
+	var lines []pythonLine
+	var current strings.Builder
+	depth := 0
+	for i, tok := range f.Tokens {
+		continued := i > 0 && (depth > 0 || f.Tokens[i-1].Text == `\` && f.Tokens[i-1].Kind == syntax.Punct)
+		if i == 0 || !continued && tok.Line > f.Tokens[i-1].EndLine {
+			if len(lines) > 0 {
+				lines[len(lines)-1].text = current.String()
+			}
+			current.Reset()
+			indent := 0
+			for _, c := range f.Line(tok.Line) {
+				if c == '\t' {
+					indent += 8
+				} else if c == ' ' {
+					indent++
+				} else {
+					break
+				}
+			}
+			lines = append(lines, pythonLine{indent: indent, line: tok.Line, first: i})
+		} else if tok.Offset > f.Tokens[i-1].End {
+			current.WriteByte(' ')
+		}
+		lines[len(lines)-1].endLine = tok.EndLine
+
+		switch {
+		case tok.Kind == syntax.String:
+			current.WriteString(`""`)
+		case tok.Kind == syntax.Punct && tok.Text == `\`:
+		default:
+			current.WriteString(tok.Text)
+		}
+		if tok.Kind == syntax.Punct {
+			switch tok.Text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth = max(0, depth-1)
+			}
+		}
+	}
+	if len(lines) > 0 {
+		lines[len(lines)-1].text = current.String()
+	}
+
+	// Leave out the lines of docstrings alone
+	kept := lines[:0]
+	for _, line := range lines {
+		if line.text = strings.TrimSpace(line.text); line.text != `""` {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// pythonFunctionMetrics measures the functions and methods in parsed Python
+// source. Methods are named by their class, and the body of a nested
+// function counts only towards that function.
+func pythonFunctionMetrics(f *syntax.File) []FunctionInfo {
+	lines := pythonLines(f)
+
+	// The lines that define functions and classes, by the index of their
+	// first token
+	byToken := make(map[int]int)
+	for i, line := range lines {
+		byToken[line.first] = i
+	}
+	definitions := make(map[int]bool)
+	var defs []int
+	var symbols []syntax.Symbol
+	for _, symbol := range f.Symbols {
+		i, ok := byToken[symbol.BodyOpen]
+		for j := symbol.BodyOpen; !ok && j >= 0; j-- {
+			i, ok = byToken[j]
+		}
+		if !ok {
+			continue
+		}
+		definitions[i] = true
+		if symbol.IsFunction() {
+			defs = append(defs, i)
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	var functions []FunctionInfo
+	for k, symbol := range symbols {
+		fn := FunctionInfo{Name: symbol.Name, Line: symbol.Line}
+		method := symbol.Kind == syntax.KindMethod
+		if method {
+			fn.Name = symbol.Container + "." + fn.Name
+		}
+		fn.Parameters = pythonParameters(f, symbol, method)
+		measurePythonBody(lines, defs[k], definitions, symbol.Name, &fn)
+		fn.Complexity = float64(fn.Cyclomatic)
+		functions = append(functions, fn)
+	}
+	return functions
+}
+
+// pythonParameters counts the parameters of a def, without the self or
+// cls of a method and the * and / separators
+func pythonParameters(f *syntax.File, symbol syntax.Symbol, method bool) int {
+	if symbol.ParamsOpen < 0 {
+		return 0
+	}
+	count := 0
+	depth := 0
+	var param []syntax.Token
+	for _, tok := range f.Tokens[symbol.ParamsOpen+1 : symbol.ParamsClose+1] {
+		if depth == 0 && (tok.Text == "," || tok.Text == ")") && tok.Kind == syntax.Punct {
+			switch {
+			case len(param) == 0:
+			case len(param) == 1 && (param[0].Text == "*" || param[0].Text == "/"):
+			case count == 0 && method && (param[0].Text == "self" || param[0].Text == "cls"):
+				method = false
+			default:
+				count++
+			}
+			param = nil
+			continue
+		}
+		switch tok.Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		}
+		param = append(param, tok)
+	}
+	return count
+}
+
+// measurePythonBody computes the cyclomatic and cognitive complexity,
+// lines of code, and nesting depth of the function defined on the line at
+// index def. definitions has the indexes of the lines that define
+// functions and classes.
+func measurePythonBody(lines []pythonLine, def int, definitions map[int]bool, name string, fn *FunctionInfo) {
+	defIndent := lines[def].indent
+	fn.Cyclomatic = 1
+	fn.LinesOfCode = lines[def].endLine - lines[def].line + 1
+	recursion := regexp.MustCompile(`(?:^|[^\w.]|\bself\.|\bcls\.)` + regexp.QuoteMeta(name) + `\s*\(`)
+
+	// Enclosing structures of the current line, and the keyword of the
+	// last structure at each indentation, to tell if from loop else
+	type structure struct {
+		indent int
+		nests  bool
+	}
+	var stack []structure
+	lastKeyword := make(map[int]string)
+
+	for i := def + 1; i < len(lines) && lines[i].indent > defIndent; i++ {
+		line := lines[i]
+		if definitions[i] {
+			// Skip nested functions and classes, which are measured
+			// separately
+			for i+1 < len(lines) && lines[i+1].indent > line.indent {
+				i++
+			}
+			continue
+		}
+		fn.LinesOfCode += line.endLine - line.line + 1
+
+		for len(stack) > 0 && line.indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		nesting := 0
+		for _, s := range stack {
+			if s.nests {
+				nesting++
+			}
+		}
+
+		// The structure that starts the line
+		keyword := ""
+		if match := pythonStartRegex.FindStringSubmatch(line.text); match != nil && strings.HasSuffix(line.text, ":") {
+			keyword = match[1]
+			switch keyword {
+			case "if", "for", "while", "except", "match":
+				fn.Cognitive += 1 + nesting
+			case "elif":
+				fn.Cognitive++
+			case "else":
+				if last := lastKeyword[line.indent]; last == "if" || last == "elif" {
+					fn.Cognitive++
+				}
+			}
+			switch keyword {
+			case "if", "elif", "else", "for", "while", "except", "match":
+				stack = append(stack, structure{indent: line.indent, nests: true})
+				fn.Nesting = max(fn.Nesting, nesting+1)
+			default:
+				stack = append(stack, structure{indent: line.indent})
+			}
+			lastKeyword[line.indent] = keyword
+		}
+
+		// Decision points and boolean operator sequences in the line
+		lastOp := ""
+		for j, match := range pythonKeywordRegex.FindAllString(line.text, -1) {
+			switch match {
+			case "if", "elif", "for", "while", "except":
+				fn.Cyclomatic++
+				if match == "if" && (j > 0 || keyword != "if") {
+					// A conditional expression or comprehension condition
+					fn.Cognitive += 1 + nesting
+				}
+			case "case":
+				if j == 0 && keyword == "case" && !strings.HasPrefix(line.text, "case _") {
+					fn.Cyclomatic++
+				}
+			case "and", "or":
+				fn.Cyclomatic++
+				if match != lastOp {
+					fn.Cognitive++
+				}
+				lastOp = match
+			}
+		}
+
+		if recursion.MatchString(line.text) {
+			fn.Cognitive++
+		}
+	}
+}
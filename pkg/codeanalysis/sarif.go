@@ -0,0 +1,124 @@
+package codeanalysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/sarif"
+)
+
+// informationURI is the home page of the tool in SARIF logs
+const informationURI = "https://github.com/Code-Monger/CodeSpinneret"
+
+// issueRules describes the rules of the built-in checks, one per issue type
+var issueRules = map[string]string{
+	"complexity":  "Function exceeds a complexity threshold",
+	"duplication": "Block of code duplicated elsewhere",
+	"naming":      "Name doesn't follow the naming conventions of the language",
+	"comments":    "Missing or misplaced comment",
+	"unused":      "Code that is never used",
+}
+
+// severityLevel maps a severity to a SARIF level
+func severityLevel(severity string) string {
+	switch severity {
+	case "high":
+		return "error"
+	case "low":
+		return "note"
+	default:
+		return "warning"
+	}
+}
+
+// issueRule returns the source of an issue, the first if several reported
+// it, and its rule: the linter's rule, or for the built-in checks the
+// issue type
+func issueRule(issue IssueInfo) (string, string) {
+	source := strings.Split(issue.Source, ", ")[0]
+	if source == "" {
+		source = builtinSource
+	}
+	ruleID := issue.Rule
+	if ruleID == "" {
+		ruleID = issue.Type
+	}
+	return source, ruleID
+}
+
+// formatIssuesSARIF renders issues as a SARIF 2.1.0 log, with a run for
+// the built-in checks and one for each linter. An issue that several
+// sources reported goes in the run of the first. File paths are relative
+// to root when they are below it.
+func formatIssuesSARIF(result *IssuesResult, root string) (string, error) {
+	var runs []sarif.Run
+	runIndex := make(map[string]int)
+	fingerprinter := sarif.NewFingerprinter()
+
+	for _, issue := range result.Issues {
+		source, ruleID := issueRule(issue)
+		i, exists := runIndex[source]
+		if !exists {
+			driver := sarif.Driver{Name: source}
+			if source == builtinSource {
+				driver = sarif.Driver{Name: "CodeSpinneret " + builtinSource, InformationURI: informationURI}
+			}
+			i = len(runs)
+			runIndex[source] = i
+			runs = append(runs, sarif.NewRun(driver, root))
+		}
+		run := &runs[i]
+
+		rule := sarif.Rule{ID: ruleID}
+		if description, exists := issueRules[ruleID]; exists && issue.Rule == "" {
+			rule.ShortDescription = &sarif.Message{Text: description}
+		}
+		ruleIndex := run.AddRule(rule)
+
+		location := sarif.PhysicalLocation{ArtifactLocation: run.ArtifactLocationOf(issue.FilePath)}
+		if issue.Line > 0 {
+			location.Region = &sarif.Region{StartLine: issue.Line, StartColumn: issue.Column}
+			if issue.Snippet != "" {
+				location.Region.Snippet = &sarif.Message{Text: issue.Snippet}
+			}
+		}
+
+		properties := map[string]interface{}{"issueType": issue.Type}
+		if issue.Severity != "" {
+			properties["severity"] = issue.Severity
+		}
+		if issue.Confidence != "" {
+			properties["confidence"] = issue.Confidence
+		}
+		if strings.Contains(issue.Source, ", ") {
+			properties["sources"] = strings.Split(issue.Source, ", ")
+		}
+
+		level := severityLevel(issue.Severity)
+		if issue.Severity == "" && issue.Confidence == "low" {
+			level = "note"
+		}
+
+		run.Results = append(run.Results, sarif.Result{
+			RuleID:              ruleID,
+			RuleIndex:           &ruleIndex,
+			Level:               level,
+			Message:             sarif.Message{Text: issue.Message},
+			Locations:           []sarif.Location{{PhysicalLocation: location}},
+			PartialFingerprints: fingerprinter.Fingerprints(source+"/"+ruleID, issue.FilePath, location.ArtifactLocation.URI, issue.Line),
+			Properties:          properties,
+		})
+	}
+
+	// A log without issues still has the run of the built-in checks
+	if len(runs) == 0 {
+		runs = append(runs, sarif.NewRun(sarif.Driver{Name: "CodeSpinneret " + builtinSource, InformationURI: informationURI}, root))
+	}
+
+	data, err := json.MarshalIndent(sarif.NewLog(runs...), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode SARIF: %v", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,254 @@
+package codeanalysis
+
+import (
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
+)
+
+// braceFunctionMetrics measures the functions in a parsed file of a
+// language other than Go and Python. The body of a nested function counts
+// only towards that function.
+func braceFunctionMetrics(f *syntax.File) []FunctionInfo {
+	var found []syntax.Symbol
+	for _, symbol := range f.Symbols {
+		if symbol.IsFunction() && symbol.BodyOpen >= 0 {
+			found = append(found, symbol)
+		}
+	}
+
+	// Nested function bodies, by the token that opens them
+	bodies := make(map[int]int)
+	for _, fn := range found {
+		bodies[fn.BodyOpen] = fn.BodyClose
+	}
+
+	var functions []FunctionInfo
+	for _, fn := range found {
+		info := FunctionInfo{
+			Name:       fn.Name,
+			Line:       fn.Line,
+			Parameters: braceParameters(f, fn),
+		}
+		if info.Name == "" {
+			info.Name = "(anonymous)"
+		}
+		measureBraceBody(f, fn, bodies, &info)
+		info.Complexity = float64(info.Cyclomatic)
+		functions = append(functions, info)
+	}
+	return functions
+}
+
+// braceParameters counts the parameters of a function
+func braceParameters(f *syntax.File, fn syntax.Symbol) int {
+	if fn.ParamsOpen < 0 {
+		if fn.ParamsClose >= 0 {
+			// A single unparenthesized lambda parameter
+			return 1
+		}
+		return 0
+	}
+	params := f.Tokens[fn.ParamsOpen+1 : fn.ParamsClose]
+	if len(params) == 0 || (len(params) == 1 && params[0].Text == "void" && f.Language.Family == "C/C++") {
+		return 0
+	}
+	count := 1
+	depth := 0
+	for _, tok := range params {
+		switch tok.Text {
+		case "(", "[", "{", "<":
+			depth++
+		case ")", "]", "}", ">":
+			depth--
+		case ",":
+			if depth == 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// measureBraceBody computes the cyclomatic and cognitive complexity, lines
+// of code, and nesting depth of a function from its tokens, skipping
+// nested functions. Structures nest by their braces, so the blocks of
+// Ruby, which end with end, don't add nesting.
+func measureBraceBody(f *syntax.File, fn syntax.Symbol, bodies map[int]int, info *FunctionInfo) {
+	tokens := f.Tokens
+	info.Cyclomatic = 1
+	lines := map[int]bool{fn.Line: true}
+	signature := fn.ParamsOpen
+	if signature < 0 {
+		signature = fn.ParamsClose
+	}
+	if signature < 0 {
+		signature = fn.BodyOpen
+	}
+	for i := signature; i < fn.BodyOpen; i++ {
+		lines[tokens[i].Line] = true
+	}
+
+	// Braces in the body that open a nested structure, and whether the
+	// last closed brace closed a do loop
+	type brace struct {
+		nests bool
+		do    bool
+	}
+	var braces []brace
+	nesting := 0
+	pending := "" // Keyword of a structure whose block hasn't opened yet
+	pendingDepth := 0
+	parenDepth := 0
+	closedDo := false
+	lastOp := ""
+
+	start := fn.BodyOpen
+	if tokens[start].Text != "{" {
+		// The arrow of an expression body, or the end of a Ruby signature
+		lines[tokens[start].Line] = true
+		start++
+	}
+	for i := start; i <= fn.BodyClose; i++ {
+		if close, nested := bodies[i]; nested && i != fn.BodyOpen {
+			// Skip nested functions, which are measured separately
+			i = close
+			lastOp = ""
+			continue
+		}
+
+		tok := tokens[i]
+		lines[tok.Line] = true
+		text := tok.Text
+		if tok.Kind == syntax.String || tok.Kind == syntax.Number {
+			// Literals are neither keywords nor operators
+			text = ""
+		}
+		next := ""
+		if i+1 < len(tokens) {
+			next = tokens[i+1].Text
+		}
+		prev := ""
+		if i > 0 {
+			prev = tokens[i-1].Text
+		}
+		keyword := tok.Kind == syntax.Identifier && f.Language.IsKeyword(text)
+
+		switch text {
+		case "{":
+			nests := pending != "" && pending != "try" && pending != "finally" && parenDepth == pendingDepth
+			braces = append(braces, brace{nests: nests, do: pending == "do"})
+			if nests {
+				nesting++
+				info.Nesting = max(info.Nesting, nesting)
+			}
+			pending = ""
+			lastOp = ""
+			continue
+		case "}":
+			closedDo = false
+			if len(braces) > 0 {
+				top := braces[len(braces)-1]
+				braces = braces[:len(braces)-1]
+				if top.nests {
+					nesting--
+				}
+				closedDo = top.do
+			}
+			lastOp = ""
+			continue
+		case "(":
+			parenDepth++
+		case ")":
+			parenDepth--
+		case ";":
+			if parenDepth == pendingDepth {
+				pending = ""
+			}
+			lastOp = ""
+		case ",":
+			lastOp = ""
+		}
+
+		switch text {
+		case "if":
+			info.Cyclomatic++
+			if prev == "else" {
+				info.Cognitive++
+			} else {
+				info.Cognitive += 1 + nesting
+			}
+			pending, pendingDepth = text, parenDepth
+		case "else":
+			if next != "if" {
+				info.Cognitive++
+				pending, pendingDepth = text, parenDepth
+			}
+		case "for", "foreach", "switch", "catch":
+			if text != "switch" {
+				info.Cyclomatic++
+			}
+			info.Cognitive += 1 + nesting
+			pending, pendingDepth = text, parenDepth
+		case "while":
+			info.Cyclomatic++
+			if prev == "}" && closedDo {
+				// The condition of a do loop, counted at the do
+				break
+			}
+			info.Cognitive += 1 + nesting
+			pending, pendingDepth = text, parenDepth
+		case "do":
+			info.Cognitive += 1 + nesting
+			pending, pendingDepth = text, parenDepth
+		case "try", "finally":
+			pending, pendingDepth = text, parenDepth
+		case "case":
+			info.Cyclomatic++
+		case "goto":
+			info.Cognitive++
+		case "break", "continue":
+			if i+1 < len(tokens) && tokens[i+1].Kind == syntax.Identifier && !f.Language.IsKeyword(next) &&
+				tokens[i+1].Line == tok.Line {
+				// A jump to a label
+				info.Cognitive++
+			}
+		case "?":
+			switch next {
+			case ":", ")", ",", ">", "=", ";", "]", ".", "extends", "super":
+				// An optional parameter or property, or a wildcard type
+			default:
+				info.Cyclomatic++
+				info.Cognitive += 1 + nesting
+			}
+		case "&&", "||", "??":
+			info.Cyclomatic++
+			if text != lastOp {
+				info.Cognitive++
+			}
+			lastOp = text
+		default:
+			switch {
+			case keyword && (text == "elsif" || text == "elseif"):
+				info.Cyclomatic++
+				info.Cognitive++
+				pending, pendingDepth = text, parenDepth
+			case keyword && (text == "unless" || text == "until" || text == "rescue"):
+				info.Cyclomatic++
+				info.Cognitive += 1 + nesting
+				pending, pendingDepth = text, parenDepth
+			case keyword && text == "when":
+				info.Cyclomatic++
+			case keyword && (text == "and" || text == "or"):
+				info.Cyclomatic++
+				if text != lastOp {
+					info.Cognitive++
+				}
+				lastOp = text
+			case text == fn.Name && next == "(" && prev != "function" && prev != "new" && prev != "def":
+				// A recursive call
+				info.Cognitive++
+			}
+		}
+	}
+
+	info.LinesOfCode = len(lines)
+}
@@ -3,6 +3,8 @@ package codeanalysis
 import (
 	"fmt"
 	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
 )
 
 // getLanguageFromExtension determines the programming language from a file extension
@@ -97,8 +99,9 @@ func isCodeFile(ext string) bool {
 	return codeExtensions[ext]
 }
 
-// findComplexityIssues finds complexity issues in a file
-func findComplexityIssues(filePath, content, language, severityLevel string) []IssueInfo {
+// findComplexityIssues finds the functions of a file whose metrics exceed
+// the thresholds, rated by how far they exceed them
+func findComplexityIssues(filePath, content, language, severityLevel string, thresholds Thresholds) []IssueInfo {
 	var issues []IssueInfo
 
 	// Analyze file
@@ -107,74 +110,55 @@ func findComplexityIssues(filePath, content, language, severityLevel string) []I
 		return issues
 	}
 
-	// Check overall complexity
-	complexityThreshold := 5.0
-	if severityLevel == "medium" {
-		complexityThreshold = 3.0
-	} else if severityLevel == "low" {
-		complexityThreshold = 2.0
-	}
-
-	if fileResult.ComplexityScore > complexityThreshold {
+	// Check every function against the thresholds
+	for _, fn := range fileResult.Functions {
+		exceeded, severity := exceededThresholds(fn, thresholds)
+		if len(exceeded) == 0 || severityRank(severity) < severityRank(severityLevel) {
+			continue
+		}
 		issues = append(issues, IssueInfo{
 			Type:     "complexity",
-			Message:  fmt.Sprintf("File has high complexity score (%.2f)", fileResult.ComplexityScore),
+			Message:  fmt.Sprintf("Function '%s' is too complex: %s", fn.Name, strings.Join(exceeded, ", ")),
 			FilePath: filePath,
-			Line:     1,
+			Line:     fn.Line,
 			Snippet:  "",
+			Severity: severity,
 		})
 	}
 
-	// Check function complexity
-	for _, fn := range fileResult.TopFunctions {
-		if fn.Complexity > complexityThreshold {
-			issues = append(issues, IssueInfo{
-				Type:     "complexity",
-				Message:  fmt.Sprintf("Function '%s' has high complexity (%.2f)", fn.Name, fn.Complexity),
-				FilePath: filePath,
-				Line:     fn.Line,
-				Snippet:  "",
-			})
-		}
-	}
-
 	return issues
 }
 
 // findDuplicationIssues finds code duplication issues in a file
 func findDuplicationIssues(filePath, content, language, severityLevel string) []IssueInfo {
 	var issues []IssueInfo
+	syntaxLanguage, ok := syntax.LanguageByName(language)
+	if !ok {
+		return issues
+	}
 
-	// This is a simplified implementation
-	// In a real implementation, you would use a more sophisticated algorithm
-	// to detect code duplication
-
-	// Split content into lines
+	// Find the clones within the file, and report each copy
+	file := cloneFile{path: filePath, language: language, tokens: cloneTokens(content, syntaxLanguage, make(map[string]int))}
 	lines := strings.Split(content, "\n")
-
-	// Look for repeated blocks of code (simplified)
-	blockSize := 5 // Look for blocks of 5 lines
-	if len(lines) > blockSize*2 {
-		for i := 0; i <= len(lines)-blockSize; i++ {
-			block1 := strings.Join(lines[i:i+blockSize], "\n")
-
-			for j := i + blockSize; j <= len(lines)-blockSize; j++ {
-				block2 := strings.Join(lines[j:j+blockSize], "\n")
-
-				// If blocks are similar
-				if strings.TrimSpace(block1) == strings.TrimSpace(block2) {
-					issues = append(issues, IssueInfo{
-						Type:     "duplication",
-						Message:  "Duplicated code block",
-						FilePath: filePath,
-						Line:     i + 1,
-						Snippet:  block1,
-					})
-
-					// Only report one instance
-					break
+	for _, group := range findClones([]cloneFile{file}, DefaultMinTokens) {
+		for i, location := range group.Locations {
+			var others []string
+			for j, other := range group.Locations {
+				if j != i {
+					others = append(others, fmt.Sprintf("%d-%d", other.StartLine, other.EndLine))
 				}
 			}
+			snippet := ""
+			if location.StartLine <= len(lines) {
+				snippet = strings.TrimSpace(lines[location.StartLine-1])
+			}
+			issues = append(issues, IssueInfo{
+				Type:     "duplication",
+				Message:  fmt.Sprintf("Duplicated code block of %d tokens (%.1f%% similar), also at lines %s", group.Tokens, group.Similarity, strings.Join(others, ", ")),
+				FilePath: filePath,
+				Line:     location.StartLine,
+				Snippet:  snippet,
+			})
 		}
 	}
 
@@ -285,12 +269,13 @@ func suggestRefactoring(filePath, content, language string) []SuggestionInfo {
 	}
 
 	// Suggest refactoring for complex functions
+	thresholds := DefaultThresholds()
 	for _, fn := range fileResult.TopFunctions {
-		if fn.Complexity > 3.0 {
+		if fn.Cyclomatic > thresholds.Cyclomatic || fn.Cognitive > thresholds.Cognitive {
 			suggestions = append(suggestions, SuggestionInfo{
 				Type:        "refactoring",
 				Title:       fmt.Sprintf("Refactor complex function '%s'", fn.Name),
-				Description: fmt.Sprintf("Function '%s' has a complexity score of %.2f. Consider breaking it down into smaller functions.", fn.Name, fn.Complexity),
+				Description: fmt.Sprintf("Function '%s' has a cyclomatic complexity of %d and a cognitive complexity of %d. Consider breaking it down into smaller functions.", fn.Name, fn.Cyclomatic, fn.Cognitive),
 				FilePath:    filePath,
 				Line:        fn.Line,
 				Before:      "",
@@ -299,6 +284,11 @@ func suggestRefactoring(filePath, content, language string) []SuggestionInfo {
 		}
 	}
 
+	// Mechanical refactorings that apply_fixes can make
+	if language == "Go" {
+		suggestions = append(suggestions, suggestErrorf(filePath, content)...)
+	}
+
 	return suggestions
 }
 
@@ -311,8 +301,11 @@ func suggestPerformanceImprovements(filePath, content, language string) []Sugges
 
 	switch language {
 	case "Go":
-		// Check for inefficient string concatenation
-		if strings.Contains(content, "+=") && strings.Contains(content, "string") {
+		// Check for inefficient string concatenation, exactly when the
+		// file parses and roughly otherwise
+		if _, _, err := parseGoSource(filePath, content); err == nil {
+			suggestions = append(suggestions, suggestStringBuilders(filePath, content)...)
+		} else if strings.Contains(content, "+=") && strings.Contains(content, "string") {
 			suggestions = append(suggestions, SuggestionInfo{
 				Type:        "performance",
 				Title:       "Use strings.Builder for string concatenation",
@@ -388,6 +381,11 @@ func suggestReadabilityImprovements(filePath, content, language string) []Sugges
 		}
 	}
 
+	// Names that don't follow the Go conventions, renamed with their references
+	if language == "Go" {
+		suggestions = append(suggestions, suggestGoNames(filePath, content)...)
+	}
+
 	return suggestions
 }
 
@@ -454,3 +452,13 @@ func findLineNumber(content, substring string) int {
 	}
 	return 1
 }
+
+// containsString reports whether a list contains a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change
+const DefaultContext = 3
+
+// opKind is the kind of a line in an edit script
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is one line of an edit script. oldLine and newLine are the zero-based
+// indexes of the line in the old and new text; only the ones that apply to
+// the kind are meaningful.
+type op struct {
+	kind    opKind
+	oldLine int
+	newLine int
+}
+
+// Unified returns a unified diff of two texts, as printed by diff -u, with
+// contextLines unchanged lines around each change. It returns an empty
+// string if the texts are equal.
+func Unified(oldName, newName, oldText, newText string, contextLines int) string {
+	if oldText == newText {
+		return ""
+	}
+	if contextLines < 0 {
+		contextLines = DefaultContext
+	}
+
+	oldLines := splitLines(oldText)
+	newLines := splitLines(newText)
+	ops := lineOps(oldLines, newLines)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", oldName, newName)
+
+	// Group the changes into hunks, merging changes whose context overlaps
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == opEqual {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while the next change is close enough
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != opEqual {
+				end = i + 1
+			} else if i-end >= 2*contextLines {
+				break
+			}
+		}
+
+		hunkStart := max(start-contextLines, 0)
+		hunkEnd := min(end+contextLines, len(ops))
+		writeHunk(&builder, ops[hunkStart:hunkEnd], oldLines, newLines)
+		start = hunkEnd
+	}
+
+	return builder.String()
+}
+
+// writeHunk writes one hunk with its header
+func writeHunk(builder *strings.Builder, ops []op, oldLines, newLines []string) {
+	oldStart, newStart := -1, -1
+	oldCount, newCount := 0, 0
+	for _, o := range ops {
+		if o.kind != opInsert {
+			if oldStart < 0 {
+				oldStart = o.oldLine
+			}
+			oldCount++
+		}
+		if o.kind != opDelete {
+			if newStart < 0 {
+				newStart = o.newLine
+			}
+			newCount++
+		}
+	}
+
+	// An empty range starts at the line before it, as in diff -u
+	if oldStart < 0 {
+		oldStart = ops[0].oldLine - 1
+	}
+	if newStart < 0 {
+		newStart = ops[0].newLine - 1
+	}
+
+	fmt.Fprintf(builder, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			writeLine(builder, " ", oldLines[o.oldLine])
+		case opDelete:
+			writeLine(builder, "-", oldLines[o.oldLine])
+		case opInsert:
+			writeLine(builder, "+", newLines[o.newLine])
+		}
+	}
+}
+
+// hunkRange formats the one-based start and length of a hunk
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// writeLine writes a line with its prefix, marking a missing final newline
+func writeLine(builder *strings.Builder, prefix, line string) {
+	builder.WriteString(prefix)
+	builder.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		builder.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// splitLines splits text into lines, keeping the line endings
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOps returns a shortest edit script that turns oldLines into newLines,
+// using Myers' algorithm on the lines between the common prefix and suffix
+func lineOps(oldLines, newLines []string) []op {
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	var ops []op
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, op{opEqual, i, i})
+	}
+
+	a := oldLines[prefix : len(oldLines)-suffix]
+	b := newLines[prefix : len(newLines)-suffix]
+	for _, o := range myers(a, b) {
+		ops = append(ops, op{o.kind, o.oldLine + prefix, o.newLine + prefix})
+	}
+
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, op{opEqual, len(oldLines) - suffix + i, len(newLines) - suffix + i})
+	}
+	return ops
+}
+
+// myers returns a shortest edit script between a and b. It keeps the
+// furthest reaching paths of every step, which needs memory proportional to
+// the number of differences times the length of the texts.
+func myers(a, b []string) []op {
+	n, m := len(a), len(b)
+	maxSteps := n + m
+	offset := maxSteps + 1
+	v := make([]int, 2*maxSteps+3)
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= maxSteps && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// Walk the trace back from the end to recover the script
+	var reversed []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		previous := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && previous[offset+k-1] < previous[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := previous[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			reversed = append(reversed, op{opEqual, x, y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				reversed = append(reversed, op{opInsert, x, y})
+			} else {
+				x--
+				reversed = append(reversed, op{opDelete, x, y})
+			}
+		}
+	}
+
+	ops := make([]op, len(reversed))
+	for i, o := range reversed {
+		ops[len(reversed)-1-i] = o
+	}
+	return ops
+}
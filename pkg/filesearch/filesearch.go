@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -71,7 +72,7 @@ func HandleFileSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 	var contentRegex *regexp.Regexp
 	if contentPattern != "" {
 		var err error
-		contentRegex, err = regexp.Compile(contentPattern)
+		contentRegex, err = compileContentPattern(contentPattern, false, false, false)
 		if err != nil {
 			return nil, fmt.Errorf("invalid content pattern regex: %v", err)
 		}
@@ -99,7 +100,7 @@ func HandleFileSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: resultText,
+				Text: artifact.Limit("filesearch", resultText),
 			},
 		},
 	}, nil
@@ -198,6 +199,28 @@ func searchFiles(directory, pattern string, contentRegex *regexp.Regexp, recursi
 	return results, nil
 }
 
+// compileContentPattern compiles a content pattern into a regular expression.
+// A fixed pattern is matched literally, and multiline lets '.' match newlines
+// and '^'/'$' match at line boundaries.
+func compileContentPattern(pattern string, fixed, ignoreCase, multiline bool) (*regexp.Regexp, error) {
+	if fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+
+	flags := ""
+	if ignoreCase {
+		flags += "i"
+	}
+	if multiline {
+		flags += "ms"
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	return regexp.Compile(pattern)
+}
+
 // parseSize parses a human-readable size string (e.g., "10KB", "5MB") to bytes
 func parseSize(sizeStr string) (int64, error) {
 	sizeStr = strings.TrimSpace(sizeStr)
@@ -0,0 +1,499 @@
+package filesearch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Default limits for the grep tool
+const (
+	defaultMaxTotalMatches = 1000
+	binaryCheckSize        = 8000
+)
+
+// vcsDirectories are skipped while walking, like ripgrep does by default
+var vcsDirectories = map[string]bool{
+	".git": true,
+	".hg":  true,
+	".svn": true,
+}
+
+// GrepOptions holds the options for a grep search
+type GrepOptions struct {
+	FixedStrings      bool
+	IgnoreCase        bool
+	Multiline         bool
+	ContextBefore     int
+	ContextAfter      int
+	Include           []string
+	Exclude           []string
+	Recursive         bool
+	MaxMatchesPerFile int
+	MaxTotalMatches   int
+}
+
+// ColumnRange represents the columns of a match on a line.
+// Columns are 1-based byte offsets and End is exclusive.
+type ColumnRange struct {
+	Start int
+	End   int
+}
+
+// GrepMatch represents a matching line, or a span of lines in multiline mode
+type GrepMatch struct {
+	Line    int
+	EndLine int
+	Columns []ColumnRange
+}
+
+// GrepFileResult represents the matches found in a single file
+type GrepFileResult struct {
+	Path      string
+	Matches   []GrepMatch
+	Truncated bool
+	lines     []string
+}
+
+// GrepResult represents the result of a grep search
+type GrepResult struct {
+	Files         []GrepFileResult
+	FilesSearched int
+	TotalMatches  int
+	Truncated     bool
+}
+
+// HandleGrep is the handler function for the grep tool
+func HandleGrep(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+
+	// Extract pattern
+	pattern, ok := arguments["pattern"].(string)
+	if !ok || pattern == "" {
+		return nil, fmt.Errorf("pattern must be a non-empty string")
+	}
+
+	// Extract directory and resolve it against the workspace
+	directory, _ := arguments["directory"].(string)
+	if directory == "" {
+		directory = "."
+	}
+	sessionID, _ := arguments["session_id"].(string)
+	fullPath := workspace.ResolveRelativePath(directory, sessionID)
+
+	// Extract options
+	options := GrepOptions{
+		Recursive:       true,
+		MaxTotalMatches: defaultMaxTotalMatches,
+	}
+	if fixed, ok := arguments["fixed_strings"].(bool); ok {
+		options.FixedStrings = fixed
+	}
+	if ignoreCase, ok := arguments["ignore_case"].(bool); ok {
+		options.IgnoreCase = ignoreCase
+	}
+	if multiline, ok := arguments["multiline"].(bool); ok {
+		options.Multiline = multiline
+	}
+	if recursive, ok := arguments["recursive"].(bool); ok {
+		options.Recursive = recursive
+	}
+	if contextLines, ok := arguments["context"].(float64); ok {
+		options.ContextBefore = int(contextLines)
+		options.ContextAfter = int(contextLines)
+	}
+	if before, ok := arguments["before_context"].(float64); ok {
+		options.ContextBefore = int(before)
+	}
+	if after, ok := arguments["after_context"].(float64); ok {
+		options.ContextAfter = int(after)
+	}
+	if maxPerFile, ok := arguments["max_matches_per_file"].(float64); ok {
+		options.MaxMatchesPerFile = int(maxPerFile)
+	}
+	if maxTotal, ok := arguments["max_total_matches"].(float64); ok {
+		options.MaxTotalMatches = int(maxTotal)
+	}
+	options.Include = stringSlice(arguments["include"])
+	options.Exclude = stringSlice(arguments["exclude"])
+
+	// Compile the pattern
+	re, err := compileContentPattern(pattern, options.FixedStrings, options.IgnoreCase, options.Multiline)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+
+	log.Printf("[Grep] Searching %s for %q", fullPath, pattern)
+
+	// Search
+	result, err := grepFiles(fullPath, re, options)
+	if err != nil {
+		return nil, fmt.Errorf("error searching files: %v", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: artifact.Limit("grep", formatGrepResult(fullPath, result, options)),
+			},
+		},
+	}, nil
+}
+
+// grepFiles searches the files under root (or root itself, if it is a file)
+func grepFiles(root string, re *regexp.Regexp, options GrepOptions) (*GrepResult, error) {
+	result := &GrepResult{}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("error accessing path: %v", err)
+	}
+
+	// A single file is searched directly, without applying include/exclude globs
+	if !info.IsDir() {
+		grepFile(root, root, re, options, result)
+		return result, nil
+	}
+
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Skip unreadable entries rather than aborting the whole search
+			return nil
+		}
+
+		relPath, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			relPath = path
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			if path == root {
+				return nil
+			}
+			if !options.Recursive || vcsDirectories[info.Name()] || matchesAnyGlob(relPath, info.Name(), options.Exclude) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if len(options.Include) > 0 && !matchesAnyGlob(relPath, info.Name(), options.Include) {
+			return nil
+		}
+		if matchesAnyGlob(relPath, info.Name(), options.Exclude) {
+			return nil
+		}
+
+		grepFile(path, relPath, re, options, result)
+
+		if result.Truncated {
+			return filepath.SkipAll
+		}
+		return nil
+	}
+
+	if err := filepath.Walk(root, walkFn); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// grepFile searches a single file and appends any matches to result
+func grepFile(path, displayPath string, re *regexp.Regexp, options GrepOptions, result *GrepResult) {
+	content, err := os.ReadFile(path)
+	if err != nil || isBinaryContent(content) {
+		return
+	}
+	result.FilesSearched++
+
+	var matches []GrepMatch
+	if options.Multiline {
+		matches = findMultilineMatches(content, re)
+	} else {
+		matches = findLineMatches(content, re)
+	}
+	if len(matches) == 0 {
+		return
+	}
+
+	fileResult := GrepFileResult{
+		Path:  displayPath,
+		lines: strings.Split(string(content), "\n"),
+	}
+
+	// Apply the per-file and total caps
+	if options.MaxMatchesPerFile > 0 && len(matches) > options.MaxMatchesPerFile {
+		matches = matches[:options.MaxMatchesPerFile]
+		fileResult.Truncated = true
+	}
+	if options.MaxTotalMatches > 0 {
+		remaining := options.MaxTotalMatches - result.TotalMatches
+		if len(matches) >= remaining {
+			if len(matches) > remaining {
+				fileResult.Truncated = true
+			}
+			matches = matches[:remaining]
+			result.Truncated = true
+		}
+	}
+
+	fileResult.Matches = matches
+	result.TotalMatches += len(matches)
+	result.Files = append(result.Files, fileResult)
+}
+
+// findLineMatches finds matches line by line, so no match spans a newline
+func findLineMatches(content []byte, re *regexp.Regexp) []GrepMatch {
+	var matches []GrepMatch
+
+	for i, line := range bytes.Split(content, []byte("\n")) {
+		locs := re.FindAllIndex(line, -1)
+		if len(locs) == 0 {
+			continue
+		}
+
+		match := GrepMatch{Line: i + 1, EndLine: i + 1}
+		for _, loc := range locs {
+			match.Columns = append(match.Columns, ColumnRange{Start: loc[0] + 1, End: loc[1] + 1})
+		}
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// findMultilineMatches finds matches over the whole content, allowing spans across lines
+func findMultilineMatches(content []byte, re *regexp.Regexp) []GrepMatch {
+	var matches []GrepMatch
+
+	// Record the offset at which each line starts
+	lineStarts := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset }) - 1
+	}
+
+	for _, loc := range re.FindAllIndex(content, -1) {
+		startLine := lineOf(loc[0])
+		lastByte := loc[1] - 1
+		if lastByte < loc[0] {
+			lastByte = loc[0]
+		}
+		endLine := lineOf(lastByte)
+
+		column := ColumnRange{
+			Start: loc[0] - lineStarts[startLine] + 1,
+			End:   loc[1] - lineStarts[endLine] + 1,
+		}
+
+		// Matches on the same single line are reported together
+		if n := len(matches); n > 0 && startLine == endLine && matches[n-1].Line == startLine+1 && matches[n-1].EndLine == startLine+1 {
+			matches[n-1].Columns = append(matches[n-1].Columns, column)
+			continue
+		}
+
+		matches = append(matches, GrepMatch{
+			Line:    startLine + 1,
+			EndLine: endLine + 1,
+			Columns: []ColumnRange{column},
+		})
+	}
+
+	return matches
+}
+
+// formatGrepResult formats the grep result in a grep-like layout
+func formatGrepResult(root string, result *GrepResult, options GrepOptions) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Found %d matches in %d files (searched %d files in %s)\n", result.TotalMatches, len(result.Files), result.FilesSearched, root))
+	if result.Truncated {
+		sb.WriteString(fmt.Sprintf("Stopped after reaching the limit of %d matches\n", options.MaxTotalMatches))
+	}
+	sb.WriteString("\n")
+
+	for _, file := range result.Files {
+		sb.WriteString(file.Path + "\n")
+
+		// Work out which lines belong to a match and which are context
+		matchStarts := make(map[int]GrepMatch)
+		inMatch := make(map[int]bool)
+		type block struct{ start, end int }
+		var blocks []block
+
+		for _, match := range file.Matches {
+			matchStarts[match.Line] = match
+			for line := match.Line; line <= match.EndLine; line++ {
+				inMatch[line] = true
+			}
+
+			start := match.Line - options.ContextBefore
+			if start < 1 {
+				start = 1
+			}
+			end := match.EndLine + options.ContextAfter
+			if end > len(file.lines) {
+				end = len(file.lines)
+			}
+
+			// Merge with the previous block if they touch or overlap
+			if n := len(blocks); n > 0 && start <= blocks[n-1].end+1 {
+				if end > blocks[n-1].end {
+					blocks[n-1].end = end
+				}
+			} else {
+				blocks = append(blocks, block{start, end})
+			}
+		}
+
+		for i, b := range blocks {
+			if i > 0 {
+				sb.WriteString("--\n")
+			}
+			for line := b.start; line <= b.end; line++ {
+				text := strings.TrimSuffix(file.lines[line-1], "\r")
+				if match, ok := matchStarts[line]; ok && match.EndLine > match.Line {
+					// A multiline span is shown as start line:column to end line:column
+					column := match.Columns[0]
+					sb.WriteString(fmt.Sprintf("%d:%d-%d:%d:%s\n", line, column.Start, match.EndLine, column.End, text))
+				} else if ok {
+					sb.WriteString(fmt.Sprintf("%d:%s:%s\n", line, formatColumns(match.Columns), text))
+				} else if inMatch[line] {
+					sb.WriteString(fmt.Sprintf("%d:%s\n", line, text))
+				} else {
+					sb.WriteString(fmt.Sprintf("%d-%s\n", line, text))
+				}
+			}
+		}
+
+		if file.Truncated {
+			sb.WriteString("(more matches in this file were omitted)\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// formatColumns formats column ranges as "start-end" pairs separated by commas
+func formatColumns(columns []ColumnRange) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = fmt.Sprintf("%d-%d", column.Start, column.End)
+	}
+	return strings.Join(parts, ",")
+}
+
+// matchesAnyGlob checks a path against glob patterns. Patterns containing a
+// slash are matched against the relative path, others against the base name.
+func matchesAnyGlob(relPath, name string, globs []string) bool {
+	for _, glob := range globs {
+		target := name
+		if strings.Contains(glob, "/") {
+			target = relPath
+		}
+		if matched, _ := filepath.Match(glob, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinaryContent reports whether content looks like a binary file
+func isBinaryContent(content []byte) bool {
+	if len(content) > binaryCheckSize {
+		content = content[:binaryCheckSize]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// stringSlice converts an array argument to a slice of strings
+func stringSlice(value interface{}) []string {
+	var result []string
+	if items, ok := value.([]interface{}); ok {
+		for _, item := range items {
+			if str, ok := item.(string); ok && str != "" {
+				result = append(result, str)
+			}
+		}
+	}
+	return result
+}
+
+// RegisterGrep registers the grep tool with the MCP server
+func RegisterGrep(mcpServer *server.MCPServer) {
+	// Create the tool definition
+	grepTool := mcp.NewTool("grep",
+		mcp.WithDescription("Searches file contents like grep/ripgrep, reporting every matching line with line numbers, match column ranges (1-based, end-exclusive) and optional surrounding context lines"),
+		mcp.WithString("pattern",
+			mcp.Description("Regular expression (RE2 syntax) to search for, or a literal string when fixed_strings is true"),
+			mcp.Required(),
+		),
+		mcp.WithString("directory",
+			mcp.Description("Directory or file to search (absolute or relative to the workspace root, default: workspace root)"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Session ID to use for resolving relative paths"),
+		),
+		mcp.WithBoolean("fixed_strings",
+			mcp.Description("Treat the pattern as a literal string instead of a regular expression (default: false)"),
+		),
+		mcp.WithBoolean("ignore_case",
+			mcp.Description("Match case-insensitively (default: false)"),
+		),
+		mcp.WithBoolean("multiline",
+			mcp.Description("Allow matches to span multiple lines; '.' also matches newlines (default: false)"),
+		),
+		mcp.WithNumber("context",
+			mcp.Description("Number of lines to show before and after each match"),
+		),
+		mcp.WithNumber("before_context",
+			mcp.Description("Number of lines to show before each match (overrides 'context')"),
+		),
+		mcp.WithNumber("after_context",
+			mcp.Description("Number of lines to show after each match (overrides 'context')"),
+		),
+		mcp.WithArray("include",
+			mcp.Description("Glob patterns of files to search (e.g., ['*.go', 'cmd/*/*.go']); patterns with a '/' match the relative path"),
+		),
+		mcp.WithArray("exclude",
+			mcp.Description("Glob patterns of files or directories to skip (e.g., ['vendor', '*_test.go'])"),
+		),
+		mcp.WithBoolean("recursive",
+			mcp.Description("Whether to search subdirectories (default: true)"),
+		),
+		mcp.WithNumber("max_matches_per_file",
+			mcp.Description("Maximum number of matching lines to report per file (default: unlimited)"),
+		),
+		mcp.WithNumber("max_total_matches",
+			mcp.Description("Maximum number of matching lines to report in total (default: 1000)"),
+		),
+	)
+
+	// Wrap the handler with stats tracking
+	wrappedHandler := stats.WrapHandler("grep", HandleGrep)
+
+	// Register the tool with the wrapped handler
+	mcpServer.AddTool(grepTool, wrappedHandler)
+
+	// Log the registration
+	log.Printf("[Grep] Registered grep tool")
+}
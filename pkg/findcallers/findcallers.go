@@ -1,16 +1,15 @@
 package findcallers
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
 	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -18,83 +17,6 @@ import (
 
 // No environment variables needed - using workspace consistently
 
-// Language represents a programming language with its file extensions and function call patterns
-type Language struct {
-	Name           string
-	FileExtensions []string
-	CallPatterns   []string
-}
-
-// GetSupportedLanguages returns a list of supported programming languages
-func GetSupportedLanguages() []Language {
-	return []Language{
-		{
-			Name:           "Go",
-			FileExtensions: []string{".go"},
-			CallPatterns:   []string{`(^|\s+|[^\w\.])%s\s*\(`},
-		},
-		{
-			Name:           "JavaScript",
-			FileExtensions: []string{".js", ".jsx", ".ts", ".tsx"},
-			CallPatterns:   []string{`(^|\s+|[^\w\.])%s\s*\(`, `(^|\s+|\.)%s\s*\(`},
-		},
-		{
-			Name:           "Python",
-			FileExtensions: []string{".py"},
-			CallPatterns:   []string{`(^|\s+|[^\w\.])%s\s*\(`},
-		},
-		{
-			Name:           "Java",
-			FileExtensions: []string{".java"},
-			CallPatterns:   []string{`(^|\s+|[^\w\.])%s\s*\(`, `(^|\s+|\.)%s\s*\(`},
-		},
-		{
-			Name:           "C#",
-			FileExtensions: []string{".cs"},
-			CallPatterns:   []string{`(^|\s+|[^\w\.])%s\s*\(`, `(^|\s+|\.)%s\s*\(`},
-		},
-		{
-			Name:           "C/C++",
-			FileExtensions: []string{".c", ".cpp", ".cc", ".h", ".hpp"},
-			CallPatterns:   []string{`(^|\s+|[^\w\.])%s\s*\(`, `(^|\s+|::|->)%s\s*\(`},
-		},
-		{
-			Name:           "Ruby",
-			FileExtensions: []string{".rb"},
-			CallPatterns:   []string{`(^|\s+|[^\w\.])%s\s*(\(|$)`, `(^|\s+|\.)%s\s*(\(|$)`},
-		},
-		{
-			Name:           "PHP",
-			FileExtensions: []string{".php"},
-			CallPatterns:   []string{`(^|\s+|[^\w\$\.])%s\s*\(`, `(^|\s+|\$|\->|\:\:)%s\s*\(`},
-		},
-	}
-}
-
-// GetLanguageByName returns a language by its name
-func GetLanguageByName(name string) (Language, bool) {
-	languages := GetSupportedLanguages()
-	for _, lang := range languages {
-		if strings.EqualFold(lang.Name, name) {
-			return lang, true
-		}
-	}
-	return Language{}, false
-}
-
-// GetLanguageByExtension returns a language by file extension
-func GetLanguageByExtension(ext string) (Language, bool) {
-	languages := GetSupportedLanguages()
-	for _, lang := range languages {
-		for _, langExt := range lang.FileExtensions {
-			if langExt == ext {
-				return lang, true
-			}
-		}
-	}
-	return Language{}, false
-}
-
 // CallerResult represents a found caller of a function
 type CallerResult struct {
 	FilePath    string
@@ -197,32 +119,17 @@ func HandleFindCallers(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 	}, nil
 }
 
-// FindCallers finds all callers of a function in a directory
+// FindCallers finds all callers of a function in a directory. A name
+// qualified by its type, as in Type.method, matches the calls of any
+// method with that name.
 func FindCallers(functionName, searchDir, language string, recursive bool) ([]CallerResult, error) {
 	var results []CallerResult
 
-	// Get supported languages
-	supportedLanguages := GetSupportedLanguages()
-
-	// Filter languages if specified
-	var languages []Language
+	// Check the language if specified
 	if language != "" {
-		lang, found := GetLanguageByName(language)
-		if found {
-			languages = []Language{lang}
-		} else {
+		if _, found := syntax.LanguageByName(language); !found {
 			return nil, fmt.Errorf("unsupported language: %s", language)
 		}
-	} else {
-		languages = supportedLanguages
-	}
-
-	// Create a map of file extensions to languages
-	extToLang := make(map[string]Language)
-	for _, lang := range languages {
-		for _, ext := range lang.FileExtensions {
-			extToLang[ext] = lang
-		}
 	}
 
 	// Walk the directory
@@ -239,15 +146,14 @@ func FindCallers(functionName, searchDir, language string, recursive bool) ([]Ca
 			return nil
 		}
 
-		// Check if the file extension is supported
-		ext := filepath.Ext(path)
-		lang, ok := extToLang[ext]
-		if !ok {
+		// Check if the file is in a supported language
+		lang, ok := syntax.LanguageForFile(path)
+		if !ok || (language != "" && !lang.Is(language)) {
 			return nil
 		}
 
 		// Search for function calls in the file
-		fileResults, err := searchFileForCalls(path, functionName, lang)
+		fileResults, err := searchFileForCalls(path, functionName)
 		if err != nil {
 			log.Printf("[FindCallers] Error searching file %s: %v", path, err)
 			return nil
@@ -264,54 +170,35 @@ func FindCallers(functionName, searchDir, language string, recursive bool) ([]Ca
 	return results, nil
 }
 
-// searchFileForCalls searches a file for function calls
-func searchFileForCalls(filePath, functionName string, language Language) ([]CallerResult, error) {
+// searchFileForCalls searches a file for function calls, which leaves out
+// the names in comments, strings, and definitions
+func searchFileForCalls(filePath, functionName string) ([]CallerResult, error) {
 	var results []CallerResult
 
-	// Open the file
-	file, err := os.Open(filePath)
+	// Parse the file
+	file, err := syntax.ParseFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
+		return nil, fmt.Errorf("error reading file: %v", err)
 	}
-	defer file.Close()
-
-	// Create a scanner to read the file
-	scanner := bufio.NewScanner(file)
 
-	// Compile regex patterns for the language
-	var patterns []*regexp.Regexp
-	for _, pattern := range language.CallPatterns {
-		regexPattern := fmt.Sprintf(pattern, regexp.QuoteMeta(functionName))
-		regex, err := regexp.Compile(regexPattern)
-		if err != nil {
-			return nil, fmt.Errorf("error compiling regex: %v", err)
-		}
-		patterns = append(patterns, regex)
+	// Calls name the method without its type
+	if i := strings.LastIndexAny(functionName, ".:"); i >= 0 {
+		functionName = functionName[i+1:]
 	}
 
-	// Search for function calls
-	lineNumber := 0
-	for scanner.Scan() {
-		lineNumber++
-		line := scanner.Text()
-
-		// Check if the line contains a function call
-		for _, regex := range patterns {
-			if regex.MatchString(line) {
-				results = append(results, CallerResult{
-					FilePath:    filePath,
-					LineNumber:  lineNumber,
-					LineContent: line,
-					Language:    language.Name,
-				})
-				break
-			}
+	// Report each line with a call once
+	lastLine := 0
+	for _, call := range file.Calls {
+		if call.Name != functionName || call.Line == lastLine {
+			continue
 		}
-	}
-
-	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
+		lastLine = call.Line
+		results = append(results, CallerResult{
+			FilePath:    filePath,
+			LineNumber:  call.Line,
+			LineContent: file.Line(call.Line),
+			Language:    file.Language.Name,
+		})
 	}
 
 	return results, nil
@@ -321,7 +208,7 @@ func searchFileForCalls(filePath, functionName string, language Language) ([]Cal
 func RegisterFindCallers(mcpServer *server.MCPServer) {
 	// Create the tool definition
 	findCallersTool := mcp.NewTool("findcallers",
-		mcp.WithDescription("Finds all callers of a specified function across a codebase. Supports multiple programming languages including Go, JavaScript, Python, Java, C#, C/C++, Ruby, and PHP. Analyzes code to identify function calls while handling complex patterns like method calls, nested functions, and calls within comments or string literals. Returns detailed results with file paths, line numbers, and context for each call, making it ideal for code refactoring, impact analysis, and understanding function usage patterns."),
+		mcp.WithDescription("Finds all callers of a specified function across a codebase. Supports multiple programming languages including Go, JavaScript, TypeScript, Python, Java, C#, C/C++, Ruby, and PHP. Parses code to identify function calls while handling complex patterns like method calls, nested functions, and calls within comments or string literals. Returns detailed results with file paths, line numbers, and context for each call, making it ideal for code refactoring, impact analysis, and understanding function usage patterns."),
 		mcp.WithString("function_name",
 			mcp.Description("The name of the function to find callers for (case-sensitive, must match exactly as defined in code)"),
 			mcp.Required(),
@@ -330,7 +217,7 @@ func RegisterFindCallers(mcpServer *server.MCPServer) {
 			mcp.Description("The directory to search in (absolute or relative path, default: current directory)"),
 		),
 		mcp.WithString("language",
-			mcp.Description("The programming language to search for (default: all supported languages - Go, JavaScript, Python, Java, C#, C/C++, Ruby, PHP)"),
+			mcp.Description("The programming language to search for (default: all supported languages - Go, JavaScript, TypeScript, Python, Java, C#, C/C++, Ruby, PHP)"),
 		),
 		mcp.WithBoolean("use_relative_paths",
 			mcp.Description("Whether to use relative paths in the results for better portability (default: true)"),
@@ -1,16 +1,15 @@
 package findfunc
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
 	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -18,13 +17,6 @@ import (
 
 // No environment variables needed - using workspace consistently
 
-// Language represents a programming language with its file extensions and function definition patterns
-type Language struct {
-	Name                 string
-	FileExtensions       []string
-	FunctionStartPattern string
-}
-
 // FunctionLocation represents the location of a function in a file
 type FunctionLocation struct {
 	FilePath    string `json:"file_path"`
@@ -33,76 +25,6 @@ type FunctionLocation struct {
 	Language    string `json:"language"`
 }
 
-// GetSupportedLanguages returns a list of supported programming languages
-func GetSupportedLanguages() []Language {
-	return []Language{
-		{
-			Name:                 "Go",
-			FileExtensions:       []string{".go"},
-			FunctionStartPattern: `func\s+%s\s*\(`,
-		},
-		{
-			Name:                 "JavaScript",
-			FileExtensions:       []string{".js", ".jsx", ".ts", ".tsx"},
-			FunctionStartPattern: `(function\s+%s|const\s+%s\s*=\s*function|let\s+%s\s*=\s*function|var\s+%s\s*=\s*function|%s\s*=\s*function|%s\s*:\s*function|%s\s*\([^{]*\)\s*{|%s\s*=\s*\([^{]*\)\s*=>|%s\s*=>)`,
-		},
-		{
-			Name:                 "Python",
-			FileExtensions:       []string{".py"},
-			FunctionStartPattern: `def\s+%s\s*\(`,
-		},
-		{
-			Name:                 "Java",
-			FileExtensions:       []string{".java"},
-			FunctionStartPattern: `(public|private|protected)?\s*(static)?\s*\S+\s+%s\s*\(`,
-		},
-		{
-			Name:                 "C#",
-			FileExtensions:       []string{".cs"},
-			FunctionStartPattern: `(public|private|protected|internal)?\s*(static|virtual|override|abstract)?\s*\S+\s+%s\s*\(`,
-		},
-		{
-			Name:                 "C/C++",
-			FileExtensions:       []string{".c", ".cpp", ".cc", ".h", ".hpp"},
-			FunctionStartPattern: `\S+\s+%s\s*\(`,
-		},
-		{
-			Name:                 "Ruby",
-			FileExtensions:       []string{".rb"},
-			FunctionStartPattern: `(def\s+%s|def\s+self\.%s)`,
-		},
-		{
-			Name:                 "PHP",
-			FileExtensions:       []string{".php"},
-			FunctionStartPattern: `(function\s+%s|public\s+function\s+%s|private\s+function\s+%s|protected\s+function\s+%s|static\s+function\s+%s)`,
-		},
-	}
-}
-
-// GetLanguageByName returns a language by its name
-func GetLanguageByName(name string) (Language, bool) {
-	languages := GetSupportedLanguages()
-	for _, lang := range languages {
-		if strings.EqualFold(lang.Name, name) {
-			return lang, true
-		}
-	}
-	return Language{}, false
-}
-
-// GetLanguageByExtension returns a language by file extension
-func GetLanguageByExtension(ext string) (Language, bool) {
-	languages := GetSupportedLanguages()
-	for _, lang := range languages {
-		for _, langExt := range lang.FileExtensions {
-			if strings.EqualFold(langExt, ext) {
-				return lang, true
-			}
-		}
-	}
-	return Language{}, false
-}
-
 // HandleFindFunc is the handler function for the findfunc tool
 func HandleFindFunc(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	arguments := request.Params.Arguments
@@ -154,7 +76,7 @@ func HandleFindFunc(ctx context.Context, request mcp.CallToolRequest) (*mcp.Call
 	}
 
 	// Find functions
-	locations, err := findFunctions(fullSearchDir, functionName, packageName, language, recursive)
+	locations, err := FindFunctions(fullSearchDir, functionName, packageName, language, recursive)
 	if err != nil {
 		return nil, fmt.Errorf("error finding functions: %v", err)
 	}
@@ -203,32 +125,17 @@ func HandleFindFunc(ctx context.Context, request mcp.CallToolRequest) (*mcp.Call
 	return result, nil
 }
 
-// findFunctions finds all functions with the given name in the specified directory
-func findFunctions(searchDir, functionName, packageName, language string, recursive bool) ([]FunctionLocation, error) {
+// FindFunctions finds all functions with the given name in the specified
+// directory. The name may be qualified by its type, as in Type.method or
+// Type::method.
+func FindFunctions(searchDir, functionName, packageName, language string, recursive bool) ([]FunctionLocation, error) {
 	var locations []FunctionLocation
 
-	// Get supported languages
-	supportedLanguages := GetSupportedLanguages()
-
-	// Filter languages if specified
-	var languages []Language
+	// Check the language if specified
 	if language != "" {
-		lang, found := GetLanguageByName(language)
-		if found {
-			languages = []Language{lang}
-		} else {
+		if _, found := syntax.LanguageByName(language); !found {
 			return nil, fmt.Errorf("unsupported language: %s", language)
 		}
-	} else {
-		languages = supportedLanguages
-	}
-
-	// Create a map of file extensions to languages
-	extToLang := make(map[string]Language)
-	for _, lang := range languages {
-		for _, ext := range lang.FileExtensions {
-			extToLang[ext] = lang
-		}
 	}
 
 	// Walk the directory
@@ -245,15 +152,14 @@ func findFunctions(searchDir, functionName, packageName, language string, recurs
 			return nil
 		}
 
-		// Check if the file extension is supported
-		ext := filepath.Ext(path)
-		lang, ok := extToLang[ext]
-		if !ok {
+		// Check if the file is in a supported language
+		lang, ok := syntax.LanguageForFile(path)
+		if !ok || (language != "" && !lang.Is(language)) {
 			return nil
 		}
 
 		// Search for function definitions in the file
-		fileLocations, err := searchFileForFunctions(path, functionName, packageName, lang)
+		fileLocations, err := searchFileForFunctions(path, functionName, packageName)
 		if err != nil {
 			log.Printf("[FindFunc] Error searching file %s: %v", path, err)
 			return nil
@@ -271,72 +177,32 @@ func findFunctions(searchDir, functionName, packageName, language string, recurs
 }
 
 // searchFileForFunctions searches for function definitions in a file
-func searchFileForFunctions(filePath, functionName, packageName string, language Language) ([]FunctionLocation, error) {
+func searchFileForFunctions(filePath, functionName, packageName string) ([]FunctionLocation, error) {
 	var locations []FunctionLocation
 
-	// Open the file
-	file, err := os.Open(filePath)
+	// Parse the file
+	file, err := syntax.ParseFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
-
-	// Create a scanner to read the file
-	scanner := bufio.NewScanner(file)
-
-	// Create the function pattern
-	var functionPattern string
-	if strings.Contains(language.FunctionStartPattern, "%s") {
-		functionPattern = fmt.Sprintf(language.FunctionStartPattern, functionName)
-	} else {
-		functionPattern = language.FunctionStartPattern
+		return nil, fmt.Errorf("error reading file: %v", err)
 	}
 
-	// Compile the pattern
-	re, err := regexp.Compile(functionPattern)
-	if err != nil {
-		return nil, fmt.Errorf("error compiling pattern: %v", err)
+	// If package name is specified, check if it matches
+	if packageName != "" && file.Package != "" && packageName != file.Package {
+		return nil, nil
 	}
 
-	// Variables to track package context
-	var currentPackage string
-	packageRe := regexp.MustCompile(`package\s+(\w+)`)
-
-	// Scan the file line by line
-	lineNumber := 0
-	for scanner.Scan() {
-		lineNumber++
-		line := scanner.Text()
-
-		// Check for package declaration (for Go)
-		if language.Name == "Go" {
-			if matches := packageRe.FindStringSubmatch(line); len(matches) > 1 {
-				currentPackage = matches[1]
-			}
-		}
-
-		// Check if the line contains a function definition
-		if re.MatchString(line) {
-			// If package name is specified, check if it matches
-			if packageName != "" && currentPackage != "" && packageName != currentPackage {
-				continue
-			}
-
-			// Add the location
+	// Add the functions and methods with the name
+	for _, symbol := range file.Symbols {
+		if symbol.IsFunction() && symbol.Matches(functionName) {
 			locations = append(locations, FunctionLocation{
 				FilePath:    filePath,
-				LineNumber:  lineNumber,
-				PackageName: currentPackage,
-				Language:    language.Name,
+				LineNumber:  symbol.Line,
+				PackageName: file.Package,
+				Language:    file.Language.Name,
 			})
 		}
 	}
 
-	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
-	}
-
 	return locations, nil
 }
 
@@ -344,9 +210,9 @@ func searchFileForFunctions(filePath, functionName, packageName string, language
 func RegisterFindFunc(mcpServer *server.MCPServer) {
 	// Create the tool definition
 	findFuncTool := mcp.NewTool("findfunc",
-		mcp.WithDescription("Finds function definitions across a codebase by name and returns their locations. Supports multiple programming languages including Go, JavaScript, Python, Java, C#, C/C++, Ruby, and PHP. Returns an array of locations with file paths and line numbers, making it ideal for use with the funcdef tool to retrieve specific function definitions. Handles package-based languages like Go and Java with optional package name filtering."),
+		mcp.WithDescription("Finds function definitions across a codebase by name and returns their locations. Supports multiple programming languages including Go, JavaScript, TypeScript, Python, Java, C#, C/C++, Ruby, and PHP, whose files are parsed to find functions, methods, and named lambdas. Returns an array of locations with file paths and line numbers, making it ideal for use with the funcdef tool to retrieve specific function definitions. Handles package-based languages like Go and Java with optional package name filtering."),
 		mcp.WithString("function_name",
-			mcp.Description("The name of the function to find (case-sensitive, must match exactly as defined in code)"),
+			mcp.Description("The name of the function to find (case-sensitive, must match exactly as defined in code), optionally qualified by its class or type as in Type.method"),
 			mcp.Required(),
 		),
 		mcp.WithString("package_name",
@@ -356,7 +222,7 @@ func RegisterFindFunc(mcpServer *server.MCPServer) {
 			mcp.Description("The directory to search in (absolute or relative path, default: current directory)"),
 		),
 		mcp.WithString("language",
-			mcp.Description("The programming language to search for (default: all supported languages - Go, JavaScript, Python, Java, C#, C/C++, Ruby, PHP)"),
+			mcp.Description("The programming language to search for (default: all supported languages - Go, JavaScript, TypeScript, Python, Java, C#, C/C++, Ruby, PHP)"),
 		),
 		mcp.WithBoolean("use_relative_paths",
 			mcp.Description("Whether to use relative paths in the results for better portability (default: true)"),
@@ -6,10 +6,10 @@ import (
 	"io/ioutil"
 	"log"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
 	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -17,94 +17,6 @@ import (
 
 // No environment variables needed - using workspace consistently
 
-// Language represents a programming language with its function definition patterns
-type Language struct {
-	Name                 string
-	FileExtensions       []string
-	FunctionStartPattern string
-	FunctionEndPattern   string
-	PrototypePattern     string // For languages like C/C++ that have separate prototypes
-}
-
-// GetSupportedLanguages returns a list of supported programming languages
-func GetSupportedLanguages() []Language {
-	return []Language{
-		{
-			Name:                 "Go",
-			FileExtensions:       []string{".go"},
-			FunctionStartPattern: `func\s+%s\s*\([^{]*{`,
-			FunctionEndPattern:   `^}`,
-		},
-		{
-			Name:                 "JavaScript",
-			FileExtensions:       []string{".js", ".jsx", ".ts", ".tsx"},
-			FunctionStartPattern: `(function\s+%s|const\s+%s\s*=\s*function|let\s+%s\s*=\s*function|var\s+%s\s*=\s*function|%s\s*=\s*function|%s\s*:\s*function|%s\s*\([^{]*\)\s*{|%s\s*=\s*\([^{]*\)\s*=>|%s\s*=>\s*{)`,
-			FunctionEndPattern:   `^}`,
-		},
-		{
-			Name:                 "Python",
-			FileExtensions:       []string{".py"},
-			FunctionStartPattern: `def\s+%s\s*\([^:]*:`,
-			FunctionEndPattern:   `^(\S|$)`, // Non-indented line or empty line
-		},
-		{
-			Name:                 "Java",
-			FileExtensions:       []string{".java"},
-			FunctionStartPattern: `(public|private|protected)?\s*(static)?\s*\S+\s+%s\s*\([^{]*{`,
-			FunctionEndPattern:   `^[\t ]*}`,
-		},
-		{
-			Name:                 "C#",
-			FileExtensions:       []string{".cs"},
-			FunctionStartPattern: `(public|private|protected|internal)?\s*(static|virtual|override|abstract)?\s*\S+\s+%s\s*\([^{]*{`,
-			FunctionEndPattern:   `^[\t ]*}`,
-		},
-		{
-			Name:                 "C/C++",
-			FileExtensions:       []string{".c", ".cpp", ".cc", ".h", ".hpp"},
-			FunctionStartPattern: `\S+\s+%s\s*\([^{;]*{`,
-			FunctionEndPattern:   `^}`,
-			PrototypePattern:     `\S+\s+%s\s*\([^{;]*;`,
-		},
-		{
-			Name:                 "Ruby",
-			FileExtensions:       []string{".rb"},
-			FunctionStartPattern: `(def\s+%s|def\s+self\.%s)`,
-			FunctionEndPattern:   `^end`,
-		},
-		{
-			Name:                 "PHP",
-			FileExtensions:       []string{".php"},
-			FunctionStartPattern: `(function\s+%s|public\s+function\s+%s|private\s+function\s+%s|protected\s+function\s+%s|static\s+function\s+%s)\s*\([^{]*{`,
-			FunctionEndPattern:   `^[\t ]*}`,
-		},
-	}
-}
-
-// GetLanguageByName returns a language by its name
-func GetLanguageByName(name string) (Language, bool) {
-	languages := GetSupportedLanguages()
-	for _, lang := range languages {
-		if strings.EqualFold(lang.Name, name) {
-			return lang, true
-		}
-	}
-	return Language{}, false
-}
-
-// GetLanguageByExtension returns a language by file extension
-func GetLanguageByExtension(ext string) (Language, bool) {
-	languages := GetSupportedLanguages()
-	for _, lang := range languages {
-		for _, langExt := range lang.FileExtensions {
-			if langExt == ext {
-				return lang, true
-			}
-		}
-	}
-	return Language{}, false
-}
-
 // FunctionDefinition represents a function definition
 type FunctionDefinition struct {
 	FilePath    string
@@ -146,7 +58,7 @@ func HandleFuncDef(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 		language = langVal
 	}
 
-	// Extract include_prototype flag (for C/C++)
+	// Extract include_prototype flag
 	includePrototype := false
 	if protoVal, ok := arguments["include_prototype"].(bool); ok {
 		includePrototype = protoVal
@@ -185,22 +97,18 @@ func HandleFuncDef(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 	log.Printf("[FuncDef] Language: %s", language)
 	log.Printf("[FuncDef] Include prototype: %v", includePrototype)
 
-	// Determine the language if not specified
-	if language == "" {
-		ext := filepath.Ext(fullPath)
-		if lang, found := GetLanguageByExtension(ext); found {
-			language = lang.Name
-			log.Printf("[FuncDef] Detected language: %s", language)
-		} else {
-			return nil, fmt.Errorf("could not determine language from file extension: %s", ext)
+	// Determine the language from the file extension, unless another
+	// language is specified
+	lang, found := syntax.LanguageForFile(fullPath)
+	if language != "" && !(found && lang.Is(language)) {
+		lang, found = syntax.LanguageByName(language)
+		if !found {
+			return nil, fmt.Errorf("unsupported language: %s", language)
 		}
+	} else if !found {
+		return nil, fmt.Errorf("could not determine language from file extension: %s", filepath.Ext(fullPath))
 	}
-
-	// Get the language definition
-	lang, found := GetLanguageByName(language)
-	if !found {
-		return nil, fmt.Errorf("unsupported language: %s", language)
-	}
+	log.Printf("[FuncDef] Detected language: %s", lang.Name)
 
 	// Perform the operation
 	if operation == "get" {
@@ -287,6 +195,7 @@ func HandleFuncDef(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 				},
 			}, nil
 		}
+		workspace.RecordEdit(sessionID, "funcdef", []string{fullPath})
 
 		// Get the updated function definition to confirm the change
 		updatedDefs, err := GetFunctionDefinition(fullPath, functionName, lang, includePrototype)
@@ -332,8 +241,11 @@ func HandleFuncDef(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 	}
 }
 
-// GetFunctionDefinition gets the definition of a function from a file
-func GetFunctionDefinition(filePath, functionName string, language Language, includePrototype bool) ([]FunctionDefinition, error) {
+// GetFunctionDefinition gets the definitions of a function from a file,
+// with the comments above them. The name may be qualified by its type, as
+// in Type.method or Type::method. Prototypes, such as C/C++ declarations
+// and abstract methods, are included if requested.
+func GetFunctionDefinition(filePath, functionName string, language syntax.Language, includePrototype bool) ([]FunctionDefinition, error) {
 	var definitions []FunctionDefinition
 
 	// Read the file
@@ -345,278 +257,27 @@ func GetFunctionDefinition(filePath, functionName string, language Language, inc
 	// Split the content into lines
 	lines := strings.Split(string(content), "\n")
 
-	// Compile the function start pattern
-	startPatternStr := fmt.Sprintf(language.FunctionStartPattern, regexp.QuoteMeta(functionName))
-	for i := 1; i < 10; i++ {
-		// Handle multiple placeholders in the pattern (for JavaScript)
-		startPatternStr = strings.Replace(startPatternStr, fmt.Sprintf("%%s", i), regexp.QuoteMeta(functionName), -1)
-	}
-	startPattern, err := regexp.Compile(startPatternStr)
-	if err != nil {
-		return nil, fmt.Errorf("error compiling function start pattern: %v", err)
-	}
-
-	// Compile the function end pattern
-	endPattern, err := regexp.Compile(language.FunctionEndPattern)
-	if err != nil {
-		return nil, fmt.Errorf("error compiling function end pattern: %v", err)
-	}
-
-	// Compile the prototype pattern if available and requested
-	var prototypePattern *regexp.Regexp
-	if includePrototype && language.PrototypePattern != "" {
-		protoPatternStr := fmt.Sprintf(language.PrototypePattern, regexp.QuoteMeta(functionName))
-		prototypePattern, err = regexp.Compile(protoPatternStr)
-		if err != nil {
-			return nil, fmt.Errorf("error compiling prototype pattern: %v", err)
-		}
-	}
-
-	// Find function definitions
-	for i := 0; i < len(lines); i++ {
-		line := lines[i]
-
-		// Check for prototype
-		if prototypePattern != nil && prototypePattern.MatchString(line) {
-			def := FunctionDefinition{
-				FilePath:    filePath,
-				Language:    language.Name,
-				StartLine:   i + 1,
-				EndLine:     i + 1,
-				IsPrototype: true,
-				Content:     line,
-			}
-			definitions = append(definitions, def)
+	// Find the function definitions
+	file := syntax.Parse(string(content), language)
+	for _, symbol := range file.Symbols {
+		if !symbol.IsFunction() || !symbol.Matches(functionName) || (symbol.Prototype && !includePrototype) {
 			continue
 		}
-
-		// Check for function start
-		if startPattern.MatchString(line) {
-			startLine := i
-			endLine := i
-			indentLevel := 0
-			braceCount := 0
-
-			// Count opening braces in the start line
-			for _, char := range line {
-				if char == '{' {
-					braceCount++
-				}
-			}
-
-			// Find the end of the function
-			inMultiLineComment := false
-			inString := false
-			inRawString := false
-			inChar := false
-			stringDelimiter := byte(0)
-
-			for j := i + 1; j < len(lines); j++ {
-				currentLine := lines[j]
-				trimmedLine := strings.TrimSpace(currentLine)
-
-				// Skip empty lines
-				if trimmedLine == "" {
-					continue
-				}
-
-				// Handle comments and strings for languages that use // and /* */ style comments
-				if language.Name == "Go" || language.Name == "C/C++" || language.Name == "Java" ||
-					language.Name == "C#" || language.Name == "JavaScript" || language.Name == "PHP" {
-
-					// Process the line character by character to handle comments, strings, and braces
-					inLineComment := false
-					inBlockComment := inMultiLineComment
-					lineCharCount := 0
-					escaped := false
-
-					for k := 0; k < len(currentLine); k++ {
-						// Handle escape sequences
-						if escaped {
-							escaped = false
-							continue
-						}
-
-						// Check for escape character
-						if (inString || inChar || inRawString) && currentLine[k] == '\\' && !inRawString {
-							escaped = true
-							continue
-						}
-
-						// Skip processing if in a line comment
-						if inLineComment {
-							continue
-						}
-
-						// Check for start of single-line comment (if not in a string)
-						if !inString && !inChar && !inRawString && k < len(currentLine)-1 &&
-							currentLine[k] == '/' && currentLine[k+1] == '/' && !inBlockComment {
-							inLineComment = true
-							continue
-						}
-
-						// Check for start of multi-line comment (if not in a string)
-						if !inString && !inChar && !inRawString && k < len(currentLine)-1 &&
-							currentLine[k] == '/' && currentLine[k+1] == '*' && !inBlockComment {
-							inBlockComment = true
-							inMultiLineComment = true
-							k++ // Skip the '*'
-							continue
-						}
-
-						// Check for end of multi-line comment
-						if inBlockComment && k < len(currentLine)-1 &&
-							currentLine[k] == '*' && currentLine[k+1] == '/' {
-							inBlockComment = false
-							inMultiLineComment = false
-							k++ // Skip the '/'
-							continue
-						}
-
-						// Handle string literals
-						if !inBlockComment && !inLineComment {
-							// Check for start/end of string literals
-							if !inString && !inChar && !inRawString {
-								// Start of double-quoted string
-								if currentLine[k] == '"' {
-									inString = true
-									stringDelimiter = '"'
-									continue
-								}
-
-								// Start of single-quoted character/string
-								if currentLine[k] == '\'' {
-									inChar = true
-									stringDelimiter = '\''
-									continue
-								}
-
-								// Start of raw string (Go backtick or JS template literal)
-								if language.Name == "Go" && currentLine[k] == '`' {
-									inRawString = true
-									stringDelimiter = '`'
-									continue
-								}
-							} else {
-								// End of string/char/raw string
-								if currentLine[k] == stringDelimiter {
-									if inString {
-										inString = false
-									} else if inChar {
-										inChar = false
-									} else if inRawString {
-										inRawString = false
-									}
-									stringDelimiter = 0
-									continue
-								}
-							}
-						}
-
-						// Only count braces if not in a comment or string
-						if !inBlockComment && !inLineComment && !inString && !inChar && !inRawString {
-							if currentLine[k] == '{' {
-								braceCount++
-								lineCharCount++
-							} else if currentLine[k] == '}' {
-								braceCount--
-								lineCharCount++
-							} else {
-								lineCharCount++
-							}
-						}
-					}
-
-					// For languages with explicit end markers, check if we've reached the end
-					if !inMultiLineComment && !inLineComment && endPattern.MatchString(currentLine) && lineCharCount > 0 {
-						if braceCount <= 0 {
-							endLine = j
-							break
-						}
-					}
-				} else if language.Name == "Python" {
-					// Skip comment lines
-					if strings.HasPrefix(trimmedLine, "#") {
-						continue
-					}
-
-					if startLine == i { // First line after function definition
-						if len(currentLine) > 0 && !strings.HasPrefix(currentLine, " ") && !strings.HasPrefix(currentLine, "\t") {
-							// Non-indented line means end of function
-							break
-						}
-						if len(currentLine) > 0 {
-							// Determine the indentation level of the function body
-							indentLevel = countLeadingWhitespace(currentLine)
-						}
-					} else {
-						if len(currentLine) > 0 && countLeadingWhitespace(currentLine) <= indentLevel {
-							// Line with less indentation means end of function
-							break
-						}
-					}
-				} else if language.Name == "Ruby" {
-					// Skip comment lines
-					if strings.HasPrefix(trimmedLine, "#") {
-						continue
-					}
-
-					if endPattern.MatchString(currentLine) {
-						endLine = j
-						break
-					}
-				} else if endPattern.MatchString(currentLine) {
-					endLine = j
-					break
-				}
-
-				endLine = j
-			}
-
-			// Find comments above the function
-			commentStartLine := startLine
-			for commentStartLine > 0 {
-				prevLine := strings.TrimSpace(lines[commentStartLine-1])
-				if strings.HasPrefix(prevLine, "//") || strings.HasPrefix(prevLine, "/*") ||
-					(commentStartLine > 1 && strings.HasPrefix(lines[commentStartLine-2], "/*") && !strings.Contains(prevLine, "*/")) {
-					// This is a comment line, continue searching upward
-					commentStartLine--
-				} else {
-					// This is not a comment line, stop searching
-					break
-				}
-			}
-
-			// Extract the function content including comments above
-			var functionContent string
-			if commentStartLine < startLine {
-				// Include comments above the function
-				functionContent = strings.Join(lines[commentStartLine:endLine+1], "\n")
-			} else {
-				// No comments above or they were not detected
-				functionContent = strings.Join(lines[startLine:endLine+1], "\n")
-			}
-
-			def := FunctionDefinition{
-				FilePath:    filePath,
-				Language:    language.Name,
-				StartLine:   commentStartLine + 1, // Use the comment start line if available
-				EndLine:     endLine + 1,
-				IsPrototype: false,
-				Content:     functionContent,
-			}
-			definitions = append(definitions, def)
-
-			// Skip to the end of the function
-			i = endLine
-		}
+		definitions = append(definitions, FunctionDefinition{
+			FilePath:    filePath,
+			Language:    language.Name,
+			StartLine:   symbol.DocLine,
+			EndLine:     symbol.EndLine,
+			IsPrototype: symbol.Prototype,
+			Content:     strings.Join(lines[symbol.DocLine-1:symbol.EndLine], "\n"),
+		})
 	}
 
 	return definitions, nil
 }
 
 // ReplaceFunctionDefinition replaces the definition of a function in a file
-func ReplaceFunctionDefinition(filePath, functionName string, language Language, replacementContent string, includePrototype bool) (bool, error) {
+func ReplaceFunctionDefinition(filePath, functionName string, language syntax.Language, replacementContent string, includePrototype bool) (bool, error) {
 	// Get the current function definition
 	definitions, err := GetFunctionDefinition(filePath, functionName, language, includePrototype)
 	if err != nil {
@@ -636,41 +297,21 @@ func ReplaceFunctionDefinition(filePath, functionName string, language Language,
 	// Split the content into lines
 	lines := strings.Split(string(content), "\n")
 
-	// Sort definitions in reverse order to avoid line number changes
-	// when replacing multiple definitions
-	for i := len(definitions) - 1; i >= 0; i-- {
-		def := definitions[i]
-
-		// Skip prototypes if not requested
-		if def.IsPrototype && !includePrototype {
-			continue
+	// Leave out the definitions nested in others, which are replaced with
+	// them
+	var outer []FunctionDefinition
+	for _, def := range definitions {
+		if len(outer) == 0 || def.StartLine > outer[len(outer)-1].EndLine {
+			outer = append(outer, def)
 		}
+	}
 
-		// Replace the function definition
-		if def.IsPrototype {
-			// For prototypes, replace just the one line
-			lines[def.StartLine-1] = replacementContent
-		} else {
-			// For implementations, replace the entire function
-
-			// Find comments above the function
-			commentStartLine := def.StartLine - 1
-			for commentStartLine > 0 {
-				prevLine := strings.TrimSpace(lines[commentStartLine-1])
-				if strings.HasPrefix(prevLine, "//") || strings.HasPrefix(prevLine, "/*") ||
-					(commentStartLine > 1 && strings.HasPrefix(lines[commentStartLine-2], "/*") && !strings.Contains(prevLine, "*/")) {
-					// This is a comment line, continue searching upward
-					commentStartLine--
-				} else {
-					// This is not a comment line, stop searching
-					break
-				}
-			}
-
-			// Replace the function and its comments with the new content
-			newLines := strings.Split(replacementContent, "\n")
-			lines = append(lines[:commentStartLine], append(newLines, lines[def.EndLine:]...)...)
-		}
+	// Replace the definitions in reverse order to avoid line number
+	// changes, each with the comments above it
+	for i := len(outer) - 1; i >= 0; i-- {
+		def := outer[i]
+		newLines := strings.Split(replacementContent, "\n")
+		lines = append(lines[:def.StartLine-1], append(newLines, lines[def.EndLine:]...)...)
 	}
 
 	// Write the modified content back to the file
@@ -682,32 +323,17 @@ func ReplaceFunctionDefinition(filePath, functionName string, language Language,
 	return true, nil
 }
 
-// countLeadingWhitespace counts the number of leading whitespace characters in a string
-func countLeadingWhitespace(s string) int {
-	count := 0
-	for _, char := range s {
-		if char == ' ' {
-			count++
-		} else if char == '\t' {
-			count += 4 // Count tabs as 4 spaces
-		} else {
-			break
-		}
-	}
-	return count
-}
-
 // RegisterFuncDef registers the funcdef tool with the MCP server
 func RegisterFuncDef(mcpServer *server.MCPServer) {
 	// Create the tool definition
 	funcDefTool := mcp.NewTool("funcdef",
-		mcp.WithDescription("Gets or replaces function definitions in source code files across multiple programming languages. Supports Go, JavaScript, Python, Java, C#, C/C++, Ruby, and PHP. Handles complex code patterns including nested functions, comments (single-line and multi-line), and string literals (including those containing braces or comment-like syntax). For C/C++, can optionally include function prototypes in the results. Returns function content with line numbers and provides clear before/after comparisons when replacing functions."),
+		mcp.WithDescription("Gets or replaces function definitions in source code files across multiple programming languages. Supports Go, JavaScript, TypeScript, Python, Java, C#, C/C++, Ruby, and PHP. Parses the file, so it handles complex code patterns including nested functions, comments (single-line and multi-line), and string literals (including those containing braces or comment-like syntax). Can optionally include function prototypes in the results, such as C/C++ declarations and abstract or interface methods. Returns function content with line numbers and provides clear before/after comparisons when replacing functions."),
 		mcp.WithString("operation",
 			mcp.Description("The operation to perform: 'get' to retrieve a function definition or 'replace' to modify it"),
 			mcp.Required(),
 		),
 		mcp.WithString("function_name",
-			mcp.Description("The name of the function to get or replace (case-sensitive in most languages)"),
+			mcp.Description("The name of the function to get or replace (case-sensitive in most languages), optionally qualified by its class or type as in Type.method"),
 			mcp.Required(),
 		),
 		mcp.WithString("file_path",
@@ -715,10 +341,10 @@ func RegisterFuncDef(mcpServer *server.MCPServer) {
 			mcp.Required(),
 		),
 		mcp.WithString("language",
-			mcp.Description("The programming language (if not specified, will be determined from file extension). Supported: Go, JavaScript, Python, Java, C#, C/C++, Ruby, PHP"),
+			mcp.Description("The programming language (if not specified, will be determined from file extension). Supported: Go, JavaScript, TypeScript, Python, Java, C#, C/C++, Ruby, PHP"),
 		),
 		mcp.WithBoolean("include_prototype",
-			mcp.Description("Whether to include function prototypes in C/C++ (declarations separate from implementations)"),
+			mcp.Description("Whether to include function prototypes, such as C/C++ declarations separate from implementations and abstract or interface methods"),
 		),
 		mcp.WithString("replacement_content",
 			mcp.Description("The new content to replace the function with (for 'replace' operation). Must include the complete function definition"),
@@ -0,0 +1,191 @@
+package goedit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// FileChange is the new content of a file changed by an edit
+type FileChange struct {
+	Path        string
+	Occurrences int // Number of places changed, if counted
+	OldContent  string
+	NewContent  string
+}
+
+// Result is a computed edit of Go code, type-checked before anything is
+// written
+type Result struct {
+	Changes    []FileChange
+	Diff       string
+	TypeErrors []string // Errors in the edited code that were not there before
+	Warnings   []string
+}
+
+// Options are the arguments shared by the tools that compute checked edits
+// of a Go module
+type Options struct {
+	SessionID string
+	Mode      string // "preview" or "apply"
+	File      string // Absolute path of the file given, if any
+	Directory string // Directory inside the Go module
+	Timeout   time.Duration
+}
+
+// ParseOptions extracts the session_id, mode, file_path, directory, and
+// timeout arguments. The directory defaults to the file's directory, or the
+// workspace root, and relative paths are resolved against the workspace root.
+func ParseOptions(arguments map[string]interface{}) (Options, error) {
+	var options Options
+
+	// Extract session ID (optional, selects the workspace root)
+	options.SessionID, _ = arguments["session_id"].(string)
+
+	// Extract mode (optional)
+	options.Mode, _ = arguments["mode"].(string)
+	if options.Mode == "" {
+		options.Mode = "preview"
+	}
+	if options.Mode != "preview" && options.Mode != "apply" {
+		return options, fmt.Errorf("unsupported mode: %s", options.Mode)
+	}
+
+	// Extract the file (optional)
+	directory, _ := arguments["directory"].(string)
+	if filePath, _ := arguments["file_path"].(string); filePath != "" {
+		absPath, err := filepath.Abs(workspace.ResolveRelativePath(filePath, options.SessionID))
+		if err != nil {
+			return options, fmt.Errorf("invalid file path: %v", err)
+		}
+		options.File = absPath
+		if directory == "" {
+			directory = filepath.Dir(absPath)
+		}
+	}
+
+	// Resolve the module directory against the workspace root, which is also
+	// the default
+	directory, err := filepath.Abs(workspace.ResolveRelativePath(directory, options.SessionID))
+	if err != nil {
+		return options, fmt.Errorf("invalid directory: %v", err)
+	}
+	if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+		return options, fmt.Errorf("directory not found: %s", directory)
+	}
+	options.Directory = directory
+
+	// Extract timeout (optional)
+	timeoutSec, ok := arguments["timeout"].(float64)
+	if !ok {
+		// Default timeout: 2 minutes
+		timeoutSec = 120
+	}
+	options.Timeout = time.Duration(timeoutSec * float64(time.Second))
+
+	return options, nil
+}
+
+// Context returns a context for loading and checking the module, which ends
+// after the timeout
+func (o Options) Context(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, o.Timeout)
+}
+
+// Apply writes the changed files
+func (r *Result) Apply() error {
+	for _, change := range r.Changes {
+		if err := WriteFile(change.Path, []byte(change.NewContent)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finish applies the result in apply mode, unless the edited code doesn't
+// type-check, remembers the changed files as the last edit of the workspace,
+// and returns the tool result: the title, the mode, the details, the type
+// check, the warnings, whether the changes were applied, and the diff
+func (r *Result) Finish(tool string, options Options, title, details string) (*mcp.CallToolResult, error) {
+	applied := false
+	if options.Mode == "apply" && len(r.TypeErrors) == 0 {
+		if err := r.Apply(); err != nil {
+			return nil, err
+		}
+		applied = true
+
+		// Remember the changed files for tools that check the last edit
+		var paths []string
+		for _, change := range r.Changes {
+			paths = append(paths, change.Path)
+		}
+		workspace.RecordEdit(options.SessionID, tool, paths)
+	}
+
+	resultText := title + "\n\n"
+	resultText += fmt.Sprintf("Mode: %s\n", options.Mode)
+	resultText += details
+	if len(r.TypeErrors) == 0 {
+		resultText += "Type check: OK\n"
+	} else {
+		resultText += fmt.Sprintf("Type check: %d new errors\n", len(r.TypeErrors))
+		for _, typeError := range r.TypeErrors {
+			resultText += fmt.Sprintf("  %s\n", typeError)
+		}
+	}
+	for _, warning := range r.Warnings {
+		resultText += fmt.Sprintf("Warning: %s\n", warning)
+	}
+
+	switch {
+	case applied:
+		resultText += "\nThe changes have been applied.\n"
+	case options.Mode == "apply":
+		resultText += "\nThe changes have NOT been applied because the edited code does not type-check.\n"
+	default:
+		resultText += "\nPreview only; call again with mode 'apply' to make the changes.\n"
+	}
+
+	resultText += "\n" + r.Diff
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: artifact.Limit(tool, resultText),
+			},
+		},
+	}, nil
+}
+
+// WriteFile writes the new content of a file, keeping its permissions
+func WriteFile(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, content, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// KeepFormatted formats the new content of a Go file with gofmt if the old
+// content was formatted, so that formatted files stay formatted without
+// reformatting others
+func KeepFormatted(oldContent, newContent []byte) []byte {
+	if formatted, err := format.Source(oldContent); err == nil && bytes.Equal(formatted, oldContent) {
+		if formatted, err := format.Source(newContent); err == nil {
+			return formatted
+		}
+	}
+	return newContent
+}
@@ -0,0 +1,335 @@
+package goload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Package is a type-checked package of the main module. The package
+// includes its in-package test files, so that renaming and other edits
+// reach them; external test packages (package foo_test) are separate
+// packages with XTest set.
+type Package struct {
+	ImportPath string
+	Name       string
+	Dir        string
+	Files      []*ast.File
+	Filenames  []string
+	Types      *types.Package
+	Info       *types.Info
+	Errors     []error // Parse and type errors
+	XTest      bool
+
+	imports []string // Import paths of the package and its test files
+}
+
+// Program is the set of type-checked packages of a Go module
+type Program struct {
+	Fset       *token.FileSet
+	ModulePath string
+	ModuleDir  string
+	Packages   []*Package // In dependency order, with external test packages last
+
+	byPath map[string]*Package
+	byFile map[string]*Package
+}
+
+// listedPackage is the part of the output of go list -json used here
+type listedPackage struct {
+	ImportPath   string
+	Name         string
+	Dir          string
+	Export       string
+	GoFiles      []string
+	CgoFiles     []string
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Imports      []string
+	TestImports  []string
+	XTestImports []string
+	ImportMap    map[string]string
+	Module       *struct {
+		Path string
+		Dir  string
+		Main bool
+	}
+}
+
+// Load type-checks the packages of the Go module that contains dir.
+// Packages of the module are parsed and checked from source, with the files
+// in overlay replacing the ones on disk, so that edits can be checked before
+// they are written. Dependencies are read from the compiler's export data,
+// which go list builds as needed.
+func Load(ctx context.Context, dir string, overlay map[string][]byte) (*Program, error) {
+	listed, err := goList(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prog := &Program{
+		Fset:   token.NewFileSet(),
+		byPath: make(map[string]*Package),
+		byFile: make(map[string]*Package),
+	}
+
+	exports := make(map[string]string)
+	importMaps := make(map[string]map[string]string)
+	var modulePackages []*listedPackage
+	for _, p := range listed {
+		if strings.Contains(p.ImportPath, " [") || strings.HasSuffix(p.ImportPath, ".test") {
+			// Test variants, which the packages themselves cover
+			continue
+		}
+		if p.Module != nil && p.Module.Main {
+			prog.ModulePath = p.Module.Path
+			prog.ModuleDir = p.Module.Dir
+			modulePackages = append(modulePackages, p)
+			importMaps[p.ImportPath] = p.ImportMap
+		} else if p.Export != "" {
+			exports[p.ImportPath] = p.Export
+		}
+	}
+	if len(modulePackages) == 0 {
+		return nil, fmt.Errorf("no Go packages found in %s", dir)
+	}
+
+	// Parse the packages of the module
+	for _, p := range modulePackages {
+		pkg := &Package{ImportPath: p.ImportPath, Name: p.Name, Dir: p.Dir}
+		pkg.imports = append(append([]string{}, p.Imports...), p.TestImports...)
+		files := append(append(append([]string{}, p.GoFiles...), p.CgoFiles...), p.TestGoFiles...)
+		prog.parseFiles(pkg, files, overlay)
+		prog.add(pkg)
+
+		if len(p.XTestGoFiles) > 0 {
+			xtest := &Package{ImportPath: p.ImportPath + "_test", Name: p.Name + "_test", Dir: p.Dir, XTest: true}
+			xtest.imports = p.XTestImports
+			prog.parseFiles(xtest, p.XTestGoFiles, overlay)
+			prog.add(xtest)
+		}
+	}
+
+	// Type-check the packages after the module packages they import
+	gcImporter := importer.ForCompiler(prog.Fset, "gc", func(path string) (io.ReadCloser, error) {
+		if export, exists := exports[path]; exists {
+			return os.Open(export)
+		}
+		return nil, fmt.Errorf("no export data for %s", path)
+	}).(types.ImporterFrom)
+
+	for _, pkg := range prog.sortedPackages() {
+		prog.check(pkg, gcImporter, importMaps[strings.TrimSuffix(pkg.ImportPath, "_test")])
+	}
+
+	return prog, nil
+}
+
+// goList lists the module's packages and all their dependencies, including
+// those of tests, with their export data
+func goList(ctx context.Context, dir string) ([]*listedPackage, error) {
+	cmd := exec.CommandContext(ctx, "go", "list", "-e", "-json", "-deps", "-test", "-export", "./...")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var listed []*listedPackage
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	for decoder.More() {
+		var p listedPackage
+		if err := decoder.Decode(&p); err != nil {
+			return nil, fmt.Errorf("failed to parse go list output: %v", err)
+		}
+		listed = append(listed, &p)
+	}
+	return listed, nil
+}
+
+// parseFiles parses the named files of a package, reading them from the
+// overlay if it has them
+func (prog *Program) parseFiles(pkg *Package, names []string, overlay map[string][]byte) {
+	for _, name := range names {
+		filename := filepath.Join(pkg.Dir, name)
+
+		var src interface{}
+		if content, exists := overlay[filename]; exists {
+			src = content
+		}
+		file, err := parser.ParseFile(prog.Fset, filename, src, parser.ParseComments)
+		if err != nil {
+			pkg.Errors = append(pkg.Errors, err)
+		}
+		if file != nil {
+			pkg.Files = append(pkg.Files, file)
+			pkg.Filenames = append(pkg.Filenames, filename)
+		}
+	}
+}
+
+// add adds a parsed package to the program
+func (prog *Program) add(pkg *Package) {
+	prog.byPath[pkg.ImportPath] = pkg
+	for _, filename := range pkg.Filenames {
+		prog.byFile[filename] = pkg
+	}
+}
+
+// sortedPackages returns the packages with every package after the module
+// packages it imports. Test files can import packages that the package
+// itself doesn't, so the order of go list is not enough.
+func (prog *Program) sortedPackages() []*Package {
+	var paths []string
+	for path := range prog.byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var sorted []*Package
+	visited := make(map[string]bool)
+	var visit func(pkg *Package)
+	visit = func(pkg *Package) {
+		if visited[pkg.ImportPath] {
+			return
+		}
+		visited[pkg.ImportPath] = true
+		for _, path := range pkg.imports {
+			if imported, exists := prog.byPath[path]; exists && !imported.XTest {
+				visit(imported)
+			}
+		}
+		sorted = append(sorted, pkg)
+	}
+
+	// External test packages go last, as they can import any package
+	for _, xtests := range []bool{false, true} {
+		for _, path := range paths {
+			if pkg := prog.byPath[path]; pkg.XTest == xtests {
+				visit(pkg)
+			}
+		}
+	}
+
+	prog.Packages = sorted
+	return sorted
+}
+
+// check type-checks a package, importing packages of the module from their
+// checked source and other packages from export data
+func (prog *Program) check(pkg *Package, gcImporter types.ImporterFrom, importMap map[string]string) {
+	pkg.Info = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+
+	config := &types.Config{
+		Importer:    &moduleImporter{prog: prog, importMap: importMap, fallback: gcImporter},
+		FakeImportC: true,
+		Error: func(err error) {
+			pkg.Errors = append(pkg.Errors, err)
+		},
+	}
+
+	typesPkg, _ := config.Check(pkg.ImportPath, prog.Fset, pkg.Files, pkg.Info)
+	pkg.Types = typesPkg
+}
+
+// moduleImporter imports packages for type checking
+type moduleImporter struct {
+	prog      *Program
+	importMap map[string]string
+	fallback  types.ImporterFrom
+}
+
+// Import imports a package by path
+func (m *moduleImporter) Import(path string) (*types.Package, error) {
+	return m.ImportFrom(path, "", 0)
+}
+
+// ImportFrom imports a package by path, resolving vendored paths
+func (m *moduleImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if mapped, exists := m.importMap[path]; exists {
+		path = mapped
+	}
+	if pkg, exists := m.prog.byPath[path]; exists && !pkg.XTest {
+		if pkg.Types == nil {
+			return nil, fmt.Errorf("import cycle through %s", path)
+		}
+		return pkg.Types, nil
+	}
+	return m.fallback.ImportFrom(path, dir, mode)
+}
+
+// Package returns a package of the module by import path
+func (prog *Program) Package(path string) *Package {
+	return prog.byPath[path]
+}
+
+// FilePackage returns the package that contains a file, and the file's syntax
+func (prog *Program) FilePackage(filename string) (*Package, *ast.File) {
+	pkg, exists := prog.byFile[filename]
+	if !exists {
+		return nil, nil
+	}
+	for i, name := range pkg.Filenames {
+		if name == filename {
+			return pkg, pkg.Files[i]
+		}
+	}
+	return nil, nil
+}
+
+// Errors returns the errors of all packages
+func (prog *Program) Errors() []error {
+	var errs []error
+	for _, pkg := range prog.Packages {
+		errs = append(errs, pkg.Errors...)
+	}
+	return errs
+}
+
+// Offset returns the byte offset in its file of a one-based line and
+// column, where the column counts characters
+func Offset(content []byte, line, column int) (int, error) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return 0, fmt.Errorf("line %d is out of range", line)
+	}
+
+	offset := 0
+	for i := 0; i < line-1; i++ {
+		offset += len(lines[i])
+	}
+
+	text := string(lines[line-1])
+	characters := 0
+	for i := range text {
+		if characters == column-1 {
+			return offset + i, nil
+		}
+		characters++
+	}
+	if characters == column-1 {
+		return offset + len(text), nil
+	}
+	return 0, fmt.Errorf("column %d is out of range on line %d", column, line)
+}
@@ -0,0 +1,116 @@
+package goload
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Pos returns the position of a one-based line and column in a file of the
+// program, where the column counts characters, with the file's package and
+// syntax
+func (prog *Program) Pos(filename string, line, column int) (*Package, *ast.File, token.Pos, error) {
+	pkg, file := prog.FilePackage(filename)
+	if pkg == nil {
+		return nil, nil, token.NoPos, fmt.Errorf("%s is not part of a package in module %s", filename, prog.ModulePath)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, token.NoPos, fmt.Errorf("failed to read file: %v", err)
+	}
+	offset, err := Offset(content, line, column)
+	if err != nil {
+		return nil, nil, token.NoPos, err
+	}
+	return pkg, file, prog.Fset.File(file.Pos()).Pos(offset), nil
+}
+
+// IdentAt returns the identifier at a one-based line and column of a file,
+// and the object it declares or refers to
+func (prog *Program) IdentAt(filename string, line, column int) (*ast.Ident, types.Object, error) {
+	pkg, file, pos, err := prog.Pos(filename, line, column)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var found *ast.Ident
+	ast.Inspect(file, func(n ast.Node) bool {
+		if found != nil || n == nil || pos < n.Pos() || pos >= n.End() {
+			return false
+		}
+		if ident, ok := n.(*ast.Ident); ok {
+			found = ident
+		}
+		return found == nil
+	})
+	if found == nil {
+		return nil, nil, fmt.Errorf("no identifier at %s:%d:%d", filepath.Base(filename), line, column)
+	}
+
+	if obj := pkg.Info.Defs[found]; obj != nil {
+		return found, obj, nil
+	}
+	if obj := pkg.Info.Uses[found]; obj != nil {
+		return found, obj, nil
+	}
+	return nil, nil, fmt.Errorf("%s at %s:%d:%d does not name a declared object", found.Name, filepath.Base(filename), line, column)
+}
+
+// LookupName finds an object by qualified name: an import path, or a path
+// relative to the module, followed by a package-level name and optionally a
+// field or method, such as pkg/shapes.Circle.Area. The root package of the
+// module can also be given by its package name.
+func (prog *Program) LookupName(name string) (types.Object, error) {
+	slash := strings.LastIndex(name, "/")
+	parts := strings.Split(name[slash+1:], ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid qualified name %q, expected package.Name or package.Type.Member", name)
+	}
+	path := name[:slash+1] + parts[0]
+
+	pkg := prog.Package(path)
+	if pkg == nil {
+		pkg = prog.Package(prog.ModulePath + "/" + strings.TrimPrefix(path, "./"))
+	}
+	if root := prog.Package(prog.ModulePath); pkg == nil && root != nil && path == root.Name {
+		// The root package has no relative path, so it goes by its name
+		pkg = root
+	}
+	if pkg == nil || pkg.Types == nil {
+		return nil, fmt.Errorf("package %s not found in module %s", path, prog.ModulePath)
+	}
+
+	obj := pkg.Types.Scope().Lookup(parts[1])
+	if obj == nil {
+		return nil, fmt.Errorf("%s not found in package %s", parts[1], pkg.ImportPath)
+	}
+	if len(parts) == 2 {
+		return obj, nil
+	}
+
+	member, _, _ := types.LookupFieldOrMethod(obj.Type(), true, pkg.Types, parts[2])
+	if member == nil {
+		return nil, fmt.Errorf("%s has no field or method %s", parts[1], parts[2])
+	}
+	return member, nil
+}
+
+// Scope returns the innermost scope of the package that contains pos
+func (pkg *Package) Scope(pos token.Pos) *types.Scope {
+	if pkg.Types == nil {
+		return nil
+	}
+	for _, file := range pkg.Files {
+		if file.Pos() <= pos && pos <= file.End() {
+			if scope := pkg.Info.Scopes[file]; scope != nil {
+				return scope.Innermost(pos)
+			}
+		}
+	}
+	return nil
+}
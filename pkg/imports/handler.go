@@ -0,0 +1,360 @@
+package imports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/diff"
+	"github.com/Code-Monger/CodeSpinneret/pkg/goedit"
+	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HandleImports is the handler function for the imports tool
+func HandleImports(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+
+	// Extract operation
+	operation, ok := arguments["operation"].(string)
+	if !ok || operation == "" {
+		return nil, fmt.Errorf("operation must be a non-empty string")
+	}
+
+	// Extract session ID (optional, selects the workspace root)
+	sessionID, _ := arguments["session_id"].(string)
+
+	// Extract file path
+	filePath, ok := arguments["file_path"].(string)
+	if !ok || filePath == "" {
+		return nil, fmt.Errorf("file_path must be a non-empty string")
+	}
+	absPath, err := filepath.Abs(workspace.ResolveRelativePath(filePath, sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("invalid file path: %v", err)
+	}
+	if !strings.HasSuffix(absPath, ".go") {
+		return nil, fmt.Errorf("not a Go file: %s", filePath)
+	}
+
+	// Extract import paths (required for add and remove)
+	var paths []string
+	if pathsArg, ok := arguments["paths"].([]interface{}); ok {
+		for _, p := range pathsArg {
+			if pathStr, ok := p.(string); ok && strings.TrimSpace(pathStr) != "" {
+				paths = append(paths, strings.TrimSpace(pathStr))
+			}
+		}
+	}
+
+	// Extract preview flag
+	preview := false
+	if previewBool, ok := arguments["preview"].(bool); ok {
+		preview = previewBool
+	}
+
+	// Extract timeout (optional)
+	timeoutSec, ok := arguments["timeout"].(float64)
+	if !ok {
+		// Default timeout: 1 minute
+		timeoutSec = 60
+	}
+	listCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec*float64(time.Second)))
+	defer cancel()
+
+	oldContent, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	var newContent []byte
+	var warnings []string
+	switch operation {
+	case "add":
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("paths is required for add")
+		}
+		add := make(map[string]string)
+		for _, p := range paths {
+			name, importPath, err := parseImport(p)
+			if err != nil {
+				return nil, err
+			}
+			add[importPath] = name
+		}
+		newContent, err = Add(oldContent, add)
+
+	case "remove":
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("paths is required for remove")
+		}
+		var remove []string
+		for _, p := range paths {
+			_, importPath, err := parseImport(p)
+			if err != nil {
+				return nil, err
+			}
+			remove = append(remove, importPath)
+		}
+		newContent, err = Remove(oldContent, remove)
+
+	case "organize":
+		var resolver *Resolver
+		resolver, err = NewResolver(listCtx, filepath.Dir(absPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list packages: %v", err)
+		}
+		newContent, err = Organize(oldContent, resolver.Names())
+
+	case "fix":
+		newContent, warnings, err = fixFile(listCtx, absPath, oldContent)
+
+	default:
+		return nil, fmt.Errorf("unsupported operation: %s", operation)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update the imports of %s: %v", filePath, err)
+	}
+
+	// Warn about imports that were already there, or not there to remove
+	if operation == "add" || operation == "remove" {
+		warnings = append(warnings, unchangedImports(operation, paths, oldContent)...)
+	}
+
+	if formatted, err := format.Source(newContent); err == nil {
+		newContent = formatted
+	} else {
+		warnings = append(warnings, fmt.Sprintf("the file could not be formatted: %v", err))
+	}
+
+	changed := !bytes.Equal(oldContent, newContent)
+	if changed && !preview {
+		if err := goedit.WriteFile(absPath, newContent); err != nil {
+			return nil, err
+		}
+		workspace.RecordEdit(sessionID, "imports", []string{absPath})
+	}
+
+	// Summarize the imports that changed
+	oldSpecs, _ := Specs(oldContent)
+	newSpecs, _ := Specs(newContent)
+	added, removed := compareSpecs(oldSpecs, newSpecs)
+
+	resultText := fmt.Sprintf("Imports (%s) of %s\n\n", operation, filePath)
+	resultText += fmt.Sprintf("Preview: %t\n", preview)
+	if len(added) > 0 {
+		resultText += fmt.Sprintf("Added: %s\n", strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		resultText += fmt.Sprintf("Removed: %s\n", strings.Join(removed, ", "))
+	}
+	for _, warning := range warnings {
+		resultText += fmt.Sprintf("Warning: %s\n", warning)
+	}
+
+	switch {
+	case !changed:
+		resultText += "\nThe file is unchanged.\n"
+	case preview:
+		resultText += "\nPreview only; call again without preview to make the changes.\n"
+	default:
+		resultText += "\nThe changes have been applied.\n"
+	}
+	if changed {
+		name := filepath.Base(absPath)
+		resultText += "\n" + diff.Unified("a/"+name, "b/"+name, string(oldContent), string(newContent), diff.DefaultContext)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: resultText,
+			},
+		},
+	}, nil
+}
+
+// fixFile removes the unused imports of a file and adds the packages it
+// uses without importing them
+func fixFile(ctx context.Context, path string, content []byte) ([]byte, []string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Names declared and imports used by the other files of the package.
+	// Test files see the package's other files, but not the reverse.
+	dir := filepath.Dir(path)
+	declared := make(map[string]bool)
+	preferred := make(map[string]bool)
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || name == filepath.Base(path) {
+			continue
+		}
+		if strings.HasSuffix(name, "_test.go") && !strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		other, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil || other.Name.Name != file.Name.Name {
+			continue
+		}
+		for declaredName := range declaredNames(other) {
+			declared[declaredName] = true
+		}
+		for _, spec := range other.Imports {
+			preferred[specImportPath(spec)] = true
+		}
+	}
+
+	resolver, err := NewResolver(ctx, dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list packages: %v", err)
+	}
+	names := resolver.Names()
+	importer := resolver.ImportPath(dir)
+
+	var warnings []string
+	add := make(map[string]string)
+	missing := Missing(file, declared, names)
+	var missingNames []string
+	for name := range missing {
+		missingNames = append(missingNames, name)
+	}
+	sort.Strings(missingNames)
+	for _, name := range missingNames {
+		resolution := resolver.Resolve(name, missing[name], importer, preferred)
+		if resolution.ImportPath == "" {
+			warnings = append(warnings, fmt.Sprintf("no package %s exporting %s was found", name, strings.Join(missing[name], ", ")))
+			continue
+		}
+		add[resolution.ImportPath] = name
+		if len(resolution.Others) > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s resolved to %q; other candidates: %s", name, resolution.ImportPath, strings.Join(resolution.Others, ", ")))
+		}
+	}
+
+	content, err = Fix(content, names, add)
+	return content, warnings, err
+}
+
+// parseImport splits an import given as a path, or as a name and a path,
+// with optional quotes
+func parseImport(text string) (string, string, error) {
+	fields := strings.Fields(text)
+	var name, importPath string
+	switch len(fields) {
+	case 1:
+		importPath = fields[0]
+	case 2:
+		name, importPath = fields[0], fields[1]
+	default:
+		return "", "", fmt.Errorf("invalid import: %s", text)
+	}
+	if unquoted, err := strconv.Unquote(importPath); err == nil {
+		importPath = unquoted
+	}
+	if importPath == "" {
+		return "", "", fmt.Errorf("invalid import: %s", text)
+	}
+	return name, importPath, nil
+}
+
+// unchangedImports returns warnings for the imports that add found already
+// imported, or that remove didn't find
+func unchangedImports(operation string, paths []string, content []byte) []string {
+	imported := make(map[string]bool)
+	if file, err := parser.ParseFile(token.NewFileSet(), "", content, parser.ImportsOnly); err == nil {
+		for _, spec := range file.Imports {
+			imported[specImportPath(spec)] = true
+		}
+	}
+
+	var warnings []string
+	for _, p := range paths {
+		_, importPath, _ := parseImport(p)
+		switch {
+		case operation == "add" && imported[importPath]:
+			warnings = append(warnings, fmt.Sprintf("%q is already imported", importPath))
+		case operation == "remove" && !imported[importPath]:
+			warnings = append(warnings, fmt.Sprintf("%q is not imported", importPath))
+		}
+	}
+	return warnings
+}
+
+// compareSpecs returns the import specs that were added and removed
+func compareSpecs(oldSpecs, newSpecs []string) ([]string, []string) {
+	count := make(map[string]int)
+	for _, spec := range oldSpecs {
+		count[spec]++
+	}
+	var added []string
+	for _, spec := range newSpecs {
+		if count[spec] > 0 {
+			count[spec]--
+			continue
+		}
+		added = append(added, spec)
+	}
+	var removed []string
+	for _, spec := range oldSpecs {
+		if count[spec] > 0 {
+			count[spec]--
+			removed = append(removed, spec)
+		}
+	}
+	return added, removed
+}
+
+// RegisterImports registers the imports tool with the MCP server
+func RegisterImports(mcpServer *server.MCPServer) {
+	// Create the tool definition
+	importsTool := mcp.NewTool("imports",
+		mcp.WithDescription("Manages the imports of a Go file. 'add' and 'remove' add or remove the given import paths. 'organize' merges the import declarations into one, removes duplicate and unused imports, and groups the standard library before other packages. 'fix' works like goimports: it removes unused imports and adds the packages the file uses without importing them, found among the standard library and the module's packages and dependencies. The file is formatted with go/format and the change returned as a unified diff."),
+		mcp.WithString("operation",
+			mcp.Required(),
+			mcp.Description("Operation to perform: 'add', 'remove', 'organize', or 'fix'"),
+		),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Go file to update, relative to the workspace root"),
+		),
+		mcp.WithArray("paths",
+			mcp.Description("Import paths to add or remove (add, remove). For add, an entry may also be a name and a path, e.g. 'yaml gopkg.in/yaml.v3'"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Workspace session ID, whose root resolves relative paths (optional)"),
+		),
+		mcp.WithBoolean("preview",
+			mcp.Description("Only return the diff without writing the file (default: false)"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Timeout in seconds for listing packages (organize, fix; default: 60)"),
+		),
+	)
+
+	// Wrap the handler with stats tracking
+	wrappedHandler := stats.WrapHandler("imports", HandleImports)
+
+	// Register the tool with the wrapped handler
+	mcpServer.AddTool(importsTool, wrappedHandler)
+
+	// Log the registration
+	log.Printf("[Imports] Registered imports tool")
+}
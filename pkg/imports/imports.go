@@ -0,0 +1,404 @@
+package imports
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// textEdit replaces the bytes from start to end of a file
+type textEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// Fix removes the imports that a file doesn't use and adds the ones in add,
+// which maps import paths to package names. names gives the package names
+// of the file's existing imports, for those that don't declare one; others
+// are guessed from their paths.
+func Fix(content []byte, names, add map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	used := usedNames(file)
+	var edits []textEdit
+	imported := make(map[string]bool)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+
+		var unused []*ast.ImportSpec
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			importPath := specImportPath(importSpec)
+			name := importName(importSpec, importPath, names)
+			if name == "_" || name == "." || importPath == "C" || used[name] {
+				imported[importPath] = true
+				continue
+			}
+			unused = append(unused, importSpec)
+		}
+		edits = append(edits, removeSpecs(fset, content, genDecl, unused)...)
+	}
+
+	var missing []string
+	for importPath := range add {
+		if !imported[importPath] {
+			missing = append(missing, importPath)
+		}
+	}
+	if len(edits) == 0 && len(missing) == 0 {
+		return content, nil
+	}
+
+	content, err = applyEdits(content, edits)
+	if err != nil {
+		return nil, err
+	}
+	return Add(content, add)
+}
+
+// Add adds the imports in add, which maps import paths to package names, to
+// a file that doesn't import them yet. Names that match the last element of
+// the path are left out of the import.
+func Add(content []byte, add map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := make(map[string]bool)
+	for _, spec := range file.Imports {
+		imported[specImportPath(spec)] = true
+	}
+	var missing []string
+	for importPath := range add {
+		if !imported[importPath] {
+			missing = append(missing, importPath)
+		}
+	}
+	if len(missing) == 0 {
+		return content, nil
+	}
+
+	// Standard library packages go first, so that they come before a new
+	// group of other packages inserted at the same place
+	sort.Slice(missing, func(i, j int) bool {
+		if isStandard(missing[i]) != isStandard(missing[j]) {
+			return isStandard(missing[i])
+		}
+		return missing[i] < missing[j]
+	})
+	return addImports(fset, file, content, missing, add)
+}
+
+// Remove removes the imports of the given paths from a file, and the import
+// declarations that become empty
+func Remove(content []byte, paths []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	remove := make(map[string]bool)
+	for _, importPath := range paths {
+		remove[importPath] = true
+	}
+
+	var edits []textEdit
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+		var removed []*ast.ImportSpec
+		for _, spec := range genDecl.Specs {
+			if importSpec := spec.(*ast.ImportSpec); remove[specImportPath(importSpec)] {
+				removed = append(removed, importSpec)
+			}
+		}
+		edits = append(edits, removeSpecs(fset, content, genDecl, removed)...)
+	}
+	return applyEdits(content, edits)
+}
+
+// Specs returns the imports of a file as they would be written in an import
+// declaration, e.g. `"fmt"` or `yaml "gopkg.in/yaml.v3"`
+func Specs(content []byte) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", content, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	var specs []string
+	for _, spec := range file.Imports {
+		text := spec.Path.Value
+		if spec.Name != nil {
+			text = spec.Name.Name + " " + text
+		}
+		specs = append(specs, text)
+	}
+	return specs, nil
+}
+
+// usedNames returns the names that a file uses as the operand of a
+// selector without declaring them, which includes the names of the
+// packages it uses
+func usedNames(file *ast.File) map[string]bool {
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if selector, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok && ident.Obj == nil {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+	return used
+}
+
+// removeSpecs returns the edits that remove some specs of an import
+// declaration, or the whole declaration if that removes all its specs
+func removeSpecs(fset *token.FileSet, content []byte, decl *ast.GenDecl, specs []*ast.ImportSpec) []textEdit {
+	if len(specs) == 0 {
+		return nil
+	}
+	if len(specs) == len(decl.Specs) {
+		start := fset.Position(decl.Pos()).Offset
+		if decl.Doc != nil {
+			start = fset.Position(decl.Doc.Pos()).Offset
+		}
+		return []textEdit{{start: lineStart(content, start), end: lineEnd(content, fset.Position(decl.End()).Offset)}}
+	}
+
+	var edits []textEdit
+	for _, spec := range specs {
+		start := spec.Pos()
+		if spec.Doc != nil {
+			start = spec.Doc.Pos()
+		}
+		end := spec.End()
+		if spec.Comment != nil {
+			end = spec.Comment.End()
+		}
+		edits = append(edits, textEdit{start: lineStart(content, fset.Position(start).Offset), end: lineEnd(content, fset.Position(end).Offset)})
+	}
+	return edits
+}
+
+// addImports adds imports to the first import declaration of a file, or to
+// a new one after the package clause
+func addImports(fset *token.FileSet, file *ast.File, content []byte, missing []string, names map[string]string) ([]byte, error) {
+	specText := func(importPath string) string {
+		if name := names[importPath]; name != "" && name != defaultName(importPath) {
+			return name + " " + strconv.Quote(importPath)
+		}
+		return strconv.Quote(importPath)
+	}
+
+	var importDecl *ast.GenDecl
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT && !isCgo(genDecl) {
+			importDecl = genDecl
+			break
+		}
+	}
+
+	var edits []textEdit
+	switch {
+	case importDecl == nil:
+		var builder strings.Builder
+		builder.WriteString("\nimport (\n")
+		for i, importPath := range missing {
+			if i > 0 && isStandard(importPath) != isStandard(missing[i-1]) {
+				builder.WriteString("\n")
+			}
+			builder.WriteString("\t" + specText(importPath) + "\n")
+		}
+		builder.WriteString(")\n")
+		offset := lineEnd(content, fset.Position(file.Name.End()).Offset)
+		edits = append(edits, textEdit{start: offset, end: offset, text: builder.String()})
+
+	case !importDecl.Lparen.IsValid():
+		// Turn the single import into a list
+		existing := fset.Position(importDecl.Specs[0].Pos()).Offset
+		existingEnd := fset.Position(importDecl.Specs[0].End()).Offset
+		all := []string{string(content[existing:existingEnd])}
+		for _, importPath := range missing {
+			all = append(all, specText(importPath))
+		}
+		standard := func(text string) bool {
+			importPath, _ := strconv.Unquote(specPath(text))
+			return isStandard(importPath)
+		}
+		sort.Slice(all, func(i, j int) bool {
+			if standard(all[i]) != standard(all[j]) {
+				return standard(all[i])
+			}
+			return specPath(all[i]) < specPath(all[j])
+		})
+		text := "import (\n"
+		for i, spec := range all {
+			if i > 0 && standard(spec) != standard(all[i-1]) {
+				text += "\n"
+			}
+			text += "\t" + spec + "\n"
+		}
+		start := fset.Position(importDecl.Pos()).Offset
+		edits = append(edits, textEdit{
+			start: start,
+			end:   fset.Position(importDecl.End()).Offset,
+			text:  text + ")",
+		})
+
+	default:
+		specs := importDecl.Specs
+		grouped := make(map[bool]bool)
+		for _, importPath := range missing {
+			std := isStandard(importPath)
+
+			// Insert the import in order among the imports of its kind, or
+			// in a group of its own
+			offset := -1
+			text := "\t" + specText(importPath) + "\n"
+			var lastOfKind ast.Spec
+			for _, spec := range specs {
+				specImportPath := specImportPath(spec.(*ast.ImportSpec))
+				if isStandard(specImportPath) != std {
+					continue
+				}
+				if specImportPath > importPath {
+					offset = lineStart(content, fset.Position(spec.Pos()).Offset)
+					break
+				}
+				lastOfKind = spec
+			}
+			if offset < 0 && lastOfKind != nil {
+				offset = lineEnd(content, fset.Position(lastOfKind.End()).Offset)
+			}
+			if offset < 0 {
+				separate := len(specs) > 0 && !grouped[std]
+				grouped[std] = true
+				if std {
+					offset = lineEnd(content, fset.Position(importDecl.Lparen).Offset)
+					if separate {
+						text += "\n"
+					}
+				} else {
+					offset = lineStart(content, fset.Position(importDecl.Rparen).Offset)
+					if separate {
+						text = "\n" + text
+					}
+				}
+			}
+			edits = append(edits, textEdit{start: offset, end: offset, text: text})
+		}
+	}
+
+	return applyEdits(content, edits)
+}
+
+// applyEdits applies non-overlapping edits to content
+func applyEdits(content []byte, edits []textEdit) ([]byte, error) {
+	sorted := append([]textEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+
+	var buffer bytes.Buffer
+	last := 0
+	for _, e := range sorted {
+		if e.start < last || e.end < e.start || e.end > len(content) {
+			return nil, fmt.Errorf("overlapping edits")
+		}
+		buffer.Write(content[last:e.start])
+		buffer.WriteString(e.text)
+		last = e.end
+	}
+	buffer.Write(content[last:])
+	return buffer.Bytes(), nil
+}
+
+// lineStart returns the offset of the start of the line containing offset
+func lineStart(content []byte, offset int) int {
+	return bytes.LastIndexByte(content[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset just after the newline that ends the line
+// containing offset
+func lineEnd(content []byte, offset int) int {
+	if i := bytes.IndexByte(content[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(content)
+}
+
+// isCgo reports whether an import declaration imports "C", whose comment
+// is the cgo preamble and so must stay separate
+func isCgo(decl *ast.GenDecl) bool {
+	for _, spec := range decl.Specs {
+		if specImportPath(spec.(*ast.ImportSpec)) == "C" {
+			return true
+		}
+	}
+	return false
+}
+
+// specImportPath returns the import path of an import spec
+func specImportPath(spec *ast.ImportSpec) string {
+	importPath, _ := strconv.Unquote(spec.Path.Value)
+	return importPath
+}
+
+// importName returns the name that an import declares
+func importName(spec *ast.ImportSpec, importPath string, names map[string]string) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	if name := names[importPath]; name != "" {
+		return name
+	}
+	return defaultName(importPath)
+}
+
+// defaultName guesses the package name of an import path from its last
+// element, skipping a major version suffix
+func defaultName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") && strings.Trim(base[1:], "0123456789") == "" && base != "v" {
+		base = path.Base(path.Dir(importPath))
+	}
+	if i := strings.LastIndex(base, ".v"); i > 0 && i+2 < len(base) && strings.Trim(base[i+2:], "0123456789") == "" {
+		// gopkg.in/yaml.v3
+		base = base[:i]
+	}
+	base = strings.TrimPrefix(base, "go-")
+	base = strings.TrimSuffix(base, ".go")
+	return strings.NewReplacer("-", "_", ".", "_").Replace(base)
+}
+
+// specPath returns the import path of the text of an import spec
+func specPath(text string) string {
+	if i := strings.IndexByte(text, '"'); i >= 0 {
+		return text[i:]
+	}
+	return text
+}
+
+// isStandard reports whether an import path belongs to the standard library,
+// whose first element has no dot
+func isStandard(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
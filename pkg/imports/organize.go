@@ -0,0 +1,133 @@
+package imports
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// organizedSpec is an import kept by Organize, with its comments
+type organizedSpec struct {
+	path string
+	text string
+}
+
+// Organize merges the import declarations of a file into one, drops
+// duplicate and unused imports, and sorts the rest into a group of standard
+// library packages followed by a group of other packages. names gives the
+// package names of imports that don't declare one, as for Fix. Declarations
+// that import "C" are left alone, as their comment is the cgo preamble.
+func Organize(content []byte, names map[string]string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var decls []*ast.GenDecl
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT && !isCgo(genDecl) {
+			decls = append(decls, genDecl)
+		}
+	}
+	if len(decls) == 0 {
+		return content, nil
+	}
+
+	used := usedNames(file)
+	seen := make(map[string]bool)
+	var standard, other []organizedSpec
+	for _, decl := range decls {
+		for _, spec := range decl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			importPath := specImportPath(importSpec)
+			name := importName(importSpec, importPath, names)
+			if name != "_" && name != "." && !used[name] {
+				continue
+			}
+
+			key := importSpec.Path.Value
+			if importSpec.Name != nil {
+				key = importSpec.Name.Name + " " + key
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			// Keep the comments before and after the import, including the
+			// doc comment of a declaration of a single import
+			var text string
+			start := importSpec.Pos()
+			if importSpec.Doc != nil {
+				start = importSpec.Doc.Pos()
+			} else if !decl.Lparen.IsValid() && decl.Doc != nil {
+				text = string(content[fset.Position(decl.Doc.Pos()).Offset:fset.Position(decl.Doc.End()).Offset]) + "\n\t"
+			}
+			end := importSpec.End()
+			if importSpec.Comment != nil {
+				end = importSpec.Comment.End()
+			}
+			text += string(content[fset.Position(start).Offset:fset.Position(end).Offset])
+			organized := organizedSpec{path: importPath, text: text}
+			if isStandard(importPath) {
+				standard = append(standard, organized)
+			} else {
+				other = append(other, organized)
+			}
+		}
+	}
+
+	// Replace the first declaration, keeping the doc comment of a list of
+	// imports, and remove the others
+	var text string
+	var groups []string
+	for _, group := range [][]organizedSpec{standard, other} {
+		if len(group) == 0 {
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].path != group[j].path {
+				return group[i].path < group[j].path
+			}
+			return group[i].text < group[j].text
+		})
+		var lines []string
+		for _, spec := range group {
+			lines = append(lines, "\t"+spec.text+"\n")
+		}
+		groups = append(groups, strings.Join(lines, ""))
+	}
+	switch {
+	case len(groups) == 0:
+	case len(decls) == 1 && !decls[0].Lparen.IsValid() && len(standard)+len(other) == 1:
+		text = "import " + strings.TrimSpace(groups[0])
+	default:
+		text = "import (\n" + strings.Join(groups, "\n") + ")"
+	}
+
+	var edits []textEdit
+	for i, decl := range decls {
+		if i == 0 && text != "" {
+			start := decl.Pos()
+			if !decl.Lparen.IsValid() && decl.Doc != nil {
+				start = decl.Doc.Pos()
+			}
+			edits = append(edits, textEdit{start: fset.Position(start).Offset, end: fset.Position(decl.End()).Offset, text: text})
+			continue
+		}
+		edits = append(edits, removeSpecs(fset, content, decl, importSpecs(decl))...)
+	}
+	return applyEdits(content, edits)
+}
+
+// importSpecs returns the specs of an import declaration
+func importSpecs(decl *ast.GenDecl) []*ast.ImportSpec {
+	var specs []*ast.ImportSpec
+	for _, spec := range decl.Specs {
+		specs = append(specs, spec.(*ast.ImportSpec))
+	}
+	return specs
+}
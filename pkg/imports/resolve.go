@@ -0,0 +1,279 @@
+package imports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// listedPackage is a package that go list reported
+type listedPackage struct {
+	importPath string
+	name       string
+	standard   bool
+	dir        string
+	files      []string
+}
+
+// Resolver finds the packages that provide the package names a file uses
+// without importing them, among the standard library and the packages of
+// the module and its dependencies
+type Resolver struct {
+	modulePath string
+	byName     map[string][]*listedPackage
+	byPath     map[string]*listedPackage
+	exports    map[string]map[string]bool
+}
+
+// Resolution is the package chosen for a missing package name
+type Resolution struct {
+	Name       string
+	ImportPath string   // Empty if no package provides the name
+	Others     []string // Other packages that would also do
+}
+
+// NewResolver lists the packages that files in dir can import: the standard
+// library, and, inside a module, the packages of the module, those it
+// imports, and those of the modules it requires directly
+func NewResolver(ctx context.Context, dir string) (*Resolver, error) {
+	r := &Resolver{
+		byName:  make(map[string][]*listedPackage),
+		byPath:  make(map[string]*listedPackage),
+		exports: make(map[string]map[string]bool),
+	}
+
+	patterns := []string{"std"}
+	if modules, err := goCommand(ctx, dir, "list", "-m", "-e", "-f", "{{.Path}}\t{{.Main}}\t{{.Indirect}}", "all"); err == nil {
+		patterns = append(patterns, "all")
+		for _, line := range strings.Split(modules, "\n") {
+			fields := strings.Split(line, "\t")
+			if len(fields) != 3 {
+				continue
+			}
+			if fields[1] == "true" {
+				r.modulePath = fields[0]
+				patterns = append(patterns, "./...")
+			} else if fields[2] == "false" {
+				patterns = append(patterns, fields[0]+"/...")
+			}
+		}
+	}
+
+	args := append([]string{"list", "-e", "-f", "{{.ImportPath}}\t{{.Name}}\t{{.Standard}}\t{{.Dir}}\t{{join .GoFiles \",\"}}"}, patterns...)
+	output, err := goCommand(ctx, dir, args...)
+	if err != nil && output == "" {
+		return nil, err
+	}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 || fields[1] == "" || r.byPath[fields[0]] != nil {
+			continue
+		}
+		p := &listedPackage{importPath: fields[0], name: fields[1], standard: fields[2] == "true", dir: fields[3]}
+		if fields[4] != "" {
+			p.files = strings.Split(fields[4], ",")
+		}
+		r.byPath[p.importPath] = p
+		r.byName[p.name] = append(r.byName[p.name], p)
+	}
+	return r, nil
+}
+
+// goCommand runs the go command in dir and returns its output
+func goCommand(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return string(output), fmt.Errorf("go %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+// Names returns the package names of the listed packages, by import path
+func (r *Resolver) Names() map[string]string {
+	names := make(map[string]string)
+	for importPath, p := range r.byPath {
+		names[importPath] = p.name
+	}
+	return names
+}
+
+// ImportPath returns the import path of the listed package in dir, or ""
+func (r *Resolver) ImportPath(dir string) string {
+	for _, p := range r.byPath {
+		if p.dir == dir {
+			return p.importPath
+		}
+	}
+	return ""
+}
+
+// Resolve chooses the package for a package name that the package importer
+// uses with the given selectors. The candidates are the importable packages
+// of that name that export all the selectors, preferring those in
+// preferred, which are usually the imports of other files of the package,
+// then the standard library, then the module's own packages, then shorter
+// paths.
+func (r *Resolver) Resolve(name string, selectors []string, importer string, preferred map[string]bool) Resolution {
+	var candidates []*listedPackage
+	for _, p := range r.byName[name] {
+		if p.name == "main" || p.importPath == importer || !importable(importer, p.importPath) {
+			continue
+		}
+		if r.exportsAll(p, selectors) {
+			candidates = append(candidates, p)
+		}
+	}
+
+	rank := func(p *listedPackage) int {
+		switch {
+		case preferred[p.importPath]:
+			return 0
+		case p.standard:
+			return 1
+		case r.modulePath != "" && (p.importPath == r.modulePath || strings.HasPrefix(p.importPath, r.modulePath+"/")):
+			return 2
+		default:
+			return 3
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if len(a.importPath) != len(b.importPath) {
+			return len(a.importPath) < len(b.importPath)
+		}
+		return a.importPath < b.importPath
+	})
+
+	resolution := Resolution{Name: name}
+	for i, p := range candidates {
+		if i == 0 {
+			resolution.ImportPath = p.importPath
+		} else {
+			resolution.Others = append(resolution.Others, p.importPath)
+		}
+	}
+	return resolution
+}
+
+// exportsAll reports whether a package exports all the selectors, reading
+// its exported top-level names from its source
+func (r *Resolver) exportsAll(p *listedPackage, selectors []string) bool {
+	exported, exists := r.exports[p.importPath]
+	if !exists {
+		exported = make(map[string]bool)
+		fset := token.NewFileSet()
+		for _, name := range p.files {
+			file, err := parser.ParseFile(fset, filepath.Join(p.dir, name), nil, parser.SkipObjectResolution)
+			if err != nil {
+				continue
+			}
+			for name := range declaredNames(file) {
+				if ast.IsExported(name) {
+					exported[name] = true
+				}
+			}
+		}
+		r.exports[p.importPath] = exported
+	}
+
+	for _, selector := range selectors {
+		if !exported[selector] {
+			return false
+		}
+	}
+	return true
+}
+
+// importable reports whether the package importer may import importPath,
+// which excludes internal packages of other trees
+func importable(importer, importPath string) bool {
+	if strings.HasPrefix(importPath, "vendor/") || strings.Contains(importPath, "/vendor/") {
+		return false
+	}
+	elements := strings.Split(importPath, "/")
+	for i := len(elements) - 1; i >= 0; i-- {
+		if elements[i] != "internal" {
+			continue
+		}
+		parent := strings.Join(elements[:i], "/")
+		return parent != "" && (importer == parent || strings.HasPrefix(importer, parent+"/"))
+	}
+	return true
+}
+
+// declaredNames returns the names that a file declares at package level
+func declaredNames(file *ast.File) map[string]bool {
+	declared := make(map[string]bool)
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				declared[decl.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					declared[spec.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						declared[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return declared
+}
+
+// Missing returns the names that a file uses as packages without importing
+// them, with the selectors used on each. declared holds the names that
+// other files of the package declare at package level, and names the
+// package names of the file's imports, as for Fix.
+func Missing(file *ast.File, declared map[string]bool, names map[string]string) map[string][]string {
+	imported := make(map[string]bool)
+	for _, spec := range file.Imports {
+		imported[importName(spec, specImportPath(spec), names)] = true
+	}
+
+	selectors := make(map[string]map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		selector, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := selector.X.(*ast.Ident)
+		if !ok || ident.Obj != nil || imported[ident.Name] || declared[ident.Name] || ident.Name == "_" || types.Universe.Lookup(ident.Name) != nil {
+			return true
+		}
+		if selectors[ident.Name] == nil {
+			selectors[ident.Name] = make(map[string]bool)
+		}
+		selectors[ident.Name][selector.Sel.Name] = true
+		return true
+	})
+
+	missing := make(map[string][]string)
+	for name, set := range selectors {
+		for selector := range set {
+			missing[name] = append(missing[name], selector)
+		}
+		sort.Strings(missing[name])
+	}
+	return missing
+}
@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultBufferSize is the number of bytes of output retained for each job
+const DefaultBufferSize = 1024 * 1024
+
+// RingBuffer is a fixed-size buffer that keeps the most recent bytes written
+// to it. Older output is discarded once the buffer is full. Offsets are
+// absolute positions in the stream of everything ever written, so callers can
+// page through output incrementally even after some of it has been dropped.
+type RingBuffer struct {
+	data   []byte
+	start  int
+	length int
+	total  int64
+	mutex  sync.Mutex
+}
+
+// NewRingBuffer creates a ring buffer holding at most size bytes
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &RingBuffer{data: make([]byte, size)}
+}
+
+// Write appends p to the buffer, discarding the oldest bytes if necessary.
+// It never fails.
+func (b *RingBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	n := len(p)
+	b.total += int64(n)
+	size := len(b.data)
+
+	// Only the tail of a write larger than the buffer can be kept
+	if n >= size {
+		copy(b.data, p[n-size:])
+		b.start = 0
+		b.length = size
+		return n, nil
+	}
+
+	end := (b.start + b.length) % size
+	copied := copy(b.data[end:], p)
+	copy(b.data, p[copied:])
+
+	b.length += n
+	if b.length > size {
+		b.start = (b.start + b.length - size) % size
+		b.length = size
+	}
+
+	return n, nil
+}
+
+// Total returns the number of bytes ever written to the buffer
+func (b *RingBuffer) Total() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.total
+}
+
+// Dropped returns the number of bytes that were discarded because the buffer was full
+func (b *RingBuffer) Dropped() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.total - int64(b.length)
+}
+
+// ReadFrom returns the retained output starting at the absolute offset, and
+// the offset to pass on the next call. If part of the requested range has
+// already been discarded, truncated is true and the result starts at the
+// oldest retained byte.
+func (b *RingBuffer) ReadFrom(offset int64) (text string, next int64, truncated bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	first := b.total - int64(b.length)
+	if offset < first {
+		offset = first
+		truncated = true
+	}
+	if offset > b.total {
+		offset = b.total
+	}
+
+	skip := int(offset - first)
+	return string(b.contents()[skip:]), b.total, truncated
+}
+
+// Tail returns the last n lines of retained output
+func (b *RingBuffer) Tail(n int) string {
+	b.mutex.Lock()
+	text := string(b.contents())
+	b.mutex.Unlock()
+
+	if n <= 0 || text == "" {
+		return text
+	}
+
+	// Ignore the trailing newline so that it does not count as an empty line
+	trimmed := strings.TrimSuffix(text, "\n")
+	lines := strings.Split(trimmed, "\n")
+	if len(lines) <= n {
+		return text
+	}
+
+	return strings.Join(lines[len(lines)-n:], "\n") + text[len(trimmed):]
+}
+
+// contents returns the retained bytes in order. The caller must hold the mutex.
+func (b *RingBuffer) contents() []byte {
+	size := len(b.data)
+	result := make([]byte, b.length)
+	copied := copy(result, b.data[b.start:min(b.start+b.length, size)])
+	copy(result[copied:], b.data[:b.length-copied])
+	return result
+}
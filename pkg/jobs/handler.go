@@ -0,0 +1,288 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HandleJobs is the handler function for the jobs tool
+func HandleJobs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+
+	// Extract operation
+	operation, ok := arguments["operation"].(string)
+	if !ok {
+		return nil, fmt.Errorf("operation must be a string")
+	}
+
+	// Extract session ID
+	sessionID, ok := arguments["session_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("session_id must be a string")
+	}
+
+	var resultText string
+
+	switch operation {
+	case "start":
+		// Extract command
+		command, ok := arguments["command"].(string)
+		if !ok || command == "" {
+			return nil, fmt.Errorf("command must be a non-empty string")
+		}
+
+		// Check the command against the command policy
+		if result, err := policy.Enforce("jobs", sessionID, command, arguments); result != nil || err != nil {
+			return result, err
+		}
+
+		// Resolve the working directory against the workspace root
+		workingDir, _ := arguments["working_directory"].(string)
+		workingDir = workspace.ResolveRelativePath(workingDir, sessionID)
+
+		// Extract timeout (optional, no limit by default)
+		timeoutSec, _ := arguments["timeout"].(float64)
+
+		job, err := StartJob(sessionID, command, workingDir, time.Duration(timeoutSec*float64(time.Second)))
+		if err != nil {
+			return nil, err
+		}
+
+		resultText = "Job started\n\n"
+		resultText += formatJobInfo(job.Info())
+
+	case "status":
+		job, err := getJobArgument(arguments, sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		resultText = formatJobInfo(job.Info())
+
+	case "list":
+		resultText = formatJobList(sessionID)
+
+	case "tail":
+		job, err := getJobArgument(arguments, sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		resultText = formatJobInfo(job.Info()) + "\n"
+
+		// An offset pages through the output incrementally, otherwise the last lines are returned
+		if offset, ok := arguments["offset"].(float64); ok {
+			text, next, truncated := job.Output.ReadFrom(int64(offset))
+			if truncated {
+				resultText += "Note: some output before the requested offset has been discarded\n"
+			}
+			resultText += fmt.Sprintf("Next Offset: %d\n\n", next)
+			resultText += fmt.Sprintf("Output:\n%s", text)
+		} else {
+			resultText += fmt.Sprintf("Output:\n%s", job.Output.Tail(getLinesArgument(arguments)))
+		}
+
+	case "wait":
+		job, err := getJobArgument(arguments, sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Extract timeout (optional)
+		timeoutSec, ok := arguments["timeout"].(float64)
+		if !ok {
+			// Default timeout: 30 seconds
+			timeoutSec = 30
+		}
+
+		if !job.Wait(time.Duration(timeoutSec * float64(time.Second))) {
+			resultText = fmt.Sprintf("Job still running after waiting %v seconds\n\n", timeoutSec)
+		}
+		resultText += formatJobInfo(job.Info()) + "\n"
+		resultText += fmt.Sprintf("Output:\n%s", job.Output.Tail(getLinesArgument(arguments)))
+
+	case "kill":
+		job, err := getJobArgument(arguments, sessionID)
+		if err != nil {
+			return nil, err
+		}
+
+		if job.Running() {
+			job.Kill()
+			resultText = "Job killed\n\n"
+		} else {
+			resultText = "Job had already finished\n\n"
+		}
+		resultText += formatJobInfo(job.Info())
+
+	default:
+		return nil, fmt.Errorf("unsupported operation: %s", operation)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: resultText,
+			},
+		},
+	}, nil
+}
+
+// getJobArgument looks up the job named by the job_id argument
+func getJobArgument(arguments map[string]interface{}, sessionID string) (*Job, error) {
+	jobID, ok := arguments["job_id"].(string)
+	if !ok || jobID == "" {
+		return nil, fmt.Errorf("job_id must be a non-empty string")
+	}
+
+	job, exists := GetJob(sessionID, jobID)
+	if !exists {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
+
+	return job, nil
+}
+
+// getLinesArgument returns the number of output lines to show
+func getLinesArgument(arguments map[string]interface{}) int {
+	if lines, ok := arguments["lines"].(float64); ok && lines > 0 {
+		return int(lines)
+	}
+	// Default: last 50 lines
+	return 50
+}
+
+// formatJobInfo formats the state of a job
+func formatJobInfo(info JobInfo) string {
+	text := fmt.Sprintf("Job ID: %s\n", info.ID)
+	text += fmt.Sprintf("Command: %s\n", info.Command)
+	text += fmt.Sprintf("Working Directory: %s\n", info.WorkingDir)
+	text += fmt.Sprintf("Status: %s\n", info.Status)
+	text += fmt.Sprintf("Started: %s\n", info.StartTime.Format(time.RFC3339))
+
+	if info.Status == StatusRunning {
+		text += fmt.Sprintf("Running For: %v\n", time.Since(info.StartTime).Round(time.Millisecond))
+	} else {
+		text += fmt.Sprintf("Finished: %s\n", info.EndTime.Format(time.RFC3339))
+		text += fmt.Sprintf("Duration: %v\n", info.EndTime.Sub(info.StartTime).Round(time.Millisecond))
+		text += fmt.Sprintf("Exit Code: %d\n", info.ExitCode)
+	}
+
+	if info.Timeout > 0 {
+		text += fmt.Sprintf("Timeout: %v\n", info.Timeout)
+	}
+	if info.Error != "" {
+		text += fmt.Sprintf("Error: %s\n", info.Error)
+	}
+
+	text += fmt.Sprintf("Output Bytes: %d", info.OutputBytes)
+	if info.DroppedBytes > 0 {
+		text += fmt.Sprintf(" (%d oldest bytes discarded)", info.DroppedBytes)
+	}
+	text += "\n"
+
+	return text
+}
+
+// formatJobList formats a summary of all jobs in a workspace session
+func formatJobList(sessionID string) string {
+	jobs := ListJobs(sessionID)
+
+	text := fmt.Sprintf("Jobs for session %s (%d):\n\n", sessionID, len(jobs))
+	for i, job := range jobs {
+		info := job.Info()
+		text += fmt.Sprintf("%d. %s [%s]", i+1, info.ID, info.Status)
+		if info.Status != StatusRunning {
+			text += fmt.Sprintf(" exit code %d", info.ExitCode)
+		}
+		text += fmt.Sprintf("\n   Command: %s\n", info.Command)
+		text += fmt.Sprintf("   Started: %s\n", info.StartTime.Format(time.RFC3339))
+	}
+
+	return text
+}
+
+// HandleJobsResource is the handler function for the jobs resource
+func HandleJobsResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	// Format: jobs://session_id
+	uri := request.Params.URI
+	sessionID := strings.TrimPrefix(uri, "jobs://")
+	if sessionID == "" || sessionID == uri {
+		return nil, fmt.Errorf("invalid jobs resource URI: %s", uri)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "text/plain",
+			Text:     formatJobList(sessionID),
+		},
+	}, nil
+}
+
+// RegisterJobs registers the jobs tool and resource with the MCP server
+func RegisterJobs(mcpServer *server.MCPServer) {
+	// Create the tool definition
+	jobsTool := mcp.NewTool("jobs",
+		mcp.WithDescription("Runs long commands such as builds and test suites in the background. Start a job, then check its status, tail its output, wait for it to finish, or kill it. Output is kept in a bounded log per job."),
+		mcp.WithString("operation",
+			mcp.Description("Operation to perform: 'start', 'status', 'list', 'tail', 'wait', or 'kill'"),
+			mcp.Required(),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Session ID that the jobs belong to"),
+			mcp.Required(),
+		),
+		mcp.WithString("command",
+			mcp.Description("The command to run (for 'start' operation)"),
+		),
+		mcp.WithString("confirm",
+			mcp.Description("Confirmation token from an earlier call, for commands that the command policy requires to be confirmed (for 'start' operation)"),
+		),
+		mcp.WithString("working_directory",
+			mcp.Description("Working directory for the command, relative to the workspace root (for 'start' operation, default: workspace root)"),
+		),
+		mcp.WithString("job_id",
+			mcp.Description("ID of the job (for 'status', 'tail', 'wait' and 'kill' operations)"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("For 'start', the maximum run time in seconds before the job is killed (default: no limit). For 'wait', how long to wait in seconds (default: 30)"),
+		),
+		mcp.WithNumber("lines",
+			mcp.Description("Number of output lines to return (for 'tail' and 'wait' operations, default: 50)"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Return all output from this byte offset instead of the last lines (for 'tail' operation). Each result includes the next offset to use."),
+		),
+	)
+
+	// Wrap the handler with stats tracking
+	wrappedHandler := stats.WrapHandler("jobs", HandleJobs)
+
+	// Register the tool
+	mcpServer.AddTool(jobsTool, wrappedHandler)
+
+	// Register the jobs resource template for session-specific URIs
+	mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(
+			"jobs://{session_id}",
+			"Background Jobs",
+			mcp.WithTemplateMIMEType("text/plain"),
+			mcp.WithTemplateDescription("Background jobs of a workspace session and their status"),
+		),
+		HandleJobsResource,
+	)
+
+	// Log the registration
+	log.Printf("[Jobs] Registered jobs tool and resource")
+}
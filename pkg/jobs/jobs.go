@@ -0,0 +1,301 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+)
+
+// JobStatus is the state of a background job
+type JobStatus string
+
+const (
+	StatusRunning   JobStatus = "running"
+	StatusCompleted JobStatus = "completed"
+	StatusFailed    JobStatus = "failed"
+	StatusKilled    JobStatus = "killed"
+	StatusTimedOut  JobStatus = "timed_out"
+)
+
+// killGracePeriod is how long a job gets to exit after being interrupted
+// before it is killed outright
+const killGracePeriod = 2 * time.Second
+
+// maxFinishedJobs is the number of finished jobs kept per session. Older
+// finished jobs are discarded when new jobs are started.
+const maxFinishedJobs = 50
+
+// Job is a command running in the background
+type Job struct {
+	ID         string
+	SessionID  string
+	Command    string
+	WorkingDir string
+	Timeout    time.Duration
+	StartTime  time.Time
+	EndTime    time.Time
+	Status     JobStatus
+	ExitCode   int
+	Error      string
+	Output     *RingBuffer
+
+	cmd        *exec.Cmd
+	done       chan struct{}
+	stopStatus JobStatus
+	mutex      sync.Mutex
+}
+
+// JobInfo is a point-in-time copy of a job's state
+type JobInfo struct {
+	ID           string
+	Command      string
+	WorkingDir   string
+	Timeout      time.Duration
+	StartTime    time.Time
+	EndTime      time.Time
+	Status       JobStatus
+	ExitCode     int
+	Error        string
+	OutputBytes  int64
+	DroppedBytes int64
+}
+
+// JobStore manages background jobs for multiple workspace sessions
+type JobStore struct {
+	sessions map[string][]*Job
+	nextID   int
+	mutex    sync.RWMutex
+}
+
+// Global job store
+var jobStore = &JobStore{
+	sessions: make(map[string][]*Job),
+}
+
+// StartJob starts command in the background for a workspace session. A
+// timeout of zero lets the job run until it exits or is killed.
+func StartJob(sessionID, command, workingDir string, timeout time.Duration) (*Job, error) {
+	// Prepare the command based on the OS
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = workingDir
+
+	// Interleave stdout and stderr in a single bounded log
+	output := NewRingBuffer(DefaultBufferSize)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	// Don't let background processes that inherited the output pipes keep
+	// the job from finishing
+	cmd.WaitDelay = killGracePeriod
+
+	// Run the job in its own process group so that kill reaches its children
+	setProcessGroup(cmd)
+
+	// Apply the sandbox limits of the workspace, if any
+	if err := sandbox.Wrap(cmd, sandbox.ConfigForSession(sessionID), workspace.GetRootDir(sessionID)); err != nil {
+		return nil, fmt.Errorf("failed to set up sandbox: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start job: %v", err)
+	}
+
+	jobStore.mutex.Lock()
+	jobStore.nextID++
+	job := &Job{
+		ID:         fmt.Sprintf("job-%d", jobStore.nextID),
+		SessionID:  sessionID,
+		Command:    command,
+		WorkingDir: workingDir,
+		Timeout:    timeout,
+		StartTime:  time.Now(),
+		Status:     StatusRunning,
+		Output:     output,
+		cmd:        cmd,
+		done:       make(chan struct{}),
+	}
+	jobStore.sessions[sessionID] = append(pruneFinished(jobStore.sessions[sessionID]), job)
+	jobStore.mutex.Unlock()
+
+	go job.wait()
+
+	if timeout > 0 {
+		time.AfterFunc(timeout, func() {
+			job.stop(StatusTimedOut)
+		})
+	}
+
+	log.Printf("[Jobs] Started %s for session %s: %s", job.ID, sessionID, command)
+
+	return job, nil
+}
+
+// pruneFinished drops the oldest finished jobs so that at most
+// maxFinishedJobs remain. The caller must hold the store mutex.
+func pruneFinished(jobs []*Job) []*Job {
+	finished := 0
+	for _, job := range jobs {
+		if !job.Running() {
+			finished++
+		}
+	}
+
+	var kept []*Job
+	for _, job := range jobs {
+		if finished > maxFinishedJobs && !job.Running() {
+			finished--
+			continue
+		}
+		kept = append(kept, job)
+	}
+	return kept
+}
+
+// GetJob returns a job of a workspace session by ID
+func GetJob(sessionID, jobID string) (*Job, bool) {
+	jobStore.mutex.RLock()
+	defer jobStore.mutex.RUnlock()
+
+	for _, job := range jobStore.sessions[sessionID] {
+		if job.ID == jobID {
+			return job, true
+		}
+	}
+	return nil, false
+}
+
+// ListJobs returns the jobs of a workspace session in the order they were started
+func ListJobs(sessionID string) []*Job {
+	jobStore.mutex.RLock()
+	defer jobStore.mutex.RUnlock()
+
+	return append([]*Job(nil), jobStore.sessions[sessionID]...)
+}
+
+// StopAll kills every running job. It is called when the server shuts down.
+func StopAll() {
+	jobStore.mutex.RLock()
+	var running []*Job
+	for _, jobs := range jobStore.sessions {
+		for _, job := range jobs {
+			if job.Running() {
+				running = append(running, job)
+			}
+		}
+	}
+	jobStore.mutex.RUnlock()
+
+	for _, job := range running {
+		job.Kill()
+	}
+}
+
+// wait waits for the job's process to exit and records the result
+func (j *Job) wait() {
+	err := j.cmd.Wait()
+
+	j.mutex.Lock()
+	j.EndTime = time.Now()
+	j.ExitCode = j.cmd.ProcessState.ExitCode()
+
+	switch {
+	case j.stopStatus != "":
+		j.Status = j.stopStatus
+	case err != nil && j.ExitCode == 0:
+		// The process exited cleanly but its output could not be collected
+		j.Status = StatusFailed
+		j.Error = err.Error()
+	case j.ExitCode != 0:
+		j.Status = StatusFailed
+	default:
+		j.Status = StatusCompleted
+	}
+	j.mutex.Unlock()
+
+	close(j.done)
+
+	log.Printf("[Jobs] %s finished with status %s (exit code %d)", j.ID, j.Status, j.ExitCode)
+}
+
+// Running reports whether the job is still running
+func (j *Job) Running() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Wait blocks until the job finishes or the timeout expires, and reports
+// whether the job finished
+func (j *Job) Wait(timeout time.Duration) bool {
+	select {
+	case <-j.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Kill interrupts the job and its child processes, killing them if they are
+// still running after a grace period. It returns once the job has exited.
+func (j *Job) Kill() {
+	j.stop(StatusKilled)
+	<-j.done
+}
+
+// stop ends a running job and records why it was stopped
+func (j *Job) stop(status JobStatus) {
+	j.mutex.Lock()
+	if !j.Running() || j.stopStatus != "" {
+		j.mutex.Unlock()
+		return
+	}
+	j.stopStatus = status
+	j.mutex.Unlock()
+
+	log.Printf("[Jobs] Stopping %s (%s)", j.ID, status)
+
+	if err := interruptProcess(j.cmd); err != nil {
+		log.Printf("[Jobs] Failed to interrupt %s: %v", j.ID, err)
+	}
+
+	if !j.Wait(killGracePeriod) {
+		if err := killProcess(j.cmd); err != nil {
+			log.Printf("[Jobs] Failed to kill %s: %v", j.ID, err)
+		}
+	}
+}
+
+// Info returns a copy of the job's state that is safe to read while the
+// job is running
+func (j *Job) Info() JobInfo {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return JobInfo{
+		ID:           j.ID,
+		Command:      j.Command,
+		WorkingDir:   j.WorkingDir,
+		Timeout:      j.Timeout,
+		StartTime:    j.StartTime,
+		EndTime:      j.EndTime,
+		Status:       j.Status,
+		ExitCode:     j.ExitCode,
+		Error:        j.Error,
+		OutputBytes:  j.Output.Total(),
+		DroppedBytes: j.Output.Dropped(),
+	}
+}
@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// interruptProcess terminates the command. Windows has no equivalent of
+// SIGTERM for console processes, so this is the same as killProcess.
+func interruptProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+// killProcess terminates the command
+func killProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,632 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// initializeTimeout is how long a server gets to start and initialize
+const initializeTimeout = 60 * time.Second
+
+// shutdownTimeout is how long a server gets to shut down before it is killed
+const shutdownTimeout = 5 * time.Second
+
+// diagnosticsSettleTime is how long to wait for further diagnostics after a
+// server has published some, as servers often publish in several passes
+const diagnosticsSettleTime = 500 * time.Millisecond
+
+// document is an open text document, as last sent to the server
+type document struct {
+	version int
+	content string
+}
+
+// published is the last set of diagnostics published for a document
+type published struct {
+	diagnostics []Diagnostic
+	sequence    int // Increases with every publish, across all documents
+}
+
+// Client is a connection to a language server process for one project root.
+// Documents are opened on first use and kept in sync with the files on disk
+// before every request.
+type Client struct {
+	server    *ServerConfig
+	root      string
+	cmd       *exec.Cmd
+	conn      *conn
+	started   time.Time
+	pullDiags bool // Whether the server supports textDocument/diagnostic
+
+	documents   map[string]*document
+	diagnostics map[string]published
+	sequence    int
+	published   chan struct{} // Closed and replaced on every publish
+	mutex       sync.Mutex
+
+	syncMutex sync.Mutex // Serializes document synchronization
+}
+
+// startClient starts a language server in a project root and initializes it
+func startClient(server *ServerConfig, root string) (*Client, error) {
+	client := &Client{
+		server:      server,
+		root:        root,
+		started:     time.Now(),
+		documents:   make(map[string]*document),
+		diagnostics: make(map[string]published),
+		published:   make(chan struct{}),
+	}
+
+	cmd := exec.Command(server.Command[0], server.Command[1:]...)
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", server.Name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", server.Name, err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", server.Name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %v", server.Name, err)
+	}
+	client.cmd = cmd
+	log.Printf("[LSP] Started %s (pid %d) for %s", server.Name, cmd.Process.Pid, root)
+
+	go client.logStderr(stderr)
+	client.conn = newConn(stdout, stdin, client.handle)
+	go func() {
+		<-client.conn.done
+		cmd.Wait()
+		log.Printf("[LSP] %s for %s exited", server.Name, root)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), initializeTimeout)
+	defer cancel()
+
+	if err := client.initialize(ctx); err != nil {
+		client.kill()
+		return nil, fmt.Errorf("failed to initialize %s: %v", server.Name, err)
+	}
+	return client, nil
+}
+
+// initialize performs the initialize handshake
+func (c *Client) initialize(ctx context.Context) error {
+	rootURI := pathToURI(c.root)
+	params := map[string]interface{}{
+		"processId": os.Getpid(),
+		"clientInfo": map[string]interface{}{
+			"name": "CodeSpinneret",
+		},
+		"rootUri":  rootURI,
+		"rootPath": c.root,
+		"workspaceFolders": []map[string]interface{}{
+			{"uri": rootURI, "name": filepath.Base(c.root)},
+		},
+		"capabilities": map[string]interface{}{
+			"general": map[string]interface{}{
+				"positionEncodings": []string{"utf-16"},
+			},
+			"workspace": map[string]interface{}{
+				"workspaceFolders": true,
+				"configuration":    true,
+				"workspaceEdit": map[string]interface{}{
+					"documentChanges": true,
+				},
+				"didChangeWatchedFiles": map[string]interface{}{
+					"dynamicRegistration": false,
+				},
+			},
+			"textDocument": map[string]interface{}{
+				"synchronization": map[string]interface{}{
+					"dynamicRegistration": false,
+				},
+				"definition": map[string]interface{}{
+					"linkSupport": true,
+				},
+				"references": map[string]interface{}{},
+				"hover": map[string]interface{}{
+					"contentFormat": []string{"markdown", "plaintext"},
+				},
+				"rename": map[string]interface{}{
+					"prepareSupport": false,
+				},
+				"documentSymbol": map[string]interface{}{
+					"hierarchicalDocumentSymbolSupport": true,
+				},
+				"publishDiagnostics": map[string]interface{}{
+					"versionSupport": true,
+				},
+				"diagnostic": map[string]interface{}{},
+			},
+		},
+	}
+	if c.server.InitializationOptions != nil {
+		params["initializationOptions"] = c.server.InitializationOptions
+	}
+
+	var result struct {
+		Capabilities map[string]json.RawMessage `json:"capabilities"`
+	}
+	if err := c.conn.call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+	if provider, exists := result.Capabilities["diagnosticProvider"]; exists && string(provider) != "null" {
+		c.pullDiags = true
+	}
+
+	return c.conn.notify("initialized", map[string]interface{}{})
+}
+
+// handle answers requests and notifications from the server
+func (c *Client) handle(method string, params json.RawMessage) (interface{}, *responseError) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var notification struct {
+			URI         string       `json:"uri"`
+			Diagnostics []Diagnostic `json:"diagnostics"`
+		}
+		if err := json.Unmarshal(params, &notification); err == nil {
+			c.mutex.Lock()
+			c.sequence++
+			c.diagnostics[notification.URI] = published{diagnostics: notification.Diagnostics, sequence: c.sequence}
+			close(c.published)
+			c.published = make(chan struct{})
+			c.mutex.Unlock()
+		}
+		return nil, nil
+	case "workspace/configuration":
+		// No settings: the server uses its defaults
+		var request struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(params, &request)
+		return make([]interface{}, len(request.Items)), nil
+	case "workspace/workspaceFolders":
+		rootURI := pathToURI(c.root)
+		return []map[string]interface{}{{"uri": rootURI, "name": filepath.Base(c.root)}}, nil
+	case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability":
+		return nil, nil
+	case "workspace/applyEdit":
+		// Edits are only applied by the tool itself
+		return map[string]interface{}{"applied": false}, nil
+	case "window/showMessageRequest", "window/logMessage", "window/showMessage", "$/progress", "telemetry/event":
+		return nil, nil
+	default:
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + method}
+	}
+}
+
+// logStderr logs what the server writes to its standard error
+func (c *Client) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		log.Printf("[LSP] %s: %s", c.server.Name, scanner.Text())
+	}
+}
+
+// exited reports whether the server has stopped
+func (c *Client) exited() bool {
+	select {
+	case <-c.conn.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// sync opens a file in the server, or sends its new content if it changed on
+// disk since it was last sent, and returns its URI and content
+func (c *Client) sync(path string) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read file: %v", err)
+	}
+	content := string(data)
+	uri := pathToURI(path)
+
+	c.syncMutex.Lock()
+	defer c.syncMutex.Unlock()
+
+	doc, exists := c.documents[uri]
+	switch {
+	case !exists:
+		err = c.conn.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"uri":        uri,
+				"languageId": c.server.languageID(path),
+				"version":    1,
+				"text":       content,
+			},
+		})
+		c.documents[uri] = &document{version: 1, content: content}
+	case doc.content != content:
+		doc.version++
+		doc.content = content
+		err = c.conn.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"uri":     uri,
+				"version": doc.version,
+			},
+			"contentChanges": []map[string]interface{}{
+				{"text": content},
+			},
+		})
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return uri, content, nil
+}
+
+// filesChanged tells the server about files changed on disk by the tool
+func (c *Client) filesChanged(paths []string) error {
+	var changes []map[string]interface{}
+	for _, path := range paths {
+		uri := pathToURI(path)
+
+		c.syncMutex.Lock()
+		_, open := c.documents[uri]
+		c.syncMutex.Unlock()
+
+		if open {
+			if _, _, err := c.sync(path); err != nil {
+				return err
+			}
+		} else {
+			// Type 2 is Changed
+			changes = append(changes, map[string]interface{}{"uri": uri, "type": 2})
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return c.conn.notify("workspace/didChangeWatchedFiles", map[string]interface{}{"changes": changes})
+}
+
+// positionParams returns the parameters of a request at a position
+func positionParams(uri string, position Position) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     position,
+	}
+}
+
+// Definition returns the locations where the symbol at a position is defined
+func (c *Client) Definition(ctx context.Context, path string, position Position) ([]Location, error) {
+	uri, _, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result json.RawMessage
+	if err := c.conn.call(ctx, "textDocument/definition", positionParams(uri, position), &result); err != nil {
+		return nil, err
+	}
+	return parseLocations(result)
+}
+
+// References returns the locations that refer to the symbol at a position
+func (c *Client) References(ctx context.Context, path string, position Position, includeDeclaration bool) ([]Location, error) {
+	uri, _, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params := positionParams(uri, position)
+	params["context"] = map[string]interface{}{"includeDeclaration": includeDeclaration}
+
+	var result json.RawMessage
+	if err := c.conn.call(ctx, "textDocument/references", params, &result); err != nil {
+		return nil, err
+	}
+	return parseLocations(result)
+}
+
+// Hover returns the hover text of the symbol at a position, usually its
+// signature and documentation
+func (c *Client) Hover(ctx context.Context, path string, position Position) (string, error) {
+	uri, _, err := c.sync(path)
+	if err != nil {
+		return "", err
+	}
+
+	var result *hover
+	if err := c.conn.call(ctx, "textDocument/hover", positionParams(uri, position), &result); err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", nil
+	}
+	return hoverText(result.Contents), nil
+}
+
+// Rename returns the edits that rename the symbol at a position. The edits
+// are not applied.
+func (c *Client) Rename(ctx context.Context, path string, position Position, newName string) (map[string][]TextEdit, error) {
+	uri, _, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+
+	params := positionParams(uri, position)
+	params["newName"] = newName
+
+	var result *WorkspaceEdit
+	if err := c.conn.call(ctx, "textDocument/rename", params, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+	return result.fileEdits()
+}
+
+// Symbol is a symbol defined in a document
+type Symbol struct {
+	Name   string
+	Detail string
+	Kind   string
+	Line   int // One-based
+	Column int // One-based
+	Depth  int // Nesting depth, 0 for top-level symbols
+}
+
+// Symbols returns the symbols defined in a document, in document order with
+// nested symbols after their parents
+func (c *Client) Symbols(ctx context.Context, path string) ([]Symbol, error) {
+	uri, content, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []json.RawMessage
+	params := map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+	}
+	if err := c.conn.call(ctx, "textDocument/documentSymbol", params, &result); err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(content)
+	var symbols []Symbol
+	var add func(symbol documentSymbol, depth int)
+	add = func(symbol documentSymbol, depth int) {
+		symbols = append(symbols, Symbol{
+			Name:   symbol.Name,
+			Detail: symbol.Detail,
+			Kind:   symbolKindName(symbol.Kind),
+			Line:   symbol.SelectionRange.Start.Line + 1,
+			Column: columnIn(lines, symbol.SelectionRange.Start),
+			Depth:  depth,
+		})
+		for _, child := range symbol.Children {
+			add(child, depth+1)
+		}
+	}
+
+	for _, raw := range result {
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			continue
+		}
+
+		if _, flat := probe["location"]; flat {
+			var info symbolInformation
+			if err := json.Unmarshal(raw, &info); err == nil {
+				symbols = append(symbols, Symbol{
+					Name:   info.Name,
+					Detail: info.ContainerName,
+					Kind:   symbolKindName(info.Kind),
+					Line:   info.Location.Range.Start.Line + 1,
+					Column: columnIn(lines, info.Location.Range.Start),
+				})
+			}
+			continue
+		}
+
+		var symbol documentSymbol
+		if err := json.Unmarshal(raw, &symbol); err == nil {
+			add(symbol, 0)
+		}
+	}
+
+	return symbols, nil
+}
+
+// Diagnostics returns the diagnostics of a document. Servers that support
+// pull diagnostics are asked for them; otherwise the client waits up to wait
+// for the server to publish diagnostics for the current content.
+func (c *Client) Diagnostics(ctx context.Context, path string, wait time.Duration) ([]Diagnostic, error) {
+	c.mutex.Lock()
+	sequence := c.sequence
+	c.mutex.Unlock()
+
+	c.syncMutex.Lock()
+	before, wasOpen := c.documents[pathToURI(path)]
+	var beforeVersion int
+	if wasOpen {
+		beforeVersion = before.version
+	}
+	c.syncMutex.Unlock()
+
+	uri, _, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.pullDiags {
+		var result struct {
+			Kind  string       `json:"kind"`
+			Items []Diagnostic `json:"items"`
+		}
+		params := map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+		}
+		if err := c.conn.call(ctx, "textDocument/diagnostic", params, &result); err == nil {
+			return result.Items, nil
+		}
+		// Fall back to published diagnostics if the request fails
+	}
+
+	// Diagnostics published for unchanged content are still current
+	c.mutex.Lock()
+	current, exists := c.diagnostics[uri]
+	c.mutex.Unlock()
+	c.syncMutex.Lock()
+	unchanged := wasOpen && c.documents[uri].version == beforeVersion
+	c.syncMutex.Unlock()
+	if exists && unchanged {
+		return current.diagnostics, nil
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	// After each new publish for the document, wait a little longer for the
+	// next pass
+	settleTimer := time.NewTimer(diagnosticsSettleTime)
+	settleTimer.Stop()
+	defer settleTimer.Stop()
+	var settle <-chan time.Time
+
+	for {
+		c.mutex.Lock()
+		current, exists = c.diagnostics[uri]
+		publishedChan := c.published
+		c.mutex.Unlock()
+
+		if exists && current.sequence > sequence {
+			sequence = current.sequence
+			settleTimer.Reset(diagnosticsSettleTime)
+			settle = settleTimer.C
+		}
+
+		select {
+		case <-publishedChan:
+		case <-settle:
+			return current.diagnostics, nil
+		case <-deadline.C:
+			return current.diagnostics, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.conn.done:
+			return nil, c.conn.err
+		}
+	}
+}
+
+// Close shuts the server down, killing it if it doesn't exit in time
+func (c *Client) Close() {
+	if c.exited() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := c.conn.call(ctx, "shutdown", nil, nil); err == nil {
+		c.conn.notify("exit", nil)
+	}
+	c.conn.close()
+
+	select {
+	case <-c.conn.done:
+	case <-ctx.Done():
+		c.kill()
+	}
+}
+
+// kill stops the server process immediately
+func (c *Client) kill() {
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+}
+
+// parseLocations parses the result of a definition or references request,
+// which is null, a Location, an array of Locations or an array of
+// LocationLinks
+func parseLocations(result json.RawMessage) ([]Location, error) {
+	if len(result) == 0 || string(result) == "null" {
+		return nil, nil
+	}
+
+	var single Location
+	if err := json.Unmarshal(result, &single); err == nil && single.URI != "" {
+		return []Location{single}, nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(result, &raw); err != nil {
+		return nil, fmt.Errorf("invalid locations: %v", err)
+	}
+
+	var locations []Location
+	for _, item := range raw {
+		var location Location
+		if err := json.Unmarshal(item, &location); err == nil && location.URI != "" {
+			locations = append(locations, location)
+			continue
+		}
+		var link locationLink
+		if err := json.Unmarshal(item, &link); err == nil && link.TargetURI != "" {
+			locations = append(locations, Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+		}
+	}
+	return locations, nil
+}
+
+// fileEdits returns the text edits of a workspace edit by file path
+func (e *WorkspaceEdit) fileEdits() (map[string][]TextEdit, error) {
+	edits := make(map[string][]TextEdit)
+	for uri, changes := range e.Changes {
+		path := uriToPath(uri)
+		edits[path] = append(edits[path], changes...)
+	}
+
+	for _, raw := range e.DocumentChanges {
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return nil, fmt.Errorf("invalid workspace edit: %v", err)
+		}
+		if kind, exists := probe["kind"]; exists {
+			return nil, fmt.Errorf("workspace edit needs an unsupported %s operation", string(kind))
+		}
+
+		var change textDocumentEdit
+		if err := json.Unmarshal(raw, &change); err != nil {
+			return nil, fmt.Errorf("invalid workspace edit: %v", err)
+		}
+		path := uriToPath(change.TextDocument.URI)
+		edits[path] = append(edits[path], change.Edits...)
+	}
+
+	return edits, nil
+}
+
+// columnIn returns the one-based character column of a position in a
+// document split into lines
+func columnIn(lines []string, position Position) int {
+	if position.Line < 0 || position.Line >= len(lines) {
+		return position.Character + 1
+	}
+	return characterColumn(lines[position.Line], position.Character)
+}
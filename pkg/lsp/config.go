@@ -0,0 +1,297 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ServerConfig describes how to run a language server and which files it
+// handles
+type ServerConfig struct {
+	Name                  string            `json:"name"`
+	Command               []string          `json:"command"`                          // Command line that starts the server on stdio
+	Languages             map[string]string `json:"languages"`                        // File extensions and their LSP language IDs, e.g. ".go": "go"
+	RootMarkers           []string          `json:"root_markers,omitempty"`           // Files that mark the root of a project, e.g. "go.mod"
+	InitializationOptions interface{}       `json:"initialization_options,omitempty"` // Sent with the initialize request
+}
+
+// Config is the language server configuration file
+type Config struct {
+	Servers []ServerConfig `json:"servers"`
+}
+
+// presets are the built-in server configurations, enabled by name
+var presets = map[string]ServerConfig{
+	"gopls": {
+		Name:        "gopls",
+		Command:     []string{"gopls"},
+		Languages:   map[string]string{".go": "go"},
+		RootMarkers: []string{"go.work", "go.mod"},
+	},
+	"tsserver": {
+		Name:    "tsserver",
+		Command: []string{"typescript-language-server", "--stdio"},
+		Languages: map[string]string{
+			".ts": "typescript", ".mts": "typescript", ".cts": "typescript", ".tsx": "typescriptreact",
+			".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "javascriptreact",
+		},
+		RootMarkers: []string{"tsconfig.json", "jsconfig.json", "package.json"},
+	},
+	"clangd": {
+		Name:    "clangd",
+		Command: []string{"clangd"},
+		Languages: map[string]string{
+			".c": "c", ".h": "c",
+			".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hh": "cpp", ".hpp": "cpp", ".hxx": "cpp",
+		},
+		RootMarkers: []string{"compile_commands.json", "compile_flags.txt", ".clangd"},
+	},
+}
+
+// Configured servers and the running clients, one per server and project root
+var (
+	servers      []*ServerConfig
+	clients      = make(map[string]*Client)
+	managerMutex sync.Mutex
+)
+
+// PresetNames returns the names of the built-in server configurations
+func PresetNames() []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadConfig reads server configurations from a JSON file. A server named
+// after a preset only needs the fields it changes.
+func LoadConfig(filePath string) ([]ServerConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read language server config: %v", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse language server config: %v", err)
+	}
+
+	for i := range config.Servers {
+		server := &config.Servers[i]
+		if server.Name == "" {
+			return nil, fmt.Errorf("language server %d has no name", i+1)
+		}
+
+		if preset, exists := presets[server.Name]; exists {
+			if len(server.Command) == 0 {
+				server.Command = preset.Command
+			}
+			if len(server.Languages) == 0 {
+				server.Languages = preset.Languages
+			}
+			if len(server.RootMarkers) == 0 {
+				server.RootMarkers = preset.RootMarkers
+			}
+		}
+
+		if len(server.Command) == 0 {
+			return nil, fmt.Errorf("language server %s has no command", server.Name)
+		}
+		if len(server.Languages) == 0 {
+			return nil, fmt.Errorf("language server %s has no languages", server.Name)
+		}
+	}
+
+	return config.Servers, nil
+}
+
+// InitLSP enables the named presets, given as a comma-separated list, and the
+// servers in the config file, if one is given. Servers from the config file
+// replace presets of the same name. Files whose extension no server handles
+// use the regex-based fallbacks.
+func InitLSP(presetNames, configFile string) error {
+	var configs []ServerConfig
+	for _, name := range strings.Split(presetNames, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		preset, exists := presets[name]
+		if !exists {
+			return fmt.Errorf("unknown language server: %s (available: %s)", name, strings.Join(PresetNames(), ", "))
+		}
+		configs = append(configs, preset)
+	}
+
+	if configFile != "" {
+		loaded, err := LoadConfig(configFile)
+		if err != nil {
+			return err
+		}
+		for _, server := range loaded {
+			replaced := false
+			for i := range configs {
+				if configs[i].Name == server.Name {
+					configs[i] = server
+					replaced = true
+				}
+			}
+			if !replaced {
+				configs = append(configs, server)
+			}
+		}
+	}
+
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+
+	servers = nil
+	for i := range configs {
+		servers = append(servers, &configs[i])
+		log.Printf("[LSP] Configured %s: %s", configs[i].Name, strings.Join(configs[i].Command, " "))
+	}
+	return nil
+}
+
+// serverForFile returns the configuration of the server that handles a file,
+// or nil if there is none
+func serverForFile(path string) *ServerConfig {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+
+	for _, server := range servers {
+		if _, exists := server.Languages[ext]; exists {
+			return server
+		}
+	}
+	return nil
+}
+
+// languageID returns the LSP language ID of a file
+func (s *ServerConfig) languageID(path string) string {
+	return s.Languages[strings.ToLower(filepath.Ext(path))]
+}
+
+// findRoot returns the project root of a file: the nearest directory with one
+// of the server's root markers, searching up to the workspace root, or else
+// the workspace root if it contains the file, or the file's directory
+func (s *ServerConfig) findRoot(path, workspaceRoot string) string {
+	dir := filepath.Dir(path)
+	inWorkspace := workspaceRoot != "" && isWithin(workspaceRoot, path)
+
+	for current := dir; ; {
+		for _, marker := range s.RootMarkers {
+			if _, err := os.Stat(filepath.Join(current, marker)); err == nil {
+				return current
+			}
+		}
+		if inWorkspace && current == workspaceRoot {
+			break
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+
+	if inWorkspace {
+		return workspaceRoot
+	}
+	return dir
+}
+
+// clientFor returns the running client of a server for a project root,
+// starting the server if it isn't running
+func clientFor(server *ServerConfig, root string) (*Client, error) {
+	key := server.Name + "\x00" + root
+
+	managerMutex.Lock()
+	client, exists := clients[key]
+	if exists && !client.exited() {
+		managerMutex.Unlock()
+		return client, nil
+	}
+	delete(clients, key)
+	managerMutex.Unlock()
+
+	// Start the server without holding the lock, as initialization can take
+	// a while for large projects
+	client, err := startClient(server, root)
+	if err != nil {
+		return nil, err
+	}
+
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+
+	if existing, exists := clients[key]; exists && !existing.exited() {
+		// Another call started the same server first
+		go client.Close()
+		return existing, nil
+	}
+	clients[key] = client
+	return client, nil
+}
+
+// runningClients returns the running clients, sorted by server and root
+func runningClients() []*Client {
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+
+	var running []*Client
+	for _, client := range clients {
+		if !client.exited() {
+			running = append(running, client)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		if running[i].server.Name != running[j].server.Name {
+			return running[i].server.Name < running[j].server.Name
+		}
+		return running[i].root < running[j].root
+	})
+	return running
+}
+
+// configuredServers returns the configured servers
+func configuredServers() []*ServerConfig {
+	managerMutex.Lock()
+	defer managerMutex.Unlock()
+
+	return append([]*ServerConfig(nil), servers...)
+}
+
+// StopAll shuts down all running language servers
+func StopAll() {
+	managerMutex.Lock()
+	running := clients
+	clients = make(map[string]*Client)
+	managerMutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, client := range running {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			client.Close()
+		}(client)
+	}
+	wg.Wait()
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,136 @@
+package lsp
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/findcallers"
+	"github.com/Code-Monger/CodeSpinneret/pkg/findfunc"
+	"github.com/Code-Monger/CodeSpinneret/pkg/funcdef"
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
+)
+
+// The fallbacks answer definition, references, hover and symbols requests for
+// files that no language server handles, using the syntax package through the
+// findfunc, findcallers and funcdef tools. Definitions, references and hovers
+// only know about functions, and match them by name.
+
+// fallbackDefinition finds the definitions of a function by name
+func fallbackDefinition(searchDir, path, name string, files lineCache) ([]resultLocation, error) {
+	language, ok := syntax.LanguageForFile(path)
+	if !ok {
+		return nil, fmt.Errorf("no fallback for %s files", filepath.Ext(path))
+	}
+
+	functions, err := findfunc.FindFunctions(searchDir, name, "", language.Family, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []resultLocation
+	for _, function := range functions {
+		locations = append(locations, locationOfName(files, function.FilePath, function.LineNumber, name))
+	}
+	return locations, nil
+}
+
+// fallbackReferences finds the calls of a function by name
+func fallbackReferences(searchDir, path, name string, files lineCache) ([]resultLocation, error) {
+	language, ok := syntax.LanguageForFile(path)
+	if !ok {
+		return nil, fmt.Errorf("no fallback for %s files", filepath.Ext(path))
+	}
+
+	callers, err := findcallers.FindCallers(name, searchDir, language.Family, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []resultLocation
+	for _, caller := range callers {
+		locations = append(locations, locationOfName(files, caller.FilePath, caller.LineNumber, name))
+	}
+	return locations, nil
+}
+
+// fallbackHover returns the first line of each definition of a function,
+// which is usually its signature
+func fallbackHover(searchDir, path, name string, files lineCache) (string, error) {
+	definitions, err := fallbackDefinition(searchDir, path, name, files)
+	if err != nil {
+		return "", err
+	}
+
+	var signatures []string
+	for _, definition := range definitions {
+		language, ok := syntax.LanguageForFile(definition.Path)
+		if !ok {
+			continue
+		}
+		functions, err := funcdef.GetFunctionDefinition(definition.Path, name, language, false)
+		if err != nil {
+			continue
+		}
+		for _, function := range functions {
+			signature := strings.TrimSpace(strings.SplitN(function.Content, "\n", 2)[0])
+			signatures = append(signatures, fmt.Sprintf("%s (%s:%d)", signature, displayPath(searchDir, definition.Path), function.StartLine))
+		}
+	}
+
+	return strings.Join(uniqueStrings(signatures), "\n"), nil
+}
+
+// fallbackSymbols finds the functions and types defined in a file
+func fallbackSymbols(path string) ([]Symbol, error) {
+	if _, ok := syntax.LanguageForFile(path); !ok {
+		return nil, fmt.Errorf("no fallback for %s files", filepath.Ext(path))
+	}
+
+	file, err := syntax.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []Symbol
+	for _, symbol := range file.Symbols {
+		symbols = append(symbols, Symbol{
+			Name:   symbol.Name,
+			Kind:   symbol.Kind,
+			Line:   symbol.Line,
+			Column: len([]rune(file.Line(symbol.Line)[:symbol.Column-1])) + 1,
+			Depth:  symbol.Depth,
+		})
+	}
+
+	return symbols, nil
+}
+
+// locationOfName returns a location on a line, at the first occurrence of a
+// name as a whole word, or at the start of the line
+func locationOfName(files lineCache, path string, lineNumber int, name string) resultLocation {
+	location := resultLocation{Path: path, Line: lineNumber, Column: 1}
+
+	lines, err := files.get(path)
+	if err != nil || lineNumber < 1 || lineNumber > len(lines) {
+		return location
+	}
+	location.Text = lines[lineNumber-1]
+	if column, found := findWord(location.Text, name, 0); found {
+		location.Column = column
+	}
+	return location
+}
+
+// uniqueStrings removes repeated strings, keeping the first occurrences
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,593 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/goedit"
+	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// resultLocation is a location in a file, with one-based line and column
+type resultLocation struct {
+	Path   string
+	Line   int
+	Column int
+	Text   string // The line, if it could be read
+}
+
+// lineCache holds the lines of the files read while formatting a result
+type lineCache map[string][]string
+
+// get returns the lines of a file, without line endings
+func (c lineCache) get(path string) ([]string, error) {
+	if lines, exists := c[path]; exists {
+		return lines, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	c[path] = lines
+	return lines, nil
+}
+
+// HandleLSP is the handler function for the lsp tool
+func HandleLSP(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+
+	// Extract operation
+	operation, ok := arguments["operation"].(string)
+	if !ok {
+		return nil, fmt.Errorf("operation must be a string")
+	}
+
+	// Extract session ID (optional, selects the workspace root)
+	sessionID, _ := arguments["session_id"].(string)
+	var workspaceRoot string
+	if info, exists := workspace.GetWorkspaceInfo(sessionID); exists {
+		workspaceRoot = info.RootDir
+	}
+
+	if operation == "status" {
+		return textResult(formatStatus()), nil
+	}
+
+	// Resolve the file against the workspace root
+	filePath, ok := arguments["file_path"].(string)
+	if !ok || filePath == "" {
+		return nil, fmt.Errorf("file_path must be a non-empty string")
+	}
+	filePath, err := filepath.Abs(workspace.ResolveRelativePath(filePath, sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("invalid file path: %v", err)
+	}
+	if info, err := os.Stat(filePath); err != nil || info.IsDir() {
+		return nil, fmt.Errorf("file not found: %s", filePath)
+	}
+
+	files := make(lineCache)
+	lines, err := files.get(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the position for operations on a symbol
+	var position Position
+	var name string
+	switch operation {
+	case "definition", "references", "hover", "rename":
+		lineNumber, _ := arguments["line"].(float64)
+		column, _ := arguments["column"].(float64)
+		symbol, _ := arguments["symbol"].(string)
+		position, name, err = resolvePosition(lines, int(lineNumber), int(column), symbol)
+		if err != nil {
+			return nil, err
+		}
+	case "symbols", "diagnostics":
+	default:
+		return nil, fmt.Errorf("unknown operation: %s", operation)
+	}
+
+	displayRoot := workspaceRoot
+	if displayRoot == "" {
+		displayRoot = filepath.Dir(filePath)
+	}
+
+	// Use the regex-based fallbacks for files that no server handles
+	server := serverForFile(filePath)
+	if server == nil {
+		searchDir := displayRoot
+		resultText, err := handleFallback(operation, filePath, name, searchDir, files)
+		if err != nil {
+			return nil, err
+		}
+		return textResult(resultText), nil
+	}
+
+	// Extract timeout (optional)
+	timeoutSec, ok := arguments["timeout"].(float64)
+	if !ok {
+		// Default timeout: 30 seconds
+		timeoutSec = 30
+	}
+	requestCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec*float64(time.Second)))
+	defer cancel()
+
+	root := server.findRoot(filePath, workspaceRoot)
+	client, err := clientFor(server, root)
+	if err != nil {
+		return nil, err
+	}
+
+	resultText := fmt.Sprintf("Server: %s (%s)\n", server.Name, root)
+	relFile := displayPath(displayRoot, filePath)
+
+	switch operation {
+	case "definition":
+		locations, err := client.Definition(requestCtx, filePath, position)
+		if err != nil {
+			return nil, err
+		}
+		resultText += fmt.Sprintf("Definition of %s at %s:%d:%d\n\n", name, relFile, position.Line+1, characterColumn(lines[position.Line], position.Character))
+		resultText += formatLocations(convertLocations(locations, files), displayRoot)
+
+	case "references":
+		includeDeclaration := true
+		if include, ok := arguments["include_declaration"].(bool); ok {
+			includeDeclaration = include
+		}
+		locations, err := client.References(requestCtx, filePath, position, includeDeclaration)
+		if err != nil {
+			return nil, err
+		}
+		resultText += fmt.Sprintf("References to %s at %s:%d:%d\n\n", name, relFile, position.Line+1, characterColumn(lines[position.Line], position.Character))
+		resultText += formatLocations(convertLocations(locations, files), displayRoot)
+
+	case "hover":
+		text, err := client.Hover(requestCtx, filePath, position)
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			text = "No information"
+		}
+		resultText += fmt.Sprintf("Hover for %s at %s:%d:%d\n\n%s\n", name, relFile, position.Line+1, characterColumn(lines[position.Line], position.Character), text)
+
+	case "rename":
+		newName, ok := arguments["new_name"].(string)
+		if !ok || newName == "" {
+			return nil, fmt.Errorf("new_name must be a non-empty string")
+		}
+		dryRun := false
+		if dryRunBool, ok := arguments["dry_run"].(bool); ok {
+			dryRun = dryRunBool
+		}
+
+		edits, err := client.Rename(requestCtx, filePath, position, newName)
+		if err != nil {
+			return nil, err
+		}
+		text, err := applyRename(client, sessionID, edits, dryRun, displayRoot)
+		if err != nil {
+			return nil, err
+		}
+		resultText += fmt.Sprintf("Rename %s to %s\n", name, newName)
+		resultText += fmt.Sprintf("Dry run: %t\n\n", dryRun)
+		resultText += text
+
+	case "symbols":
+		symbols, err := client.Symbols(requestCtx, filePath)
+		if err != nil {
+			return nil, err
+		}
+		resultText += fmt.Sprintf("Symbols in %s\n\n", relFile)
+		resultText += formatSymbols(symbols)
+
+	case "diagnostics":
+		// Extract how long to wait for the server to publish diagnostics
+		waitSec, ok := arguments["wait"].(float64)
+		if !ok {
+			// Default wait: 5 seconds
+			waitSec = 5
+		}
+		diagnostics, err := client.Diagnostics(requestCtx, filePath, time.Duration(waitSec*float64(time.Second)))
+		if err != nil {
+			return nil, err
+		}
+		resultText += fmt.Sprintf("Diagnostics for %s: %d\n\n", relFile, len(diagnostics))
+		for _, diagnostic := range diagnostics {
+			resultText += formatDiagnostic(relFile, lines, diagnostic)
+		}
+	}
+
+	return textResult(artifact.Limit("lsp", resultText)), nil
+}
+
+// handleFallback answers a request with the regex-based fallbacks
+func handleFallback(operation, filePath, name, searchDir string, files lineCache) (string, error) {
+	ext := filepath.Ext(filePath)
+	resultText := fmt.Sprintf("Server: none (no language server is configured for %s files; using regex search, which only finds functions by name)\n", ext)
+	relFile := displayPath(searchDir, filePath)
+
+	switch operation {
+	case "definition":
+		locations, err := fallbackDefinition(searchDir, filePath, name, files)
+		if err != nil {
+			return "", err
+		}
+		resultText += fmt.Sprintf("Definition of %s\n\n", name)
+		resultText += formatLocations(locations, searchDir)
+
+	case "references":
+		locations, err := fallbackReferences(searchDir, filePath, name, files)
+		if err != nil {
+			return "", err
+		}
+		resultText += fmt.Sprintf("References to %s\n\n", name)
+		resultText += formatLocations(locations, searchDir)
+
+	case "hover":
+		text, err := fallbackHover(searchDir, filePath, name, files)
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			text = "No information"
+		}
+		resultText += fmt.Sprintf("Hover for %s\n\n%s\n", name, text)
+
+	case "symbols":
+		symbols, err := fallbackSymbols(filePath)
+		if err != nil {
+			return "", err
+		}
+		resultText += fmt.Sprintf("Symbols in %s\n\n", relFile)
+		resultText += formatSymbols(symbols)
+
+	default:
+		return "", fmt.Errorf("%s needs a language server, and none is configured for %s files", operation, ext)
+	}
+
+	return artifact.Limit("lsp", resultText), nil
+}
+
+// resolvePosition finds the position of a symbol on a one-based line. The
+// symbol is either named, in which case its first occurrence at or after the
+// column is used, or found at the column.
+func resolvePosition(lines []string, lineNumber, column int, symbol string) (Position, string, error) {
+	if lineNumber < 1 || lineNumber > len(lines) {
+		return Position{}, "", fmt.Errorf("line must be between 1 and %d", len(lines))
+	}
+	line := lines[lineNumber-1]
+
+	if symbol != "" {
+		found := false
+		column, found = findWord(line, symbol, column)
+		if !found {
+			return Position{}, "", fmt.Errorf("symbol %s not found on line %d", symbol, lineNumber)
+		}
+		return Position{Line: lineNumber - 1, Character: utf16Column(line, column)}, symbol, nil
+	}
+
+	if column < 1 {
+		return Position{}, "", fmt.Errorf("column or symbol is required")
+	}
+	name := identifierAt(line, column)
+	if name == "" {
+		return Position{}, "", fmt.Errorf("no identifier at line %d, column %d", lineNumber, column)
+	}
+	return Position{Line: lineNumber - 1, Character: utf16Column(line, column)}, name, nil
+}
+
+// findWord returns the one-based column of the first occurrence of word as a
+// whole word in a line, starting at a one-based column
+func findWord(line, word string, fromColumn int) (int, bool) {
+	for start := 0; start < len(line); {
+		index := strings.Index(line[start:], word)
+		if index < 0 {
+			break
+		}
+		index += start
+		column := utf8.RuneCountInString(line[:index]) + 1
+		before, _ := utf8.DecodeLastRuneInString(line[:index])
+		after, _ := utf8.DecodeRuneInString(line[index+len(word):])
+		if column >= fromColumn && !isWordRune(before) && !isWordRune(after) {
+			return column, true
+		}
+		start = index + 1
+	}
+	return 0, false
+}
+
+// identifierAt returns the identifier that contains a one-based column
+func identifierAt(line string, column int) string {
+	runes := []rune(line)
+	index := column - 1
+	if index < 0 || index >= len(runes) || !isWordRune(runes[index]) {
+		return ""
+	}
+
+	start, end := index, index
+	for start > 0 && isWordRune(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isWordRune(runes[end]) {
+		end++
+	}
+	return string(runes[start:end])
+}
+
+// isWordRune reports whether r can be part of an identifier
+func isWordRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// convertLocations converts LSP locations to one-based file locations
+func convertLocations(locations []Location, files lineCache) []resultLocation {
+	var result []resultLocation
+	for _, location := range locations {
+		converted := resultLocation{
+			Path:   uriToPath(location.URI),
+			Line:   location.Range.Start.Line + 1,
+			Column: location.Range.Start.Character + 1,
+		}
+		if lines, err := files.get(converted.Path); err == nil && location.Range.Start.Line < len(lines) {
+			converted.Text = lines[location.Range.Start.Line]
+			converted.Column = characterColumn(converted.Text, location.Range.Start.Character)
+		}
+		result = append(result, converted)
+	}
+	return result
+}
+
+// applyRename applies the edits of a rename, unless this is a dry run, and
+// describes the changed lines
+func applyRename(client *Client, sessionID string, edits map[string][]TextEdit, dryRun bool, root string) (string, error) {
+	if len(edits) == 0 {
+		return "No changes\n", nil
+	}
+
+	var paths []string
+	editCount := 0
+	for path, fileEdits := range edits {
+		paths = append(paths, path)
+		editCount += len(fileEdits)
+	}
+	sort.Strings(paths)
+
+	resultText := fmt.Sprintf("%d edits in %d files\n", editCount, len(paths))
+
+	// Compute all new contents before writing anything
+	newContents := make(map[string]string)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		newContent, err := applyTextEdits(string(data), edits[path])
+		if err != nil {
+			return "", fmt.Errorf("failed to apply edits to %s: %v", path, err)
+		}
+		newContents[path] = newContent
+
+		resultText += fmt.Sprintf("\n%s:\n", displayPath(root, path))
+		resultText += describeChangedLines(string(data), newContent, edits[path])
+	}
+
+	if dryRun {
+		return resultText, nil
+	}
+
+	for _, path := range paths {
+		if err := goedit.WriteFile(path, []byte(newContents[path])); err != nil {
+			return "", err
+		}
+	}
+
+	// Remember the renamed files for tools that check the last edit, and tell
+	// the server about them
+	workspace.RecordEdit(sessionID, "lsp", paths)
+	if err := client.filesChanged(paths); err != nil {
+		log.Printf("[LSP] Failed to notify %s of changed files: %v", client.server.Name, err)
+	}
+
+	return resultText, nil
+}
+
+// describeChangedLines lists the lines touched by edits, before and after.
+// Edits that change the number of lines are listed by position only.
+func describeChangedLines(oldContent, newContent string, edits []TextEdit) string {
+	oldLines := strings.Split(oldContent, "\n")
+	newLines := strings.Split(newContent, "\n")
+
+	var lineNumbers []int
+	seen := make(map[int]bool)
+	for _, edit := range edits {
+		if !seen[edit.Range.Start.Line] {
+			seen[edit.Range.Start.Line] = true
+			lineNumbers = append(lineNumbers, edit.Range.Start.Line)
+		}
+	}
+	sort.Ints(lineNumbers)
+
+	var text string
+	for _, line := range lineNumbers {
+		if len(oldLines) != len(newLines) || line >= len(oldLines) {
+			text += fmt.Sprintf("  line %d\n", line+1)
+			continue
+		}
+		text += fmt.Sprintf("  %d: - %s\n", line+1, strings.TrimSpace(oldLines[line]))
+		text += fmt.Sprintf("  %d: + %s\n", line+1, strings.TrimSpace(newLines[line]))
+	}
+	return text
+}
+
+// formatLocations formats locations as path:line:column with the line text
+func formatLocations(locations []resultLocation, root string) string {
+	if len(locations) == 0 {
+		return "No locations found\n"
+	}
+
+	text := fmt.Sprintf("Found %d locations:\n", len(locations))
+	for _, location := range locations {
+		text += fmt.Sprintf("%s:%d:%d", displayPath(root, location.Path), location.Line, location.Column)
+		if location.Text != "" {
+			text += fmt.Sprintf(": %s", strings.TrimSpace(location.Text))
+		}
+		text += "\n"
+	}
+	return text
+}
+
+// formatSymbols formats symbols as an indented outline
+func formatSymbols(symbols []Symbol) string {
+	if len(symbols) == 0 {
+		return "No symbols found\n"
+	}
+
+	var text string
+	for _, symbol := range symbols {
+		text += fmt.Sprintf("%s%s %s", strings.Repeat("  ", symbol.Depth), symbol.Kind, symbol.Name)
+		if symbol.Detail != "" {
+			text += fmt.Sprintf(" (%s)", symbol.Detail)
+		}
+		text += fmt.Sprintf(" at %d:%d\n", symbol.Line, symbol.Column)
+	}
+	return text
+}
+
+// formatDiagnostic formats a diagnostic as path:line:column: severity: message
+func formatDiagnostic(relFile string, lines []string, diagnostic Diagnostic) string {
+	start := diagnostic.Range.Start
+	column := start.Character + 1
+	if start.Line < len(lines) {
+		column = characterColumn(lines[start.Line], start.Character)
+	}
+
+	text := fmt.Sprintf("%s:%d:%d: %s: %s", relFile, start.Line+1, column, severityName(diagnostic.Severity), diagnostic.Message)
+	var source []string
+	if diagnostic.Source != "" {
+		source = append(source, diagnostic.Source)
+	}
+	if code := strings.Trim(string(diagnostic.Code), `"`); code != "" && code != "null" {
+		source = append(source, code)
+	}
+	if len(source) > 0 {
+		text += fmt.Sprintf(" [%s]", strings.Join(source, " "))
+	}
+	return text + "\n"
+}
+
+// formatStatus lists the configured servers and the running ones
+func formatStatus() string {
+	configured := configuredServers()
+	if len(configured) == 0 {
+		return "No language servers are configured; definition, references, hover and symbols use regex search.\n" +
+			fmt.Sprintf("Enable servers with the -lsp flag (%s) or a -lsp-config file.\n", strings.Join(PresetNames(), ", "))
+	}
+
+	text := "Configured language servers:\n"
+	for _, server := range configured {
+		var extensions []string
+		for ext := range server.Languages {
+			extensions = append(extensions, ext)
+		}
+		sort.Strings(extensions)
+		text += fmt.Sprintf("- %s: %s (%s)\n", server.Name, strings.Join(server.Command, " "), strings.Join(extensions, " "))
+	}
+
+	running := runningClients()
+	text += fmt.Sprintf("\nRunning: %d\n", len(running))
+	for _, client := range running {
+		text += fmt.Sprintf("- %s for %s, pid %d, started %s\n", client.server.Name, client.root, client.cmd.Process.Pid, client.started.Format(time.RFC3339))
+	}
+	return text
+}
+
+// displayPath returns a path relative to root if it is inside it
+func displayPath(root, path string) string {
+	if root != "" && isWithin(root, path) {
+		if rel, err := filepath.Rel(root, path); err == nil {
+			return rel
+		}
+	}
+	return path
+}
+
+// textResult wraps text in a tool result
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}
+}
+
+// RegisterLSP registers the lsp tool with the MCP server
+func RegisterLSP(mcpServer *server.MCPServer) {
+	// Create the tool definition
+	lspTool := mcp.NewTool("lsp",
+		mcp.WithDescription("Queries a language server (gopls, typescript-language-server, clangd or a configured one) for a file: go to definition, find references, hover, rename a symbol across files, list document symbols and get diagnostics. Servers are started per project on first use. Files that no server handles fall back to regex search for definition, references, hover and symbols."),
+		mcp.WithString("operation",
+			mcp.Required(),
+			mcp.Description("Operation: 'definition', 'references', 'hover', 'rename', 'symbols', 'diagnostics' or 'status'"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Workspace session ID, whose root resolves relative paths (optional)"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("File to query, relative to the workspace root (required except for 'status')"),
+		),
+		mcp.WithNumber("line",
+			mcp.Description("One-based line of the symbol (for definition, references, hover and rename)"),
+		),
+		mcp.WithNumber("column",
+			mcp.Description("One-based column of the symbol, counted in characters (optional if symbol is given)"),
+		),
+		mcp.WithString("symbol",
+			mcp.Description("Name of the symbol on the line, used instead of the column or as the first occurrence at or after it"),
+		),
+		mcp.WithString("new_name",
+			mcp.Description("New name of the symbol (for rename)"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Show the edits of a rename without applying them (default: false)"),
+		),
+		mcp.WithBoolean("include_declaration",
+			mcp.Description("Include the declaration in the references (default: true)"),
+		),
+		mcp.WithNumber("wait",
+			mcp.Description("Seconds to wait for the server to publish diagnostics (default: 5)"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Timeout in seconds for the request (default: 30)"),
+		),
+	)
+
+	// Wrap the handler with stats tracking
+	wrappedHandler := stats.WrapHandler("lsp", HandleLSP)
+
+	// Register the tool with the wrapped handler
+	mcpServer.AddTool(lspTool, wrappedHandler)
+
+	// Log the registration
+	log.Printf("[LSP] Registered lsp tool")
+}
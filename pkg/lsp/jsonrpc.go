@@ -0,0 +1,231 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// message is a JSON-RPC 2.0 request, notification or response. Requests
+// have a method and an ID, notifications only a method, and responses only
+// an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+// responseError is the error of a JSON-RPC response
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// codeMethodNotFound is the JSON-RPC error code for unsupported methods
+const codeMethodNotFound = -32601
+
+// handlerFunc handles a request or notification from the server. The result
+// is only sent back for requests.
+type handlerFunc func(method string, params json.RawMessage) (interface{}, *responseError)
+
+// conn is a JSON-RPC connection to a language server over its standard input
+// and output, using the LSP base protocol: each message is preceded by a
+// Content-Length header.
+type conn struct {
+	reader  *bufio.Reader
+	writer  io.WriteCloser
+	handler handlerFunc
+
+	nextID  int64
+	pending map[int64]chan *message
+	done    chan struct{}
+	err     error
+	mutex   sync.Mutex
+
+	writeMutex sync.Mutex
+}
+
+// newConn creates a connection and starts reading messages from the server
+func newConn(reader io.Reader, writer io.WriteCloser, handler handlerFunc) *conn {
+	c := &conn{
+		reader:  bufio.NewReader(reader),
+		writer:  writer,
+		handler: handler,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// call sends a request and decodes the result into result, which may be nil
+func (c *conn) call(ctx context.Context, method string, params, result interface{}) error {
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	response := make(chan *message, 1)
+	c.pending[id] = response
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+	}()
+
+	if err := c.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+		"params":  params,
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-response:
+		if msg.Error != nil {
+			return fmt.Errorf("%s failed: %s", method, msg.Error.Message)
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("invalid %s response: %v", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.notify("$/cancelRequest", map[string]interface{}{"id": id})
+		return fmt.Errorf("%s: %v", method, ctx.Err())
+	case <-c.done:
+		return c.err
+	}
+}
+
+// notify sends a notification
+func (c *conn) notify(method string, params interface{}) error {
+	return c.write(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+}
+
+// write sends one message with its header
+func (c *conn) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %v", err)
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return fmt.Errorf("failed to write to language server: %v", err)
+	}
+	if _, err := c.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write to language server: %v", err)
+	}
+	return nil
+}
+
+// readLoop reads messages until the server closes its output, dispatching
+// responses to the waiting calls and requests and notifications to the
+// handler
+func (c *conn) readLoop() {
+	var err error
+	for {
+		var msg *message
+		msg, err = c.read()
+		if err != nil {
+			break
+		}
+
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			// Answer requests in the background, so that a slow handler
+			// doesn't hold up responses
+			go c.reply(msg)
+		case msg.Method != "":
+			c.handler(msg.Method, msg.Params)
+		case msg.ID != nil:
+			id, parseErr := strconv.ParseInt(strings.Trim(string(*msg.ID), `"`), 10, 64)
+			if parseErr != nil {
+				continue
+			}
+			c.mutex.Lock()
+			response, exists := c.pending[id]
+			c.mutex.Unlock()
+			if exists {
+				response <- msg
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	if err == io.EOF {
+		err = fmt.Errorf("language server exited")
+	}
+	c.err = err
+	c.mutex.Unlock()
+	close(c.done)
+}
+
+// reply handles a request from the server and sends the response
+func (c *conn) reply(msg *message) {
+	result, rpcErr := c.handler(msg.Method, msg.Params)
+
+	response := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      msg.ID,
+	}
+	if rpcErr != nil {
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	c.write(response)
+}
+
+// read reads one message
+func (c *conn) read() (*message, error) {
+	headers, err := textproto.NewReader(c.reader).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header from language server")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return nil, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message from language server: %v", err)
+	}
+	return &msg, nil
+}
+
+// close closes the connection to the server's input
+func (c *conn) close() error {
+	return c.writer.Close()
+}
@@ -0,0 +1,282 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// The subset of the Language Server Protocol used by the client. Positions
+// are zero-based, and characters count UTF-16 code units.
+
+// Position is a position in a text document
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a range in a text document
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a document identified by its URI
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// locationLink is returned instead of a Location by servers that support
+// links for definitions
+type locationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetRange          Range  `json:"targetRange"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
+// TextEdit replaces a range of a document with new text
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// textDocumentEdit holds the edits of one document in a WorkspaceEdit
+type textDocumentEdit struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Edits []TextEdit `json:"edits"`
+}
+
+// WorkspaceEdit is a set of edits to several documents. Servers send the
+// edits either as changes or as documentChanges; resource operations such as
+// creating and renaming files are not supported.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []json.RawMessage     `json:"documentChanges,omitempty"`
+}
+
+// Diagnostic is an error or warning reported by a server
+type Diagnostic struct {
+	Range    Range           `json:"range"`
+	Severity int             `json:"severity,omitempty"`
+	Code     json.RawMessage `json:"code,omitempty"`
+	Source   string          `json:"source,omitempty"`
+	Message  string          `json:"message"`
+}
+
+// documentSymbol is a symbol in a document, with its children
+type documentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           int              `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []documentSymbol `json:"children,omitempty"`
+}
+
+// symbolInformation is the flat form of a document symbol, used by older
+// servers
+type symbolInformation struct {
+	Name          string   `json:"name"`
+	Kind          int      `json:"kind"`
+	Location      Location `json:"location"`
+	ContainerName string   `json:"containerName,omitempty"`
+}
+
+// hover is the result of a hover request. Contents is a MarkupContent, a
+// MarkedString or an array of MarkedStrings.
+type hover struct {
+	Contents json.RawMessage `json:"contents"`
+}
+
+// markedString is the object form of a MarkedString or a MarkupContent
+type markedString struct {
+	Language string `json:"language"`
+	Kind     string `json:"kind"`
+	Value    string `json:"value"`
+}
+
+// Diagnostic severities
+const (
+	severityError       = 1
+	severityWarning     = 2
+	severityInformation = 3
+	severityHint        = 4
+)
+
+// severityName returns the name of a diagnostic severity
+func severityName(severity int) string {
+	switch severity {
+	case severityError:
+		return "error"
+	case severityWarning:
+		return "warning"
+	case severityInformation:
+		return "info"
+	case severityHint:
+		return "hint"
+	default:
+		return "error"
+	}
+}
+
+// symbolKinds are the names of the LSP symbol kinds, starting at 1
+var symbolKinds = []string{
+	"file", "module", "namespace", "package", "class", "method", "property",
+	"field", "constructor", "enum", "interface", "function", "variable",
+	"constant", "string", "number", "boolean", "array", "object", "key",
+	"null", "enum member", "struct", "event", "operator", "type parameter",
+}
+
+// symbolKindName returns the name of a symbol kind
+func symbolKindName(kind int) string {
+	if kind >= 1 && kind <= len(symbolKinds) {
+		return symbolKinds[kind-1]
+	}
+	return "symbol"
+}
+
+// hoverText returns the text of a hover result
+func hoverText(contents json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(contents, &text); err == nil {
+		return text
+	}
+
+	var marked markedString
+	if err := json.Unmarshal(contents, &marked); err == nil {
+		if marked.Language != "" {
+			return "```" + marked.Language + "\n" + marked.Value + "\n```"
+		}
+		return marked.Value
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(contents, &parts); err == nil {
+		var texts []string
+		for _, part := range parts {
+			if text := hoverText(part); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		return strings.Join(texts, "\n\n")
+	}
+
+	return ""
+}
+
+// pathToURI converts an absolute file path to a file URI
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths such as C:/dir get a leading slash
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// uriToPath converts a file URI to a file path
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
+
+// utf16Column converts a one-based column, counted in characters, to a
+// zero-based LSP character offset in a line
+func utf16Column(line string, column int) int {
+	offset := 0
+	for i, r := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		offset += utf16.RuneLen(r)
+	}
+	return offset
+}
+
+// characterColumn converts a zero-based LSP character offset in a line to a
+// one-based column counted in characters
+func characterColumn(line string, character int) int {
+	column := 1
+	units := 0
+	for _, r := range line {
+		if units >= character {
+			break
+		}
+		units += utf16.RuneLen(r)
+		column++
+	}
+	return column
+}
+
+// applyTextEdits applies edits to the content of a document. The ranges of
+// the edits refer to the original content and must not overlap.
+func applyTextEdits(content string, edits []TextEdit) (string, error) {
+	lines := splitLines(content)
+	offset := func(position Position) int {
+		if position.Line >= len(lines) {
+			return len(content)
+		}
+		start := 0
+		for i := 0; i < position.Line; i++ {
+			start += len(lines[i])
+		}
+		line := strings.TrimRight(lines[position.Line], "\r\n")
+		units := 0
+		for i, r := range line {
+			if units >= position.Character {
+				return start + i
+			}
+			units += utf16.RuneLen(r)
+		}
+		return start + len(line)
+	}
+
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, edit := range edits {
+		spans = append(spans, span{offset(edit.Range.Start), offset(edit.Range.End), edit.NewText})
+	}
+
+	// Sort by start offset, keeping the server's order for equal offsets
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+
+	var builder strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last || s.end < s.start {
+			return "", fmt.Errorf("overlapping edits")
+		}
+		builder.WriteString(content[last:s.start])
+		builder.WriteString(s.text)
+		last = s.end
+	}
+	builder.WriteString(content[last:])
+
+	return builder.String(), nil
+}
+
+// splitLines splits text into lines, keeping the line endings
+func splitLines(text string) []string {
+	return strings.SplitAfter(text, "\n")
+}
@@ -61,6 +61,15 @@ func HandlePatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 		return nil, fmt.Errorf("error applying patch: %v", err)
 	}
 
+	// Remember the patched files for tools that check the last edit
+	if !dryRun {
+		var patchedPaths []string
+		for _, file := range result.FilesPatched {
+			patchedPaths = append(patchedPaths, filepath.Join(targetDir, file))
+		}
+		workspace.RecordEdit(sessionID, "patch", patchedPaths)
+	}
+
 	// Format the result
 	resultText := fmt.Sprintf("Patch Application Results:\n\n")
 	resultText += fmt.Sprintf("Target directory: %s\n", targetDir)
@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEntry is a line of the audit log
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Tool      string    `json:"tool"`
+	SessionID string    `json:"session_id,omitempty"`
+	Command   string    `json:"command"`
+	Decision  Action    `json:"decision"`
+	Confirmed bool      `json:"confirmed,omitempty"`
+	Rule      string    `json:"rule,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Matched   string    `json:"matched,omitempty"` // The simple command that decided
+}
+
+// Audit log state
+var (
+	auditFile  *os.File
+	auditMutex sync.Mutex
+)
+
+// initAuditLog opens the audit log in the data directory for appending
+func initAuditLog(dataDir string) error {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(dataDir, "policy_audit.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open policy audit log: %v", err)
+	}
+
+	if auditFile != nil {
+		auditFile.Close()
+	}
+	auditFile = file
+	return nil
+}
+
+// writeAudit appends a decision to the audit log, one JSON object per line
+func writeAudit(tool, sessionID, commandLine string, decision Decision, confirmed bool) {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	if auditFile == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:      time.Now(),
+		Tool:      tool,
+		SessionID: sessionID,
+		Command:   commandLine,
+		Decision:  decision.Action,
+		Confirmed: confirmed,
+		Rule:      decision.Rule,
+		Reason:    decision.Reason,
+		Matched:   decision.Command,
+	}
+
+	// Keep shell operators such as > and & readable in the log
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(entry); err != nil {
+		log.Printf("[Policy] Failed to encode audit entry: %v", err)
+		return
+	}
+	if _, err := auditFile.Write(buf.Bytes()); err != nil {
+		log.Printf("[Policy] Failed to write audit log: %v", err)
+	}
+}
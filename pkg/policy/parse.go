@@ -0,0 +1,497 @@
+package policy
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// SimpleCommand is a single command found in a command line, such as one
+// stage of a pipeline
+type SimpleCommand struct {
+	Name         string     // The binary as written, e.g. "rm" or "/usr/bin/rm"
+	Args         []string   // Arguments after the binary, with quotes removed
+	Redirects    []Redirect // Redirections of the command's input and output
+	Piped        bool       // The command reads the output of the previous pipeline stage
+	Substitution bool       // The command runs inside $(...), backticks, sh -c, or eval
+}
+
+// Redirect is a redirection such as "> out.txt" or "2>> errors.log"
+type Redirect struct {
+	Op     string // The operator, e.g. ">", ">>", "<", "2>" or "&>"
+	Target string // The file or file descriptor redirected to or from
+}
+
+// Binary returns the base name of the command's binary, without a .exe suffix
+func (c SimpleCommand) Binary() string {
+	name := filepath.Base(strings.ReplaceAll(c.Name, "\\", "/"))
+	return strings.TrimSuffix(strings.ToLower(name), ".exe")
+}
+
+// String returns the command as a single line
+func (c SimpleCommand) String() string {
+	parts := append([]string{c.Name}, c.Args...)
+	for _, redirect := range c.Redirects {
+		parts = append(parts, redirect.Op+redirect.Target)
+	}
+	return strings.Join(parts, " ")
+}
+
+// wrapperCommands run another command given as their arguments. The wrapped
+// command is checked as well, so that "sudo rm" is caught by a rule for rm.
+var wrapperCommands = map[string]bool{
+	"sudo":    true,
+	"doas":    true,
+	"env":     true,
+	"nohup":   true,
+	"nice":    true,
+	"time":    true,
+	"timeout": true,
+	"exec":    true,
+	"command": true,
+	"xargs":   true,
+	"watch":   true,
+	"stdbuf":  true,
+}
+
+// wrapperValueFlags are the flags of wrapper commands that take a value, so
+// that in "sudo -u root rm" the value root isn't taken for the wrapped command
+var wrapperValueFlags = map[string]map[string]bool{
+	"sudo": {
+		"-u": true, "-g": true, "-C": true, "-D": true, "-R": true, "-T": true, "-U": true, "-h": true,
+		"-p": true, "-r": true, "-t": true,
+		"--user": true, "--group": true, "--close-from": true, "--chdir": true, "--chroot": true,
+		"--command-timeout": true, "--other-user": true, "--host": true, "--prompt": true, "--role": true, "--type": true,
+	},
+	"doas": {"-u": true, "-C": true},
+	"env": {
+		"-u": true, "-C": true, "-S": true,
+		"--unset": true, "--chdir": true, "--split-string": true,
+	},
+	"nice":    {"-n": true, "--adjustment": true},
+	"timeout": {"-s": true, "-k": true, "--signal": true, "--kill-after": true},
+	"exec":    {"-a": true},
+	"time":    {"-f": true, "-o": true, "--format": true, "--output": true},
+	"xargs": {
+		"-n": true, "-P": true, "-I": true, "-L": true, "-s": true, "-d": true, "-E": true, "-a": true,
+		"--max-args": true, "--max-procs": true, "--max-lines": true, "--max-chars": true,
+		"--delimiter": true, "--eof": true, "--arg-file": true, "--process-slot-var": true,
+	},
+	"watch":  {"-n": true, "--interval": true},
+	"stdbuf": {"-i": true, "-o": true, "-e": true, "--input": true, "--output": true, "--error": true},
+}
+
+// shellCommands run the script given with -c
+var shellCommands = map[string]bool{
+	"sh":   true,
+	"bash": true,
+	"zsh":  true,
+	"dash": true,
+	"ksh":  true,
+}
+
+// token is a word or operator of a command line
+type token struct {
+	text       string
+	operator   bool     // A control or redirection operator rather than a word
+	substitute []string // Command substitutions found inside a word
+}
+
+// ParseCommandLine splits a shell command line into simple commands. It
+// understands quoting, pipelines, lists (;, &&, ||, &), redirections, here
+// documents, subshells and command substitution, which is enough to see what
+// a command line runs without fully implementing the shell language.
+// Commands inside substitutions, and commands run through wrappers such as
+// sudo or sh -c, are included as well.
+func ParseCommandLine(line string) []SimpleCommand {
+	return parseCommandLine(line, false, 0)
+}
+
+// QuoteCommandLine joins arguments into a command line that parses back into
+// the same words, so that commands run without a shell can be checked too
+func QuoteCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\r\n'\"\\$`|&;()<>*?[]#~{}!") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// maxParseDepth limits how deeply nested substitutions are parsed
+const maxParseDepth = 8
+
+// parseCommandLine parses a command line, marking the commands as
+// substitutions if it is nested in another command line
+func parseCommandLine(line string, nested bool, depth int) []SimpleCommand {
+	if depth > maxParseDepth {
+		return nil
+	}
+
+	var commands []SimpleCommand
+	current := SimpleCommand{Substitution: nested}
+	started := false
+	piped := false
+
+	finish := func() {
+		if current.Name != "" {
+			commands = append(commands, expandCommand(current, depth)...)
+		} else if len(current.Redirects) > 0 {
+			// A bare redirection such as "> file" still writes to the file
+			commands = append(commands, current)
+		}
+		current = SimpleCommand{Substitution: nested, Piped: piped}
+		started = false
+	}
+
+	tokens := tokenize(line)
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		for _, inner := range tok.substitute {
+			commands = append(commands, parseCommandLine(inner, true, depth+1)...)
+		}
+
+		if tok.operator {
+			switch {
+			case tok.text == "|" || tok.text == "|&":
+				piped = true
+				finish()
+			case isRedirectOperator(tok.text):
+				redirect := Redirect{Op: tok.text}
+				if i+1 < len(tokens) && !tokens[i+1].operator {
+					i++
+					redirect.Target = tokens[i].text
+					for _, inner := range tokens[i].substitute {
+						commands = append(commands, parseCommandLine(inner, true, depth+1)...)
+					}
+				}
+				current.Redirects = append(current.Redirects, redirect)
+			default:
+				// Lists, subshells and groups start a new command
+				piped = false
+				finish()
+			}
+			continue
+		}
+
+		// Leading variable assignments are not the command
+		if !started && isAssignment(tok.text) {
+			continue
+		}
+		if !started && (tok.text == "{" || tok.text == "}" || tok.text == "!") {
+			continue
+		}
+
+		if !started {
+			current.Name = tok.text
+			started = true
+		} else {
+			current.Args = append(current.Args, tok.text)
+		}
+	}
+	piped = false
+	finish()
+
+	return commands
+}
+
+// expandCommand returns a command together with any commands it runs, such
+// as the command wrapped by sudo or the script passed to sh -c
+func expandCommand(command SimpleCommand, depth int) []SimpleCommand {
+	commands := []SimpleCommand{command}
+	binary := command.Binary()
+
+	switch {
+	case wrapperCommands[binary]:
+		args := command.Args
+		for len(args) > 0 && (strings.HasPrefix(args[0], "-") || isAssignment(args[0])) {
+			if args[0] == "--" {
+				args = args[1:]
+				break
+			}
+			if takesValue(binary, args[0]) && len(args) > 1 {
+				args = args[1:]
+			}
+			args = args[1:]
+		}
+		// timeout takes a duration before the command
+		if binary == "timeout" && len(args) > 0 {
+			args = args[1:]
+		}
+		if len(args) > 0 {
+			wrapped := SimpleCommand{
+				Name:         args[0],
+				Args:         args[1:],
+				Piped:        command.Piped,
+				Substitution: command.Substitution,
+			}
+			commands = append(commands, expandCommand(wrapped, depth)...)
+		}
+
+	case shellCommands[binary]:
+		for i, arg := range command.Args {
+			if strings.HasPrefix(arg, "-") && strings.Contains(arg, "c") && !strings.HasPrefix(arg, "--") && i+1 < len(command.Args) {
+				commands = append(commands, parseCommandLine(command.Args[i+1], true, depth+1)...)
+				break
+			}
+		}
+
+	case binary == "eval":
+		// eval runs its arguments joined into a command line, like sh -c
+		commands = append(commands, parseCommandLine(strings.Join(command.Args, " "), true, depth+1)...)
+	}
+
+	return commands
+}
+
+// takesValue reports whether a flag of a wrapper command is followed by a
+// separate value, as in "sudo -u root", "sudo -Eu root" or "nice
+// --adjustment 5", rather than having none or an attached one, as in "sudo
+// -uroot" or "nice --adjustment=5"
+func takesValue(binary, flag string) bool {
+	flags := wrapperValueFlags[binary]
+	if strings.HasPrefix(flag, "--") {
+		return flags[flag]
+	}
+	// In a group of short flags, the first that takes a value takes the
+	// rest of the group, or the next argument if it is the last
+	for i := 1; i < len(flag); i++ {
+		if flags["-"+flag[i:i+1]] {
+			return i == len(flag)-1
+		}
+	}
+	return false
+}
+
+// isAssignment reports whether a word is a variable assignment such as FOO=bar
+func isAssignment(word string) bool {
+	name, _, found := strings.Cut(word, "=")
+	if !found || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isRedirectOperator reports whether an operator redirects input or output
+func isRedirectOperator(op string) bool {
+	return strings.ContainsAny(op, "<>")
+}
+
+// tokenize splits a command line into words and operators
+func tokenize(line string) []token {
+	var tokens []token
+	var word strings.Builder
+	var substitute []string
+	inWord := false
+	var heredocs []string // Delimiters of here documents whose bodies follow the current line
+
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, token{text: word.String(), substitute: substitute})
+		}
+		word.Reset()
+		substitute = nil
+		inWord = false
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\\' && i+1 < len(runes):
+			if runes[i+1] != '\n' {
+				word.WriteRune(runes[i+1])
+				inWord = true
+			}
+			i++
+
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			word.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			i = end
+
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				switch {
+				case runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\\\"$`", runes[i+1]):
+					i++
+					word.WriteRune(runes[i])
+				case runes[i] == '$' && i+1 < len(runes) && runes[i+1] == '(':
+					end := matchParen(runes, i+1)
+					substitute = append(substitute, string(runes[i+2:end]))
+					word.WriteString(string(runes[i:min(end+1, len(runes))]))
+					i = end
+				case runes[i] == '`':
+					end := indexRune(runes, i+1, '`')
+					substitute = append(substitute, string(runes[i+1:end]))
+					word.WriteString(string(runes[i:min(end+1, len(runes))]))
+					i = end
+				default:
+					word.WriteRune(runes[i])
+				}
+			}
+			inWord = true
+
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			end := matchParen(runes, i+1)
+			substitute = append(substitute, string(runes[i+2:end]))
+			word.WriteString(string(runes[i:min(end+1, len(runes))]))
+			inWord = true
+			i = end
+
+		case r == '`':
+			end := indexRune(runes, i+1, '`')
+			substitute = append(substitute, string(runes[i+1:end]))
+			word.WriteString(string(runes[i:min(end+1, len(runes))]))
+			inWord = true
+			i = end
+
+		case r == '#' && !inWord:
+			// A comment runs to the end of the line
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+
+		case r == ' ' || r == '\t' || r == '\r':
+			flush()
+
+		case r == '\n':
+			flush()
+			tokens = append(tokens, token{text: "\n", operator: true})
+			// Skip the bodies of here documents started on this line
+			for _, delimiter := range heredocs {
+				i = skipHeredoc(runes, i+1, delimiter) - 1
+			}
+			heredocs = nil
+
+		case strings.ContainsRune("|&;()<>", r):
+			// A file descriptor number directly before a redirection is part of it
+			prefix := ""
+			if (r == '<' || r == '>') && inWord && isDigits(word.String()) {
+				prefix = word.String()
+				word.Reset()
+				inWord = false
+			}
+			flush()
+
+			op := readOperator(runes, i)
+			i += len([]rune(op)) - 1
+			tokens = append(tokens, token{text: prefix + op, operator: true})
+
+			if op == "<<" || op == "<<-" {
+				if delimiter, next := readWord(runes, i+1); delimiter != "" {
+					heredocs = append(heredocs, delimiter)
+					tokens = append(tokens, token{text: delimiter})
+					i = next - 1
+				}
+			}
+
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// readOperator returns the longest operator starting at position i
+func readOperator(runes []rune, i int) string {
+	operators := []string{"<<<", "<<-", "&>>", "&&", "||", ";;", "|&", ">>", "<<", ">&", "<&", "&>", ">|", "<>"}
+	rest := string(runes[i:min(i+3, len(runes))])
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			return op
+		}
+	}
+	return string(runes[i])
+}
+
+// readWord reads a possibly quoted word after skipping blanks, returning the
+// word without quotes and the position after it
+func readWord(runes []rune, i int) (string, int) {
+	for i < len(runes) && (runes[i] == ' ' || runes[i] == '\t') {
+		i++
+	}
+
+	var word strings.Builder
+	for i < len(runes) && !strings.ContainsRune(" \t\r\n|&;()<>", runes[i]) {
+		if runes[i] != '\'' && runes[i] != '"' && runes[i] != '\\' {
+			word.WriteRune(runes[i])
+		}
+		i++
+	}
+	return word.String(), i
+}
+
+// skipHeredoc returns the position after the line that ends a here document
+// whose body starts at position i
+func skipHeredoc(runes []rune, i int, delimiter string) int {
+	for i < len(runes) {
+		end := indexRune(runes, i, '\n')
+		line := strings.TrimLeft(strings.TrimRight(string(runes[i:end]), "\r"), "\t")
+		i = end + 1
+		if line == delimiter {
+			break
+		}
+	}
+	return min(i, len(runes))
+}
+
+// indexRune returns the position of r at or after start, or the end of the
+// input if it is missing
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// matchParen returns the position of the parenthesis closing the one at
+// position open, skipping quoted text
+func matchParen(runes []rune, open int) int {
+	depth := 0
+	for i := open; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '\'':
+			i = indexRune(runes, i+1, '\'')
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(runes)
+}
+
+// isDigits reports whether s is a non-empty string of digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,392 @@
+package policy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Action is what happens to a command that matches a rule
+type Action string
+
+// Policy actions, from least to most restrictive
+const (
+	ActionAllow Action = "allow"
+	ActionAsk   Action = "ask"
+	ActionDeny  Action = "deny"
+)
+
+// confirmationLifetime is how long a confirmation token stays valid
+const confirmationLifetime = 10 * time.Minute
+
+// Rule matches simple commands and decides what to do with them. All of the
+// conditions that are set must hold for the rule to match; a rule without
+// conditions matches every command.
+type Rule struct {
+	Name        string   `json:"name"`
+	Action      Action   `json:"action"`
+	Reason      string   `json:"reason,omitempty"`
+	Tools       []string `json:"tools,omitempty"`        // Tools the rule applies to (default: all)
+	Commands    []string `json:"commands,omitempty"`     // Glob patterns for the binary name, e.g. "rm" or "python*"
+	Args        []string `json:"args,omitempty"`         // Glob patterns that must each match at least one argument
+	ArgsPattern string   `json:"args_pattern,omitempty"` // Regular expression matched against the arguments joined by spaces
+	Redirects   []string `json:"redirects,omitempty"`    // Glob patterns for redirection targets, e.g. "/etc/*"
+	Piped       bool     `json:"piped,omitempty"`        // Only match commands that read the output of another command
+
+	argsRegexp *regexp.Regexp
+}
+
+// Policy is a set of rules for commands run by the cmdexec and shell tools.
+// Each simple command in a command line gets the action of the first rule
+// that matches it, or the default action. The command line as a whole gets
+// the most restrictive action of its commands.
+type Policy struct {
+	Default Action `json:"default"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Decision is the result of checking a command line against the policy
+type Decision struct {
+	Action  Action
+	Rule    string // Name of the rule that decided, empty for the default action
+	Reason  string
+	Command string // The simple command that decided
+}
+
+// confirmation is a pending request to run a command that needs confirmation
+type confirmation struct {
+	tool      string
+	sessionID string
+	command   string
+	expires   time.Time
+}
+
+// Global policy state
+var (
+	currentPolicy = &Policy{Default: ActionAllow}
+	confirmations = make(map[string]confirmation)
+	policyMutex   sync.RWMutex
+)
+
+// LoadPolicy reads a policy from a JSON file
+func LoadPolicy(filePath string) (*Policy, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %v", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file: %v", err)
+	}
+
+	if policy.Default == "" {
+		policy.Default = ActionAllow
+	}
+	if !validAction(policy.Default) {
+		return nil, fmt.Errorf("invalid default action: %s", policy.Default)
+	}
+
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if !validAction(rule.Action) {
+			return nil, fmt.Errorf("invalid action for %s: %q", rule.Name, rule.Action)
+		}
+		if rule.ArgsPattern != "" {
+			rule.argsRegexp, err = regexp.Compile(rule.ArgsPattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid args_pattern for %s: %v", rule.Name, err)
+			}
+		}
+		for _, pattern := range append(append(append([]string{}, rule.Commands...), rule.Args...), rule.Redirects...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q for %s: %v", pattern, rule.Name, err)
+			}
+		}
+	}
+
+	return &policy, nil
+}
+
+// InitPolicy loads the policy file, if one is given, and opens the audit log
+// in the data directory. Without a policy file every command is allowed, but
+// decisions are still audited.
+func InitPolicy(policyFile, dataDir string) error {
+	if policyFile != "" {
+		policy, err := LoadPolicy(policyFile)
+		if err != nil {
+			return err
+		}
+		SetPolicy(policy)
+		log.Printf("[Policy] Loaded %d rules from %s (default: %s)", len(policy.Rules), policyFile, policy.Default)
+	}
+
+	return initAuditLog(dataDir)
+}
+
+// SetPolicy replaces the current policy
+func SetPolicy(policy *Policy) {
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+
+	currentPolicy = policy
+}
+
+// Evaluate checks a command line run by a tool against the policy
+func (p *Policy) Evaluate(tool, commandLine string) Decision {
+	decision := Decision{Action: p.Default}
+	if strings.TrimSpace(commandLine) == "" {
+		return decision
+	}
+
+	first := true
+	for _, command := range ParseCommandLine(commandLine) {
+		current := Decision{Action: p.Default, Command: command.String()}
+		for _, rule := range p.Rules {
+			if rule.matches(tool, command) {
+				current = Decision{Action: rule.Action, Rule: rule.Name, Reason: rule.Reason, Command: command.String()}
+				break
+			}
+		}
+
+		if first || restrictiveness(current.Action) > restrictiveness(decision.Action) {
+			decision = current
+			first = false
+		}
+	}
+
+	return decision
+}
+
+// matches reports whether a rule applies to a simple command run by a tool
+func (r *Rule) matches(tool string, command SimpleCommand) bool {
+	if len(r.Tools) > 0 && !containsString(r.Tools, tool) {
+		return false
+	}
+	if r.Piped && !command.Piped {
+		return false
+	}
+
+	if len(r.Commands) > 0 {
+		binary := command.Binary()
+		matched := false
+		for _, pattern := range r.Commands {
+			if matchGlob(strings.ToLower(pattern), binary) || matchGlob(pattern, command.Name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, pattern := range r.Args {
+		matched := false
+		for _, arg := range command.Args {
+			if matchGlob(pattern, arg) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if r.argsRegexp != nil && !r.argsRegexp.MatchString(strings.Join(command.Args, " ")) {
+		return false
+	}
+
+	if len(r.Redirects) > 0 {
+		matched := false
+		for _, redirect := range command.Redirects {
+			for _, pattern := range r.Redirects {
+				if matchGlob(pattern, redirect.Target) {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check evaluates a command line run by a tool against the current policy
+// and records the decision in the audit log. A command that needs
+// confirmation is allowed if confirmToken is a token that was issued for the
+// same command.
+func Check(tool, sessionID, commandLine, confirmToken string) (Decision, string) {
+	policyMutex.RLock()
+	decision := currentPolicy.Evaluate(tool, commandLine)
+	policyMutex.RUnlock()
+
+	confirmed := false
+	token := ""
+	if decision.Action == ActionAsk {
+		if confirmToken != "" && useConfirmation(confirmToken, tool, sessionID, commandLine) {
+			confirmed = true
+		} else {
+			token = newConfirmation(tool, sessionID, commandLine)
+		}
+	}
+
+	writeAudit(tool, sessionID, commandLine, decision, confirmed)
+	log.Printf("[Policy] %s %s for %s command: %s", decisionLabel(decision.Action, confirmed), ruleLabel(decision), tool, commandLine)
+
+	if confirmed {
+		decision.Action = ActionAllow
+	}
+	return decision, token
+}
+
+// Enforce applies the policy to a command line about to be run by a tool.
+// It returns an error if the command is denied, and a result asking for
+// confirmation if the command needs it. If both are nil the command may run.
+func Enforce(tool, sessionID, commandLine string, arguments map[string]interface{}) (*mcp.CallToolResult, error) {
+	confirmToken, _ := arguments["confirm"].(string)
+	decision, token := Check(tool, sessionID, commandLine, confirmToken)
+
+	switch decision.Action {
+	case ActionDeny:
+		return nil, fmt.Errorf("command denied by policy (%s): %s", ruleLabel(decision), describe(decision))
+
+	case ActionAsk:
+		resultText := "Confirmation required\n\n"
+		resultText += fmt.Sprintf("The command needs confirmation under the command policy (%s): %s\n\n", ruleLabel(decision), describe(decision))
+		resultText += fmt.Sprintf("Command: %s\n", commandLine)
+		resultText += fmt.Sprintf("Confirmation Token: %s\n\n", token)
+		resultText += fmt.Sprintf("To run the command, repeat the same call with confirm set to the token within %v.\n", confirmationLifetime)
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: resultText,
+				},
+			},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// newConfirmation issues a token that allows a command to run once
+func newConfirmation(tool, sessionID, commandLine string) string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+
+	// Drop expired tokens
+	now := time.Now()
+	for key, pending := range confirmations {
+		if now.After(pending.expires) {
+			delete(confirmations, key)
+		}
+	}
+
+	confirmations[token] = confirmation{
+		tool:      tool,
+		sessionID: sessionID,
+		command:   commandLine,
+		expires:   now.Add(confirmationLifetime),
+	}
+	return token
+}
+
+// useConfirmation consumes a token if it was issued for the same command
+func useConfirmation(token, tool, sessionID, commandLine string) bool {
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+
+	pending, exists := confirmations[token]
+	if !exists || time.Now().After(pending.expires) {
+		return false
+	}
+	if pending.tool != tool || pending.sessionID != sessionID || pending.command != commandLine {
+		return false
+	}
+
+	delete(confirmations, token)
+	return true
+}
+
+// describe explains a decision
+func describe(decision Decision) string {
+	if decision.Reason != "" {
+		return fmt.Sprintf("%s (%s)", decision.Reason, decision.Command)
+	}
+	return decision.Command
+}
+
+// ruleLabel names the rule that made a decision
+func ruleLabel(decision Decision) string {
+	if decision.Rule == "" {
+		return "default action"
+	}
+	return "rule " + decision.Rule
+}
+
+// decisionLabel returns the outcome of a decision for logging
+func decisionLabel(action Action, confirmed bool) string {
+	if confirmed {
+		return "confirmed"
+	}
+	return string(action)
+}
+
+// restrictiveness orders actions from allow to deny
+func restrictiveness(action Action) int {
+	switch action {
+	case ActionDeny:
+		return 2
+	case ActionAsk:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// validAction reports whether an action is known
+func validAction(action Action) bool {
+	return action == ActionAllow || action == ActionAsk || action == ActionDeny
+}
+
+// matchGlob matches a value against a glob pattern. A pattern ending in
+// "/**" also matches everything below the directory.
+func matchGlob(pattern, value string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return value == prefix || strings.HasPrefix(value, prefix+"/")
+	}
+	matched, _ := path.Match(pattern, value)
+	return matched
+}
+
+// containsString reports whether a list contains a string
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
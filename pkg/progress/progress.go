@@ -0,0 +1,130 @@
+package progress
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// minInterval is the minimum time between two progress notifications, so that
+// fast loops over many files do not flood the client
+const minInterval = 100 * time.Millisecond
+
+// Reporter sends progress and partial output notifications for a single tool
+// call. Notifications are only sent when the client supplied a progressToken.
+// A nil Reporter is valid and reports nothing.
+type Reporter struct {
+	ctx      context.Context
+	server   *server.MCPServer
+	token    mcp.ProgressToken
+	toolName string
+	mutex    sync.Mutex
+	lastSent time.Time
+	sent     bool    // Whether a progress notification was sent
+	progress float64 // Progress of the last notification
+}
+
+// NewReporter creates a reporter for the given tool call
+func NewReporter(ctx context.Context, toolName string, request mcp.CallToolRequest) *Reporter {
+	reporter := &Reporter{
+		ctx:      ctx,
+		server:   server.ServerFromContext(ctx),
+		toolName: toolName,
+	}
+	if request.Params.Meta != nil {
+		reporter.token = request.Params.Meta.ProgressToken
+	}
+	return reporter
+}
+
+// Enabled reports whether the client asked for progress notifications
+func (r *Reporter) Enabled() bool {
+	return r != nil && r.server != nil && r.token != nil
+}
+
+// Report sends a progress notification. total may be 0 if unknown, and
+// message usually names the item currently being processed. Updates are
+// throttled, except for the final one where progress reaches total. Progress
+// must increase, so updates that don't go past the last one sent, as from
+// workers that finish out of order, are dropped.
+func (r *Reporter) Report(progress, total float64, message string) {
+	if !r.Enabled() {
+		return
+	}
+
+	// The notification is sent under the lock, which sending doesn't block,
+	// so that notifications go out in the order they were checked
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.sent && progress <= r.progress {
+		return
+	}
+	final := total > 0 && progress >= total
+	if !final && time.Since(r.lastSent) < minInterval {
+		return
+	}
+	r.lastSent = time.Now()
+	r.sent = true
+	r.progress = progress
+
+	params := map[string]any{
+		"progressToken": r.token,
+		"progress":      progress,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	if message != "" {
+		params["message"] = message
+	}
+
+	r.send("notifications/progress", params)
+}
+
+// Output sends a chunk of partial output from a running command as a logging
+// notification. stream is "stdout" or "stderr".
+func (r *Reporter) Output(stream, text string) {
+	if !r.Enabled() || text == "" {
+		return
+	}
+
+	r.send("notifications/message", map[string]any{
+		"level":  mcp.LoggingLevelInfo,
+		"logger": r.toolName,
+		"data": map[string]any{
+			"progressToken": r.token,
+			"stream":        stream,
+			"text":          text,
+		},
+	})
+}
+
+// Writer returns an io.Writer that forwards everything written to it as
+// partial output on the given stream
+func (r *Reporter) Writer(stream string) *OutputWriter {
+	return &OutputWriter{reporter: r, stream: stream}
+}
+
+// send sends a notification to the client that made the tool call
+func (r *Reporter) send(method string, params map[string]any) {
+	if err := r.server.SendNotificationToClient(r.ctx, method, params); err != nil {
+		log.Printf("[Progress] Failed to send %s for tool '%s': %v", method, r.toolName, err)
+	}
+}
+
+// OutputWriter is an io.Writer that streams writes as output notifications
+type OutputWriter struct {
+	reporter *Reporter
+	stream   string
+}
+
+// Write forwards p as partial output. It never fails, so it is safe to use
+// with io.MultiWriter alongside the buffer that collects the full output.
+func (w *OutputWriter) Write(p []byte) (int, error) {
+	w.reporter.Output(w.stream, string(p))
+	return len(p), nil
+}
@@ -0,0 +1,57 @@
+package progress
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// testSession collects the notifications sent to a client
+type testSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) Initialize()       {}
+func (s *testSession) Initialized() bool { return true }
+func (s *testSession) SessionID() string { return "test" }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func TestReportIncreases(t *testing.T) {
+	const total = 10
+	srv := server.NewMCPServer("test", "1.0")
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, total)}
+	reporter := &Reporter{
+		ctx:      srv.WithContext(context.Background(), session),
+		server:   srv,
+		token:    mcp.ProgressToken("token"),
+		toolName: "test",
+	}
+
+	// Workers that finish out of order report lower progress later, even
+	// after the final notification, which isn't throttled
+	reporter.Report(2, total, "")
+	time.Sleep(minInterval)
+	reporter.Report(1, total, "")
+	reporter.Report(total, total, "")
+	time.Sleep(minInterval)
+	reporter.Report(3, total, "")
+	reporter.Report(total, total, "")
+	close(session.notifications)
+
+	last := 0.0
+	for notification := range session.notifications {
+		progress := notification.Params.AdditionalFields["progress"].(float64)
+		if progress <= last {
+			t.Fatalf("progress %v sent after %v", progress, last)
+		}
+		last = progress
+	}
+	if last == 0 {
+		t.Fatal("no progress was sent")
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
 )
 
-// indexRepository indexes a repository for RAG
-func indexRepository(repoPath string, filePatterns []string) (*IndexResult, error) {
+// indexRepository indexes a repository for RAG, reporting progress as it
+// finds the files to index
+func indexRepository(repoPath string, filePatterns []string, reporter *progress.Reporter) (*IndexResult, error) {
 	startTime := time.Now()
 
 	// Validate repository path
@@ -54,6 +57,7 @@ func indexRepository(repoPath string, filePatterns []string) (*IndexResult, erro
 			}
 			if matched {
 				files = append(files, path)
+				reporter.Report(float64(len(files)), 0, path)
 
 				// Count file types
 				ext := filepath.Ext(path)
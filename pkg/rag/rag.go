@@ -6,6 +6,7 @@ import (
 	"log"
 	"path/filepath"
 
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
 	workspace "github.com/Code-Monger/CodeSpinneret/pkg/workspace"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -59,7 +60,7 @@ func HandleRAG(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolR
 		}
 
 		// Index the repository
-		indexResult, err := indexRepository(repoPath, filePatterns)
+		indexResult, err := indexRepository(repoPath, filePatterns, progress.NewReporter(ctx, "rag", request))
 		if err != nil {
 			return nil, fmt.Errorf("error indexing repository: %v", err)
 		}
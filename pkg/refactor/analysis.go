@@ -0,0 +1,180 @@
+package refactor
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// mutation records how the code changes a variable
+type mutation struct {
+	assigned     bool // Assigned, incremented, or a field or array element assigned
+	addressTaken bool // Its address is taken, or a pointer method is called on it
+}
+
+// mutations returns the variables that the code in node changes, directly
+// or through their fields and array elements
+func mutations(info *types.Info, node ast.Node) map[*types.Var]*mutation {
+	mutated := make(map[*types.Var]*mutation)
+	record := func(expr ast.Expr) *mutation {
+		v := rootVar(info, expr)
+		if v == nil {
+			return nil
+		}
+		if mutated[v] == nil {
+			mutated[v] = &mutation{}
+		}
+		return mutated[v]
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				// A short variable declaration assigns the variables it
+				// redeclares, which are uses rather than definitions
+				if ident, ok := lhs.(*ast.Ident); ok && n.Tok == token.DEFINE && info.Defs[ident] != nil {
+					continue
+				}
+				if m := record(lhs); m != nil {
+					m.assigned = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if m := record(n.X); m != nil {
+				m.assigned = true
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.ASSIGN {
+				for _, expr := range []ast.Expr{n.Key, n.Value} {
+					if m := record(expr); m != nil {
+						m.assigned = true
+					}
+				}
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				if m := record(n.X); m != nil {
+					m.addressTaken = true
+				}
+			}
+		case *ast.SelectorExpr:
+			// Calling a pointer method on an addressable value takes its
+			// address
+			if selection := info.Selections[n]; selection != nil && selection.Kind() == types.MethodVal {
+				if signature, ok := selection.Obj().Type().(*types.Signature); ok && signature.Recv() != nil {
+					if _, pointerRecv := signature.Recv().Type().(*types.Pointer); pointerRecv && !isPointer(info.TypeOf(n.X)) {
+						if m := record(n.X); m != nil {
+							m.addressTaken = true
+						}
+					}
+				}
+			}
+		}
+		return true
+	})
+	return mutated
+}
+
+// rootVar returns the local variable whose storage an expression denotes,
+// following field selections and indexing of arrays, but not pointers,
+// slices, or maps, which refer to storage elsewhere
+func rootVar(info *types.Info, expr ast.Expr) *types.Var {
+	for {
+		switch e := expr.(type) {
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.SelectorExpr:
+			selection := info.Selections[e]
+			if selection == nil || selection.Kind() != types.FieldVal || selection.Indirect() || isPointer(info.TypeOf(e.X)) {
+				return nil
+			}
+			expr = e.X
+		case *ast.IndexExpr:
+			if _, ok := typeUnder(info.TypeOf(e.X)).(*types.Array); !ok {
+				return nil
+			}
+			expr = e.X
+		case *ast.Ident:
+			if v, ok := info.ObjectOf(e).(*types.Var); ok && !v.IsField() {
+				return v
+			}
+			return nil
+		default:
+			return nil
+		}
+	}
+}
+
+// isPointer reports whether a type is a pointer
+func isPointer(t types.Type) bool {
+	_, ok := typeUnder(t).(*types.Pointer)
+	return ok
+}
+
+// typeUnder returns the underlying type of t, or nil
+func typeUnder(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
+
+// protectedLines returns the lines of the text from line firstLine on,
+// counted from zero, that continue raw string literals in node and so must
+// keep their indentation
+func protectedLines(fset *token.FileSet, node ast.Node, firstLine int) map[int]bool {
+	protected := make(map[int]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING && strings.HasPrefix(lit.Value, "`") {
+			start := fset.Position(lit.Pos()).Line
+			end := fset.Position(lit.End()).Line
+			for line := start + 1; line <= end; line++ {
+				protected[line-firstLine] = true
+			}
+		}
+		return true
+	})
+	return protected
+}
+
+// qualifier returns a types.Qualifier that names packages as the file does,
+// adding the packages that the file doesn't import to missing
+func qualifier(pkg *types.Package, file *ast.File, info *types.Info, missing map[string]string) types.Qualifier {
+	names := make(map[string]string)
+	for _, spec := range file.Imports {
+		var obj types.Object
+		if spec.Name != nil {
+			obj = info.Defs[spec.Name]
+		} else {
+			obj = info.Implicits[spec]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok && pkgName.Name() != "_" && pkgName.Name() != "." {
+			names[pkgName.Imported().Path()] = pkgName.Name()
+		}
+	}
+
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		if name, exists := names[other.Path()]; exists {
+			return name
+		}
+		if missing != nil {
+			missing[other.Path()] = other.Name()
+		}
+		return other.Name()
+	}
+}
+
+// conversionType formats a type for use in a conversion, with parentheses
+// where the type would otherwise not parse as one
+func conversionType(t types.Type, q types.Qualifier) string {
+	s := types.TypeString(t, q)
+	if strings.HasPrefix(s, "*") || strings.HasPrefix(s, "<-") || strings.HasPrefix(s, "func") {
+		return "(" + s + ")"
+	}
+	return s
+}
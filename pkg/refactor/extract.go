@@ -0,0 +1,485 @@
+package refactor
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/goload"
+)
+
+// selection is the run of statements of a function body that is extracted
+type selection struct {
+	decl  *ast.FuncDecl
+	stmts []ast.Stmt
+	start token.Pos // Start of the first statement
+	end   token.Pos // End of the last statement
+}
+
+// contains reports whether pos is inside the selection
+func (s *selection) contains(pos token.Pos) bool {
+	return s.start <= pos && pos < s.end
+}
+
+// Extract moves the statements on lines startLine to endLine of a function
+// into a new function named name, declared after the function. Variables
+// declared outside the lines and used in them become parameters; variables
+// that the lines change, or declare and the rest of the function uses, are
+// returned and assigned at the call.
+func Extract(ctx context.Context, dir, filename string, startLine, endLine int, name string) (*Result, error) {
+	if !token.IsIdentifier(name) || name == "_" {
+		return nil, fmt.Errorf("invalid function name: %q", name)
+	}
+	if startLine < 1 || endLine < startLine {
+		return nil, fmt.Errorf("invalid line range %d-%d", startLine, endLine)
+	}
+
+	prog, err := goload.Load(ctx, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	pkg, file := prog.FilePackage(filename)
+	if pkg == nil {
+		return nil, fmt.Errorf("%s is not part of a package in module %s", filename, prog.ModulePath)
+	}
+	if pkg.Types == nil {
+		return nil, fmt.Errorf("package %s could not be type-checked", pkg.ImportPath)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if endLine > prog.Fset.File(file.Pos()).LineCount() {
+		return nil, fmt.Errorf("line %d is out of range", endLine)
+	}
+
+	sel, err := findSelection(prog.Fset, file, content, startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkControlFlow(pkg.Info, sel); err != nil {
+		return nil, err
+	}
+
+	// The new function must not collide with a package-level name, an
+	// import, or a local name where it is called
+	if existing := pkg.Types.Scope().Lookup(name); existing != nil {
+		return nil, fmt.Errorf("%s is already declared at %s", name, prog.Fset.Position(existing.Pos()))
+	}
+	for _, f := range pkg.Files {
+		if scope := pkg.Info.Scopes[f]; scope != nil && scope.Lookup(name) != nil {
+			return nil, fmt.Errorf("%s is the name of an import in %s", name, prog.Fset.Position(f.Pos()).Filename)
+		}
+	}
+	if scope := pkg.Scope(sel.start); scope != nil {
+		if _, existing := scope.LookupParent(name, sel.start); existing != nil && existing.Parent() != types.Universe {
+			return nil, fmt.Errorf("%s is declared at %s, which is in scope at the extracted lines", name, prog.Fset.Position(existing.Pos()))
+		}
+	}
+
+	params, results, declared, pointers, err := extractedVars(prog.Fset, pkg.Info, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make(map[string]string)
+	q := qualifier(pkg.Types, file, pkg.Info, missing)
+
+	// The parameters, merging runs of the same type
+	paramType := func(v *types.Var) types.Type {
+		if pointers[v] {
+			return types.NewPointer(v.Type())
+		}
+		return v.Type()
+	}
+	var paramList []string
+	for i, v := range params {
+		if i+1 < len(params) && types.Identical(paramType(v), paramType(params[i+1])) {
+			paramList = append(paramList, v.Name())
+		} else {
+			paramList = append(paramList, v.Name()+" "+types.TypeString(paramType(v), q))
+		}
+	}
+	var resultTypes, resultNames []string
+	for _, v := range results {
+		resultTypes = append(resultTypes, types.TypeString(v.Type(), q))
+		resultNames = append(resultNames, v.Name())
+	}
+	var argNames []string
+	for _, v := range params {
+		if pointers[v] {
+			argNames = append(argNames, "&"+v.Name())
+		} else {
+			argNames = append(argNames, v.Name())
+		}
+	}
+
+	// The body keeps the lines as they are, with their comments, and
+	// dereferences the variables passed as pointers where selectors don't
+	firstLineStart := offsetOfLine(content, startLine)
+	lastLineEnd := lineEnd(content, offsetOfLine(content, endLine))
+	bodyEdits := pointerEdits(prog.Fset, pkg.Info, sel, pointers, firstLineStart)
+	bodyContent, err := applyEdits(content[firstLineStart:lastLineEnd], bodyEdits)
+	if err != nil {
+		return nil, err
+	}
+	protected := protectedLines(prog.Fset, &ast.BlockStmt{List: sel.stmts}, startLine)
+	body := reindent(string(bodyContent), "\t", protected)
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	if len(results) > 0 {
+		body += "\treturn " + strings.Join(resultNames, ", ") + "\n"
+	}
+
+	signature := "func " + name + "(" + strings.Join(paramList, ", ") + ")"
+	switch len(resultTypes) {
+	case 0:
+	case 1:
+		signature += " " + resultTypes[0]
+	default:
+		signature += " (" + strings.Join(resultTypes, ", ") + ")"
+	}
+	function := "\n\n" + signature + " {\n" + body + "}"
+
+	// The call assigns the results, declaring the ones that are new
+	indent := indentation(content, prog.Fset.Position(sel.start).Offset)
+	call := name + "(" + strings.Join(argNames, ", ") + ")"
+	var callText string
+	switch {
+	case len(results) == 0:
+		callText = indent + call + "\n"
+	case len(declared) == len(results):
+		callText = indent + strings.Join(resultNames, ", ") + " := " + call + "\n"
+	default:
+		for _, v := range declared {
+			callText += indent + "var " + v.Name() + " " + types.TypeString(v.Type(), q) + "\n"
+		}
+		callText += indent + strings.Join(resultNames, ", ") + " = " + call + "\n"
+	}
+
+	declEnd := prog.Fset.Position(sel.decl.End()).Offset
+	edits := map[string][]textEdit{
+		filename: {
+			{start: firstLineStart, end: lastLineEnd, text: callText},
+			{start: declEnd, end: declEnd, text: function},
+		},
+	}
+
+	result := &Result{}
+	result.Summary = fmt.Sprintf("Extracted lines %d-%d of %s into %s", startLine, endLine, sel.decl.Name.Name, signature)
+	return finish(ctx, dir, prog, result, edits, map[string]map[string]string{filename: missing})
+}
+
+// findSelection finds the statements of a function body that the lines
+// cover. The lines must cover whole statements of one block, with nothing
+// else on them but comments.
+func findSelection(fset *token.FileSet, file *ast.File, content []byte, startLine, endLine int) (*selection, error) {
+	var decl *ast.FuncDecl
+	for _, d := range file.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil &&
+			fset.Position(fd.Body.Lbrace).Line < startLine && fset.Position(fd.Body.Rbrace).Line > endLine {
+			decl = fd
+			break
+		}
+	}
+	if decl == nil {
+		return nil, fmt.Errorf("lines %d-%d are not inside a function body", startLine, endLine)
+	}
+
+	var found *selection
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		var list []ast.Stmt
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			list = n.List
+		case *ast.CaseClause:
+			list = n.Body
+		case *ast.CommClause:
+			list = n.Body
+		default:
+			return true
+		}
+
+		var stmts []ast.Stmt
+		for _, stmt := range list {
+			first := fset.Position(stmt.Pos()).Line
+			last := fset.Position(stmt.End()).Line
+			if last < startLine || first > endLine {
+				continue
+			}
+			if first < startLine || last > endLine {
+				return true
+			}
+			stmts = append(stmts, stmt)
+		}
+		if len(stmts) == 0 {
+			return true
+		}
+
+		// Everything else on the lines must be whitespace and comments
+		start := fset.Position(stmts[0].Pos()).Offset
+		end := fset.Position(stmts[len(stmts)-1].End()).Offset
+		before := content[lineStart(content, offsetOfLine(content, startLine)):start]
+		after := content[end:lineEnd(content, offsetOfLine(content, endLine))]
+		if onlyComments(before) && onlyComments(after) {
+			found = &selection{decl: decl, stmts: stmts, start: stmts[0].Pos(), end: stmts[len(stmts)-1].End()}
+		}
+		return true
+	})
+
+	if found == nil {
+		return nil, fmt.Errorf("lines %d-%d do not cover whole statements of a block in %s", startLine, endLine, decl.Name.Name)
+	}
+	return found, nil
+}
+
+// offsetOfLine returns the offset of the start of a one-based line
+func offsetOfLine(content []byte, line int) int {
+	offset := 0
+	for i := 1; i < line && offset < len(content); i++ {
+		offset = lineEnd(content, offset)
+	}
+	return offset
+}
+
+// onlyComments reports whether source text has nothing but whitespace,
+// comments, and semicolons
+func onlyComments(text []byte) bool {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("", -1, len(text)), text, nil, scanner.ScanComments)
+	for {
+		_, tok, lit := s.Scan()
+		switch {
+		case tok == token.EOF:
+			return true
+		case tok == token.COMMENT:
+		case tok == token.SEMICOLON && lit != "\n":
+		default:
+			return false
+		}
+	}
+}
+
+// checkControlFlow rejects selections whose statements leave the function
+// or jump out of the selection, which a function call can't do
+func checkControlFlow(info *types.Info, sel *selection) error {
+	labels := make(map[*types.Label]bool)
+	for _, stmt := range sel.stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if labeled, ok := n.(*ast.LabeledStmt); ok {
+				if label, ok := info.Defs[labeled.Label].(*types.Label); ok {
+					labels[label] = true
+				}
+			}
+			return true
+		})
+	}
+
+	// Labels declared in the selection can't be the target of jumps from
+	// outside it
+	var err error
+	ast.Inspect(sel.decl.Body, func(n ast.Node) bool {
+		if branch, ok := n.(*ast.BranchStmt); ok && branch.Label != nil && !sel.contains(branch.Pos()) {
+			if label, ok := info.Uses[branch.Label].(*types.Label); ok && labels[label] && err == nil {
+				err = fmt.Errorf("the label %s is used outside the extracted lines", label.Name())
+			}
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Walk the statements, tracking the loops and switches that unlabeled
+	// break and continue statements leave
+	var walk func(node ast.Node, breakable, loop bool) error
+	walk = func(node ast.Node, breakable, loop bool) error {
+		var err error
+		ast.Inspect(node, func(n ast.Node) bool {
+			if err != nil {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				err = fmt.Errorf("the extracted lines contain a return statement")
+			case *ast.DeferStmt:
+				err = fmt.Errorf("the extracted lines contain a defer statement, which would run when the new function returns")
+			case *ast.ForStmt:
+				err = walk(n.Body, true, true)
+				return false
+			case *ast.RangeStmt:
+				err = walk(n.Body, true, true)
+				return false
+			case *ast.SwitchStmt:
+				err = walk(n.Body, true, loop)
+				return false
+			case *ast.TypeSwitchStmt:
+				err = walk(n.Body, true, loop)
+				return false
+			case *ast.SelectStmt:
+				err = walk(n.Body, true, loop)
+				return false
+			case *ast.BranchStmt:
+				switch {
+				case n.Tok == token.FALLTHROUGH:
+					err = fmt.Errorf("the extracted lines contain a fallthrough statement")
+				case n.Label != nil:
+					if label, ok := info.Uses[n.Label].(*types.Label); ok && !labels[label] {
+						err = fmt.Errorf("the extracted lines jump to the label %s outside them", label.Name())
+					}
+				case n.Tok == token.BREAK && !breakable:
+					err = fmt.Errorf("the extracted lines contain a break statement for a loop or switch outside them")
+				case n.Tok == token.CONTINUE && !loop:
+					err = fmt.Errorf("the extracted lines contain a continue statement for a loop outside them")
+				}
+			}
+			return err == nil
+		})
+		return err
+	}
+
+	for _, stmt := range sel.stmts {
+		if err := walk(stmt, false, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractedVars returns the parameters of the extracted function, the
+// variables it returns, and which of those are declared by the selection.
+// Parameters whose address the selection takes are passed as pointers, so
+// that it keeps changing the caller's variables.
+func extractedVars(fset *token.FileSet, info *types.Info, sel *selection) (params, results, declared []*types.Var, pointers map[*types.Var]bool, err error) {
+	inFunction := func(obj types.Object) bool {
+		return sel.decl.Pos() <= obj.Pos() && obj.Pos() < sel.decl.End()
+	}
+
+	// Uses inside the selection of local objects declared outside it
+	var idents []*ast.Ident
+	for ident := range info.Uses {
+		if sel.contains(ident.Pos()) {
+			idents = append(idents, ident)
+		}
+	}
+	sort.Slice(idents, func(i, j int) bool { return idents[i].Pos() < idents[j].Pos() })
+
+	seen := make(map[types.Object]bool)
+	for _, ident := range idents {
+		obj := info.Uses[ident]
+		if seen[obj] || !inFunction(obj) || sel.contains(obj.Pos()) {
+			continue
+		}
+		seen[obj] = true
+		switch obj := obj.(type) {
+		case *types.Var:
+			if !obj.IsField() {
+				params = append(params, obj)
+			}
+		case *types.TypeName:
+			if _, ok := obj.Type().(*types.TypeParam); ok {
+				return nil, nil, nil, nil, fmt.Errorf("the extracted lines use the type parameter %s", obj.Name())
+			}
+			return nil, nil, nil, nil, fmt.Errorf("the extracted lines use the local type %s declared at %s", obj.Name(), fset.Position(obj.Pos()))
+		case *types.Const:
+			return nil, nil, nil, nil, fmt.Errorf("the extracted lines use the local constant %s declared at %s", obj.Name(), fset.Position(obj.Pos()))
+		}
+	}
+
+	// Variables changed by the selection are returned
+	pointers = make(map[*types.Var]bool)
+	mutated := mutations(info, &ast.BlockStmt{List: sel.stmts})
+	for _, v := range params {
+		switch m := mutated[v]; {
+		case m == nil:
+		case m.addressTaken:
+			pointers[v] = true
+		default:
+			results = append(results, v)
+		}
+	}
+
+	// Objects declared in the selection and used after it
+	usedAfter := make(map[types.Object]bool)
+	for ident, obj := range info.Uses {
+		if inFunction(obj) && sel.contains(obj.Pos()) && !sel.contains(ident.Pos()) && sel.decl.Pos() <= ident.Pos() && ident.Pos() < sel.decl.End() {
+			usedAfter[obj] = true
+		}
+	}
+	var later []types.Object
+	for obj := range usedAfter {
+		later = append(later, obj)
+	}
+	sort.Slice(later, func(i, j int) bool { return later[i].Pos() < later[j].Pos() })
+	for _, obj := range later {
+		v, ok := obj.(*types.Var)
+		if !ok {
+			return nil, nil, nil, nil, fmt.Errorf("%s is declared in the extracted lines and used after them", obj.Name())
+		}
+		results = append(results, v)
+		declared = append(declared, v)
+	}
+
+	return params, results, declared, pointers, nil
+}
+
+// pointerEdits rewrites the uses in the selection of the variables passed as
+// pointers: &v becomes v, selectors stay as they are, and other uses become
+// *v. Offsets are relative to base.
+func pointerEdits(fset *token.FileSet, info *types.Info, sel *selection, pointers map[*types.Var]bool, base int) []textEdit {
+	var edits []textEdit
+	offset := func(pos token.Pos) int {
+		return fset.Position(pos).Offset - base
+	}
+	for _, stmt := range sel.stmts {
+		var stack []ast.Node
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if n == nil {
+				stack = stack[:len(stack)-1]
+				return false
+			}
+			var parent ast.Node
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			stack = append(stack, n)
+
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			if v, ok := info.Uses[ident].(*types.Var); !ok || !pointers[v] {
+				return true
+			}
+
+			switch parent := parent.(type) {
+			case *ast.SelectorExpr:
+				if parent.X == ident {
+					return true
+				}
+			case *ast.UnaryExpr:
+				if parent.Op == token.AND {
+					edits = append(edits, textEdit{start: offset(parent.Pos()), end: offset(ident.Pos())})
+					return true
+				}
+			}
+			text := "*" + ident.Name
+			if needsParens(parent, ident) {
+				text = "(" + text + ")"
+			}
+			edits = append(edits, textEdit{start: offset(ident.Pos()), end: offset(ident.End()), text: text})
+			return true
+		})
+	}
+	return edits
+}
@@ -0,0 +1,129 @@
+package refactor
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/goedit"
+	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HandleRefactor is the handler function for the refactor tool
+func HandleRefactor(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+
+	// Extract operation
+	operation, ok := arguments["operation"].(string)
+	if !ok || operation == "" {
+		return nil, fmt.Errorf("operation must be a non-empty string")
+	}
+
+	// Extract the session, mode, file, module directory and timeout. The
+	// file is required for extract, and for inline by position.
+	options, err := goedit.ParseOptions(arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	loadCtx, cancel := options.Context(ctx)
+	defer cancel()
+
+	var result *Result
+	switch operation {
+	case "extract":
+		functionName, _ := arguments["function_name"].(string)
+		if functionName == "" {
+			return nil, fmt.Errorf("function_name is required for extract")
+		}
+		startLine, _ := arguments["start_line"].(float64)
+		endLine, _ := arguments["end_line"].(float64)
+		if options.File == "" || startLine < 1 {
+			return nil, fmt.Errorf("file_path and start_line are required for extract")
+		}
+		if endLine < 1 {
+			endLine = startLine
+		}
+		result, err = Extract(loadCtx, options.Directory, options.File, int(startLine), int(endLine), functionName)
+
+	case "inline":
+		var target Target
+		if name, _ := arguments["function"].(string); name != "" {
+			target = Target{Name: name}
+		} else {
+			line, _ := arguments["line"].(float64)
+			column, _ := arguments["column"].(float64)
+			if options.File == "" || line < 1 || column < 1 {
+				return nil, fmt.Errorf("either function, or file_path with line and column, is required for inline")
+			}
+			target = Target{File: options.File, Line: int(line), Column: int(column)}
+		}
+		result, err = Inline(loadCtx, options.Directory, target)
+
+	default:
+		return nil, fmt.Errorf("unsupported operation: %s", operation)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the refactoring, unless it breaks the code
+	details := fmt.Sprintf("Files changed: %d\n", len(result.Changes))
+	return result.Finish("refactor", options, result.Summary, details)
+}
+
+// RegisterRefactor registers the refactor tool with the MCP server
+func RegisterRefactor(mcpServer *server.MCPServer) {
+	// Create the tool definition
+	refactorTool := mcp.NewTool("refactor",
+		mcp.WithDescription("Refactors Go code on its syntax tree, keeping comments. 'extract' moves whole statements on a range of lines into a new function, with parameters for the variables they use and results for the variables they change or declare for later code. 'inline' replaces calls of a function whose body is a single return statement, or that returns nothing, with its body, and removes the function once it is unused. Returns the edits as a unified diff, and only applies them if the refactored code still type-checks."),
+		mcp.WithString("operation",
+			mcp.Required(),
+			mcp.Description("Refactoring to perform: 'extract' or 'inline'"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Workspace session ID, whose root resolves relative paths (optional)"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("File to refactor, relative to the workspace root (extract; inline by position)"),
+		),
+		mcp.WithNumber("start_line",
+			mcp.Description("First line of the statements to extract (extract)"),
+		),
+		mcp.WithNumber("end_line",
+			mcp.Description("Last line of the statements to extract (extract, default: start_line)"),
+		),
+		mcp.WithString("function_name",
+			mcp.Description("Name of the new function (extract)"),
+		),
+		mcp.WithNumber("line",
+			mcp.Description("One-based line of the function's name, at its declaration to inline every call, or at a call to inline only that call (inline)"),
+		),
+		mcp.WithNumber("column",
+			mcp.Description("One-based column of the function's name, counted in characters (inline)"),
+		),
+		mcp.WithString("function",
+			mcp.Description("Qualified name of the function to inline instead of a position, e.g. 'pkg/shapes.area' or 'pkg/shapes.Circle.Area' (inline)"),
+		),
+		mcp.WithString("directory",
+			mcp.Description("Directory inside the Go module, relative to the workspace root (default: the file's directory, or the workspace root)"),
+		),
+		mcp.WithString("mode",
+			mcp.Description("'preview' to only return the diff, or 'apply' to also write the changes (default: 'preview')"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Timeout in seconds for loading and checking the module (default: 120)"),
+		),
+	)
+
+	// Wrap the handler with stats tracking
+	wrappedHandler := stats.WrapHandler("refactor", HandleRefactor)
+
+	// Register the tool with the wrapped handler
+	mcpServer.AddTool(refactorTool, wrappedHandler)
+
+	// Log the registration
+	log.Printf("[Refactor] Registered refactor tool")
+}
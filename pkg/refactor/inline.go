@@ -0,0 +1,931 @@
+package refactor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/goload"
+)
+
+// Target identifies the function to inline, either by position or by
+// qualified name. A position on a call inlines only that call.
+type Target struct {
+	File   string // Absolute path of the file
+	Line   int    // One-based line
+	Column int    // One-based column, counted in characters
+	Name   string // Qualified name such as example.com/mod/pkg.Func
+}
+
+// callee is the analyzed declaration of the inlined function
+type callee struct {
+	fset      *token.FileSet
+	fn        *types.Func
+	pkg       *goload.Package
+	decl      *ast.FuncDecl
+	content   []byte
+	signature *types.Signature
+	params    []*types.Var // The receiver first, if there is one
+	uses      map[*types.Var][]*ast.Ident
+	mutated   map[*types.Var]bool
+	results   []ast.Expr // The returned expressions, for functions with results
+	stmts     []ast.Stmt // The statements, for functions without results
+	locals    map[string]bool
+	blockVars bool         // Whether the body declares variables at its top level
+	refs      []*ast.Ident // Names of package-level, imported, and predeclared objects
+	members   []*ast.Ident // Selected fields and methods
+	parents   map[ast.Node]ast.Node
+}
+
+// callSite is a call of the inlined function
+type callSite struct {
+	pkg     *goload.Package
+	file    *ast.File
+	content []byte
+	call    *ast.CallExpr
+	parent  ast.Node
+}
+
+// Inline replaces calls of a function with its body. The function must
+// either consist of a single return statement, whose expressions replace the
+// call, or return nothing, in which case its statements replace the call
+// statement. Arguments are substituted for the parameters where that keeps
+// their evaluation the same, and are otherwise assigned to variables of the
+// parameters' names. When every call is inlined, an unexported function is
+// removed.
+func Inline(ctx context.Context, dir string, target Target) (*Result, error) {
+	prog, err := goload.Load(ctx, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj types.Object
+	var onlyCall *ast.CallExpr
+	if target.Name != "" {
+		obj, err = prog.LookupName(target.Name)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var ident *ast.Ident
+		ident, obj, err = prog.IdentAt(target.File, target.Line, target.Column)
+		if err != nil {
+			return nil, err
+		}
+		pkg, file := prog.FilePackage(target.File)
+		if pkg.Info.Uses[ident] != nil {
+			onlyCall = callOf(file, ident)
+			if onlyCall == nil {
+				return nil, fmt.Errorf("%s at %d:%d is not called there", ident.Name, target.Line, target.Column)
+			}
+		}
+	}
+
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a function", obj.Name())
+	}
+	fn = fn.Origin()
+
+	c, err := analyzeCallee(prog, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	sites, otherRefs := findCallSites(prog, fn)
+	if onlyCall != nil {
+		var selected []*callSite
+		for _, site := range sites {
+			if site.call == onlyCall {
+				selected = append(selected, site)
+			}
+		}
+		otherRefs += len(sites) - len(selected)
+		sites = selected
+	}
+	if len(sites) == 0 {
+		return nil, fmt.Errorf("%s is never called", fn.Name())
+	}
+
+	result := &Result{}
+	edits := make(map[string][]textEdit)
+	imports := make(map[string]map[string]string)
+	inlined := 0
+	for _, site := range sites {
+		position := prog.Fset.Position(site.call.Pos())
+		filename := position.Filename
+		if nestedCall(site, sites) {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: not inlined: the call is an argument of another inlined call; inline again to replace it", relativePath(prog.ModuleDir, position.String())))
+			otherRefs++
+			continue
+		}
+
+		if imports[filename] == nil {
+			imports[filename] = make(map[string]string)
+		}
+		missing := make(map[string]string)
+		edit, err := c.inlineAt(site, missing)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s: not inlined: %v", relativePath(prog.ModuleDir, position.String()), err))
+			otherRefs++
+			continue
+		}
+		edits[filename] = append(edits[filename], edit)
+		for path, name := range missing {
+			imports[filename][path] = name
+		}
+		inlined++
+	}
+	if inlined == 0 {
+		return nil, fmt.Errorf("no call of %s can be inlined: %s", fn.Name(), strings.Join(result.Warnings, "; "))
+	}
+
+	result.Summary = fmt.Sprintf("Inlined %d of %d calls of %s", inlined, len(sites), fn.FullName())
+
+	// Remove the function once nothing refers to it. Exported functions can
+	// have callers in other modules, and methods can be needed by interfaces.
+	declFile := prog.Fset.Position(c.decl.Pos()).Filename
+	switch {
+	case otherRefs > 0:
+	case c.signature.Recv() != nil:
+		result.Warnings = append(result.Warnings, fmt.Sprintf("the method %s is kept, as interfaces can require it", fn.Name()))
+	case fn.Exported() && fn.Pkg().Name() != "main":
+		result.Warnings = append(result.Warnings, fmt.Sprintf("the exported function %s is kept for callers outside the module", fn.Name()))
+	default:
+		start := prog.Fset.Position(c.decl.Pos()).Offset
+		if c.decl.Doc != nil {
+			start = prog.Fset.Position(c.decl.Doc.Pos()).Offset
+		}
+		start = lineStart(c.content, start)
+		end := lineEnd(c.content, prog.Fset.Position(c.decl.End()).Offset)
+		if end < len(c.content) && c.content[end] == '\n' {
+			end++
+		} else if start > 1 && c.content[start-1] == '\n' && c.content[start-2] == '\n' {
+			start--
+		}
+		edits[declFile] = append(edits[declFile], textEdit{start: start, end: end})
+		result.Summary += ", and removed its declaration"
+	}
+
+	return finish(ctx, dir, prog, result, edits, imports)
+}
+
+// callOf returns the call whose function is named by ident, if any
+func callOf(file *ast.File, ident *ast.Ident) *ast.CallExpr {
+	var found *ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && calledIdent(call) == ident {
+			found = call
+		}
+		return found == nil
+	})
+	return found
+}
+
+// calledIdent returns the identifier that names the function of a call, as
+// in f(), pkg.f(), or x.f()
+func calledIdent(call *ast.CallExpr) *ast.Ident {
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		return fun
+	case *ast.SelectorExpr:
+		return fun.Sel
+	}
+	return nil
+}
+
+// analyzeCallee checks that a function can be inlined and collects what
+// inlining it needs
+func analyzeCallee(prog *goload.Program, fn *types.Func) (*callee, error) {
+	if fn.Pkg() == nil || prog.Package(fn.Pkg().Path()) == nil {
+		return nil, fmt.Errorf("%s is not declared in module %s", fn.Name(), prog.ModulePath)
+	}
+	pkg := prog.Package(fn.Pkg().Path())
+
+	c := &callee{
+		fset:      prog.Fset,
+		fn:        fn,
+		pkg:       pkg,
+		signature: fn.Type().(*types.Signature),
+		uses:      make(map[*types.Var][]*ast.Ident),
+		mutated:   make(map[*types.Var]bool),
+		locals:    make(map[string]bool),
+		parents:   make(map[ast.Node]ast.Node),
+	}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && pkg.Info.Defs[fd.Name] == fn {
+				c.decl = fd
+			}
+		}
+	}
+	if c.decl == nil || c.decl.Body == nil {
+		return nil, fmt.Errorf("%s has no body to inline", fn.Name())
+	}
+
+	content, err := os.ReadFile(prog.Fset.Position(c.decl.Pos()).Filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	c.content = content
+
+	if c.decl.Type.TypeParams != nil || (c.signature.Recv() != nil && c.signature.RecvTypeParams().Len() > 0) {
+		return nil, fmt.Errorf("generic functions can't be inlined")
+	}
+	if c.signature.Variadic() {
+		return nil, fmt.Errorf("variadic functions can't be inlined")
+	}
+	for i := 0; i < c.signature.Results().Len(); i++ {
+		if c.signature.Results().At(i).Name() != "" {
+			return nil, fmt.Errorf("functions with named results can't be inlined")
+		}
+	}
+
+	if recv := c.signature.Recv(); recv != nil {
+		c.params = append(c.params, recv)
+	}
+	for i := 0; i < c.signature.Params().Len(); i++ {
+		c.params = append(c.params, c.signature.Params().At(i))
+	}
+
+	// The body must be a single return statement, or have no return
+	// statement except a final bare one
+	body := c.decl.Body.List
+	if c.signature.Results().Len() > 0 {
+		ret, ok := singleReturn(body)
+		if !ok || len(ret.Results) != c.signature.Results().Len() {
+			return nil, fmt.Errorf("only functions whose body is a single return statement, or that return nothing, can be inlined")
+		}
+		c.results = ret.Results
+	} else {
+		if n := len(body); n > 0 {
+			if ret, ok := body[n-1].(*ast.ReturnStmt); ok && len(ret.Results) == 0 {
+				body = body[:n-1]
+			}
+		}
+		c.stmts = body
+	}
+
+	var err2 error
+	var stack []ast.Node
+	ast.Inspect(c.decl.Body, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if len(stack) > 0 {
+			c.parents[n] = stack[len(stack)-1]
+		}
+		stack = append(stack, n)
+
+		switch n := n.(type) {
+		case *ast.ReturnStmt:
+			if c.results == nil && !inFuncLit(c.parents, n) && err2 == nil {
+				err2 = fmt.Errorf("functions with early returns can't be inlined")
+			}
+		case *ast.DeferStmt:
+			if !inFuncLit(c.parents, n) && err2 == nil {
+				err2 = fmt.Errorf("functions with defer statements can't be inlined")
+			}
+		case *ast.LabeledStmt:
+			if err2 == nil {
+				err2 = fmt.Errorf("functions with labels can't be inlined")
+			}
+		case *ast.Ident:
+			obj := pkg.Info.Uses[n]
+			if obj == nil {
+				if defined := pkg.Info.Defs[n]; defined != nil {
+					c.locals[defined.Name()] = true
+					if defined.Parent() == pkg.Info.Scopes[c.decl.Type] {
+						c.blockVars = true
+					}
+				}
+				break
+			}
+			if obj == fn {
+				if err2 == nil {
+					err2 = fmt.Errorf("recursive functions can't be inlined")
+				}
+				break
+			}
+			if builtin, ok := obj.(*types.Builtin); ok && builtin.Name() == "recover" && err2 == nil {
+				err2 = fmt.Errorf("functions that call recover can't be inlined")
+			}
+			if v, ok := obj.(*types.Var); ok && c.isParam(v) {
+				c.uses[v] = append(c.uses[v], n)
+				break
+			}
+			if c.decl.Body.Pos() <= obj.Pos() && obj.Pos() < c.decl.Body.End() {
+				// Declared in the body
+				break
+			}
+			if selector, ok := c.parents[n].(*ast.SelectorExpr); ok && selector.Sel == n {
+				c.members = append(c.members, n)
+				break
+			}
+			if v, ok := obj.(*types.Var); ok && v.IsField() {
+				// A field key of a composite literal
+				c.members = append(c.members, n)
+				break
+			}
+			c.refs = append(c.refs, n)
+		}
+		return true
+	})
+	if err2 != nil {
+		return nil, err2
+	}
+
+	for v, m := range mutations(pkg.Info, c.decl.Body) {
+		if c.isParam(v) && (m.assigned || m.addressTaken) {
+			c.mutated[v] = true
+		}
+	}
+	return c, nil
+}
+
+// singleReturn returns the statement of a body that is a single return
+func singleReturn(body []ast.Stmt) (*ast.ReturnStmt, bool) {
+	if len(body) != 1 {
+		return nil, false
+	}
+	ret, ok := body[0].(*ast.ReturnStmt)
+	return ret, ok
+}
+
+// inFuncLit reports whether a node of the body is inside a function literal
+func inFuncLit(parents map[ast.Node]ast.Node, n ast.Node) bool {
+	for p := parents[n]; p != nil; p = parents[p] {
+		if _, ok := p.(*ast.FuncLit); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isParam reports whether v is a parameter or the receiver of the callee
+func (c *callee) isParam(v *types.Var) bool {
+	for _, param := range c.params {
+		if param == v {
+			return true
+		}
+	}
+	return false
+}
+
+// findCallSites returns the calls of fn in the module, and the number of
+// other references to it, such as uses as a value
+func findCallSites(prog *goload.Program, fn *types.Func) ([]*callSite, int) {
+	var sites []*callSite
+	otherRefs := 0
+	contents := make(map[string][]byte)
+
+	for _, pkg := range prog.Packages {
+		for i, file := range pkg.Files {
+			var stack []ast.Node
+			calls := make(map[*ast.Ident]*callSite)
+			ast.Inspect(file, func(n ast.Node) bool {
+				if n == nil {
+					stack = stack[:len(stack)-1]
+					return false
+				}
+				if call, ok := n.(*ast.CallExpr); ok {
+					if ident := calledIdent(call); ident != nil {
+						if used, ok := pkg.Info.Uses[ident].(*types.Func); ok && used.Origin() == fn {
+							if selector, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); !ok || pkg.Info.Selections[selector] == nil || pkg.Info.Selections[selector].Kind() == types.MethodVal {
+								calls[ident] = &callSite{pkg: pkg, file: file, call: call, parent: stack[len(stack)-1]}
+							}
+						}
+					}
+				}
+				stack = append(stack, n)
+				return true
+			})
+
+			for ident, used := range pkg.Info.Uses {
+				if f, ok := used.(*types.Func); !ok || f.Origin() != fn || ident.Pos() < file.Pos() || ident.Pos() > file.End() {
+					continue
+				}
+				site, exists := calls[ident]
+				if !exists {
+					otherRefs++
+					continue
+				}
+				filename := pkg.Filenames[i]
+				if contents[filename] == nil {
+					content, err := os.ReadFile(filename)
+					if err != nil {
+						otherRefs++
+						continue
+					}
+					contents[filename] = content
+				}
+				site.content = contents[filename]
+				sites = append(sites, site)
+			}
+		}
+	}
+
+	sort.Slice(sites, func(i, j int) bool {
+		pi, pj := prog.Fset.Position(sites[i].call.Pos()), prog.Fset.Position(sites[j].call.Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	return sites, otherRefs
+}
+
+// nestedCall reports whether a call site is inside another one
+func nestedCall(site *callSite, sites []*callSite) bool {
+	for _, other := range sites {
+		if other != site && other.file == site.file && other.call.Pos() <= site.call.Pos() && site.call.End() <= other.call.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineAt computes the edit that inlines a call, adding the imports the
+// inlined code needs to missing
+func (c *callee) inlineAt(site *callSite, missing map[string]string) (textEdit, error) {
+	info := site.pkg.Info
+	call := site.call
+	if call.Ellipsis.IsValid() {
+		return textEdit{}, fmt.Errorf("the call passes a variadic argument")
+	}
+	if len(call.Args) != c.signature.Params().Len() {
+		return textEdit{}, fmt.Errorf("the arguments are the results of a call")
+	}
+	switch site.parent.(type) {
+	case *ast.GoStmt, *ast.DeferStmt:
+		return textEdit{}, fmt.Errorf("the call is in a go or defer statement")
+	}
+
+	q := qualifier(site.pkg.Types, site.file, info, missing)
+	scope := site.pkg.Scope(call.Pos())
+	samePackage := site.pkg.Types == c.pkg.Types
+
+	// The arguments of the parameters, with the receiver first
+	args := call.Args
+	if c.signature.Recv() != nil {
+		selector, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok {
+			return textEdit{}, fmt.Errorf("the method is not called through a selector")
+		}
+		args = append([]ast.Expr{selector.X}, args...)
+	}
+
+	// Names in the body must mean the same at the call
+	replacements := make(map[*ast.Ident]string)
+	for _, ident := range c.refs {
+		obj := c.pkg.Info.Uses[ident]
+		switch obj := obj.(type) {
+		case *types.PkgName:
+			name := q(obj.Imported())
+			if _, found := scope.LookupParent(name, call.Pos()); found != nil {
+				if pkgName, ok := found.(*types.PkgName); !ok || pkgName.Imported() != obj.Imported() {
+					return textEdit{}, fmt.Errorf("%s is shadowed at the call", name)
+				}
+			}
+			if name != ident.Name {
+				replacements[ident] = name
+			}
+		default:
+			if obj.Parent() == types.Universe || samePackage {
+				if _, found := scope.LookupParent(ident.Name, call.Pos()); found != obj {
+					return textEdit{}, fmt.Errorf("%s is shadowed at the call", ident.Name)
+				}
+				break
+			}
+			if !obj.Exported() {
+				return textEdit{}, fmt.Errorf("the body uses %s, which is not exported", ident.Name)
+			}
+			name := q(c.pkg.Types)
+			if _, found := scope.LookupParent(name, call.Pos()); found != nil {
+				if pkgName, ok := found.(*types.PkgName); !ok || pkgName.Imported() != c.pkg.Types {
+					return textEdit{}, fmt.Errorf("%s is shadowed at the call", name)
+				}
+			}
+			replacements[ident] = name + "." + ident.Name
+		}
+	}
+	if !samePackage {
+		for _, ident := range c.members {
+			if obj := c.pkg.Info.Uses[ident]; obj != nil && !obj.Exported() && obj.Pkg() == c.pkg.Types {
+				return textEdit{}, fmt.Errorf("the body uses %s, which is not exported", ident.Name)
+			}
+		}
+	}
+
+	// Substitute the arguments for the parameters, or assign them to
+	// variables where substituting would change how often or when they are
+	// evaluated
+	var bindings []string
+	substituted := 0
+	for i, param := range c.params {
+		arg := args[i]
+		argText := string(site.content[c.offset(arg.Pos()):c.offset(arg.End())])
+		uses := c.uses[param]
+		simple := isSimple(info, arg)
+
+		captured := false
+		ast.Inspect(arg, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok && c.locals[ident.Name] {
+				captured = true
+			}
+			return !captured
+		})
+
+		isRecv := c.signature.Recv() != nil && i == 0
+		text, bindText := c.argumentText(param, arg, argText, info, q, isRecv)
+
+		switch {
+		case len(uses) == 0 && simple:
+			continue
+		case len(uses) == 0:
+			if c.results != nil {
+				return textEdit{}, fmt.Errorf("the argument for the unused %s would not be evaluated", paramName(param, i))
+			}
+			bindings = append(bindings, "_ = "+argText)
+			continue
+		case !c.mutated[param] && !captured && simple:
+		case !c.mutated[param] && !captured && len(uses) == 1 && c.results != nil && !callBefore(c, uses[0]):
+			substituted++
+			if substituted == 1 {
+				break
+			}
+			fallthrough
+		default:
+			if c.results != nil {
+				return textEdit{}, fmt.Errorf("the argument for %s would have to be assigned to a variable", param.Name())
+			}
+			for _, arg := range args {
+				if mentions(arg, param.Name()) {
+					return textEdit{}, fmt.Errorf("the variable for %s would capture a name used by the arguments", param.Name())
+				}
+			}
+			bindings = append(bindings, bindText)
+			continue
+		}
+
+		for _, use := range uses {
+			replacement := text
+			if !isPrimary(arg) && text == argText && needsParens(c.parents[use], use) {
+				replacement = "(" + text + ")"
+			}
+			replacements[use] = replacement
+		}
+	}
+
+	if c.results != nil {
+		return c.inlineExpression(site, replacements, q)
+	}
+	return c.inlineStatements(site, replacements, bindings)
+}
+
+// argumentText returns the text that replaces a parameter, converting the
+// argument to the parameter's type where the type differs, and the statement
+// that assigns the argument to a variable of the parameter's name
+func (c *callee) argumentText(param *types.Var, arg ast.Expr, argText string, info *types.Info, q types.Qualifier, isRecv bool) (string, string) {
+	argType := info.TypeOf(arg)
+	if isRecv {
+		// Methods are called on addressable values and pointers alike
+		selectorsOnly := true
+		for _, use := range c.uses[param] {
+			if selector, ok := c.parents[use].(*ast.SelectorExpr); !ok || selector.X != use {
+				selectorsOnly = false
+			}
+		}
+		switch {
+		case isPointer(param.Type()) && !isPointer(argType):
+			if selectorsOnly {
+				return argText, param.Name() + " := &" + argText
+			}
+			return "(&" + argText + ")", param.Name() + " := &" + argText
+		case !isPointer(param.Type()) && isPointer(argType):
+			if selectorsOnly {
+				return argText, param.Name() + " := *" + argText
+			}
+			return "(*" + argText + ")", param.Name() + " := *" + argText
+		}
+		return argText, param.Name() + " := " + argText
+	}
+
+	if untyped, defaultType := untypedDefault(info, arg); untyped {
+		if defaultType != nil && types.Identical(defaultType, param.Type()) {
+			return argText, param.Name() + " := " + argText
+		}
+	} else if types.Identical(argType, param.Type()) {
+		return argText, param.Name() + " := " + argText
+	}
+	typeText := types.TypeString(param.Type(), q)
+	return conversionType(param.Type(), q) + "(" + argText + ")", "var " + param.Name() + " " + typeText + " = " + argText
+}
+
+// inlineExpression replaces a call with the returned expressions
+func (c *callee) inlineExpression(site *callSite, replacements map[*ast.Ident]string, q types.Qualifier) (textEdit, error) {
+	if len(c.results) > 1 {
+		// Several results can only be assigned or returned as a whole
+		ok := false
+		switch parent := site.parent.(type) {
+		case *ast.AssignStmt:
+			ok = len(parent.Rhs) == 1
+		case *ast.ReturnStmt:
+			ok = len(parent.Results) == 1
+		case *ast.ValueSpec:
+			ok = len(parent.Values) == 1
+		}
+		if !ok {
+			return textEdit{}, fmt.Errorf("the results are passed on to another call")
+		}
+	}
+
+	var texts []string
+	for i, expr := range c.results {
+		text := c.source(c.offset(expr.Pos()), c.offset(expr.End()), replacements)
+
+		resultType := c.signature.Results().At(i).Type()
+		untyped, defaultType := untypedDefault(c.pkg.Info, expr)
+		converted := false
+		if (untyped && (defaultType == nil || !types.Identical(defaultType, resultType))) ||
+			(!untyped && !types.Identical(c.pkg.Info.TypeOf(expr), resultType)) {
+			text = conversionType(resultType, q) + "(" + text + ")"
+			converted = true
+		}
+		if !converted && !isPrimary(expr) && len(c.results) == 1 && needsParens(site.parent, site.call) {
+			text = "(" + text + ")"
+		}
+		texts = append(texts, text)
+	}
+
+	// Continuation lines of the expressions follow the call's indentation,
+	// unless they continue raw strings
+	text := strings.Join(texts, ", ")
+	if len(protectedLines(c.fset, c.decl.Body, 0)) == 0 {
+		indent := indentation(site.content, c.offset(site.call.Pos()))
+		bodyIndent := indentation(c.content, c.offset(c.results[0].Pos()))
+		text = strings.ReplaceAll(text, "\n"+bodyIndent, "\n"+indent)
+	}
+
+	return textEdit{start: c.offset(site.call.Pos()), end: c.offset(site.call.End()), text: text}, nil
+}
+
+// inlineStatements replaces a call statement with the statements of the
+// body, in a block of their own if they declare variables
+func (c *callee) inlineStatements(site *callSite, replacements map[*ast.Ident]string, bindings []string) (textEdit, error) {
+	stmt, ok := site.parent.(*ast.ExprStmt)
+	if !ok {
+		return textEdit{}, fmt.Errorf("the call is not a statement")
+	}
+	start := c.offset(stmt.Pos())
+	end := c.offset(stmt.End())
+	indent := indentation(site.content, start)
+
+	var body string
+	if len(c.stmts) > 0 {
+		// Comments before the first statement belong to the body too
+		first := lineEnd(c.content, c.offset(c.decl.Body.Lbrace))
+		if first > c.offset(c.stmts[0].Pos()) {
+			first = c.offset(c.stmts[0].Pos())
+		}
+		last := lineEnd(c.content, c.offset(c.stmts[len(c.stmts)-1].End()))
+		body = reindent(c.source(first, last, replacements), "", protectedLines(c.fset, c.decl.Body, lineOf(c.content, first)))
+	}
+
+	block := c.blockVars || len(bindings) > 0
+	innerIndent := indent
+	if block {
+		innerIndent += "\t"
+	}
+
+	var builder strings.Builder
+	for _, binding := range bindings {
+		builder.WriteString(innerIndent + binding + "\n")
+	}
+	for _, line := range strings.SplitAfter(body, "\n") {
+		if strings.TrimSpace(line) != "" {
+			builder.WriteString(innerIndent)
+		}
+		builder.WriteString(line)
+	}
+	text := strings.TrimSuffix(builder.String(), "\n")
+
+	if block {
+		text = "{\n" + text + "\n" + indent + "}"
+	} else {
+		text = strings.TrimPrefix(text, indent)
+	}
+
+	if text == "" && strings.TrimSpace(string(site.content[lineStart(site.content, start):start])) == "" &&
+		strings.TrimSpace(string(site.content[end:lineEnd(site.content, end)])) == "" {
+		// Nothing to inline; remove the line of the call
+		return textEdit{start: lineStart(site.content, start), end: lineEnd(site.content, end)}, nil
+	}
+	return textEdit{start: start, end: end, text: text}, nil
+}
+
+// paramName names a parameter in messages, including unnamed ones
+func paramName(param *types.Var, index int) string {
+	if param.Name() == "" || param.Name() == "_" {
+		return fmt.Sprintf("parameter %d", index+1)
+	}
+	return param.Name()
+}
+
+// offset returns the offset of a position in its file
+func (c *callee) offset(pos token.Pos) int {
+	return c.fset.Position(pos).Offset
+}
+
+// source returns the text of the callee's file from start to end, with the
+// identifiers in replacements replaced
+func (c *callee) source(start, end int, replacements map[*ast.Ident]string) string {
+	var edits []textEdit
+	for ident, text := range replacements {
+		offset := c.offset(ident.Pos())
+		if start <= offset && offset < end {
+			edits = append(edits, textEdit{start: offset - start, end: offset - start + len(ident.Name), text: text})
+		}
+	}
+	text, err := applyEdits(c.content[start:end], edits)
+	if err != nil {
+		// Identifiers don't overlap
+		return string(c.content[start:end])
+	}
+	return string(text)
+}
+
+// lineOf returns the one-based line of an offset
+func lineOf(content []byte, offset int) int {
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// isSimple reports whether evaluating an expression has no effects and is
+// cheap, so that it can be evaluated more often or at another time
+func isSimple(info *types.Info, expr ast.Expr) bool {
+	if tv, ok := info.Types[expr]; ok && tv.Value != nil {
+		return true
+	}
+	switch e := expr.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return isSimple(info, e.X)
+	case *ast.SelectorExpr:
+		if ident, ok := e.X.(*ast.Ident); ok {
+			if _, ok := info.Uses[ident].(*types.PkgName); ok {
+				return true
+			}
+		}
+		selection := info.Selections[e]
+		return selection != nil && selection.Kind() == types.FieldVal && isSimple(info, e.X)
+	case *ast.UnaryExpr:
+		return (e.Op == token.AND || e.Op == token.SUB || e.Op == token.NOT || e.Op == token.XOR) && isSimple(info, e.X)
+	}
+	return false
+}
+
+// isPrimary reports whether an expression can be an operand without
+// parentheses
+func isPrimary(expr ast.Expr) bool {
+	switch expr.(type) {
+	case *ast.Ident, *ast.BasicLit, *ast.CompositeLit, *ast.CallExpr, *ast.SelectorExpr, *ast.IndexExpr,
+		*ast.IndexListExpr, *ast.SliceExpr, *ast.TypeAssertExpr, *ast.ParenExpr, *ast.FuncLit:
+		return true
+	}
+	return false
+}
+
+// needsParens reports whether an expression that isn't primary needs
+// parentheses as the child of parent
+func needsParens(parent, child ast.Node) bool {
+	switch p := parent.(type) {
+	case *ast.BinaryExpr, *ast.UnaryExpr, *ast.StarExpr:
+		return true
+	case *ast.SelectorExpr:
+		return p.X == child
+	case *ast.IndexExpr:
+		return p.X == child
+	case *ast.IndexListExpr:
+		return p.X == child
+	case *ast.SliceExpr:
+		return p.X == child
+	case *ast.TypeAssertExpr:
+		return p.X == child
+	case *ast.CallExpr:
+		return p.Fun == child
+	}
+	return false
+}
+
+// untypedDefault reports whether an expression is an untyped constant or
+// nil, whose type depends on where it is used, and returns the type it has
+// when nothing else gives it one
+func untypedDefault(info *types.Info, expr ast.Expr) (bool, types.Type) {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.INT:
+			return true, types.Typ[types.Int]
+		case token.FLOAT:
+			return true, types.Typ[types.Float64]
+		case token.IMAG:
+			return true, types.Typ[types.Complex128]
+		case token.CHAR:
+			return true, types.Typ[types.Int32]
+		case token.STRING:
+			return true, types.Typ[types.String]
+		}
+	case *ast.Ident:
+		switch obj := info.Uses[e].(type) {
+		case *types.Const:
+			if basic, ok := obj.Type().(*types.Basic); ok && basic.Info()&types.IsUntyped != 0 {
+				return true, types.Default(basic)
+			}
+		case *types.Nil:
+			return true, nil
+		}
+	case *ast.UnaryExpr:
+		if e.Op != token.ARROW && e.Op != token.AND {
+			return untypedDefault(info, e.X)
+		}
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return true, types.Typ[types.Bool]
+		case token.SHL, token.SHR:
+			return untypedDefault(info, e.X)
+		}
+		leftUntyped, left := untypedDefault(info, e.X)
+		rightUntyped, right := untypedDefault(info, e.Y)
+		switch {
+		case leftUntyped && rightUntyped:
+			// The result has the larger of the two kinds
+			if left != nil && right != nil && rank(right) > rank(left) {
+				return true, right
+			}
+			return true, left
+		}
+	}
+	return false, nil
+}
+
+// rank orders the default types of untyped constants by kind
+func rank(t types.Type) int {
+	switch t {
+	case types.Typ[types.Int]:
+		return 1
+	case types.Typ[types.Int32]:
+		return 2
+	case types.Typ[types.Float64]:
+		return 3
+	case types.Typ[types.Complex128]:
+		return 4
+	}
+	return 0
+}
+
+// callBefore reports whether the returned expressions of the callee call a
+// function or receive from a channel before use, which would then be
+// evaluated first once the argument is substituted
+func callBefore(c *callee, use *ast.Ident) bool {
+	found := false
+	for _, expr := range c.results {
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if found || n == nil || n.Pos() >= use.Pos() {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if tv, ok := c.pkg.Info.Types[n.Fun]; n.End() <= use.Pos() && !(ok && tv.IsType()) {
+					found = true
+				}
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW && n.End() <= use.Pos() {
+					found = true
+				}
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// mentions reports whether an expression contains an identifier named name
+func mentions(expr ast.Expr, name string) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
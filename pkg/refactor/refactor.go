@@ -0,0 +1,223 @@
+package refactor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/diff"
+	"github.com/Code-Monger/CodeSpinneret/pkg/goedit"
+	"github.com/Code-Monger/CodeSpinneret/pkg/goload"
+	"github.com/Code-Monger/CodeSpinneret/pkg/imports"
+)
+
+// Result is a computed refactoring
+type Result struct {
+	goedit.Result
+	Summary string
+}
+
+// maxReportedErrors is the number of type errors listed in a result
+const maxReportedErrors = 10
+
+// textEdit replaces the bytes from start to end of a file
+type textEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// applyEdits applies non-overlapping edits to content
+func applyEdits(content []byte, edits []textEdit) ([]byte, error) {
+	sorted := append([]textEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+
+	var buffer bytes.Buffer
+	last := 0
+	for _, e := range sorted {
+		if e.start < last || e.end < e.start || e.end > len(content) {
+			return nil, fmt.Errorf("overlapping edits")
+		}
+		buffer.Write(content[last:e.start])
+		buffer.WriteString(e.text)
+		last = e.end
+	}
+	buffer.Write(content[last:])
+	return buffer.Bytes(), nil
+}
+
+// finish builds the result from the edits of each file: it fixes the imports
+// of the edited files, adding those in added, formats the files that were
+// formatted before, computes the diff, and type-checks the module with the
+// new contents
+func finish(ctx context.Context, dir string, prog *goload.Program, result *Result, edits map[string][]textEdit, added map[string]map[string]string) (*Result, error) {
+	var paths []string
+	for path := range edits {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	overlay := make(map[string][]byte)
+	for _, path := range paths {
+		oldContent, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+
+		newContent, err := applyEdits(oldContent, edits[path])
+		if err != nil {
+			return nil, fmt.Errorf("%v in %s", err, path)
+		}
+		newContent, err = imports.Fix(newContent, importNames(prog, path), added[path])
+		if err != nil {
+			return nil, fmt.Errorf("the refactored %s does not parse: %v", filepath.Base(path), err)
+		}
+
+		newContent = goedit.KeepFormatted(oldContent, newContent)
+
+		if bytes.Equal(oldContent, newContent) {
+			continue
+		}
+		change := goedit.FileChange{Path: path, OldContent: string(oldContent), NewContent: string(newContent)}
+		result.Changes = append(result.Changes, change)
+		overlay[path] = newContent
+
+		rel := relativePath(prog.ModuleDir, path)
+		result.Diff += diff.Unified("a/"+rel, "b/"+rel, change.OldContent, change.NewContent, diff.DefaultContext)
+	}
+
+	if len(result.Changes) == 0 {
+		return nil, fmt.Errorf("the refactoring changes nothing")
+	}
+
+	// Type-check the refactored code, reporting errors that weren't there
+	// before
+	refactored, err := goload.Load(ctx, dir, overlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the refactored code: %v", err)
+	}
+	result.TypeErrors = newErrors(prog, refactored)
+
+	return result, nil
+}
+
+// importNames returns the package names of the imports of a file, by path
+func importNames(prog *goload.Program, path string) map[string]string {
+	names := make(map[string]string)
+	if pkg, _ := prog.FilePackage(path); pkg != nil && pkg.Types != nil {
+		for _, imported := range pkg.Types.Imports() {
+			names[imported.Path()] = imported.Name()
+		}
+	}
+	return names
+}
+
+// newErrors returns the type errors of the refactored program that the
+// original program didn't have. Errors are compared by file and message, as
+// refactorings move lines.
+func newErrors(before, after *goload.Program) []string {
+	key := func(err error) string {
+		if typeErr, ok := err.(types.Error); ok {
+			return typeErr.Fset.Position(typeErr.Pos).Filename + ":" + typeErr.Msg
+		}
+		return err.Error()
+	}
+
+	existing := make(map[string]int)
+	for _, err := range before.Errors() {
+		existing[key(err)]++
+	}
+
+	var errs []string
+	for _, err := range after.Errors() {
+		if existing[key(err)] > 0 {
+			existing[key(err)]--
+			continue
+		}
+		errs = append(errs, relativeError(after.ModuleDir, err.Error()))
+		if len(errs) == maxReportedErrors {
+			break
+		}
+	}
+	return errs
+}
+
+// lineStart returns the offset of the start of the line that contains offset
+func lineStart(content []byte, offset int) int {
+	return bytes.LastIndexByte(content[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset after the newline of the line that contains
+// offset
+func lineEnd(content []byte, offset int) int {
+	if i := bytes.IndexByte(content[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(content)
+}
+
+// indentation returns the leading whitespace of the line that contains
+// offset
+func indentation(content []byte, offset int) string {
+	start := lineStart(content, offset)
+	end := start
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return string(content[start:end])
+}
+
+// reindent replaces the common indentation of the lines of text with indent.
+// Lines in protected, which continue raw string literals, are left alone.
+func reindent(text, indent string, protected map[int]bool) string {
+	lines := strings.SplitAfter(text, "\n")
+	common := ""
+	first := true
+	for i, line := range lines {
+		if protected[i] || strings.TrimSpace(line) == "" {
+			continue
+		}
+		lead := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			common = lead
+			first = false
+			continue
+		}
+		for !strings.HasPrefix(lead, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	var builder strings.Builder
+	for i, line := range lines {
+		switch {
+		case protected[i]:
+			builder.WriteString(line)
+		case strings.TrimSpace(line) == "":
+			if strings.HasSuffix(line, "\n") {
+				builder.WriteString("\n")
+			}
+		default:
+			builder.WriteString(indent + strings.TrimPrefix(line, common))
+		}
+	}
+	return builder.String()
+}
+
+// relativePath returns a path relative to dir if it is inside it
+func relativePath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// relativeError shortens the file names in an error message
+func relativeError(dir, message string) string {
+	return strings.ReplaceAll(message, dir+string(filepath.Separator), "")
+}
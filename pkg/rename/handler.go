@@ -0,0 +1,107 @@
+package rename
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/goedit"
+	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HandleRename is the handler function for the rename tool
+func HandleRename(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+
+	// Extract the session, mode, file, module directory and timeout
+	options, err := goedit.ParseOptions(arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract the new name
+	newName, ok := arguments["new_name"].(string)
+	if !ok || newName == "" {
+		return nil, fmt.Errorf("new_name must be a non-empty string")
+	}
+
+	// Find the identifier, by position or by qualified name
+	var target Target
+	if options.File != "" {
+		line, _ := arguments["line"].(float64)
+		column, _ := arguments["column"].(float64)
+		if line < 1 || column < 1 {
+			return nil, fmt.Errorf("line and column are required with file_path")
+		}
+		target = Target{File: options.File, Line: int(line), Column: int(column)}
+	} else if name, _ := arguments["name"].(string); name != "" {
+		target = Target{Name: name}
+	} else {
+		return nil, fmt.Errorf("either file_path with line and column, or name is required")
+	}
+
+	loadCtx, cancel := options.Context(ctx)
+	defer cancel()
+
+	result, err := Compute(loadCtx, options.Directory, target, newName)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := 0
+	for _, change := range result.Changes {
+		occurrences += change.Occurrences
+	}
+
+	// Apply the rename, unless it breaks the code
+	title := fmt.Sprintf("Rename %s to %s", result.Object, result.NewName)
+	details := fmt.Sprintf("Occurrences: %d in %d files\n", occurrences, len(result.Changes))
+	return result.Finish("rename", options, title, details)
+}
+
+// RegisterRename registers the rename tool with the MCP server
+func RegisterRename(mcpServer *server.MCPServer) {
+	// Create the tool definition
+	renameTool := mcp.NewTool("rename",
+		mcp.WithDescription("Renames a Go identifier semantically, using the type checker: every reference in the module is updated, including methods that must change together to keep satisfying the same interfaces, while comments, strings and unrelated identifiers of the same name are left alone. Returns the edits as a unified diff, and only applies them if the renamed code still type-checks."),
+		mcp.WithString("new_name",
+			mcp.Required(),
+			mcp.Description("New name of the identifier"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Workspace session ID, whose root resolves relative paths (optional)"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("File containing the identifier, relative to the workspace root (use with line and column)"),
+		),
+		mcp.WithNumber("line",
+			mcp.Description("One-based line of the identifier"),
+		),
+		mcp.WithNumber("column",
+			mcp.Description("One-based column of the identifier, counted in characters"),
+		),
+		mcp.WithString("name",
+			mcp.Description("Qualified name of the identifier instead of a position: package path (an import path, or a path relative to the module) followed by a package-level name and optionally a field or method, e.g. 'pkg/shapes.Circle.Area'; the root package of the module can also be given by its package name, e.g. 'main.Config'"),
+		),
+		mcp.WithString("directory",
+			mcp.Description("Directory inside the Go module, relative to the workspace root (default: the file's directory, or the workspace root)"),
+		),
+		mcp.WithString("mode",
+			mcp.Description("'preview' to only return the diff, or 'apply' to also write the changes (default: 'preview')"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Timeout in seconds for loading and checking the module (default: 120)"),
+		),
+	)
+
+	// Wrap the handler with stats tracking
+	wrappedHandler := stats.WrapHandler("rename", HandleRename)
+
+	// Register the tool with the wrapped handler
+	mcpServer.AddTool(renameTool, wrappedHandler)
+
+	// Log the registration
+	log.Printf("[Rename] Registered rename tool")
+}
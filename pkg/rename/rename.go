@@ -0,0 +1,575 @@
+package rename
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/diff"
+	"github.com/Code-Monger/CodeSpinneret/pkg/goedit"
+	"github.com/Code-Monger/CodeSpinneret/pkg/goload"
+)
+
+// Target identifies the identifier to rename, either by position or by
+// qualified name
+type Target struct {
+	File   string // Absolute path of the file
+	Line   int    // One-based line
+	Column int    // One-based column, counted in characters
+	Name   string // Qualified name such as example.com/mod/pkg.Type.Method
+}
+
+// Result is a computed rename
+type Result struct {
+	goedit.Result
+	Object  string // Description of the renamed object
+	OldName string
+	NewName string
+	Objects int // Number of objects renamed, more than one for related methods
+}
+
+// maxReportedErrors is the number of type errors listed in a result
+const maxReportedErrors = 10
+
+// edit replaces the identifier at an offset
+type edit struct {
+	offset int
+	length int
+}
+
+// Compute resolves the target in the module that contains dir and computes
+// the edits that rename it, including every reference in the module and the
+// related methods of types that satisfy the same interfaces. The renamed
+// code is type-checked before the result is returned; nothing is written.
+func Compute(ctx context.Context, dir string, target Target, newName string) (*Result, error) {
+	if !token.IsIdentifier(newName) || newName == "_" {
+		return nil, fmt.Errorf("invalid identifier: %q", newName)
+	}
+
+	prog, err := goload.Load(ctx, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := resolve(prog, target)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Pkg() == nil || prog.Package(obj.Pkg().Path()) == nil {
+		return nil, fmt.Errorf("%s is not declared in module %s", obj.Name(), prog.ModulePath)
+	}
+	if obj.Name() == newName {
+		return nil, fmt.Errorf("%s already has that name", obj.Name())
+	}
+	if fn, ok := obj.(*types.Func); ok && fn.Type().(*types.Signature).Recv() == nil &&
+		(fn.Name() == "init" || (fn.Name() == "main" && fn.Pkg().Name() == "main")) {
+		return nil, fmt.Errorf("cannot rename the %s function", fn.Name())
+	}
+	if _, ok := obj.(*types.PkgName); ok {
+		return nil, fmt.Errorf("renaming imports is not supported")
+	}
+
+	result := &Result{
+		Object:  describe(obj),
+		OldName: obj.Name(),
+		NewName: newName,
+	}
+
+	objects := relatedObjects(prog, obj)
+	result.Objects = len(objects)
+	for related := range objects {
+		if related != obj && related.Pkg() != nil && prog.Package(related.Pkg().Path()) == nil {
+			return nil, fmt.Errorf("%s must keep its name to match %s", describe(obj), describe(related))
+		}
+	}
+
+	if err := checkConflicts(prog, objects, newName); err != nil {
+		return nil, err
+	}
+
+	// Collect the identifiers to change, by file
+	edits := make(map[string]map[int]edit)
+	addEdit := func(pos token.Pos, length int) {
+		position := prog.Fset.Position(pos)
+		if edits[position.Filename] == nil {
+			edits[position.Filename] = make(map[int]edit)
+		}
+		edits[position.Filename][position.Offset] = edit{offset: position.Offset, length: length}
+	}
+
+	for _, pkg := range prog.Packages {
+		for ident, defined := range pkg.Info.Defs {
+			if defined != nil && objects[origin(defined)] {
+				addEdit(ident.Pos(), len(ident.Name))
+			}
+		}
+		for ident, used := range pkg.Info.Uses {
+			if objects[origin(used)] {
+				addEdit(ident.Pos(), len(ident.Name))
+			}
+		}
+		for _, file := range pkg.Files {
+			for _, pos := range docCommentNames(file, pkg.Info, objects, obj.Name()) {
+				addEdit(pos, len(obj.Name()))
+			}
+		}
+	}
+
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("no occurrences of %s found", obj.Name())
+	}
+
+	// Apply the edits to the contents of the files
+	var paths []string
+	for path := range edits {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	overlay := make(map[string][]byte)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+
+		var offsets []int
+		for offset := range edits[path] {
+			offsets = append(offsets, offset)
+		}
+		sort.Ints(offsets)
+
+		var builder strings.Builder
+		last := 0
+		for _, offset := range offsets {
+			e := edits[path][offset]
+			if offset < last || offset+e.length > len(data) {
+				return nil, fmt.Errorf("overlapping edits in %s", path)
+			}
+			builder.Write(data[last:offset])
+			builder.WriteString(newName)
+			last = offset + e.length
+		}
+		builder.Write(data[last:])
+
+		change := goedit.FileChange{
+			Path:        path,
+			Occurrences: len(offsets),
+			OldContent:  string(data),
+			NewContent:  builder.String(),
+		}
+		result.Changes = append(result.Changes, change)
+		overlay[path] = []byte(change.NewContent)
+
+		rel := relativePath(prog.ModuleDir, path)
+		result.Diff += diff.Unified("a/"+rel, "b/"+rel, change.OldContent, change.NewContent, diff.DefaultContext)
+	}
+
+	// Type-check the renamed code, reporting errors that weren't there before
+	renamed, err := goload.Load(ctx, dir, overlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the renamed code: %v", err)
+	}
+	result.TypeErrors = newErrors(prog, renamed, obj.Name(), newName)
+
+	if _, ok := obj.(*types.Func); ok && result.Objects > 1 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d methods are renamed together so that their types keep satisfying the same interfaces", result.Objects))
+	}
+	// Other modules can use exported names, except those of commands
+	importable := obj.Exported() && obj.Pkg().Name() != "main"
+	if importable && !token.IsExported(newName) {
+		result.Warnings = append(result.Warnings, "the new name is unexported; code outside the module that uses it will break")
+	} else if importable && obj.Parent() == obj.Pkg().Scope() {
+		result.Warnings = append(result.Warnings, "code outside the module that uses the old name is not updated")
+	}
+
+	return result, nil
+}
+
+// resolve finds the object named by a target
+func resolve(prog *goload.Program, target Target) (types.Object, error) {
+	var obj types.Object
+	var err error
+	if target.Name != "" {
+		obj, err = prog.LookupName(target.Name)
+	} else {
+		_, obj, err = prog.IdentAt(target.File, target.Line, target.Column)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return origin(obj), nil
+}
+
+// relatedObjects returns the objects that must be renamed together with obj:
+// methods that the same interfaces require, and the fields that embed a
+// renamed type
+func relatedObjects(prog *goload.Program, obj types.Object) map[types.Object]bool {
+	objects := map[types.Object]bool{obj: true}
+
+	var named []*types.Named
+	for _, pkg := range prog.Packages {
+		for _, defined := range pkg.Info.Defs {
+			if typeName, ok := defined.(*types.TypeName); ok && !typeName.IsAlias() {
+				if t, ok := typeName.Type().(*types.Named); ok {
+					named = append(named, t)
+				}
+			}
+		}
+	}
+
+	switch obj := obj.(type) {
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			relatedMethods(objects, named, obj)
+		}
+	case *types.TypeName:
+		// An embedded type also names the field that embeds it
+		for _, pkg := range prog.Packages {
+			for _, defined := range pkg.Info.Defs {
+				if field, ok := defined.(*types.Var); ok && field.Embedded() {
+					if t, ok := deref(field.Type()).(*types.Named); ok && t.Obj() == obj {
+						objects[origin(field)] = true
+					}
+				}
+			}
+		}
+	}
+
+	return objects
+}
+
+// relatedMethods adds the methods linked to method through interfaces: the
+// interface methods that a concrete method implements, and the methods of
+// every type that implements an interface whose method is renamed. It
+// repeats until no more methods are found.
+func relatedMethods(objects map[types.Object]bool, named []*types.Named, method *types.Func) {
+	name := method.Name()
+	worklist := []*types.Func{method}
+
+	add := func(obj types.Object) {
+		if fn, ok := obj.(*types.Func); ok {
+			fn = fn.Origin()
+			if !objects[fn] {
+				objects[fn] = true
+				worklist = append(worklist, fn)
+			}
+		}
+	}
+
+	for len(worklist) > 0 {
+		current := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+
+		recv := current.Type().(*types.Signature).Recv().Type()
+		iface, isInterface := recv.Underlying().(*types.Interface)
+
+		for _, t := range named {
+			other, ok := t.Underlying().(*types.Interface)
+			if isInterface {
+				if ok {
+					// Interfaces that must stay assignable to or from this one
+					if types.Implements(recv, other) || types.Implements(t, iface) {
+						member, _, _ := types.LookupFieldOrMethod(t, false, t.Obj().Pkg(), name)
+						add(member)
+					}
+				} else if implements(t, iface) {
+					member, _, _ := types.LookupFieldOrMethod(t, true, t.Obj().Pkg(), name)
+					add(member)
+				}
+			} else if ok && other.NumMethods() > 0 && implements(deref(recv), other) {
+				// Interfaces that the method's type satisfies with this method
+				member, _, _ := types.LookupFieldOrMethod(t, false, t.Obj().Pkg(), name)
+				add(member)
+			}
+		}
+	}
+}
+
+// implements reports whether a type or a pointer to it implements an
+// interface, ignoring generic types that are not instantiated
+func implements(t types.Type, iface *types.Interface) bool {
+	if named, ok := t.(*types.Named); ok && named.TypeParams().Len() > 0 {
+		return false
+	}
+	if _, ok := t.Underlying().(*types.Interface); ok {
+		return false
+	}
+	return types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface)
+}
+
+// checkConflicts reports an error if the new name is already declared where
+// a renamed object is declared, or would shadow or be shadowed at one of its
+// references
+func checkConflicts(prog *goload.Program, objects map[types.Object]bool, newName string) error {
+	for obj := range objects {
+		switch obj := obj.(type) {
+		case *types.Func:
+			if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+				if existing, _, _ := types.LookupFieldOrMethod(recv.Type(), true, obj.Pkg(), newName); existing != nil {
+					return fmt.Errorf("%s already has a field or method named %s", typeString(recv.Type()), newName)
+				}
+				continue
+			}
+		case *types.Var:
+			if obj.IsField() {
+				if conflict := fieldConflict(prog, obj, newName); conflict != "" {
+					return fmt.Errorf("%s", conflict)
+				}
+				continue
+			}
+		}
+
+		if scope := obj.Parent(); scope != nil {
+			if existing := scope.Lookup(newName); existing != nil {
+				return fmt.Errorf("%s conflicts with %s declared at %s", newName, describe(existing), prog.Fset.Position(existing.Pos()))
+			}
+			if scope == obj.Pkg().Scope() {
+				if err := importConflict(prog, obj, newName); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Fields and methods are selected through their type, but other objects
+	// are found by scope: a declaration of the new name in an inner scope
+	// would capture references to the renamed object, and the renamed object
+	// would hide declarations of the new name in outer scopes
+	for _, pkg := range prog.Packages {
+		for ident, used := range pkg.Info.Uses {
+			if used.Parent() == nil || (used.Pkg() != nil && used.Pkg() != pkg.Types) {
+				// Fields, methods and qualified references to other packages;
+				// only builtins have no package
+				continue
+			}
+
+			if objects[origin(used)] {
+				scope := pkg.Scope(ident.Pos())
+				if scope == nil {
+					continue
+				}
+				if found, existing := scope.LookupParent(newName, ident.Pos()); existing != nil && within(found, used.Parent()) && found != used.Parent() {
+					return fmt.Errorf("renaming would make the reference at %s refer to %s", prog.Fset.Position(ident.Pos()), describe(existing))
+				}
+				continue
+			}
+
+			if used.Name() != newName {
+				continue
+			}
+			for obj := range objects {
+				scope := obj.Parent()
+				if scope == nil || obj.Pkg() != pkg.Types || !within(scope, used.Parent()) || scope == used.Parent() {
+					continue
+				}
+				// Local declarations are in scope from the point they are declared
+				if scope == obj.Pkg().Scope() || (scope.Contains(ident.Pos()) && ident.Pos() > obj.Pos()) {
+					return fmt.Errorf("the renamed %s would hide %s, which is used at %s", obj.Name(), describe(used), prog.Fset.Position(ident.Pos()))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// within reports whether scope is outer or nested inside it
+func within(scope, outer *types.Scope) bool {
+	for ; scope != nil; scope = scope.Parent() {
+		if scope == outer {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldConflict checks the struct of a field for another field of the new name
+func fieldConflict(prog *goload.Program, field *types.Var, newName string) string {
+	for _, pkg := range prog.Packages {
+		for _, defined := range pkg.Info.Defs {
+			typeName, ok := defined.(*types.TypeName)
+			if !ok {
+				continue
+			}
+			structType, ok := typeName.Type().Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+			for i := 0; i < structType.NumFields(); i++ {
+				if structType.Field(i) != field {
+					continue
+				}
+				if existing, _, _ := types.LookupFieldOrMethod(typeName.Type(), true, field.Pkg(), newName); existing != nil {
+					return fmt.Sprintf("%s already has a field or method named %s", typeName.Name(), newName)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// importConflict checks the files of a package-level object's package for
+// an import of the new name
+func importConflict(prog *goload.Program, obj types.Object, newName string) error {
+	pkg := prog.Package(obj.Pkg().Path())
+	for _, file := range pkg.Files {
+		if scope := pkg.Info.Scopes[file]; scope != nil {
+			if existing := scope.Lookup(newName); existing != nil {
+				return fmt.Errorf("%s conflicts with the import at %s", newName, prog.Fset.Position(existing.Pos()))
+			}
+		}
+	}
+	return nil
+}
+
+// docCommentNames returns the positions of the old name at the start of
+// the doc comments of the renamed declarations, which by convention begin
+// with the name
+func docCommentNames(file *ast.File, info *types.Info, objects map[types.Object]bool, oldName string) []token.Pos {
+	var positions []token.Pos
+	check := func(doc *ast.CommentGroup, names ...*ast.Ident) {
+		if doc == nil || len(doc.List) == 0 {
+			return
+		}
+		for _, name := range names {
+			if obj := info.Defs[name]; obj != nil && objects[origin(obj)] {
+				first := doc.List[0]
+				text := strings.TrimPrefix(first.Text, "//")
+				trimmed := strings.TrimLeft(text, " ")
+				if strings.HasPrefix(trimmed, oldName+" ") || trimmed == oldName {
+					positions = append(positions, first.Slash+token.Pos(2+len(text)-len(trimmed)))
+				}
+				return
+			}
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			check(n.Doc, n.Name)
+		case *ast.GenDecl:
+			for _, spec := range n.Specs {
+				doc := n.Doc
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Doc != nil || len(n.Specs) > 1 {
+						doc = spec.Doc
+					}
+					check(doc, spec.Name)
+				case *ast.ValueSpec:
+					if spec.Doc != nil || len(n.Specs) > 1 {
+						doc = spec.Doc
+					}
+					check(doc, spec.Names...)
+				}
+			}
+		case *ast.Field:
+			check(n.Doc, n.Names...)
+		}
+		return true
+	})
+	return positions
+}
+
+// newErrors returns the type errors of the renamed program that the original
+// program didn't have. Errors are compared by file and message, with the new
+// name replaced by the old one, as the rename can shift columns.
+func newErrors(before, after *goload.Program, oldName, newName string) []string {
+	key := func(err error, name string) string {
+		if typeErr, ok := err.(types.Error); ok {
+			position := typeErr.Fset.Position(typeErr.Pos)
+			return position.Filename + ":" + fmt.Sprint(position.Line) + ":" + strings.ReplaceAll(typeErr.Msg, name, oldName)
+		}
+		return strings.ReplaceAll(err.Error(), name, oldName)
+	}
+
+	existing := make(map[string]bool)
+	for _, err := range before.Errors() {
+		existing[key(err, oldName)] = true
+	}
+
+	var errs []string
+	for _, err := range after.Errors() {
+		if !existing[key(err, newName)] {
+			errs = append(errs, relativeError(after.ModuleDir, err.Error()))
+			if len(errs) == maxReportedErrors {
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// origin returns the generic object that an instantiated field or method
+// comes from
+func origin(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+// deref returns the element type of a pointer type
+func deref(t types.Type) types.Type {
+	if pointer, ok := t.(*types.Pointer); ok {
+		return pointer.Elem()
+	}
+	return t
+}
+
+// describe returns a short description of an object, such as
+// "method (*Circle).Area"
+func describe(obj types.Object) string {
+	qualifier := func(pkg *types.Package) string { return pkg.Name() }
+	switch obj := obj.(type) {
+	case *types.Func:
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			return fmt.Sprintf("method (%s).%s", types.TypeString(recv.Type(), qualifier), obj.Name())
+		}
+		return fmt.Sprintf("func %s.%s", obj.Pkg().Name(), obj.Name())
+	case *types.Var:
+		if obj.IsField() {
+			return fmt.Sprintf("field %s %s", obj.Name(), types.TypeString(obj.Type(), qualifier))
+		}
+		return fmt.Sprintf("var %s %s", obj.Name(), types.TypeString(obj.Type(), qualifier))
+	case *types.Const:
+		return fmt.Sprintf("const %s", obj.Name())
+	case *types.TypeName:
+		if obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() {
+			return fmt.Sprintf("type %s.%s", obj.Pkg().Name(), obj.Name())
+		}
+		return fmt.Sprintf("type %s", obj.Name())
+	case *types.Label:
+		return fmt.Sprintf("label %s", obj.Name())
+	}
+	return obj.Name()
+}
+
+// typeString formats a type with package names as qualifiers
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string { return pkg.Name() })
+}
+
+// relativePath returns a path relative to dir if it is inside it
+func relativePath(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// relativeError shortens the file names in an error message
+func relativeError(dir, message string) string {
+	return strings.ReplaceAll(message, dir+string(filepath.Separator), "")
+}
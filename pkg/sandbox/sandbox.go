@@ -0,0 +1,167 @@
+package sandbox
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Config sets the limits for commands run by the command execution tools.
+// Zero values mean no limit.
+type Config struct {
+	CPUSeconds    int      `json:"cpu_seconds"`    // CPU time limit per process
+	MemoryMB      int      `json:"memory_mb"`      // Address space limit per process
+	MaxProcesses  int      `json:"max_processes"`  // Limit on the number of processes of the user
+	NoNetwork     bool     `json:"no_network"`     // Run in a new network namespace with only loopback
+	ReadOnlyFS    bool     `json:"read_only_fs"`   // Make the filesystem read-only except for the workspace root
+	WritablePaths []string `json:"writable_paths"` // Paths besides the workspace root that stay writable
+}
+
+// Enabled reports whether any limit is set
+func (c Config) Enabled() bool {
+	return c.CPUSeconds > 0 || c.MemoryMB > 0 || c.MaxProcesses > 0 || c.NoNetwork || c.ReadOnlyFS
+}
+
+// String describes the limits
+func (c Config) String() string {
+	if !c.Enabled() {
+		return "none"
+	}
+
+	var parts []string
+	if c.CPUSeconds > 0 {
+		parts = append(parts, fmt.Sprintf("cpu %ds", c.CPUSeconds))
+	}
+	if c.MemoryMB > 0 {
+		parts = append(parts, fmt.Sprintf("memory %d MB", c.MemoryMB))
+	}
+	if c.MaxProcesses > 0 {
+		parts = append(parts, fmt.Sprintf("processes %d", c.MaxProcesses))
+	}
+	if c.NoNetwork {
+		parts = append(parts, "no network")
+	}
+	if c.ReadOnlyFS {
+		text := "read-only filesystem"
+		if len(c.WritablePaths) > 0 {
+			text += fmt.Sprintf(" (writable: workspace root, %s)", strings.Join(c.WritablePaths, ", "))
+		}
+		parts = append(parts, text)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Server-wide limits and per-workspace overrides
+var (
+	defaultConfig  Config
+	sessionConfigs = make(map[string]Config)
+	configMutex    sync.RWMutex
+)
+
+// SetDefaultConfig sets the limits used by workspace sessions without overrides
+func SetDefaultConfig(config Config) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	defaultConfig = config
+}
+
+// DefaultConfig returns the server-wide limits
+func DefaultConfig() Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	return defaultConfig
+}
+
+// SetSessionConfig sets the limits for a workspace session
+func SetSessionConfig(sessionID string, config Config) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	sessionConfigs[sessionID] = config
+}
+
+// ConfigForSession returns the limits for a workspace session, which are the
+// server-wide limits unless the workspace overrides them
+func ConfigForSession(sessionID string) Config {
+	configMutex.RLock()
+	defer configMutex.RUnlock()
+
+	if config, exists := sessionConfigs[sessionID]; exists {
+		return config
+	}
+	return defaultConfig
+}
+
+// ApplyOverrides returns the limits of base tightened by the values given in
+// overrides, which come from the client and so can't loosen them: a number
+// must be a limit no higher than that of base, a flag can only be turned on,
+// and writable paths must be inside the workspace root. Keys that are
+// missing keep the base value.
+func ApplyOverrides(base Config, overrides map[string]interface{}, workspaceRoot string) (Config, error) {
+	config := base
+	config.WritablePaths = append([]string(nil), base.WritablePaths...)
+
+	for key, value := range overrides {
+		switch key {
+		case "cpu_seconds", "memory_mb", "max_processes":
+			number, ok := value.(float64)
+			if !ok || number < 1 {
+				return config, fmt.Errorf("sandbox %s must be a positive number", key)
+			}
+			limit := &config.CPUSeconds
+			switch key {
+			case "memory_mb":
+				limit = &config.MemoryMB
+			case "max_processes":
+				limit = &config.MaxProcesses
+			}
+			if *limit > 0 && int(number) > *limit {
+				return config, fmt.Errorf("sandbox %s can't be raised above the server's limit of %d", key, *limit)
+			}
+			*limit = int(number)
+
+		case "no_network", "read_only_fs":
+			flag, ok := value.(bool)
+			if !ok {
+				return config, fmt.Errorf("sandbox %s must be a boolean", key)
+			}
+			setting := &config.NoNetwork
+			if key == "read_only_fs" {
+				setting = &config.ReadOnlyFS
+			}
+			if *setting && !flag {
+				return config, fmt.Errorf("sandbox %s can't be turned off, since the server turns it on", key)
+			}
+			*setting = flag
+
+		case "writable_paths":
+			paths, ok := value.([]interface{})
+			if !ok {
+				return config, fmt.Errorf("sandbox writable_paths must be an array of strings")
+			}
+			config.WritablePaths = nil
+			for _, path := range paths {
+				pathStr, ok := path.(string)
+				if !ok {
+					return config, fmt.Errorf("sandbox writable_paths must be an array of strings")
+				}
+				if !filepath.IsAbs(pathStr) {
+					pathStr = filepath.Join(workspaceRoot, pathStr)
+				}
+				pathStr = filepath.Clean(pathStr)
+				if relPath, err := filepath.Rel(workspaceRoot, pathStr); err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+					return config, fmt.Errorf("sandbox writable path %s is outside the workspace root", pathStr)
+				}
+				config.WritablePaths = append(config.WritablePaths, pathStr)
+			}
+
+		default:
+			return config, fmt.Errorf("unknown sandbox setting: %s", key)
+		}
+	}
+
+	return config, nil
+}
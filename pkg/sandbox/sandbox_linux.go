@@ -0,0 +1,317 @@
+package sandbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// helperEnv passes the sandbox settings to the helper process
+const helperEnv = "CODESPINNERET_SANDBOX"
+
+// helperExitCode is the exit code of the helper if it fails to set up the sandbox
+const helperExitCode = 126
+
+// Constants that the syscall package doesn't define
+const (
+	rlimitNproc          = 6  // RLIMIT_NPROC
+	capNetAdmin          = 12 // CAP_NET_ADMIN
+	capSysAdmin          = 21 // CAP_SYS_ADMIN
+	prCapAmbient         = 47 // PR_CAP_AMBIENT
+	prCapAmbientClearAll = 4  // PR_CAP_AMBIENT_CLEAR_ALL
+)
+
+// helperSettings is what the helper needs to set up the sandbox and run the command
+type helperSettings struct {
+	Config   Config   `json:"config"`
+	Writable []string `json:"writable"`
+	Path     string   `json:"path"`
+	Args     []string `json:"args"`
+}
+
+// The sandbox is set up by re-executing the server binary, which notices the
+// helper environment variable before main runs, applies the limits to itself
+// and then executes the real command in its place
+func init() {
+	if settings, ok := os.LookupEnv(helperEnv); ok {
+		runHelper(settings)
+	}
+}
+
+// Wrap changes a command that has not been started yet so that it runs in a
+// sandbox with the given limits. The workspace root stays writable when the
+// filesystem is read-only. Network and filesystem isolation use Linux
+// namespaces, which for an unprivileged server requires unprivileged user
+// namespaces to be enabled.
+func Wrap(cmd *exec.Cmd, config Config, workspaceRoot string) error {
+	if !config.Enabled() {
+		return nil
+	}
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+
+	// The workspace root and the extra writable paths must be absolute
+	var writable []string
+	for _, path := range append([]string{workspaceRoot}, config.WritablePaths...) {
+		if path == "" {
+			continue
+		}
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("invalid writable path %s: %v", path, err)
+		}
+		writable = append(writable, absPath)
+	}
+
+	settings, err := json.Marshal(helperSettings{
+		Config:   config,
+		Writable: writable,
+		Path:     cmd.Path,
+		Args:     cmd.Args,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode sandbox settings: %v", err)
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(append([]string(nil), env...), helperEnv+"="+string(settings))
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = append([]string{"codespinneret-sandbox"}, cmd.Args...)
+
+	if config.NoNetwork || config.ReadOnlyFS {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		if config.NoNetwork {
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNET
+		}
+		if config.ReadOnlyFS {
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS
+		}
+
+		// Without root, namespaces can only be created inside a new user
+		// namespace, where the server's user keeps its own ID. The helper
+		// keeps the capabilities it needs there as ambient capabilities,
+		// which it drops before running the command.
+		if uid, gid := os.Getuid(), os.Getgid(); uid != 0 {
+			cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+			cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+			cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+			cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+			cmd.SysProcAttr.AmbientCaps = []uintptr{capSysAdmin, capNetAdmin}
+		}
+	}
+
+	return nil
+}
+
+// runHelper sets up the sandbox for the current process and executes the
+// command. It never returns.
+func runHelper(encoded string) {
+	var settings helperSettings
+	if err := json.Unmarshal([]byte(encoded), &settings); err != nil {
+		helperFailed(fmt.Errorf("invalid settings: %v", err))
+	}
+	os.Unsetenv(helperEnv)
+
+	config := settings.Config
+	if config.ReadOnlyFS {
+		if err := makeFilesystemReadOnly(settings.Writable); err != nil {
+			helperFailed(err)
+		}
+	}
+	if config.NoNetwork {
+		// The new network namespace has no interfaces besides loopback, which
+		// is brought up so that local servers still work
+		if err := bringUpLoopback(); err != nil {
+			helperFailed(err)
+		}
+	}
+
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, uint64(config.CPUSeconds)},
+		{syscall.RLIMIT_AS, uint64(config.MemoryMB) * 1024 * 1024},
+		{rlimitNproc, uint64(config.MaxProcesses)},
+	}
+	for _, limit := range limits {
+		if limit.value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(limit.resource, &syscall.Rlimit{Cur: limit.value, Max: limit.value}); err != nil {
+			helperFailed(fmt.Errorf("failed to set resource limit %d: %v", limit.resource, err))
+		}
+	}
+
+	// Drop the capabilities needed to set up the namespaces, if any
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0); errno != 0 && errno != syscall.EINVAL {
+		helperFailed(fmt.Errorf("failed to drop capabilities: %v", errno))
+	}
+
+	err := syscall.Exec(settings.Path, settings.Args, os.Environ())
+	helperFailed(fmt.Errorf("failed to execute %s: %v", settings.Path, err))
+}
+
+// helperFailed reports an error setting up the sandbox and exits
+func helperFailed(err error) {
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(helperExitCode)
+}
+
+// makeFilesystemReadOnly remounts every mount read-only, except for the
+// writable paths, which are bind mounted onto themselves first so that they
+// become mounts of their own. It must run in a new mount namespace.
+func makeFilesystemReadOnly(writable []string) error {
+	// Keep the changes from propagating back to the server's mounts
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %v", err)
+	}
+
+	for _, path := range writable {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := syscall.Mount(path, path, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to bind mount %s: %v", path, err)
+		}
+	}
+
+	mounts, err := readMounts()
+	if err != nil {
+		return err
+	}
+
+	for _, mount := range mounts {
+		if isUnder(mount.point, writable) {
+			continue
+		}
+
+		// Flags that are already set must be kept, since clearing them is not
+		// allowed in a user namespace
+		flags := uintptr(syscall.MS_REMOUNT | syscall.MS_BIND | syscall.MS_RDONLY)
+		for _, option := range mount.options {
+			switch option {
+			case "nosuid":
+				flags |= syscall.MS_NOSUID
+			case "nodev":
+				flags |= syscall.MS_NODEV
+			case "noexec":
+				flags |= syscall.MS_NOEXEC
+			case "noatime":
+				flags |= syscall.MS_NOATIME
+			case "nodiratime":
+				flags |= syscall.MS_NODIRATIME
+			case "relatime":
+				flags |= syscall.MS_RELATIME
+			}
+		}
+
+		if err := syscall.Mount("", mount.point, "", flags, ""); err != nil {
+			// Mounts hidden under other mounts can't be reached, and
+			// pseudo-filesystems may refuse; neither matters for the files
+			// commands can write, unless it's the root
+			if mount.point == "/" {
+				return fmt.Errorf("failed to make %s read-only: %v", mount.point, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// mountEntry is a mount point and its per-mount options
+type mountEntry struct {
+	point   string
+	options []string
+}
+
+// readMounts lists the mounts of the current mount namespace
+func readMounts() ([]mountEntry, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %v", err)
+	}
+	defer file.Close()
+
+	var mounts []mountEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Fields: mount ID, parent ID, major:minor, root, mount point, options, ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		mounts = append(mounts, mountEntry{
+			point:   unescapeMountPath(fields[4]),
+			options: strings.Split(fields[5], ","),
+		})
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes used for spaces and other
+// special characters in mountinfo
+func unescapeMountPath(path string) string {
+	var result strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if value, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				result.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		result.WriteByte(path[i])
+	}
+	return result.String()
+}
+
+// isUnder reports whether a path is one of the directories or below one of them
+func isUnder(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ifreqFlags is the part of struct ifreq used to get and set interface flags
+type ifreqFlags struct {
+	name  [syscall.IFNAMSIZ]byte
+	flags uint16
+	_     [22]byte
+}
+
+// bringUpLoopback enables the loopback interface of the network namespace
+func bringUpLoopback() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open socket: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	var req ifreqFlags
+	copy(req.name[:], "lo")
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return fmt.Errorf("failed to get loopback flags: %v", errno)
+	}
+	req.flags |= syscall.IFF_UP
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return fmt.Errorf("failed to bring up loopback: %v", errno)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Wrap changes a command so that it runs in a sandbox. Sandboxing is only
+// supported on Linux, so commands with limits are refused elsewhere rather
+// than run without them.
+func Wrap(cmd *exec.Cmd, config Config, workspaceRoot string) error {
+	if !config.Enabled() {
+		return nil
+	}
+	return fmt.Errorf("sandboxed command execution is only supported on Linux")
+}
@@ -0,0 +1,272 @@
+package sarif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Version is the version of SARIF that this package reads and writes
+const Version = "2.1.0"
+
+// Schema is the JSON schema of SARIF 2.1.0
+const Schema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// SourceRoot is the base URI ID of the files under the root of a run
+const SourceRoot = "%SRCROOT%"
+
+// FingerprintKey is the key of the fingerprints computed by Fingerprinter
+const FingerprintKey = "codeSpinneretFingerprint/v1"
+
+// Log is a SARIF log file, the output of one or more analysis runs
+type Log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema,omitempty"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of one analysis tool
+type Run struct {
+	Tool               Tool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]ArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	ColumnKind         string                      `json:"columnKind,omitempty"` // utf16CodeUnits (the default) or unicodeCodePoints
+	Results            []Result                    `json:"results"`
+}
+
+// Tool describes the analysis tool of a run
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the main component of a tool, with the rules it checks
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules,omitempty"`
+}
+
+// Rule describes a rule that results may refer to by ID
+type Rule struct {
+	ID                   string         `json:"id"`
+	Name                 string         `json:"name,omitempty"`
+	ShortDescription     *Message       `json:"shortDescription,omitempty"`
+	DefaultConfiguration *Configuration `json:"defaultConfiguration,omitempty"`
+}
+
+// Configuration is the default configuration of a rule
+type Configuration struct {
+	Level string `json:"level,omitempty"`
+}
+
+// Result is one finding of a run
+type Result struct {
+	RuleID              string                 `json:"ruleId,omitempty"`
+	RuleIndex           *int                   `json:"ruleIndex,omitempty"`
+	Level               string                 `json:"level,omitempty"` // error, warning, note, or none
+	Message             Message                `json:"message"`
+	Locations           []Location             `json:"locations,omitempty"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Fixes               []Fix                  `json:"fixes,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Fix is a proposed change that resolves a result
+type Fix struct {
+	Description     *Message         `json:"description,omitempty"`
+	ArtifactChanges []ArtifactChange `json:"artifactChanges"`
+}
+
+// ArtifactChange is the part of a fix that changes one file
+type ArtifactChange struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Replacements     []Replacement    `json:"replacements"`
+}
+
+// Replacement replaces a region of a file with new content
+type Replacement struct {
+	DeletedRegion   Region   `json:"deletedRegion"`
+	InsertedContent *Message `json:"insertedContent,omitempty"`
+}
+
+// Message is the text of a result or rule description
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Location is where a result was found
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is the URI of a file, relative to the base URI named
+// by URIBaseID if it has one
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// Region is a range of lines and columns in a file, counted from one
+type Region struct {
+	StartLine   int      `json:"startLine,omitempty"`
+	StartColumn int      `json:"startColumn,omitempty"`
+	EndLine     int      `json:"endLine,omitempty"`
+	EndColumn   int      `json:"endColumn,omitempty"`
+	Snippet     *Message `json:"snippet,omitempty"`
+}
+
+// NewLog returns a log of runs
+func NewLog(runs ...Run) *Log {
+	return &Log{Version: Version, Schema: Schema, Runs: runs}
+}
+
+// NewRun returns a run of a tool whose files are given relative to root
+func NewRun(driver Driver, root string) Run {
+	run := Run{Tool: Tool{Driver: driver}, Results: []Result{}}
+	if root != "" {
+		run.OriginalURIBaseIDs = map[string]ArtifactLocation{
+			SourceRoot: {URI: strings.TrimSuffix(FileURI(root), "/") + "/"},
+		}
+	}
+	return run
+}
+
+// AddRule adds a rule to the run unless it has one with the same ID, and
+// returns its index
+func (r *Run) AddRule(rule Rule) int {
+	for i, existing := range r.Tool.Driver.Rules {
+		if existing.ID == rule.ID {
+			return i
+		}
+	}
+	r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, rule)
+	return len(r.Tool.Driver.Rules) - 1
+}
+
+// ArtifactLocationOf returns the location of a file, relative to the run's
+// root if it is below it
+func (r *Run) ArtifactLocationOf(path string) ArtifactLocation {
+	root := ""
+	if base, exists := r.OriginalURIBaseIDs[SourceRoot]; exists {
+		root = uriPath(base.URI)
+	}
+	return NewArtifactLocation(path, root)
+}
+
+// NewArtifactLocation returns the location of a file, relative to root if
+// it is below it, or else its absolute file URI
+func NewArtifactLocation(path, root string) ArtifactLocation {
+	if root != "" && filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return ArtifactLocation{URI: FileURI(rel), URIBaseID: SourceRoot}
+		}
+	}
+	return ArtifactLocation{URI: FileURI(path)}
+}
+
+// Parse reads a SARIF log
+func Parse(data []byte) (*Log, error) {
+	var log Log
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("invalid SARIF: %v", err)
+	}
+	if log.Version != Version {
+		return nil, fmt.Errorf("unsupported SARIF version: %q", log.Version)
+	}
+	return &log, nil
+}
+
+// FilePath returns the path of a location's file. Relative URIs are
+// resolved against the run's base URIs, or else against dir.
+func (r *Run) FilePath(location ArtifactLocation, dir string) string {
+	path := uriPath(location.URI)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if base, exists := r.OriginalURIBaseIDs[location.URIBaseID]; exists && location.URIBaseID != "" {
+		if basePath := uriPath(base.URI); filepath.IsAbs(basePath) {
+			return filepath.Join(basePath, path)
+		}
+	}
+	return filepath.Join(dir, path)
+}
+
+// uriPath returns the file path of a file URI or a relative URI reference
+func uriPath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return filepath.FromSlash(uri)
+	}
+	if parsed.Scheme == "file" {
+		path := parsed.Path
+		// file:///C:/dir on Windows
+		if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+			path = path[1:]
+		}
+		return filepath.FromSlash(path)
+	}
+	return filepath.FromSlash(strings.TrimPrefix(parsed.Path, "./"))
+}
+
+// FileURI returns the file URI of an absolute path, or the relative URI
+// reference of a relative one
+func FileURI(path string) string {
+	slashed := filepath.ToSlash(path)
+	if !filepath.IsAbs(path) {
+		return (&url.URL{Path: slashed}).String()
+	}
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed
+	}
+	return (&url.URL{Scheme: "file", Path: slashed}).String()
+}
+
+// Fingerprinter computes fingerprints of results that stay the same from
+// one run to the next while the code around them doesn't change, even if
+// lines are added above them. A fingerprint hashes the rule, the file, the
+// text of the line, and how many earlier results of the file have the same
+// rule and line text.
+type Fingerprinter struct {
+	lines  map[string][]string
+	counts map[string]int
+}
+
+// NewFingerprinter returns a Fingerprinter
+func NewFingerprinter() *Fingerprinter {
+	return &Fingerprinter{lines: make(map[string][]string), counts: make(map[string]int)}
+}
+
+// Fingerprints returns the partial fingerprints of a result of a rule at a
+// line of a file. uri identifies the file in the fingerprint, so that it
+// doesn't depend on where the files are checked out.
+func (f *Fingerprinter) Fingerprints(ruleID, path, uri string, line int) map[string]string {
+	lines, exists := f.lines[path]
+	if !exists {
+		if content, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+		}
+		f.lines[path] = lines
+	}
+	text := ""
+	if line >= 1 && line <= len(lines) {
+		text = strings.Join(strings.Fields(lines[line-1]), " ")
+	}
+
+	key := ruleID + "\x00" + uri + "\x00" + text
+	occurrence := f.counts[key]
+	f.counts[key]++
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", key, occurrence)))
+	return map[string]string{FingerprintKey: hex.EncodeToString(hash[:16])}
+}
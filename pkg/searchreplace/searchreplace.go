@@ -91,6 +91,15 @@ func HandleSearchReplace(ctx context.Context, request mcp.CallToolRequest) (*mcp
 		return nil, fmt.Errorf("error performing search and replace: %v", err)
 	}
 
+	// Remember the modified files for tools that check the last edit
+	if !preview {
+		var modifiedPaths []string
+		for _, fileDetail := range result.FileDetails {
+			modifiedPaths = append(modifiedPaths, fileDetail.FilePath)
+		}
+		workspace.RecordEdit(sessionID, "searchreplace", modifiedPaths)
+	}
+
 	// Format the result
 	resultText := fmt.Sprintf("Search and Replace Results:\n\n")
 	resultText += fmt.Sprintf("Directory: %s\n", directory)
@@ -0,0 +1,22 @@
+package shell
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// interruptShell is not supported on Windows, where console processes can't
+// be sent Ctrl-C through pipes. The shell is killed instead once the grace
+// period has passed.
+func interruptShell(cmd *exec.Cmd) error {
+	return fmt.Errorf("interrupting commands is not supported on Windows")
+}
+
+// killShell terminates the shell
+func killShell(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,103 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// startPTY starts cmd with a new pseudo-terminal as its controlling terminal
+// and standard streams, and returns the master side of the terminal. Local
+// echo is turned off so that commands written to the terminal don't show up
+// in their own output.
+func startPTY(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pseudo-terminal: %v", err)
+	}
+
+	// Unlock the slave side and find its name
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to unlock pseudo-terminal: %v", err)
+	}
+	var number uint32
+	if err := ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&number)); err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to get pseudo-terminal number: %v", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("failed to open pseudo-terminal slave: %v", err)
+	}
+	// The child has its own copy once it is started
+	defer slave.Close()
+
+	var termios syscall.Termios
+	if err := ioctl(slave, syscall.TCGETS, unsafe.Pointer(&termios)); err == nil {
+		termios.Lflag &^= syscall.ECHO
+		ioctl(slave, syscall.TCSETS, unsafe.Pointer(&termios))
+	}
+
+	if err := setPTYSize(master, rows, cols); err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	// Make the terminal the controlling terminal of a new session, keeping
+	// any namespaces set up for the sandbox
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	return master, nil
+}
+
+// setPTYSize sets the window size of a pseudo-terminal. The foreground
+// process group is sent SIGWINCH by the kernel.
+func setPTYSize(master *os.File, rows, cols int) error {
+	size := struct {
+		Rows, Cols, X, Y uint16
+	}{uint16(rows), uint16(cols), 0, 0}
+
+	if err := ioctl(master, syscall.TIOCSWINSZ, unsafe.Pointer(&size)); err != nil {
+		return fmt.Errorf("failed to set terminal size: %v", err)
+	}
+	return nil
+}
+
+// ioctl performs an ioctl on a file without switching it to blocking mode,
+// so that pending reads can still be interrupted by closing it
+func ioctl(file *os.File, request uintptr, arg unsafe.Pointer) error {
+	conn, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// startPTY is only implemented on Linux
+func startPTY(cmd *exec.Cmd, rows, cols int) (*os.File, error) {
+	return nil, fmt.Errorf("pty shells are only supported on Linux")
+}
+
+// setPTYSize is only implemented on Linux
+func setPTYSize(master *os.File, rows, cols int) error {
+	return fmt.Errorf("pty shells are only supported on Linux")
+}
@@ -0,0 +1,377 @@
+package shell
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parser states for terminal output
+const (
+	stateGround = iota
+	stateEscape
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateCharset
+)
+
+// Screen is a minimal VT100-style terminal emulator. It keeps a grid of the
+// characters currently on screen, handling cursor movement, erasing and
+// scrolling, and ignores colors and other attributes. It also extracts the
+// plain text from the output, with escape sequences stripped.
+type Screen struct {
+	rows, cols int
+	cells      [][]rune
+	row, col   int
+	savedRow   int
+	savedCol   int
+	state      int
+	params     []byte
+	osc        []rune
+	partial    []byte // Incomplete UTF-8 sequence from the previous write
+}
+
+// NewScreen creates a blank screen of the given size
+func NewScreen(rows, cols int) *Screen {
+	s := &Screen{}
+	s.Resize(rows, cols)
+	return s
+}
+
+// Resize changes the size of the screen, keeping the top-left content
+func (s *Screen) Resize(rows, cols int) {
+	if rows <= 0 || cols <= 0 {
+		return
+	}
+
+	cells := make([][]rune, rows)
+	for i := range cells {
+		cells[i] = blankLine(cols)
+		if i < len(s.cells) {
+			copy(cells[i], s.cells[i])
+		}
+	}
+
+	s.rows, s.cols, s.cells = rows, cols, cells
+	s.row = min(s.row, rows-1)
+	s.col = min(s.col, cols-1)
+}
+
+// Size returns the number of rows and columns of the screen
+func (s *Screen) Size() (int, int) {
+	return s.rows, s.cols
+}
+
+// Write processes terminal output and returns its plain text
+func (s *Screen) Write(p []byte) string {
+	data := append(s.partial, p...)
+	s.partial = nil
+
+	var plain strings.Builder
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 && !utf8.FullRune(data) {
+			// Keep the start of a multi-byte character for the next write
+			s.partial = append([]byte(nil), data...)
+			break
+		}
+		data = data[size:]
+		s.process(r, &plain)
+	}
+
+	return plain.String()
+}
+
+// Render returns the text currently on screen, without trailing blanks
+func (s *Screen) Render() string {
+	lines := make([]string, len(s.cells))
+	for i, line := range s.cells {
+		lines[i] = strings.TrimRight(string(line), " ")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// process handles a single character of output
+func (s *Screen) process(r rune, plain *strings.Builder) {
+	switch s.state {
+	case stateGround:
+		switch {
+		case r == 0x1b:
+			s.state = stateEscape
+		case r == '\r':
+			s.col = 0
+		case r == '\n', r == '\v', r == '\f':
+			s.lineFeed()
+			plain.WriteRune('\n')
+		case r == '\b':
+			s.col = max(s.col-1, 0)
+		case r == '\t':
+			s.col = min((s.col/8+1)*8, s.cols-1)
+			plain.WriteRune('\t')
+		case r < 0x20 || r == 0x7f:
+			// Ignore other control characters such as the bell
+		default:
+			s.put(r)
+			plain.WriteRune(r)
+		}
+
+	case stateEscape:
+		s.state = stateGround
+		switch r {
+		case '[':
+			s.state = stateCSI
+			s.params = s.params[:0]
+		case ']':
+			s.state = stateOSC
+			s.osc = s.osc[:0]
+		case '(', ')', '*', '+':
+			s.state = stateCharset
+		case 'D':
+			s.lineFeed()
+		case 'E':
+			s.col = 0
+			s.lineFeed()
+		case 'M':
+			s.reverseLineFeed()
+		case '7':
+			s.savedRow, s.savedCol = s.row, s.col
+		case '8':
+			s.row, s.col = s.savedRow, s.savedCol
+		case 'c':
+			s.eraseDisplay(2)
+			s.row, s.col = 0, 0
+		}
+
+	case stateCSI:
+		switch {
+		case r >= 0x30 && r <= 0x3f:
+			s.params = append(s.params, byte(r))
+		case r >= 0x20 && r <= 0x2f:
+			// Intermediate bytes are not used by any sequence handled here
+		case r >= 0x40 && r <= 0x7e:
+			s.state = stateGround
+			s.executeCSI(r)
+		default:
+			s.state = stateGround
+		}
+
+	case stateOSC:
+		// Operating system commands, such as setting the window title, end
+		// with BEL or ST and have no effect on the screen
+		switch r {
+		case 0x07:
+			s.state = stateGround
+			s.endOSC(plain)
+		case 0x1b:
+			s.state = stateOSCEscape
+		default:
+			s.osc = append(s.osc, r)
+		}
+
+	case stateOSCEscape:
+		s.state = stateGround
+		s.endOSC(plain)
+
+	case stateCharset:
+		s.state = stateGround
+	}
+}
+
+// endOSC handles the end of an operating system command. Command completion
+// sentinels are sent this way so that they don't show up on screen, and are
+// passed on to the plain text as a line of their own.
+func (s *Screen) endOSC(plain *strings.Builder) {
+	if payload := string(s.osc); strings.HasPrefix(payload, sentinelPrefix) {
+		plain.WriteString(payload + "\n")
+	}
+}
+
+// executeCSI handles a control sequence with the given final character
+func (s *Screen) executeCSI(final rune) {
+	params := string(s.params)
+
+	// Private modes such as ?1049h switch to the alternate screen, which is
+	// emulated by clearing the screen
+	if strings.HasPrefix(params, "?") {
+		switch params[1:] {
+		case "1049", "1047", "47":
+			if final == 'h' || final == 'l' {
+				s.eraseDisplay(2)
+				s.row, s.col = 0, 0
+			}
+		}
+		return
+	}
+
+	args := parseParams(params)
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return def
+	}
+
+	switch final {
+	case 'A':
+		s.row = max(s.row-arg(0, 1), 0)
+	case 'B', 'e':
+		s.row = min(s.row+arg(0, 1), s.rows-1)
+	case 'C', 'a':
+		s.col = min(s.col+arg(0, 1), s.cols-1)
+	case 'D':
+		s.col = max(s.col-arg(0, 1), 0)
+	case 'E':
+		s.row = min(s.row+arg(0, 1), s.rows-1)
+		s.col = 0
+	case 'F':
+		s.row = max(s.row-arg(0, 1), 0)
+		s.col = 0
+	case 'G', '`':
+		s.col = clamp(arg(0, 1)-1, s.cols)
+	case 'd':
+		s.row = clamp(arg(0, 1)-1, s.rows)
+	case 'H', 'f':
+		s.row = clamp(arg(0, 1)-1, s.rows)
+		s.col = clamp(arg(1, 1)-1, s.cols)
+	case 'J':
+		s.eraseDisplay(arg(0, 0))
+	case 'K':
+		s.eraseLine(arg(0, 0))
+	case 'L':
+		for i := 0; i < arg(0, 1); i++ {
+			s.insertLine()
+		}
+	case 'M':
+		for i := 0; i < arg(0, 1); i++ {
+			s.deleteLine()
+		}
+	case '@':
+		line := s.cells[s.row]
+		n := min(arg(0, 1), s.cols-s.col)
+		copy(line[s.col+n:], line[s.col:])
+		fill(line[s.col : s.col+n])
+	case 'P':
+		line := s.cells[s.row]
+		n := min(arg(0, 1), s.cols-s.col)
+		copy(line[s.col:], line[s.col+n:])
+		fill(line[s.cols-n:])
+	case 'X':
+		n := min(arg(0, 1), s.cols-s.col)
+		fill(s.cells[s.row][s.col : s.col+n])
+	case 's':
+		s.savedRow, s.savedCol = s.row, s.col
+	case 'u':
+		s.row, s.col = s.savedRow, s.savedCol
+	}
+	// Everything else, including colors (m) and scroll regions (r), is ignored
+}
+
+// put writes a character at the cursor and advances it, wrapping at the end of the line
+func (s *Screen) put(r rune) {
+	if s.col >= s.cols {
+		s.col = 0
+		s.lineFeed()
+	}
+	s.cells[s.row][s.col] = r
+	s.col++
+}
+
+// lineFeed moves the cursor down one line, scrolling at the bottom of the screen
+func (s *Screen) lineFeed() {
+	if s.row < s.rows-1 {
+		s.row++
+		return
+	}
+	s.cells = append(s.cells[1:], blankLine(s.cols))
+}
+
+// reverseLineFeed moves the cursor up one line, scrolling at the top of the screen
+func (s *Screen) reverseLineFeed() {
+	if s.row > 0 {
+		s.row--
+		return
+	}
+	s.insertLine()
+}
+
+// insertLine inserts a blank line at the cursor, pushing the lines below down
+func (s *Screen) insertLine() {
+	copy(s.cells[s.row+1:], s.cells[s.row:s.rows-1])
+	s.cells[s.row] = blankLine(s.cols)
+}
+
+// deleteLine deletes the line at the cursor, pulling the lines below up
+func (s *Screen) deleteLine() {
+	copy(s.cells[s.row:], s.cells[s.row+1:])
+	s.cells[s.rows-1] = blankLine(s.cols)
+}
+
+// eraseDisplay erases below the cursor (0), above it (1), or everything (2, 3)
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		for i := s.row + 1; i < s.rows; i++ {
+			fill(s.cells[i])
+		}
+	case 1:
+		s.eraseLine(1)
+		for i := 0; i < s.row; i++ {
+			fill(s.cells[i])
+		}
+	default:
+		for i := range s.cells {
+			fill(s.cells[i])
+		}
+	}
+}
+
+// eraseLine erases from the cursor to the end of the line (0), from the start
+// of the line to the cursor (1), or the whole line (2)
+func (s *Screen) eraseLine(mode int) {
+	line := s.cells[s.row]
+	col := min(s.col, s.cols-1)
+	switch mode {
+	case 0:
+		fill(line[col:])
+	case 1:
+		fill(line[:col+1])
+	default:
+		fill(line)
+	}
+}
+
+// parseParams parses the numeric parameters of a control sequence. Missing
+// parameters are returned as 0.
+func parseParams(params string) []int {
+	if params == "" {
+		return nil
+	}
+
+	var result []int
+	for _, field := range strings.Split(params, ";") {
+		n, _ := strconv.Atoi(field)
+		result = append(result, n)
+	}
+	return result
+}
+
+// blankLine returns a line of spaces
+func blankLine(cols int) []rune {
+	line := make([]rune, cols)
+	fill(line)
+	return line
+}
+
+// fill overwrites a part of a line with spaces
+func fill(line []rune) {
+	for i := range line {
+		line[i] = ' '
+	}
+}
+
+// clamp limits a zero-based position to the range [0, size)
+func clamp(pos, size int) int {
+	return max(0, min(pos, size-1))
+}
@@ -8,50 +8,123 @@ import (
 	"log"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
 	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// sentinelPrefix starts every marker printed after a command to detect its end
+const sentinelPrefix = "__CODESPINNERET_DONE_"
+
+// sentinelCounter makes sentinels unique within the process
+var sentinelCounter int64
+
+// interruptGracePeriod is how long a timed out command gets to stop after
+// being interrupted before the shell is killed
+const interruptGracePeriod = 2 * time.Second
+
+// maxLineLength is the longest line of output that can be read from the shell
+const maxLineLength = 1024 * 1024
+
 // ShellSession represents a persistent shell session
 type ShellSession struct {
-	ShellType   string    // Type of shell (bash, powershell, cmd)
-	Cmd         *exec.Cmd // The command process
-	Stdin       io.WriteCloser
-	StdoutPipe  io.ReadCloser
-	StderrPipe  io.ReadCloser
-	OutputChan  chan string
-	ErrChan     chan string
-	LastOutput  string
-	LastError   string
-	LastCommand string
-	StartTime   time.Time
-	LastAccess  time.Time
-	Mutex       sync.Mutex
-	Closed      bool
+	Name         string    // Name of the shell within its workspace session
+	ShellType    string    // Type of shell (bash, powershell, cmd)
+	Cmd          *exec.Cmd // The command process
+	Stdin        io.WriteCloser
+	StdoutPipe   io.ReadCloser
+	StderrPipe   io.ReadCloser
+	OutputChan   chan string
+	ErrChan      chan string
+	LastOutput   string
+	LastError    string
+	LastCommand  string
+	LastExitCode int
+	History      []HistoryEntry // Commands executed in this shell, oldest first
+	StartTime    time.Time
+	LastAccess   time.Time
+	Mutex        sync.Mutex
+	Closed       bool
+	Terminal     *Terminal // Pseudo-terminal of a PTY shell, nil for a shell using pipes
+
+	execMutex sync.Mutex // Held while a command is running
+}
+
+// ShellOptions configures a new shell session
+type ShellOptions struct {
+	PTY  bool // Run the shell on a pseudo-terminal (Linux only)
+	Rows int  // Terminal height for PTY shells (default: 24)
+	Cols int  // Terminal width for PTY shells (default: 80)
+}
+
+// DefaultShellName is the name of the first shell of a workspace session
+const DefaultShellName = "default"
+
+// maxHistory is the number of commands kept in each shell's history
+const maxHistory = 1000
+
+// HistoryEntry is a command that was executed in a shell
+type HistoryEntry struct {
+	Command  string
+	ExitCode int
+	Time     time.Time
+}
+
+// workspaceShells holds the named shells of a workspace session and which of
+// them is used when no name is given
+type workspaceShells struct {
+	shells map[string]*ShellSession
+	active string
 }
 
 // ShellStore manages shell sessions for multiple workspace sessions
 type ShellStore struct {
-	sessions map[string]*ShellSession
+	sessions map[string]*workspaceShells
 	mutex    sync.RWMutex
 }
 
 // Global shell store
 var shellStore = &ShellStore{
-	sessions: make(map[string]*ShellSession),
+	sessions: make(map[string]*workspaceShells),
+}
+
+// resolveShellName returns the name of the shell to use for a workspace
+// session. An empty name refers to the active shell. The caller must hold
+// the store mutex.
+func resolveShellName(sessionID, name string) string {
+	if name != "" {
+		return name
+	}
+	if sessionShells, exists := shellStore.sessions[sessionID]; exists && sessionShells.active != "" {
+		return sessionShells.active
+	}
+	return DefaultShellName
 }
 
-// GetShellSession returns the shell session for a workspace session
-func GetShellSession(sessionID string) (*ShellSession, bool) {
+// GetShellSession returns a named shell session of a workspace session. An
+// empty name returns the active shell.
+func GetShellSession(sessionID, name string) (*ShellSession, bool) {
 	shellStore.mutex.RLock()
 	defer shellStore.mutex.RUnlock()
 
-	session, exists := shellStore.sessions[sessionID]
+	sessionShells, exists := shellStore.sessions[sessionID]
+	if !exists {
+		return nil, false
+	}
+
+	session, exists := sessionShells.shells[resolveShellName(sessionID, name)]
 	if exists {
 		// Update last access time
 		session.LastAccess = time.Now()
@@ -60,26 +133,99 @@ func GetShellSession(sessionID string) (*ShellSession, bool) {
 	return session, exists
 }
 
-// SetShellSession sets the shell session for a workspace session
+// SetShellSession adds a shell session to a workspace session under its
+// name, and makes it the active shell
 func SetShellSession(sessionID string, session *ShellSession) {
 	shellStore.mutex.Lock()
 	defer shellStore.mutex.Unlock()
 
-	shellStore.sessions[sessionID] = session
+	sessionShells, exists := shellStore.sessions[sessionID]
+	if !exists {
+		sessionShells = &workspaceShells{shells: make(map[string]*ShellSession)}
+		shellStore.sessions[sessionID] = sessionShells
+	}
+
+	sessionShells.shells[session.Name] = session
+	sessionShells.active = session.Name
 }
 
-// RemoveShellSession removes a shell session
-func RemoveShellSession(sessionID string) {
+// RemoveShellSession removes a named shell session. If it was the active
+// shell, another remaining shell becomes active. The session is closed after
+// the store is unlocked, since waiting for the shell to exit can take a while.
+func RemoveShellSession(sessionID, name string) {
+	if session := removeShellSession(sessionID, name); session != nil {
+		session.Close()
+	}
+}
+
+// removeShellSession removes a named shell session from the store and
+// returns it, or nil if there is no such shell
+func removeShellSession(sessionID, name string) *ShellSession {
 	shellStore.mutex.Lock()
 	defer shellStore.mutex.Unlock()
 
-	if session, exists := shellStore.sessions[sessionID]; exists {
-		// Close the session if it's still open
-		if !session.Closed {
-			session.Close()
+	sessionShells, exists := shellStore.sessions[sessionID]
+	if !exists {
+		return nil
+	}
+
+	name = resolveShellName(sessionID, name)
+	session := sessionShells.shells[name]
+	delete(sessionShells.shells, name)
+
+	if sessionShells.active == name {
+		sessionShells.active = ""
+		if names := sortedShellNames(sessionShells); len(names) > 0 {
+			sessionShells.active = names[0]
 		}
-		delete(shellStore.sessions, sessionID)
 	}
+	return session
+}
+
+// SwitchShellSession makes a named shell the active shell of a workspace session
+func SwitchShellSession(sessionID, name string) error {
+	shellStore.mutex.Lock()
+	defer shellStore.mutex.Unlock()
+
+	sessionShells, exists := shellStore.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("no shells found for session: %s", sessionID)
+	}
+	if _, exists := sessionShells.shells[name]; !exists {
+		return fmt.Errorf("shell not found: %s", name)
+	}
+
+	sessionShells.active = name
+	return nil
+}
+
+// ListShellSessions returns the shells of a workspace session sorted by name,
+// and the name of the active shell
+func ListShellSessions(sessionID string) ([]*ShellSession, string) {
+	shellStore.mutex.RLock()
+	defer shellStore.mutex.RUnlock()
+
+	sessionShells, exists := shellStore.sessions[sessionID]
+	if !exists {
+		return nil, ""
+	}
+
+	var sessions []*ShellSession
+	for _, name := range sortedShellNames(sessionShells) {
+		sessions = append(sessions, sessionShells.shells[name])
+	}
+
+	return sessions, sessionShells.active
+}
+
+// sortedShellNames returns the names of the shells of a workspace session in order
+func sortedShellNames(sessionShells *workspaceShells) []string {
+	names := make([]string, 0, len(sessionShells.shells))
+	for name := range sessionShells.shells {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // Close closes the shell session
@@ -129,10 +275,16 @@ func (s *ShellSession) Close() {
 	s.Closed = true
 }
 
-// InitializeShell initializes a new shell session for a workspace session
-func InitializeShell(sessionID string, shellType string) (*ShellSession, error) {
-	// Check if a session already exists
-	if existingSession, exists := GetShellSession(sessionID); exists {
+// InitializeShell initializes a new named shell session for a workspace
+// session and makes it the active shell. An empty name refers to the active
+// shell, or the default shell if there is none.
+func InitializeShell(sessionID, name, shellType string, options ShellOptions) (*ShellSession, error) {
+	shellStore.mutex.RLock()
+	name = resolveShellName(sessionID, name)
+	shellStore.mutex.RUnlock()
+
+	// Check if a shell with this name already exists
+	if existingSession, exists := GetShellSession(sessionID, name); exists {
 		// Close the existing session
 		existingSession.Close()
 	}
@@ -173,6 +325,10 @@ func InitializeShell(sessionID string, shellType string) (*ShellSession, error)
 		cmd.Dir = workspaceInfo.RootDir
 	}
 
+	if options.PTY {
+		return initializePTYShell(sessionID, name, shellType, cmd, options)
+	}
+
 	// Create pipes for stdin, stdout, and stderr
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -194,6 +350,7 @@ func InitializeShell(sessionID string, shellType string) (*ShellSession, error)
 
 	// Create the shell session
 	session := &ShellSession{
+		Name:       name,
 		ShellType:  shellType,
 		Cmd:        cmd,
 		Stdin:      stdin,
@@ -206,6 +363,18 @@ func InitializeShell(sessionID string, shellType string) (*ShellSession, error)
 		Closed:     false,
 	}
 
+	// Run the shell in its own process group so that a timed out command can be interrupted
+	setProcessGroup(cmd)
+
+	// Apply the sandbox limits of the workspace, if any, to the shell and so
+	// to every command it runs
+	if err := sandbox.Wrap(cmd, sandbox.ConfigForSession(sessionID), workspace.GetRootDir(sessionID)); err != nil {
+		stdin.Close()
+		stdout.Close()
+		stderr.Close()
+		return nil, fmt.Errorf("failed to set up sandbox: %v", err)
+	}
+
 	// Start the command
 	if err := cmd.Start(); err != nil {
 		stdin.Close()
@@ -215,8 +384,18 @@ func InitializeShell(sessionID string, shellType string) (*ShellSession, error)
 	}
 
 	// Start goroutines to read stdout and stderr
-	go readOutput(stdout, session.OutputChan, &session.LastOutput, &session.Mutex)
-	go readOutput(stderr, session.ErrChan, &session.LastError, &session.Mutex)
+	go readOutput(stdout, session.OutputChan)
+	go readOutput(stderr, session.ErrChan)
+
+	// Make bash exit once the interrupt sent to a timed out command has
+	// stopped it, so the rest of the command doesn't run after the timeout
+	// has been reported. The caller starts a new shell in its place.
+	if shellType == "bash" {
+		if _, err := io.WriteString(stdin, "trap 'exit 130' INT\n"); err != nil {
+			session.Close()
+			return nil, fmt.Errorf("failed to configure shell: %v", err)
+		}
+	}
 
 	// Store the session
 	SetShellSession(sessionID, session)
@@ -224,26 +403,112 @@ func InitializeShell(sessionID string, shellType string) (*ShellSession, error)
 	return session, nil
 }
 
-// readOutput reads from a pipe and sends the output to a channel
-func readOutput(pipe io.Reader, outputChan chan string, lastOutput *string, mutex *sync.Mutex) {
+// readOutput reads lines from a pipe and sends them to a channel. The channel
+// is closed when the pipe is closed, which happens when the shell exits.
+func readOutput(pipe io.Reader, outputChan chan string) {
 	scanner := bufio.NewScanner(pipe)
+	scanner.Buffer(make([]byte, 64*1024), maxLineLength)
 	for scanner.Scan() {
-		line := scanner.Text()
-		outputChan <- line
+		outputChan <- scanner.Text()
+	}
+	close(outputChan)
+}
+
+// CommandResult is the outcome of a command executed in a shell session
+type CommandResult struct {
+	Stdout      string
+	Stderr      string
+	ExitCode    int  // -1 if the command did not complete
+	TimedOut    bool // The command ran past its timeout and was interrupted
+	ShellExited bool // The shell exited, either by itself or because it had to be killed
+}
+
+// newSentinel returns a marker that is unique to one command execution
+func newSentinel() string {
+	return fmt.Sprintf("%s%d_%d__", sentinelPrefix, time.Now().UnixNano(), atomic.AddInt64(&sentinelCounter, 1))
+}
 
-		// Update last output
-		mutex.Lock()
-		*lastOutput += line + "\n"
-		mutex.Unlock()
+// wrapCommand returns the input to write to the shell to run command. After
+// the command finishes, the shell prints the sentinel followed by the exit
+// status on stdout, and the sentinel alone on stderr, so that the end of both
+// streams can be detected.
+func wrapCommand(shellType, command, sentinel string) string {
+	switch shellType {
+	case "powershell":
+		return command + "\n" +
+			"$__csStatus = if ($?) { 0 } elseif ($LASTEXITCODE) { $LASTEXITCODE } else { 1 }\n" +
+			fmt.Sprintf("Write-Output \"%s $__csStatus\"; [Console]::Error.WriteLine(\"%s\")\n", sentinel, sentinel)
+	case "cmd":
+		return command + "\n" +
+			fmt.Sprintf("@echo %s %%errorlevel%%\n@echo %s 1>&2\n", sentinel, sentinel)
+	default:
+		// Run the command at top level, so that the variables it declares
+		// persist, in a group with stdin detached so that it cannot consume
+		// the sentinel, which follows on its own line
+		return "{\n" + command + "\n} < /dev/null\n" +
+			fmt.Sprintf("echo \"%s $?\"; echo \"%s\" >&2\n", sentinel, sentinel)
+	}
+}
+
+// checkSyntax parses a bash command with bash -n without running it. A
+// command that doesn't parse, such as one with an unterminated quote, would
+// otherwise swallow the sentinel and leave the shell waiting for more input.
+func checkSyntax(shellType, command string) error {
+	if shellType != "bash" {
+		return nil
+	}
+	cmd := exec.Command("bash", "-n")
+	cmd.Stdin = strings.NewReader(command + "\n")
+	output, err := cmd.CombinedOutput()
+	if _, failed := err.(*exec.ExitError); failed {
+		return fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// parseSentinel checks a line of output for the sentinel. It returns the text
+// printed before the sentinel on the same line, and whether it was found.
+func parseSentinel(line, sentinel string) (string, string, bool) {
+	index := strings.Index(line, sentinel)
+	if index < 0 {
+		return "", "", false
+	}
+	return line[:index], strings.TrimSpace(line[index+len(sentinel):]), true
+}
+
+// drainOutput discards output that is still buffered from earlier commands,
+// such as output printed after a previous command timed out
+func drainOutput(session *ShellSession) {
+	for {
+		select {
+		case _, ok := <-session.OutputChan:
+			if !ok {
+				return
+			}
+		case _, ok := <-session.ErrChan:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
 	}
 }
 
-// ExecuteCommand executes a command in the shell session
-func ExecuteCommand(session *ShellSession, command string, timeoutSec float64) (string, string, error) {
+// ExecuteCommand executes a command in the shell session and collects its
+// output until the shell reports that the command has finished. If the
+// command runs past the timeout, the foreground process is interrupted, and
+// if it still does not stop, the shell is killed. Output lines are streamed
+// through reporter as they arrive.
+func ExecuteCommand(session *ShellSession, command string, timeoutSec float64, reporter *progress.Reporter) (*CommandResult, error) {
+	// Only one command can run in a shell at a time
+	session.execMutex.Lock()
+	defer session.execMutex.Unlock()
+
 	session.Mutex.Lock()
 	if session.Closed {
 		session.Mutex.Unlock()
-		return "", "", fmt.Errorf("shell session is closed")
+		return nil, fmt.Errorf("shell session is closed")
 	}
 
 	// Clear previous output
@@ -252,65 +517,130 @@ func ExecuteCommand(session *ShellSession, command string, timeoutSec float64) (
 	session.LastCommand = command
 	session.Mutex.Unlock()
 
+	var result *CommandResult
+	var err error
+	if syntaxErr := checkSyntax(session.ShellType, command); syntaxErr != nil {
+		// Report the error the way bash would, without sending the command
+		session.appendOutput(&session.LastError, syntaxErr.Error()+"\n", reporter, "stderr")
+		result = &CommandResult{ExitCode: 2}
+	} else if session.Terminal != nil {
+		result, err = executeInTerminal(session, command, timeoutSec, reporter)
+	} else {
+		result, err = executeInPipes(session, command, timeoutSec, reporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session.Mutex.Lock()
+	defer session.Mutex.Unlock()
+
+	session.LastExitCode = result.ExitCode
+	result.Stdout = session.LastOutput
+	result.Stderr = session.LastError
+
+	// Record the command, keeping only the most recent entries
+	session.History = append(session.History, HistoryEntry{
+		Command:  command,
+		ExitCode: result.ExitCode,
+		Time:     time.Now(),
+	})
+	if len(session.History) > maxHistory {
+		session.History = session.History[len(session.History)-maxHistory:]
+	}
+
+	return result, nil
+}
+
+// executeInPipes runs a command in a shell that uses plain pipes, collecting
+// stdout and stderr separately
+func executeInPipes(session *ShellSession, command string, timeoutSec float64, reporter *progress.Reporter) (*CommandResult, error) {
+	drainOutput(session)
+
 	// Write the command to stdin
-	if _, err := session.Stdin.Write([]byte(command + "\n")); err != nil {
-		return "", "", fmt.Errorf("failed to write command to shell: %v", err)
+	sentinel := newSentinel()
+	if _, err := io.WriteString(session.Stdin, wrapCommand(session.ShellType, command, sentinel)); err != nil {
+		return nil, fmt.Errorf("failed to write command to shell: %v", err)
 	}
 
-	// Wait for output with timeout
-	timeout := time.After(time.Duration(timeoutSec) * time.Second)
-	outputDone := false
-	errorDone := false
+	result := &CommandResult{ExitCode: -1}
+	outputChan := session.OutputChan
+	errChan := session.ErrChan
+	timeout := time.After(time.Duration(timeoutSec * float64(time.Second)))
+	interrupted := false
 
-	// Collect output until timeout or both channels are empty
-	for !outputDone || !errorDone {
+	// Collect output until the sentinel has been seen on both streams
+	for outputChan != nil || errChan != nil {
 		select {
-		case <-timeout:
-			return session.LastOutput, session.LastError, nil
-		case output, ok := <-session.OutputChan:
+		case output, ok := <-outputChan:
 			if !ok {
-				outputDone = true
-			} else {
-				session.Mutex.Lock()
-				session.LastOutput += output + "\n"
-				session.Mutex.Unlock()
+				result.ShellExited = true
+				outputChan = nil
+				continue
+			}
+			if text, status, found := parseSentinel(output, sentinel); found {
+				if exitCode, err := strconv.Atoi(status); err == nil {
+					result.ExitCode = exitCode
+					session.appendOutput(&session.LastOutput, text, reporter, "stdout")
+					outputChan = nil
+					continue
+				}
 			}
-		case errOutput, ok := <-session.ErrChan:
+			if !strings.HasPrefix(output, sentinelPrefix) {
+				session.appendOutput(&session.LastOutput, output+"\n", reporter, "stdout")
+			}
+		case errOutput, ok := <-errChan:
 			if !ok {
-				errorDone = true
-			} else {
-				session.Mutex.Lock()
-				session.LastError += errOutput + "\n"
-				session.Mutex.Unlock()
+				result.ShellExited = true
+				errChan = nil
+				continue
 			}
-		case <-time.After(100 * time.Millisecond):
-			// Check if there's been no output for a short time
-			// This helps detect when the command has finished
-			select {
-			case output, ok := <-session.OutputChan:
-				if !ok {
-					outputDone = true
-				} else {
-					session.Mutex.Lock()
-					session.LastOutput += output + "\n"
-					session.Mutex.Unlock()
+			if text, _, found := parseSentinel(errOutput, sentinel); found {
+				session.appendOutput(&session.LastError, text, reporter, "stderr")
+				errChan = nil
+				continue
+			}
+			if !strings.HasPrefix(errOutput, sentinelPrefix) {
+				session.appendOutput(&session.LastError, errOutput+"\n", reporter, "stderr")
+			}
+		case <-timeout:
+			if !interrupted {
+				// Interrupt the foreground process like Ctrl-C would
+				log.Printf("[Shell] Command timed out after %v seconds, interrupting: %s", timeoutSec, command)
+				result.TimedOut = true
+				interrupted = true
+				if err := interruptShell(session.Cmd); err != nil {
+					log.Printf("[Shell] Failed to interrupt command: %v", err)
 				}
-			case errOutput, ok := <-session.ErrChan:
-				if !ok {
-					errorDone = true
-				} else {
-					session.Mutex.Lock()
-					session.LastError += errOutput + "\n"
-					session.Mutex.Unlock()
+			} else {
+				// The command ignored the interrupt, so the shell has to go
+				log.Printf("[Shell] Command did not stop after interrupt, killing shell")
+				if err := killShell(session.Cmd); err != nil {
+					log.Printf("[Shell] Failed to kill shell: %v", err)
 				}
-			default:
-				// No output for 100ms, assume command is done
-				return session.LastOutput, session.LastError, nil
+				result.ShellExited = true
+				outputChan = nil
+				errChan = nil
 			}
+			timeout = time.After(interruptGracePeriod)
 		}
 	}
 
-	return session.LastOutput, session.LastError, nil
+	return result, nil
+}
+
+// appendOutput appends text to one of the session's output buffers and
+// streams it to the client
+func (s *ShellSession) appendOutput(buffer *string, text string, reporter *progress.Reporter, stream string) {
+	if text == "" {
+		return
+	}
+
+	s.Mutex.Lock()
+	*buffer += text
+	s.Mutex.Unlock()
+
+	reporter.Output(stream, text)
 }
 
 // HandleShell is the handler function for the shell tool
@@ -329,13 +659,16 @@ func HandleShell(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 		return nil, fmt.Errorf("session_id must be a string")
 	}
 
+	// Extract shell name (optional, defaults to the active shell)
+	shellName, _ := arguments["shell_name"].(string)
+
 	switch operation {
 	case "initialize":
 		// Extract shell type (optional)
 		shellType, _ := arguments["shell_type"].(string)
 
 		// Initialize the shell
-		session, err := InitializeShell(sessionID, shellType)
+		session, err := InitializeShell(sessionID, shellName, shellType, getShellOptions(arguments))
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize shell: %v", err)
 		}
@@ -343,7 +676,12 @@ func HandleShell(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 		// Format the result
 		resultText := fmt.Sprintf("Shell initialized successfully\n\n")
 		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Shell Name: %s\n", session.Name)
 		resultText += fmt.Sprintf("Shell Type: %s\n", session.ShellType)
+		if session.Terminal != nil {
+			rows, cols := session.Terminal.Size()
+			resultText += fmt.Sprintf("Terminal: %dx%d (pty)\n", cols, rows)
+		}
 		resultText += fmt.Sprintf("Started: %s\n", session.StartTime.Format(time.RFC3339))
 
 		return &mcp.CallToolResult{
@@ -369,37 +707,187 @@ func HandleShell(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 			timeoutSec = 30
 		}
 
+		// Check the command against the command policy
+		if result, err := policy.Enforce("shell", sessionID, command, arguments); result != nil || err != nil {
+			return result, err
+		}
+
 		// Get the shell session
-		session, exists := GetShellSession(sessionID)
+		session, exists := GetShellSession(sessionID, shellName)
 		if !exists {
 			// Try to initialize a new session if it doesn't exist
 			var err error
 			shellType, _ := arguments["shell_type"].(string)
-			session, err = InitializeShell(sessionID, shellType)
+			session, err = InitializeShell(sessionID, shellName, shellType, getShellOptions(arguments))
 			if err != nil {
 				return nil, fmt.Errorf("shell session not found and failed to initialize: %v", err)
 			}
 		}
 
 		// Execute the command
-		stdout, stderr, err := ExecuteCommand(session, command, timeoutSec)
+		reporter := progress.NewReporter(ctx, "shell", request)
+		result, err := ExecuteCommand(session, command, timeoutSec, reporter)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute command: %v", err)
 		}
 
 		// Format the result
 		resultText := fmt.Sprintf("Command executed in shell session\n\n")
+		if result.TimedOut {
+			resultText = fmt.Sprintf("Command timed out after %v seconds and was interrupted\n\n", timeoutSec)
+		}
 		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Shell Name: %s\n", session.Name)
 		resultText += fmt.Sprintf("Shell Type: %s\n", session.ShellType)
-		resultText += fmt.Sprintf("Command: %s\n\n", command)
+		resultText += fmt.Sprintf("Command: %s\n", command)
+		resultText += fmt.Sprintf("Exit Code: %d\n\n", result.ExitCode)
+
+		// Keep the full output as an artifact if it is too large
+		if result.Stdout != "" {
+			resultText += fmt.Sprintf("Standard Output:\n%s\n", artifact.Limit("shell", result.Stdout))
+		}
+		if result.Stderr != "" {
+			resultText += fmt.Sprintf("Standard Error:\n%s\n", artifact.Limit("shell", result.Stderr))
+		}
+
+		// A shell that exited can't run further commands, so drop it. One
+		// stopped by a timeout is replaced right away, as the user didn't ask
+		// for it to go; otherwise the next execute starts a fresh one.
+		if result.ShellExited {
+			RemoveShellSession(sessionID, session.Name)
+			if result.TimedOut {
+				resultText += restartShell(sessionID, session)
+			} else {
+				resultText += "The shell exited. A new shell will be started for the next command; environment and working directory changes are lost.\n"
+			}
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: resultText,
+				},
+			},
+		}, nil
+
+	case "send":
+		session, err := getTerminalSession(sessionID, shellName)
+		if err != nil {
+			return nil, err
+		}
+
+		// Raw input is sent first, followed by any named keys
+		input, _ := arguments["input"].(string)
+		if keys, ok := arguments["keys"].([]interface{}); ok {
+			for _, key := range keys {
+				keyName, ok := key.(string)
+				if !ok {
+					return nil, fmt.Errorf("keys must be an array of strings")
+				}
+				sequence, err := KeySequence(keyName)
+				if err != nil {
+					return nil, err
+				}
+				input += sequence
+			}
+		}
+		if input == "" {
+			return nil, fmt.Errorf("input or keys must be provided")
+		}
+
+		// Typed text may be a command for the shell, so each line that the
+		// input completes is checked against the command policy as a whole,
+		// with the text that earlier sends typed on it
+		for _, line := range session.Terminal.TypedLines(input) {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if result, err := policy.Enforce("shell", sessionID, line, arguments); result != nil || err != nil {
+				return result, err
+			}
+		}
+
+		if err := session.Terminal.Send(input); err != nil {
+			return nil, err
+		}
+
+		// Format the result
+		resultText := fmt.Sprintf("Input sent to terminal\n\n")
+		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Shell Name: %s\n", session.Name)
+		resultText += fmt.Sprintf("Bytes Sent: %d\n", len(input))
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: resultText,
+				},
+			},
+		}, nil
+
+	case "read":
+		session, err := getTerminalSession(sessionID, shellName)
+		if err != nil {
+			return nil, err
+		}
 
-		if stdout != "" {
-			resultText += fmt.Sprintf("Standard Output:\n%s\n", stdout)
+		// Extract timeout (optional)
+		timeoutSec, ok := arguments["timeout"].(float64)
+		if !ok {
+			// Default timeout: 1 second
+			timeoutSec = 1
+		}
+
+		// Give the program time to respond to earlier input
+		session.Terminal.WaitForOutput(time.Duration(timeoutSec * float64(time.Second)))
+
+		// Extract mode (optional)
+		mode, _ := arguments["mode"].(string)
+
+		var resultText string
+		switch mode {
+		case "", "text":
+			output, closed := session.Terminal.TakeOutput()
+			resultText = fmt.Sprintf("Terminal Output:\n%s\n", artifact.Limit("shell", output))
+			if closed {
+				resultText += "\nThe shell has exited.\n"
+			}
+		case "screen":
+			rows, cols := session.Terminal.Size()
+			resultText = fmt.Sprintf("Terminal Screen (%dx%d):\n%s\n", cols, rows, session.Terminal.Screen())
+		default:
+			return nil, fmt.Errorf("unsupported mode: %s", mode)
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: resultText,
+				},
+			},
+		}, nil
+
+	case "resize":
+		session, err := getTerminalSession(sessionID, shellName)
+		if err != nil {
+			return nil, err
 		}
-		if stderr != "" {
-			resultText += fmt.Sprintf("Standard Error:\n%s\n", stderr)
+
+		rows, _ := arguments["rows"].(float64)
+		cols, _ := arguments["cols"].(float64)
+		if err := session.Terminal.Resize(int(rows), int(cols)); err != nil {
+			return nil, err
 		}
 
+		// Format the result
+		resultText := fmt.Sprintf("Terminal resized\n\n")
+		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Shell Name: %s\n", session.Name)
+		resultText += fmt.Sprintf("Terminal: %dx%d\n", int(cols), int(rows))
+
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				mcp.TextContent{
@@ -411,20 +899,24 @@ func HandleShell(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 
 	case "close":
 		// Close the shell session
-		session, exists := GetShellSession(sessionID)
+		session, exists := GetShellSession(sessionID, shellName)
 		if !exists {
 			return nil, fmt.Errorf("shell session not found: %s", sessionID)
 		}
 
 		// Close the session
 		session.Close()
-		RemoveShellSession(sessionID)
+		RemoveShellSession(sessionID, session.Name)
 
 		// Format the result
 		resultText := fmt.Sprintf("Shell session closed\n\n")
 		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Shell Name: %s\n", session.Name)
 		resultText += fmt.Sprintf("Shell Type: %s\n", session.ShellType)
 		resultText += fmt.Sprintf("Duration: %v\n", time.Since(session.StartTime))
+		if _, active := ListShellSessions(sessionID); active != "" {
+			resultText += fmt.Sprintf("Active Shell: %s\n", active)
+		}
 
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -437,7 +929,7 @@ func HandleShell(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 
 	case "status":
 		// Get the shell session status
-		session, exists := GetShellSession(sessionID)
+		session, exists := GetShellSession(sessionID, shellName)
 		if !exists {
 			return nil, fmt.Errorf("shell session not found: %s", sessionID)
 		}
@@ -445,11 +937,18 @@ func HandleShell(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 		// Format the result
 		resultText := fmt.Sprintf("Shell Session Status\n\n")
 		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Shell Name: %s\n", session.Name)
 		resultText += fmt.Sprintf("Shell Type: %s\n", session.ShellType)
+		if session.Terminal != nil {
+			rows, cols := session.Terminal.Size()
+			resultText += fmt.Sprintf("Terminal: %dx%d (pty)\n", cols, rows)
+		}
 		resultText += fmt.Sprintf("Started: %s\n", session.StartTime.Format(time.RFC3339))
 		resultText += fmt.Sprintf("Last Access: %s\n", session.LastAccess.Format(time.RFC3339))
 		resultText += fmt.Sprintf("Duration: %v\n", time.Since(session.StartTime))
 		resultText += fmt.Sprintf("Last Command: %s\n", session.LastCommand)
+		resultText += fmt.Sprintf("Last Exit Code: %d\n", session.LastExitCode)
+		resultText += fmt.Sprintf("Commands Run: %d\n", len(session.History))
 		resultText += fmt.Sprintf("Status: %s\n", func() string {
 			if session.Closed {
 				return "Closed"
@@ -466,24 +965,157 @@ func HandleShell(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToo
 			},
 		}, nil
 
+	case "list":
+		sessions, active := ListShellSessions(sessionID)
+
+		// Format the result
+		resultText := fmt.Sprintf("Shells for session %s (%d):\n\n", sessionID, len(sessions))
+		for i, session := range sessions {
+			session.Mutex.Lock()
+			resultText += fmt.Sprintf("%d. %s [%s]", i+1, session.Name, session.ShellType)
+			if session.Terminal != nil {
+				resultText += " (pty)"
+			}
+			if session.Name == active {
+				resultText += " (active)"
+			}
+			resultText += fmt.Sprintf("\n   Started: %s\n", session.StartTime.Format(time.RFC3339))
+			resultText += fmt.Sprintf("   Commands Run: %d\n", len(session.History))
+			if session.LastCommand != "" {
+				resultText += fmt.Sprintf("   Last Command: %s (exit code %d)\n", session.LastCommand, session.LastExitCode)
+			}
+			session.Mutex.Unlock()
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: resultText,
+				},
+			},
+		}, nil
+
+	case "switch":
+		if shellName == "" {
+			return nil, fmt.Errorf("shell_name must be a non-empty string")
+		}
+
+		if err := SwitchShellSession(sessionID, shellName); err != nil {
+			return nil, err
+		}
+
+		// Format the result
+		resultText := fmt.Sprintf("Switched active shell\n\n")
+		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Active Shell: %s\n", shellName)
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: resultText,
+				},
+			},
+		}, nil
+
+	case "history":
+		session, exists := GetShellSession(sessionID, shellName)
+		if !exists {
+			return nil, fmt.Errorf("shell session not found: %s", sessionID)
+		}
+
+		session.Mutex.Lock()
+		history := session.History
+		session.Mutex.Unlock()
+
+		// Extract the number of entries to show (optional, default: all)
+		first := 0
+		if lines, ok := arguments["lines"].(float64); ok && int(lines) > 0 && int(lines) < len(history) {
+			first = len(history) - int(lines)
+		}
+
+		// Format the result
+		resultText := fmt.Sprintf("Shell History\n\n")
+		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Shell Name: %s\n", session.Name)
+		resultText += fmt.Sprintf("Commands Run: %d\n\n", len(history))
+		for i := first; i < len(history); i++ {
+			entry := history[i]
+			resultText += fmt.Sprintf("%d. [%s] (exit code %d) %s\n", i+1, entry.Time.Format(time.RFC3339), entry.ExitCode, entry.Command)
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: resultText,
+				},
+			},
+		}, nil
+
 	default:
 		return nil, fmt.Errorf("unsupported operation: %s", operation)
 	}
 }
 
+// getShellOptions extracts the options for a new shell from the tool arguments
+func getShellOptions(arguments map[string]interface{}) ShellOptions {
+	options := ShellOptions{}
+	options.PTY, _ = arguments["pty"].(bool)
+	if rows, ok := arguments["rows"].(float64); ok {
+		options.Rows = int(rows)
+	}
+	if cols, ok := arguments["cols"].(float64); ok {
+		options.Cols = int(cols)
+	}
+	return options
+}
+
+// restartShell starts a new shell in place of one that exited after its
+// command timed out, with the same name, type and terminal, and returns a
+// note for the result saying what happened
+func restartShell(sessionID string, session *ShellSession) string {
+	options := ShellOptions{PTY: session.Terminal != nil}
+	if session.Terminal != nil {
+		options.Rows, options.Cols = session.Terminal.Size()
+	}
+	if _, err := InitializeShell(sessionID, session.Name, session.ShellType, options); err != nil {
+		log.Printf("[Shell] Failed to restart shell %s: %v", session.Name, err)
+		return fmt.Sprintf("The shell exited and could not be restarted (%v). A new shell will be started for the next command; environment and working directory changes are lost.\n", err)
+	}
+	return "The shell was stopped so the rest of the command would not run, and a new shell was started in its place; environment and working directory changes are lost.\n"
+}
+
+// getTerminalSession returns a named shell session of a workspace session,
+// which must be a PTY shell
+func getTerminalSession(sessionID, name string) (*ShellSession, error) {
+	session, exists := GetShellSession(sessionID, name)
+	if !exists {
+		return nil, fmt.Errorf("shell session not found: %s", sessionID)
+	}
+	if session.Terminal == nil {
+		return nil, fmt.Errorf("shell %s of session %s is not a pty shell, initialize it with pty set to true", session.Name, sessionID)
+	}
+	return session, nil
+}
+
 // RegisterShell registers the shell tool with the MCP server
 func RegisterShell(mcpServer *server.MCPServer) {
 	// Create the tool definition
 	shellTool := mcp.NewTool("shell",
-		mcp.WithDescription("Maintains a persistent shell session for executing commands with context"),
+		mcp.WithDescription("Maintains persistent shell sessions for executing commands with context. A workspace session can have several named shells, each with its own environment, working directory and history, for example one running a dev server and another running tests. On Linux the shell can run on a pseudo-terminal for programs that need a TTY, such as REPLs, pagers and password prompts; keystrokes can then be sent and the screen read back."),
 		mcp.WithString("operation",
-			mcp.Description("Operation to perform: 'initialize', 'execute', 'close', or 'status'"),
+			mcp.Description("Operation to perform: 'initialize', 'execute', 'close', 'status', 'list' (all shells of the session), 'switch' (change the active shell), or 'history' (commands run in a shell). For pty shells also 'send' (type input or keys), 'read' (read output or the screen), and 'resize'"),
 			mcp.Required(),
 		),
 		mcp.WithString("session_id",
 			mcp.Description("Session ID to associate with the shell"),
 			mcp.Required(),
 		),
+		mcp.WithString("shell_name",
+			mcp.Description("Name of the shell within the workspace session. 'initialize' creates or replaces the shell with this name and makes it active; other operations use it instead of the active shell (default: the active shell, or 'default')"),
+		),
 		mcp.WithString("shell_type",
 			mcp.Description("Type of shell to use: 'bash', 'powershell', or 'cmd' (default depends on OS)"),
 		),
@@ -491,7 +1123,31 @@ func RegisterShell(mcpServer *server.MCPServer) {
 			mcp.Description("The command to execute (for 'execute' operation)"),
 		),
 		mcp.WithNumber("timeout",
-			mcp.Description("Timeout in seconds for command execution (default: 30)"),
+			mcp.Description("Timeout in seconds for command execution (default: 30). For 'read', how long to wait for output (default: 1)"),
+		),
+		mcp.WithBoolean("pty",
+			mcp.Description("Run the shell on a pseudo-terminal (for 'initialize' operation, Linux and bash only, default: false)"),
+		),
+		mcp.WithNumber("rows",
+			mcp.Description("Terminal height in rows (for 'initialize' and 'resize' operations, default: 24)"),
+		),
+		mcp.WithNumber("cols",
+			mcp.Description("Terminal width in columns (for 'initialize' and 'resize' operations, default: 80)"),
+		),
+		mcp.WithString("input",
+			mcp.Description("Raw text to type into the terminal, including control characters such as \\u0003 for Ctrl-C (for 'send' operation)"),
+		),
+		mcp.WithArray("keys",
+			mcp.Description("Named keys to press after the input, such as 'enter', 'tab', 'escape', 'up', 'down', 'left', 'right', 'backspace', 'ctrl-c' or 'ctrl-d' (for 'send' operation)"),
+		),
+		mcp.WithNumber("lines",
+			mcp.Description("Number of most recent commands to show (for 'history' operation, default: all)"),
+		),
+		mcp.WithString("confirm",
+			mcp.Description("Confirmation token from an earlier call, for commands that the command policy requires to be confirmed (for 'execute' and 'send' operations)"),
+		),
+		mcp.WithString("mode",
+			mcp.Description("What to read: 'text' for new output with escape sequences stripped, or 'screen' for the current screen contents (for 'read' operation, default: 'text')"),
 		),
 	)
 
@@ -0,0 +1,378 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+)
+
+// Default size of a pseudo-terminal
+const (
+	defaultRows = 24
+	defaultCols = 80
+)
+
+// maxPendingOutput is the amount of unread terminal output that is kept.
+// Older output is discarded.
+const maxPendingOutput = 1024 * 1024
+
+// settleInterval is how long output has to be quiet before a read returns
+const settleInterval = 100 * time.Millisecond
+
+// keySequences maps key names to the bytes a terminal sends for them.
+// Ctrl-A to Ctrl-Z are handled separately.
+var keySequences = map[string]string{
+	"enter":     "\r",
+	"tab":       "\t",
+	"space":     " ",
+	"escape":    "\x1b",
+	"backspace": "\x7f",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"home":      "\x1b[H",
+	"end":       "\x1b[F",
+	"insert":    "\x1b[2~",
+	"delete":    "\x1b[3~",
+	"pageup":    "\x1b[5~",
+	"pagedown":  "\x1b[6~",
+}
+
+// Terminal is the pseudo-terminal of a PTY shell session. It keeps an
+// emulated screen and the output that has not been read yet.
+type Terminal struct {
+	master  *os.File
+	screen  *Screen
+	pending string
+	typed   string // Text typed since the last Enter
+	notify  chan struct{}
+	closed  bool
+	mutex   sync.Mutex
+}
+
+// newTerminal creates a terminal for the master side of a pseudo-terminal and
+// starts reading its output
+func newTerminal(master *os.File, rows, cols int) *Terminal {
+	t := &Terminal{
+		master: master,
+		screen: NewScreen(rows, cols),
+		notify: make(chan struct{}, 1),
+	}
+	go t.readOutput()
+	return t
+}
+
+// readOutput reads from the pseudo-terminal until it is closed
+func (t *Terminal) readOutput() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.master.Read(buf)
+		if n > 0 {
+			t.mutex.Lock()
+			t.pending += t.screen.Write(buf[:n])
+			if len(t.pending) > maxPendingOutput {
+				t.pending = t.pending[len(t.pending)-maxPendingOutput:]
+			}
+			t.mutex.Unlock()
+			t.signal()
+		}
+		if err != nil {
+			t.mutex.Lock()
+			t.closed = true
+			t.mutex.Unlock()
+			t.signal()
+			return
+		}
+	}
+}
+
+// signal wakes up a goroutine waiting for output
+func (t *Terminal) signal() {
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// TakeOutput returns the plain text output that has arrived since the last
+// call, and whether the terminal has been closed
+func (t *Terminal) TakeOutput() (string, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	output := t.pending
+	t.pending = ""
+	return output, t.closed
+}
+
+// WaitForOutput waits up to timeout for output to arrive, then until output
+// has been quiet for a short time so that a program can finish redrawing
+func (t *Terminal) WaitForOutput(timeout time.Duration) {
+	deadline := time.After(timeout)
+	select {
+	case <-t.notify:
+	case <-deadline:
+		return
+	}
+
+	for {
+		select {
+		case <-t.notify:
+		case <-time.After(settleInterval):
+			return
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// Screen returns the text currently shown on the terminal
+func (t *Terminal) Screen() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.screen.Render()
+}
+
+// Size returns the number of rows and columns of the terminal
+func (t *Terminal) Size() (int, int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.screen.Size()
+}
+
+// Resize changes the size of the terminal
+func (t *Terminal) Resize(rows, cols int) error {
+	if rows <= 0 || cols <= 0 {
+		return fmt.Errorf("rows and cols must be positive")
+	}
+	if err := setPTYSize(t.master, rows, cols); err != nil {
+		return err
+	}
+
+	t.mutex.Lock()
+	t.screen.Resize(rows, cols)
+	t.mutex.Unlock()
+
+	return nil
+}
+
+// Send writes raw input to the terminal, as if it were typed
+func (t *Terminal) Send(input string) error {
+	if _, err := io.WriteString(t.master, input); err != nil {
+		return fmt.Errorf("failed to write to terminal: %v", err)
+	}
+
+	t.mutex.Lock()
+	_, t.typed = typeLines(t.typed, input)
+	t.mutex.Unlock()
+	return nil
+}
+
+// TypedLines returns the lines that input would complete if it were sent,
+// each with the text that earlier input typed on it
+func (t *Terminal) TypedLines(input string) []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	lines, _ := typeLines(t.typed, input)
+	return lines
+}
+
+// typeLines applies input to a line being typed, and returns the lines that
+// Enter completes and the text left on the last one. Without readline, the
+// terminal edits the line: backspace erases a character, and Ctrl-C and
+// Ctrl-U discard the line. Escape sequences such as arrow keys are left out.
+func typeLines(line, input string) ([]string, string) {
+	var lines []string
+	current := []rune(line)
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\r' || r == '\n':
+			lines = append(lines, string(current))
+			current = nil
+		case r == '\x7f' || r == '\b':
+			if len(current) > 0 {
+				current = current[:len(current)-1]
+			}
+		case r == '\x03' || r == '\x15':
+			current = nil
+		case r == '\x1b':
+			if i+1 < len(runes) && (runes[i+1] == '[' || runes[i+1] == 'O') {
+				// Skip to the final character of the sequence
+				i += 2
+				for i < len(runes) && (runes[i] < 0x40 || runes[i] > 0x7e) {
+					i++
+				}
+			}
+		case r == '\t' || r >= ' ':
+			current = append(current, r)
+		}
+	}
+	return lines, string(current)
+}
+
+// KeySequence returns the input for a named key such as "enter", "up" or "ctrl-c"
+func KeySequence(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if sequence, ok := keySequences[name]; ok {
+		return sequence, nil
+	}
+
+	if letter, ok := strings.CutPrefix(name, "ctrl-"); ok && len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' {
+		return string(rune(letter[0] - 'a' + 1)), nil
+	}
+
+	return "", fmt.Errorf("unknown key: %s", name)
+}
+
+// executeInTerminal runs a command in a PTY shell. The terminal merges stdout
+// and stderr, so all output is reported as stdout.
+func executeInTerminal(session *ShellSession, command string, timeoutSec float64, reporter *progress.Reporter) (*CommandResult, error) {
+	terminal := session.Terminal
+
+	// Discard output from earlier commands and interactive use
+	terminal.TakeOutput()
+
+	// The sentinel is printed inside an escape sequence, which keeps it off
+	// the screen
+	sentinel := newSentinel()
+	sentinelCommand := fmt.Sprintf("printf '\\033]%%s %%s\\007' %s \"$?\"\n", sentinel)
+	input := command + "\n" + sentinelCommand
+
+	// Discard a line partly typed with send, which would otherwise run
+	// together with the command
+	if lines := terminal.TypedLines("\n"); len(lines) > 0 && lines[0] != "" {
+		input = "\x15" + input
+	}
+	if err := terminal.Send(input); err != nil {
+		return nil, err
+	}
+
+	result := &CommandResult{ExitCode: -1}
+	timeout := time.After(time.Duration(timeoutSec * float64(time.Second)))
+	interrupted := false
+	partialLine := ""
+
+	for {
+		output, closed := terminal.TakeOutput()
+
+		// Look at complete lines only, since the sentinel may arrive in pieces
+		lines := strings.Split(partialLine+output, "\n")
+		partialLine = lines[len(lines)-1]
+		for _, line := range lines[:len(lines)-1] {
+			if text, status, found := parseSentinel(line, sentinel); found {
+				if exitCode, err := strconv.Atoi(status); err == nil {
+					result.ExitCode = exitCode
+					session.appendOutput(&session.LastOutput, text, reporter, "stdout")
+					return result, nil
+				}
+			}
+			if !strings.Contains(line, sentinelPrefix) {
+				session.appendOutput(&session.LastOutput, line+"\n", reporter, "stdout")
+			}
+		}
+
+		if closed {
+			session.appendOutput(&session.LastOutput, partialLine, reporter, "stdout")
+			result.ShellExited = true
+			return result, nil
+		}
+
+		select {
+		case <-terminal.notify:
+		case <-timeout:
+			if !interrupted {
+				// Press Ctrl-C, which also discards the pending sentinel
+				// command, so send it again
+				log.Printf("[Shell] Command timed out after %v seconds, interrupting: %s", timeoutSec, command)
+				result.TimedOut = true
+				interrupted = true
+				terminal.Send("\x03")
+				time.Sleep(settleInterval)
+				terminal.Send(sentinelCommand)
+			} else {
+				log.Printf("[Shell] Command did not stop after interrupt, killing shell")
+				if err := killShell(session.Cmd); err != nil {
+					log.Printf("[Shell] Failed to kill shell: %v", err)
+				}
+				session.appendOutput(&session.LastOutput, partialLine, reporter, "stdout")
+				result.ShellExited = true
+				return result, nil
+			}
+			timeout = time.After(interruptGracePeriod)
+		}
+	}
+}
+
+// initializePTYShell starts a shell on a pseudo-terminal, so that programs
+// that check for a terminal behave as they would for a user
+func initializePTYShell(sessionID, name, shellType string, cmd *exec.Cmd, options ShellOptions) (*ShellSession, error) {
+	if shellType != "bash" {
+		return nil, fmt.Errorf("pty shells are only supported with bash")
+	}
+
+	rows, cols := options.Rows, options.Cols
+	if rows <= 0 {
+		rows = defaultRows
+	}
+	if cols <= 0 {
+		cols = defaultCols
+	}
+
+	// Without readline, bash leaves echoing to the terminal, where it is
+	// turned off so that commands don't show up in their own output
+	cmd.Args = append(cmd.Args, "--noediting")
+	cmd.Env = append(os.Environ(), "TERM=xterm")
+
+	// Apply the sandbox limits of the workspace, if any
+	if err := sandbox.Wrap(cmd, sandbox.ConfigForSession(sessionID), workspace.GetRootDir(sessionID)); err != nil {
+		return nil, fmt.Errorf("failed to set up sandbox: %v", err)
+	}
+
+	master, err := startPTY(cmd, rows, cols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start shell: %v", err)
+	}
+
+	session := &ShellSession{
+		Name:       name,
+		ShellType:  shellType,
+		Cmd:        cmd,
+		Stdin:      master,
+		Terminal:   newTerminal(master, rows, cols),
+		StartTime:  time.Now(),
+		LastAccess: time.Now(),
+	}
+
+	// Hide the prompts, which would otherwise be mixed into command output,
+	// then wait for the shell to be ready, discarding anything it printed at
+	// startup
+	if err := session.Terminal.Send("PS1=''; PS2=''; unset PROMPT_COMMAND\n"); err != nil {
+		session.Close()
+		return nil, err
+	}
+	if result, err := executeInTerminal(session, "true", 10, nil); err != nil || result.ExitCode != 0 {
+		session.Close()
+		return nil, fmt.Errorf("shell did not start")
+	}
+	session.LastOutput = ""
+
+	// Store the session
+	SetShellSession(sessionID, session)
+
+	return session, nil
+}
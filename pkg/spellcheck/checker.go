@@ -1,138 +1,107 @@
 package spellcheck
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
 )
 
-// spellCheckFile performs spell checking on a single file
+// spellCheckFile performs spell checking on a single file, finding its
+// comments, string literals, and identifiers with the syntax package
 func spellCheckFile(filePath, language string, checkComments, checkStrings, checkIdentifiers bool, dictionaryType string, customDictionary []string) ([]SpellCheckResult, error) {
 	var results []SpellCheckResult
 
-	// Determine the language if not specified
-	var lang Language
-	if language == "" {
-		ext := filepath.Ext(filePath)
-		var found bool
-		lang, found = GetLanguageByExtension(ext)
-		if !found {
-			return nil, fmt.Errorf("unsupported file extension: %s", ext)
-		}
-	} else {
-		var found bool
-		lang, found = GetLanguageByName(language)
+	// Determine the language from the file extension, unless another
+	// language is specified
+	lang, found := syntax.LanguageForFile(filePath)
+	if language != "" && !(found && lang.Is(language)) {
+		lang, found = syntax.LanguageByName(language)
 		if !found {
 			return nil, fmt.Errorf("unsupported language: %s", language)
 		}
+	} else if !found {
+		return nil, fmt.Errorf("unsupported file extension: %s", filepath.Ext(filePath))
 	}
 
-	// Open the file
-	file, err := os.Open(filePath)
+	// Read and parse the file
+	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
+		return nil, fmt.Errorf("error reading file: %v", err)
 	}
-	defer file.Close()
-
-	// Create a scanner to read the file
-	scanner := bufio.NewScanner(file)
-
-	// Process each line
-	lineNumber := 0
-	for scanner.Scan() {
-		lineNumber++
-		line := scanner.Text()
-
-		// Check comments if enabled
-		if checkComments {
-			// Check for single-line comments
-			commentIndex := strings.Index(line, lang.SingleLineComment)
-			if commentIndex >= 0 {
-				comment := line[commentIndex+len(lang.SingleLineComment):]
-				commentResults := checkTextForSpellingErrors(comment, lineNumber, commentIndex+len(lang.SingleLineComment), "comment", dictionaryType, customDictionary)
-				for _, result := range commentResults {
+	file := syntax.Parse(string(content), lang)
+
+	// checkText checks the text of comments or string literals, line by
+	// line
+	checkText := func(tokens []syntax.Token, textType string) {
+		for _, tok := range tokens {
+			for _, segment := range file.Segments(tok) {
+				for _, result := range checkTextForSpellingErrors(segment.Text, segment.Line, segment.Column-1, textType, dictionaryType, customDictionary) {
 					result.FilePath = filePath
-					result.Context = line
+					result.Context = file.Line(segment.Line)
 					results = append(results, result)
 				}
 			}
 		}
+	}
 
-		// Check string literals if enabled
-		if checkStrings {
-			for _, delimiter := range lang.StringDelimiters {
-				// Find all string literals in the line
-				startIndex := 0
-				for {
-					startIndex = strings.Index(line[startIndex:], delimiter)
-					if startIndex < 0 {
-						break
-					}
-
-					// Find the end of the string
-					endIndex := strings.Index(line[startIndex+len(delimiter):], delimiter)
-					if endIndex < 0 {
-						break
-					}
+	// Check comments if enabled
+	if checkComments {
+		checkText(file.Comments, "comment")
+	}
 
-					// Extract the string content
-					stringContent := line[startIndex+len(delimiter) : startIndex+len(delimiter)+endIndex]
-					stringResults := checkTextForSpellingErrors(stringContent, lineNumber, startIndex+len(delimiter), "string", dictionaryType, customDictionary)
-					for _, result := range stringResults {
-						result.FilePath = filePath
-						result.Context = line
-						results = append(results, result)
-					}
+	// Check string literals if enabled
+	if checkStrings {
+		checkText(file.Strings(), "string")
+	}
 
-					startIndex += len(delimiter) + endIndex + len(delimiter)
-				}
+	// Check identifiers if enabled, each where it first appears
+	if checkIdentifiers {
+		checked := make(map[string]bool)
+		for _, tok := range file.Tokens {
+			if tok.Kind != syntax.Identifier || lang.IsKeyword(tok.Text) {
+				continue
 			}
-		}
-
-		// Check identifiers if enabled
-		if checkIdentifiers {
-			// Simple regex to find identifiers (variable and function names)
-			// This is a simplified approach and would need to be more sophisticated in a real implementation
-			words := strings.Fields(line)
-			for _, word := range words {
-				// Skip if it's not a valid identifier
-				if !isValidIdentifier(word) {
-					continue
-				}
 
-				// Split camelCase, PascalCase, or snake_case identifiers into words
-				subWords := splitIdentifier(word)
-				for _, subWord := range subWords {
-					if len(subWord) > 2 && !isCommonProgrammingTerm(subWord) {
-						// Check if the word is misspelled
-						if isMisspelled(subWord, dictionaryType) && !isInCustomDictionary(subWord, customDictionary) {
-							// Find the position of the word in the line
-							wordIndex := strings.Index(line, word)
-							if wordIndex >= 0 {
-								results = append(results, SpellCheckResult{
-									FilePath:    filePath,
-									LineNumber:  lineNumber,
-									ColumnStart: wordIndex,
-									ColumnEnd:   wordIndex + len(word),
-									Word:        subWord,
-									Context:     line,
-									Type:        "identifier",
-									Suggestions: getSuggestions(subWord, dictionaryType),
-								})
-							}
-						}
+			// Leave out the sigils of PHP and Ruby variables and the
+			// suffixes of Ruby methods
+			word := strings.TrimRight(strings.TrimLeft(tok.Text, "$@"), "?!=")
+			if checked[word] || !isValidIdentifier(word) {
+				continue
+			}
+			checked[word] = true
+
+			// Split camelCase, PascalCase, or snake_case identifiers into words
+			for _, subWord := range splitIdentifier(word) {
+				if len(subWord) > 2 && !isCommonProgrammingTerm(subWord) {
+					// Check if the word is misspelled
+					if isMisspelled(subWord, dictionaryType) && !isInCustomDictionary(subWord, customDictionary) {
+						results = append(results, SpellCheckResult{
+							FilePath:    filePath,
+							LineNumber:  tok.Line,
+							ColumnStart: tok.Column - 1,
+							ColumnEnd:   tok.Column - 1 + len(tok.Text),
+							Word:        subWord,
+							Context:     file.Line(tok.Line),
+							Type:        "identifier",
+							Suggestions: getSuggestions(subWord, dictionaryType),
+						})
 					}
 				}
 			}
 		}
 	}
 
-	// Check for scanner errors
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
-	}
+	// Report the results in the order of the file
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].LineNumber != results[j].LineNumber {
+			return results[i].LineNumber < results[j].LineNumber
+		}
+		return results[i].ColumnStart < results[j].ColumnStart
+	})
 
 	return results, nil
 }
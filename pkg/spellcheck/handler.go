@@ -8,7 +8,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/syntax"
 	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -18,28 +20,11 @@ import (
 func spellCheckDirectory(dirPath, language string, recursive, checkComments, checkStrings, checkIdentifiers bool, dictionaryType string, customDictionary []string) ([]SpellCheckResult, error) {
 	var results []SpellCheckResult
 
-	// Get supported languages
-	supportedLanguages := GetSupportedLanguages()
-
-	// Filter languages if specified
-	var languages []Language
+	// Check the language if specified
 	if language != "" {
-		lang, found := GetLanguageByName(language)
-		if found {
-			languages = []Language{lang}
-		} else {
+		if _, found := syntax.LanguageByName(language); !found {
 			return nil, fmt.Errorf("unsupported language: %s", language)
 		}
-	} else {
-		languages = supportedLanguages
-	}
-
-	// Create a map of file extensions to languages
-	extToLang := make(map[string]Language)
-	for _, lang := range languages {
-		for _, ext := range lang.FileExtensions {
-			extToLang[ext] = lang
-		}
 	}
 
 	// Walk the directory
@@ -56,10 +41,9 @@ func spellCheckDirectory(dirPath, language string, recursive, checkComments, che
 			return nil
 		}
 
-		// Check if the file extension is supported
-		ext := filepath.Ext(path)
-		lang, ok := extToLang[ext]
-		if !ok {
+		// Check if the file is in a supported language
+		lang, ok := syntax.LanguageForFile(path)
+		if !ok || (language != "" && !lang.Is(language)) {
 			return nil
 		}
 
@@ -137,6 +121,15 @@ func HandleSpellCheck(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 			}
 		}
 	}
+	// Extract output format (optional)
+	format, _ := arguments["format"].(string)
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "sarif" {
+		return nil, fmt.Errorf("unsupported format: %s (supported: text, sarif)", format)
+	}
+
 	// Extract session ID
 	sessionID, _ := arguments["session_id"].(string)
 
@@ -173,6 +166,33 @@ func HandleSpellCheck(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		return nil, fmt.Errorf("error performing spell check: %v", err)
 	}
 
+	// SARIF gives paths relative to the workspace root itself
+	if format == "sarif" {
+		absRoot, err := filepath.Abs(rootDir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root directory: %v", err)
+		}
+		for i := range results {
+			if absPath, err := filepath.Abs(results[i].FilePath); err == nil {
+				results[i].FilePath = absPath
+			}
+		}
+		sarifText, err := formatSARIF(results, absRoot)
+		if err != nil {
+			return nil, err
+		}
+		// A cut SARIF log is invalid, so a large one is saved whole
+		summary := fmt.Sprintf("SARIF log of %s: %d misspellings", fullPath, len(results))
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: artifact.LimitWhole("spellcheck", sarifText, summary),
+				},
+			},
+		}, nil
+	}
+
 	// Convert paths to relative if requested
 	if useRelativePaths {
 		for i := range results {
@@ -251,6 +271,9 @@ func RegisterSpellCheck(mcpServer *server.MCPServer) {
 		mcp.WithArray("custom_dictionary",
 			mcp.Description("A list of custom words to consider as correctly spelled"),
 		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'text', or 'sarif' for a SARIF 2.1.0 log with the suggestions as fixes (default: 'text')"),
+		),
 		mcp.WithString("session_id",
 			mcp.Description("Session ID to use for resolving relative paths"),
 		),
@@ -0,0 +1,120 @@
+package spellcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/sarif"
+)
+
+// spellingRules are the SARIF rules, one per kind of text checked
+var spellingRules = map[string]string{
+	"comment":    "Misspelled word in a comment",
+	"string":     "Misspelled word in a string literal",
+	"identifier": "Misspelled word in an identifier",
+}
+
+// formatSARIF renders spelling issues as a SARIF 2.1.0 log, with the
+// suggestions as fixes. File paths are relative to rootDir when they are
+// below it.
+func formatSARIF(results []SpellCheckResult, rootDir string) (string, error) {
+	run := sarif.NewRun(sarif.Driver{
+		Name:           "CodeSpinneret spellcheck",
+		InformationURI: "https://github.com/Code-Monger/CodeSpinneret",
+	}, rootDir)
+	// Columns count characters, not UTF-16 code units
+	run.ColumnKind = "unicodeCodePoints"
+	fingerprinter := sarif.NewFingerprinter()
+
+	for _, issue := range results {
+		ruleID := issue.Type + "-spelling"
+		rule := sarif.Rule{ID: ruleID, DefaultConfiguration: &sarif.Configuration{Level: "note"}}
+		if description, exists := spellingRules[issue.Type]; exists {
+			rule.ShortDescription = &sarif.Message{Text: description}
+		}
+		ruleIndex := run.AddRule(rule)
+
+		filePath := issue.FilePath
+		if !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(rootDir, filePath)
+		}
+		artifactLocation := run.ArtifactLocationOf(filePath)
+		region := wordRegion(issue)
+
+		result := sarif.Result{
+			RuleID:    ruleID,
+			RuleIndex: &ruleIndex,
+			Level:     "note",
+			Message:   sarif.Message{Text: fmt.Sprintf("Possible misspelling: %s", issue.Word)},
+			Locations: []sarif.Location{{PhysicalLocation: sarif.PhysicalLocation{
+				ArtifactLocation: artifactLocation,
+				Region:           &region,
+			}}},
+			PartialFingerprints: fingerprinter.Fingerprints(ruleID+"/"+strings.ToLower(issue.Word), filePath, artifactLocation.URI, issue.LineNumber),
+		}
+		if len(issue.Suggestions) > 0 {
+			result.Message.Text += fmt.Sprintf(" (did you mean %s?)", strings.Join(issue.Suggestions, ", "))
+		}
+		for _, suggestion := range issue.Suggestions {
+			replacement := matchCase(suggestion, issue.Word)
+			result.Fixes = append(result.Fixes, sarif.Fix{
+				Description: &sarif.Message{Text: fmt.Sprintf("Replace '%s' with '%s'", issue.Word, replacement)},
+				ArtifactChanges: []sarif.ArtifactChange{{
+					ArtifactLocation: artifactLocation,
+					Replacements: []sarif.Replacement{{
+						DeletedRegion:   sarif.Region{StartLine: region.StartLine, StartColumn: region.StartColumn, EndColumn: region.EndColumn},
+						InsertedContent: &sarif.Message{Text: replacement},
+					}},
+				}},
+			})
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	data, err := json.MarshalIndent(sarif.NewLog(run), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode SARIF: %v", err)
+	}
+	return string(data), nil
+}
+
+// wordRegion returns the region of the misspelled word itself, which lies
+// within the columns of the issue, with one-based columns counted in
+// characters
+func wordRegion(issue SpellCheckResult) sarif.Region {
+	start, end := issue.ColumnStart, issue.ColumnEnd
+	if start >= 0 && end <= len(issue.Context) && start <= end {
+		if i := strings.Index(issue.Context[start:end], issue.Word); i >= 0 {
+			start += i
+			end = start + len(issue.Word)
+		}
+		return sarif.Region{
+			StartLine:   issue.LineNumber,
+			StartColumn: utf8.RuneCountInString(issue.Context[:start]) + 1,
+			EndColumn:   utf8.RuneCountInString(issue.Context[:end]) + 1,
+			Snippet:     &sarif.Message{Text: issue.Context[start:end]},
+		}
+	}
+	return sarif.Region{StartLine: issue.LineNumber}
+}
+
+// matchCase gives a suggestion the case of the word it replaces: all upper
+// case, capitalized, or as it is
+func matchCase(suggestion, word string) string {
+	if suggestion == "" {
+		return suggestion
+	}
+	if strings.ToUpper(word) == word && strings.ToLower(word) != word {
+		return strings.ToUpper(suggestion)
+	}
+	first, size := utf8.DecodeRuneInString(word)
+	if unicode.IsUpper(first) && size > 0 {
+		s, n := utf8.DecodeRuneInString(suggestion)
+		return string(unicode.ToUpper(s)) + suggestion[n:]
+	}
+	return suggestion
+}
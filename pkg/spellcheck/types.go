@@ -13,14 +13,3 @@ type SpellCheckResult struct {
 	Type        string   `json:"type"` // "comment", "string", "identifier"
 	Suggestions []string `json:"suggestions,omitempty"`
 }
-
-// Language represents a programming language with its file extensions and comment patterns
-type Language struct {
-	Name                  string
-	FileExtensions        []string
-	SingleLineComment     string
-	MultiLineCommentStart string
-	MultiLineCommentEnd   string
-	StringDelimiters      []string
-	RawStringDelimiters   []string
-}
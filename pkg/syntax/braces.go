@@ -0,0 +1,983 @@
+package syntax
+
+import "strings"
+
+// typeKeywords are the keywords that declare class-like types
+var typeKeywords = map[string]bool{
+	"class": true, "interface": true, "struct": true, "enum": true, "trait": true, "record": true, "union": true,
+}
+
+// controlKeywords are keywords followed by parentheses and a block that
+// are not function declarations
+var controlKeywords = map[string]bool{
+	"if": true, "for": true, "foreach": true, "while": true, "switch": true, "catch": true,
+	"with": true, "using": true, "lock": true, "fixed": true, "synchronized": true,
+	"return": true, "sizeof": true, "typeof": true, "new": true, "else": true, "do": true,
+	"try": true, "finally": true, "await": true, "throw": true, "case": true, "function": true,
+}
+
+// familyControlKeywords are the control keywords of some families only,
+// which can name functions in the others
+var familyControlKeywords = map[string]map[string]bool{
+	"PHP": {"elseif": true, "declare": true, "match": true, "fn": true, "use": true},
+	"C#":  {"when": true},
+}
+
+// declModifiers are the words that can come before a declaration on the
+// lines above it
+var declModifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "internal": true, "static": true, "final": true,
+	"abstract": true, "virtual": true, "override": true, "sealed": true, "readonly": true, "async": true,
+	"export": true, "default": true, "extern": true, "inline": true, "constexpr": true, "unsafe": true,
+	"partial": true, "native": true, "synchronized": true, "strictfp": true, "declare": true,
+	"template": true, "typename": true,
+}
+
+// declStops are the words that end the search for the start of a
+// declaration, because they end the statement before it
+var declStops = map[string]bool{
+	"return": true, "else": true, "case": true, "do": true, "throw": true, "new": true, "yield": true,
+	"await": true, "in": true, "of": true, "typeof": true, "goto": true, "echo": true,
+}
+
+// accessSpecifiers are the labels of the sections of a C++ class
+var accessSpecifiers = map[string]bool{"public": true, "private": true, "protected": true}
+
+// lambdaStarts are the tokens that can come before the single
+// unparenthesized parameter of a lambda
+var lambdaStarts = map[string]bool{
+	"(": true, ",": true, "=": true, ":": true, "return": true, "async": true, "?": true, "=>": true,
+	"->": true, "[": true, "{": true, "||": true, "&&": true, "??": true,
+}
+
+// isControl reports whether a word is a control keyword of the language
+func (p *braceParser) isControl(word string) bool {
+	return controlKeywords[word] || familyControlKeywords[p.family][word]
+}
+
+// braceParser finds the symbols, calls, and imports in the tokens of a
+// language with braced blocks
+type braceParser struct {
+	f       *File
+	family  string
+	tokens  []Token
+	matches map[int]int
+	defined map[int]bool // Indexes of the names of the symbols
+}
+
+// parseBraces parses the tokens of JavaScript, TypeScript, Java, C, C++,
+// C#, or PHP
+func parseBraces(f *File) {
+	p := &braceParser{
+		f:       f,
+		family:  f.Language.Family,
+		tokens:  f.Tokens,
+		matches: matchBrackets(f.Tokens),
+		defined: make(map[int]bool),
+	}
+
+	// Types and functions with bodies, from the tokens that open them
+	var bodies []int // Closes of the function bodies around the token
+	for i, tok := range p.tokens {
+		for len(bodies) > 0 && bodies[len(bodies)-1] < i {
+			bodies = bodies[:len(bodies)-1]
+		}
+		if tok.Kind != Punct {
+			continue
+		}
+		var s Symbol
+		var ok bool
+		switch {
+		case tok.Text == "{":
+			if s, ok = p.typeSymbol(i); !ok {
+				s, ok = p.functionSymbol(i, len(bodies) > 0)
+			}
+		case p.isArrow(i) && textAt(p.tokens, i+1) != "{":
+			s, ok = p.lambda(i, p.expressionEnd(i))
+		}
+		if !ok {
+			continue
+		}
+		p.add(s)
+		if s.IsFunction() {
+			bodies = append(bodies, s.BodyClose)
+		}
+	}
+
+	p.prototypes()
+	p.calls()
+	p.imports()
+}
+
+// add adds a symbol
+func (p *braceParser) add(s Symbol) {
+	if s.nameIndex >= 0 {
+		p.defined[s.nameIndex] = true
+	}
+	p.f.Symbols = append(p.f.Symbols, s)
+}
+
+// newSymbol makes a symbol without a name, parameters, or body
+func newSymbol(kind string) Symbol {
+	return Symbol{Kind: kind, ParamsOpen: -1, ParamsClose: -1, BodyOpen: -1, BodyClose: -1, nameIndex: -1}
+}
+
+// typeSymbol returns the class-like type whose body opens at the token at
+// index i, if there is one
+func (p *braceParser) typeSymbol(i int) (Symbol, bool) {
+	tokens := p.tokens
+	end := p.whereStart(i)
+	for j := end - 1; j >= 0 && j >= i-64; j-- {
+		tok := tokens[j]
+		if tok.Kind == Punct {
+			switch tok.Text {
+			case ")", "]":
+				open, ok := p.matches[j]
+				if !ok || tok.Text == ")" && p.family == "C/C++" {
+					// The parameters of a function that returns a struct
+					return Symbol{}, false
+				}
+				j = open
+			case ";", "{", "}", "(", "[", "=", "=>", "->":
+				return Symbol{}, false
+			}
+			continue
+		}
+		if tok.Kind != Identifier || !typeKeywords[tok.Text] {
+			continue
+		}
+		if !p.f.Language.IsKeyword(tok.Text) && !(tok.Text == "record" && (p.family == "Java" || p.family == "C#")) {
+			continue
+		}
+		if prev := textAt(tokens, j-1); prev == "." || prev == "::" || prev == "->" {
+			// Foo.class in Java
+			return Symbol{}, false
+		}
+		if j+1 >= end || tokens[j+1].Kind != Identifier || p.f.Language.IsKeyword(tokens[j+1].Text) {
+			// An anonymous class, or a constraint such as where T : class
+			return Symbol{}, false
+		}
+
+		keyword := j
+		if textAt(tokens, j-1) == "enum" {
+			// enum class and enum struct in C++
+			keyword = j - 1
+		}
+		close, ok := p.matches[i]
+		if !ok {
+			return Symbol{}, false
+		}
+		s := newSymbol(tokens[keyword].Text)
+		s.Name, s.nameIndex = tokens[j+1].Text, j+1
+		s.Start = tokens[p.declStart(keyword)].Offset
+		s.BodyOpen, s.BodyClose = i, close
+		s.End = tokens[close].End
+		return s, true
+	}
+	return Symbol{}, false
+}
+
+// functionSymbol returns the function, method, or lambda whose body opens
+// at the token at index i, if there is one. inFunction tells if the body
+// is inside the body of another function.
+func (p *braceParser) functionSymbol(i int, inFunction bool) (Symbol, bool) {
+	tokens := p.tokens
+	close, ok := p.matches[i]
+	if !ok {
+		return Symbol{}, false
+	}
+	if i > 0 && p.isArrow(i-1) {
+		return p.lambda(i-1, close)
+	}
+
+	s := newSymbol(KindFunction)
+	s.BodyOpen, s.BodyClose = i, close
+	s.End = tokens[close].End
+
+	if p.indexer(&s, i-1) {
+		return s, true
+	}
+
+	paramClose := signatureParen(tokens, p.whereStart(i))
+	for paramClose >= 0 {
+		paramOpen, ok := p.matches[paramClose]
+		if !ok || paramOpen == 0 {
+			return Symbol{}, false
+		}
+		name := p.nameBefore(paramOpen)
+		if name < 0 {
+			return Symbol{}, false
+		}
+		nameTok := tokens[name]
+		before := textAt(tokens, name-1)
+		operator, operatorName := p.operatorName(paramOpen)
+
+		switch {
+		case operator >= 0:
+			// An operator overload or a conversion operator
+			s.Name, s.nameIndex = operatorName, operator
+			s.Start = tokens[p.declStart(p.qualifiedStart(operator, &s))].Offset
+
+		case (p.family == "C/C++" || p.family == "C#") && nameTok.Kind == Identifier && (before == "," || before == ":") &&
+			!accessSpecifiers[textAt(tokens, name-2)]:
+			// A member initializer of a C++ constructor, a call of a base
+			// constructor in C#, or a new() constraint
+			paramClose = signatureParen(tokens, name-1)
+			continue
+
+		case p.family == "PHP" && nameTok.Text == "use":
+			// The captured variables of a closure
+			paramClose = signatureParen(tokens, name)
+			continue
+
+		case nameTok.Text == "function" || nameTok.Text == "fn" && p.family == "PHP":
+			// A function expression, named by what it is assigned to
+			start := name
+			if textAt(tokens, name-1) == "async" || textAt(tokens, name-1) == "static" {
+				start--
+			}
+			if assigned := p.assignedName(start - 1); assigned >= 0 {
+				s.Name, s.nameIndex = nameText(tokens[assigned]), assigned
+				s.Start = tokens[p.declStart(assigned)].Offset
+			} else {
+				s.Start = tokens[start].Offset
+			}
+
+		case nameTok.Text == "]" && p.family == "C/C++":
+			// A C++ lambda
+			captures, ok := p.matches[name]
+			if !ok {
+				return Symbol{}, false
+			}
+			s.Start = tokens[captures].Offset
+			if assigned := p.assignedName(captures - 1); assigned >= 0 {
+				s.Name, s.nameIndex = nameText(tokens[assigned]), assigned
+				s.Start = tokens[p.declStart(assigned)].Offset
+			}
+
+		case nameTok.Kind == Identifier && !p.isControl(nameTok.Text):
+			if before == "new" {
+				// An anonymous class or an object initializer
+				return Symbol{}, false
+			}
+			if inFunction && (p.family == "C/C++" || p.family == "Java") {
+				// A macro or a statement, since functions don't nest in C
+				// and Java
+				return Symbol{}, false
+			}
+			s.Name, s.nameIndex = nameTok.Text, name
+			if before == "~" {
+				s.Name = "~" + s.Name
+			}
+			s.Start = tokens[p.declStart(p.qualifiedStart(name, &s))].Offset
+
+		default:
+			return Symbol{}, false
+		}
+		s.ParamsOpen, s.ParamsClose = paramOpen, paramClose
+		return s, true
+	}
+	return Symbol{}, false
+}
+
+// nameBefore returns the index of the name before the parameters that
+// open at index paramOpen, going back over the type parameters of a
+// generic function in the languages that put them after the name, or -1
+// if the parameters open the file
+func (p *braceParser) nameBefore(paramOpen int) int {
+	name := paramOpen - 1
+	if name < 0 {
+		return -1
+	}
+	if p.tokens[name].Text == ">" && (p.family == "JavaScript" || p.family == "C#") {
+		if open := p.angleOpen(name); open > 0 {
+			name = open - 1
+		}
+	}
+	return name
+}
+
+// whereStart returns the index of the first where clause of the
+// constraints on the type parameters of a C# type or method before the
+// token at index i, as in class Q<T> where T : class {, or i if there are
+// none
+func (p *braceParser) whereStart(i int) int {
+	start := i
+	if p.family != "C#" {
+		return start
+	}
+	for j := i - 1; j >= 0 && j >= i-64; j-- {
+		switch tok := p.tokens[j]; {
+		case tok.Kind == Identifier:
+			if tok.Text == "where" {
+				start = j
+			}
+		case tok.Text == ")":
+			// A new() constraint, or the parameters before the constraints
+			open, ok := p.matches[j]
+			if !ok {
+				return start
+			}
+			j = open
+		case tok.Text == "," || tok.Text == ":" || tok.Text == "<" || tok.Text == ">" || tok.Text == "." || tok.Text == "?":
+		default:
+			return start
+		}
+	}
+	return start
+}
+
+// indexer reports whether the parameters that close at index paramClose
+// are those of a C# indexer, as in int this[int i], and if so names s this
+// after it and sets its parameters and start
+func (p *braceParser) indexer(s *Symbol, paramClose int) bool {
+	if p.family != "C#" || paramClose < 1 || p.tokens[paramClose].Text != "]" {
+		return false
+	}
+	paramOpen, ok := p.matches[paramClose]
+	if !ok || textAt(p.tokens, paramOpen-1) != "this" || textAt(p.tokens, paramOpen-2) == "." {
+		return false
+	}
+	s.Name, s.nameIndex = "this", paramOpen-1
+	s.ParamsOpen, s.ParamsClose = paramOpen, paramClose
+	s.Start = p.tokens[p.declStart(paramOpen-1)].Offset
+	return true
+}
+
+// operatorName returns the index of the operator keyword of the C++ or C#
+// operator overload or conversion operator whose parameters open at index
+// paramOpen, and its name, such as operator+, operator[], operator(), or
+// operator int, or -1 if the parameters aren't those of an operator
+func (p *braceParser) operatorName(paramOpen int) (int, string) {
+	tokens := p.tokens
+	if p.family != "C/C++" && p.family != "C#" {
+		return -1, ""
+	}
+	for j := paramOpen - 1; j >= 0 && j >= paramOpen-8; j-- {
+		switch tokens[j].Text {
+		case "operator":
+			if tokens[j].Kind != Identifier || j == paramOpen-1 || !p.startsOperator(j) {
+				return -1, ""
+			}
+			name := "operator"
+			for k := j + 1; k < paramOpen; k++ {
+				if tokens[k].Kind == Identifier && tokens[k-1].Kind == Identifier {
+					name += " "
+				}
+				name += tokens[k].Text
+			}
+			return j, name
+		case ";", "{", "}":
+			return -1, ""
+		}
+	}
+	return -1, ""
+}
+
+// startsOperator reports whether the operator keyword at index i is in a
+// declaration, after its return type or at the start of a member, rather
+// than in a call such as a.operator+(b)
+func (p *braceParser) startsOperator(i int) bool {
+	if i == 0 {
+		return true
+	}
+	switch before := p.tokens[i-1]; before.Text {
+	case "::", "*", "&", "&&", ">", "]", ";", "{", "}", ":":
+		return true
+	default:
+		return before.Kind == Identifier && !declStops[before.Text]
+	}
+}
+
+// qualifiedStart returns the index of the first token of the C++ name at
+// index i, such as Type::~Type, and sets the container of the symbol to
+// its qualifier
+func (p *braceParser) qualifiedStart(i int, s *Symbol) int {
+	tokens := p.tokens
+	if textAt(tokens, i-1) == "~" {
+		i--
+	}
+	if p.family != "C/C++" || textAt(tokens, i-1) != "::" {
+		return i
+	}
+	qualifier := i - 2
+	if textAt(tokens, qualifier) == ">" {
+		if open := p.angleOpen(qualifier); open > 0 {
+			qualifier = open - 1
+		}
+	}
+	if qualifier < 0 || tokens[qualifier].Kind != Identifier {
+		return i
+	}
+	s.Container = tokens[qualifier].Text
+	for qualifier >= 2 && tokens[qualifier-1].Text == "::" && tokens[qualifier-2].Kind == Identifier {
+		qualifier -= 2
+	}
+	return qualifier
+}
+
+// isArrow reports whether the token at index i is the arrow of a lambda
+// in the language
+func (p *braceParser) isArrow(i int) bool {
+	switch p.tokens[i].Text {
+	case "=>":
+		return p.family == "JavaScript" || p.family == "C#" || p.family == "PHP"
+	case "->":
+		return p.family == "Java"
+	}
+	return false
+}
+
+// lambda returns the lambda or expression-bodied member whose arrow is at
+// index arrow and whose body ends at index end, if there is one
+func (p *braceParser) lambda(arrow, end int) (Symbol, bool) {
+	tokens := p.tokens
+	s := newSymbol(KindFunction)
+	s.BodyOpen, s.BodyClose = arrow, end
+	if arrow+1 < len(tokens) && tokens[arrow+1].Text == "{" {
+		s.BodyOpen = arrow + 1
+	}
+	if end <= arrow {
+		return Symbol{}, false
+	}
+	s.End = tokens[end].End
+
+	if p.indexer(&s, arrow-1) {
+		return s, true
+	}
+
+	start := -1
+	if paramClose := signatureParen(tokens, p.whereStart(arrow)); paramClose >= 0 {
+		paramOpen, ok := p.matches[paramClose]
+		if !ok {
+			return Symbol{}, false
+		}
+		s.ParamsOpen, s.ParamsClose = paramOpen, paramClose
+		start = paramOpen
+		nameIndex := p.nameBefore(paramOpen)
+		name := textAt(tokens, nameIndex)
+		operator, operatorName := p.operatorName(paramOpen)
+		switch {
+		case operator >= 0:
+			// An expression-bodied operator in C#
+			s.Name, s.nameIndex = operatorName, operator
+			s.Start = tokens[p.declStart(operator)].Offset
+			return s, true
+		case p.family == "PHP":
+			if name != "fn" {
+				return Symbol{}, false
+			}
+			start--
+		case nameIndex >= 0 && tokens[nameIndex].Kind == Identifier && !p.f.Language.IsKeyword(name) &&
+			!p.isControl(name) && name != "async" && p.family != "JavaScript":
+			// An expression-bodied method in C#
+			s.Name, s.nameIndex = name, nameIndex
+			s.Start = tokens[p.declStart(nameIndex)].Offset
+			return s, true
+		}
+		if p.family == "JavaScript" && textAt(tokens, paramOpen-1) == ":" && p.isTypeAnnotation(arrow, end) {
+			// A function type in TypeScript, such as (e: Event) => void
+			return Symbol{}, false
+		}
+	} else {
+		param := arrow - 1
+		if param < 0 || tokens[param].Kind != Identifier || p.f.Language.IsKeyword(tokens[param].Text) || p.family == "PHP" {
+			return Symbol{}, false
+		}
+		if param > 0 && !lambdaStarts[tokens[param-1].Text] {
+			// A property of C#, as in int Count => items.Length
+			return Symbol{}, false
+		}
+		if p.family == "Java" && p.inCaseLabel(param) || p.family == "C#" && tokens[param].Text == "_" {
+			// A rule of a switch
+			return Symbol{}, false
+		}
+		s.ParamsClose = param
+		start = param
+	}
+
+	if textAt(tokens, start-1) == "async" {
+		start--
+	}
+	s.Start = tokens[start].Offset
+	if assigned := p.assignedName(start - 1); assigned >= 0 {
+		s.Name, s.nameIndex = nameText(tokens[assigned]), assigned
+		s.Start = tokens[p.declStart(assigned)].Offset
+	}
+	return s, true
+}
+
+// inCaseLabel reports whether the token at index i is in the label of a
+// case of a Java switch, as in case A, B -> ...
+func (p *braceParser) inCaseLabel(i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		switch tok := p.tokens[j]; {
+		case tok.Text == "case":
+			return true
+		case tok.Kind == Identifier || tok.Kind == Number || tok.Kind == String || tok.Text == "," || tok.Text == ".":
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// isTypeAnnotation reports whether the body of a TypeScript arrow from
+// index arrow to end is a single type name, as in a function type
+func (p *braceParser) isTypeAnnotation(arrow, end int) bool {
+	if end != arrow+1 || p.tokens[end].Kind != Identifier {
+		return false
+	}
+	switch textAt(p.tokens, end+1) {
+	case ";", ",", "}", ")", ">", "|", "&", "[", "<", "=":
+		return true
+	}
+	return end+1 >= len(p.tokens) || p.tokens[end+1].Line > p.tokens[end].Line
+}
+
+// assignedName returns the index of the name that an anonymous function is
+// assigned to, from the index of the token before the function, as in
+// const name = function or name: () =>, or -1
+func (p *braceParser) assignedName(i int) int {
+	tokens := p.tokens
+	if i < 1 || tokens[i].Text != "=" && tokens[i].Text != ":" {
+		return -1
+	}
+	name := i - 1
+	if tokens[i].Text == "=" && name >= 2 && tokens[name-1].Text == ":" && tokens[name-2].Kind == Identifier {
+		// A TypeScript type annotation, as in const f: Handler = ...
+		name -= 2
+	}
+	if tokens[name].Kind == String && tokens[i].Text == ":" && tokens[name].Line == tokens[name].EndLine {
+		// A quoted key of an object
+		return name
+	}
+	if tokens[name].Kind != Identifier || p.f.Language.IsKeyword(tokens[name].Text) {
+		return -1
+	}
+	return name
+}
+
+// nameText returns the name that a token gives, without quotes
+func nameText(tok Token) string {
+	if tok.Kind == String {
+		return stringValue(tok)
+	}
+	return tok.Text
+}
+
+// expressionEnd returns the index of the last token of the expression
+// body of a lambda whose arrow is at index arrow
+func (p *braceParser) expressionEnd(arrow int) int {
+	tokens := p.tokens
+	end := arrow
+	for j := arrow + 1; j < len(tokens); j++ {
+		tok := tokens[j]
+		if tok.Kind == Punct {
+			switch tok.Text {
+			case "(", "[", "{":
+				close, ok := p.matches[j]
+				if !ok {
+					return end
+				}
+				j = close
+				tok = tokens[close]
+			case ")", "]", "}", ",", ";":
+				return end
+			}
+		}
+		end = j
+
+		// A line break ends a statement in JavaScript unless an operator
+		// continues it
+		if p.family == "JavaScript" && j+1 < len(tokens) && tokens[j+1].Line > tok.EndLine &&
+			(tok.Kind != Punct || tok.Text == ")" || tok.Text == "]" || tok.Text == "}") && !continuesExpression(tokens[j+1]) {
+			return end
+		}
+	}
+	return end
+}
+
+// continuesExpression reports whether a token at the start of a line
+// continues the expression on the line above
+func continuesExpression(tok Token) bool {
+	if tok.Kind != Punct {
+		return tok.Text == "instanceof" || tok.Text == "in"
+	}
+	switch tok.Text {
+	case "(", "[", "{", "!", "~", "++", "--", ";", ")", "]", "}", ",", "@", "#":
+		return false
+	}
+	return true
+}
+
+// signatureParen looks back from the token at end, a { or an arrow, over
+// the tokens that can come between the parameters of a function and its
+// body, such as a return type, and returns the index of the ) that closes
+// the parameters, or -1
+func signatureParen(tokens []Token, end int) int {
+	for i := end - 1; i >= 0 && i >= end-16; i-- {
+		switch text := tokens[i].Text; {
+		case text == ")" && tokens[i].Kind == Punct:
+			return i
+		case tokens[i].Kind == Identifier && !controlKeywords[text]:
+		case text == ":" || text == "<" || text == ">" || text == "," || text == "." || text == "[" || text == "]" ||
+			text == "::" || text == "*" || text == "&" || text == "&&" || text == "?" || text == "|" || text == "->":
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// declStart returns the index of the first token of the declaration whose
+// keyword or name is at index i, going back over its modifiers, type,
+// annotations, attributes, and template parameters
+func (p *braceParser) declStart(i int) int {
+	tokens := p.tokens
+	start := i
+	for j := i - 1; j >= 0; j-- {
+		tok := tokens[j]
+		sameLine := tok.EndLine == tokens[start].Line
+		switch {
+		case tok.Kind == Identifier:
+			// Words on the lines above are modifiers, annotations, or the
+			// return type of a C function
+			annotation := textAt(tokens, j-1) == "@" || textAt(tokens, j-1) == "."
+			if declStops[tok.Text] || !sameLine && !declModifiers[tok.Text] && !annotation && p.family != "C/C++" {
+				return start
+			}
+
+		case tok.Text == ")":
+			// The arguments of an annotation, as in @Name(...)
+			open, ok := p.matches[j]
+			if !ok || open < 2 || tokens[open-1].Kind != Identifier {
+				return start
+			}
+			k := open - 1
+			for k >= 2 && tokens[k-1].Text == "." && tokens[k-2].Kind == Identifier {
+				k -= 2
+			}
+			if k < 1 || tokens[k-1].Text != "@" {
+				return start
+			}
+			j = k - 1
+
+		case tok.Text == "]":
+			// An attribute, as in [Test] or #[Route], or an array type
+			open, ok := p.matches[j]
+			if !ok {
+				return start
+			}
+			j = open
+			if textAt(tokens, j-1) == "#" {
+				j--
+			}
+
+		case tok.Text == ">":
+			open := p.angleOpen(j)
+			if open < 0 {
+				return start
+			}
+			j = open
+
+		case tok.Text == "@" || tok.Text == "." || tok.Text == "::" || tok.Text == "\\":
+		case (tok.Text == "*" || tok.Text == "&" || tok.Text == "?" || tok.Text == "~") && sameLine:
+		case tok.Text == "*" && p.family == "C/C++":
+		default:
+			return start
+		}
+		start = j
+	}
+	return start
+}
+
+// angleOpen returns the index of the < that matches the > at index i, or
+// -1 if the tokens between them can't be type arguments
+func (p *braceParser) angleOpen(i int) int {
+	depth := 0
+	for j := i; j >= 0 && j >= i-64; j-- {
+		switch tok := p.tokens[j]; {
+		case tok.Text == ">":
+			depth++
+		case tok.Text == "<":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		case tok.Kind == Identifier || tok.Kind == Number:
+		case strings.Contains(",.:?[]*&=()", tok.Text) || tok.Text == "::":
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// prototypes finds the declarations of functions without bodies outside
+// the bodies of functions, such as C prototypes, abstract methods, and
+// the methods of interfaces
+func (p *braceParser) prototypes() {
+	tokens := p.tokens
+	inBody := make([]int, len(tokens)+1)
+	for _, s := range p.f.Symbols {
+		if s.IsFunction() && s.BodyOpen >= 0 {
+			inBody[s.BodyOpen]++
+			inBody[s.BodyClose+1]--
+		}
+	}
+	typeBodies := make(map[int]bool)
+	for _, s := range p.f.Symbols {
+		if !s.IsFunction() {
+			typeBodies[s.BodyOpen] = true
+		}
+	}
+
+	depth := 0
+	var braces []int // Opening braces around the token
+	for j, tok := range tokens {
+		depth += inBody[j]
+		if tok.Kind == Punct && tok.Text == "{" {
+			braces = append(braces, j)
+		} else if tok.Kind == Punct && tok.Text == "}" && len(braces) > 0 {
+			braces = braces[:len(braces)-1]
+		}
+		if depth > 0 || tok.Text != ";" || tok.Kind != Punct {
+			continue
+		}
+
+		// Pure, defaulted, and deleted C++ functions
+		end := j
+		if text := textAt(tokens, j-1); (text == "0" || text == "default" || text == "delete") && textAt(tokens, j-2) == "=" {
+			end = j - 2
+		}
+		paramClose := signatureParen(tokens, p.whereStart(end))
+		if paramClose < 0 {
+			continue
+		}
+		paramOpen, ok := p.matches[paramClose]
+		if !ok || paramOpen < 1 {
+			continue
+		}
+		if operator, operatorName := p.operatorName(paramOpen); operator >= 0 {
+			// The declaration of an operator
+			s := newSymbol(KindFunction)
+			s.Name, s.nameIndex = operatorName, operator
+			s.Start = tokens[p.declStart(p.qualifiedStart(operator, &s))].Offset
+			s.End = tok.End
+			s.ParamsOpen, s.ParamsClose = paramOpen, paramClose
+			s.Prototype = true
+			p.add(s)
+			continue
+		}
+		name := p.nameBefore(paramOpen)
+		if name < 0 || tokens[name].Kind != Identifier || p.isControl(tokens[name].Text) || p.f.Language.IsKeyword(tokens[name].Text) || p.defined[name] {
+			continue
+		}
+
+		// The name follows a type, or starts a member of a TypeScript
+		// interface
+		before := textAt(tokens, name-1)
+		typed := name > 0 && (tokens[name-1].Kind == Identifier && !declStops[before] || before == "*" || before == "&" ||
+			before == ">" || before == "]" || before == "::" || before == "~" || before == "?")
+		member := p.family == "JavaScript" && (before == ";" || before == "{" || before == "}") &&
+			len(braces) > 0 && typeBodies[braces[len(braces)-1]]
+		if p.family == "PHP" {
+			// Abstract methods and the methods of interfaces
+			typed = before == "function"
+		}
+		if !typed && !member {
+			continue
+		}
+
+		s := newSymbol(KindFunction)
+		if before == "record" && p.family == "C#" {
+			// A positional record without a body
+			s.Kind = "record"
+			s.Start = tokens[p.declStart(name-1)].Offset
+			s.Name, s.nameIndex = tokens[name].Text, name
+			s.End = tok.End
+			p.add(s)
+			continue
+		}
+		s.Name, s.nameIndex = tokens[name].Text, name
+		if before == "~" {
+			s.Name = "~" + s.Name
+		}
+		s.Start = tokens[p.declStart(p.qualifiedStart(name, &s))].Offset
+		s.End = tok.End
+		s.ParamsOpen, s.ParamsClose = paramOpen, paramClose
+		s.Prototype = true
+		p.add(s)
+	}
+}
+
+// calls finds the calls of functions and methods by name, including
+// generic calls such as f<T>(x)
+func (p *braceParser) calls() {
+	tokens := p.tokens
+	for i, tok := range tokens {
+		if tok.Kind != Identifier || p.defined[i] || p.f.Language.IsKeyword(tok.Text) || p.isControl(tok.Text) {
+			continue
+		}
+		if before := textAt(tokens, i-1); before == "function" || before == "@" {
+			continue
+		}
+		next := i + 1
+		if textAt(tokens, next) == "<" {
+			if close, ok := p.angleClose(next); ok {
+				next = close + 1
+			}
+		}
+		if textAt(tokens, next) != "(" {
+			continue
+		}
+		if tok.Text == "async" && p.family == "JavaScript" {
+			// The parameters of an async arrow function
+			continue
+		}
+		p.f.addCall(i)
+	}
+}
+
+// angleClose returns the index of the > that matches the < at index i, if
+// the tokens between them can be type arguments
+func (p *braceParser) angleClose(i int) (int, bool) {
+	depth := 0
+	for j := i; j < len(p.tokens) && j <= i+64; j++ {
+		switch tok := p.tokens[j]; {
+		case tok.Text == "<":
+			depth++
+		case tok.Text == ">":
+			depth--
+			if depth == 0 {
+				return j, true
+			}
+		case tok.Kind == Identifier:
+		case tok.Text == "," || tok.Text == "." || tok.Text == "::" || tok.Text == "?" || tok.Text == "[" || tok.Text == "]" || tok.Text == "*" || tok.Text == "&":
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// imports finds the imported modules, packages, namespaces, and files, and
+// the package of a Java file
+func (p *braceParser) imports() {
+	tokens := p.tokens
+	f := p.f
+	switch p.family {
+	case "JavaScript":
+		for i, tok := range tokens {
+			switch {
+			case (tok.Text == "from" || tok.Text == "import") && textAt(tokens, i+1) != "(" && i+1 < len(tokens) && tokens[i+1].Kind == String:
+				f.Imports = append(f.Imports, stringValue(tokens[i+1]))
+			case (tok.Text == "require" || tok.Text == "import") && textAt(tokens, i+1) == "(" && i+2 < len(tokens) && tokens[i+2].Kind == String:
+				f.Imports = append(f.Imports, stringValue(tokens[i+2]))
+			}
+		}
+
+	case "Java":
+		for i, tok := range tokens {
+			if (tok.Text == "import" || tok.Text == "package") && startsStatement(tokens, i) {
+				j := i + 1
+				if textAt(tokens, j) == "static" {
+					j++
+				}
+				name := joinUntil(tokens, j, ";")
+				if tok.Text == "package" {
+					f.Package = name
+				} else if name != "" {
+					f.Imports = append(f.Imports, name)
+				}
+			}
+		}
+
+	case "C/C++":
+		for _, directive := range f.Directives {
+			text := strings.TrimSpace(strings.TrimPrefix(directive.Text, "#"))
+			for _, keyword := range []string{"include", "import"} {
+				if rest, ok := strings.CutPrefix(text, keyword); ok {
+					rest = strings.TrimSpace(rest)
+					if end := strings.IndexAny(rest[min(1, len(rest)):], `>"`); end >= 0 && (rest[0] == '<' || rest[0] == '"') {
+						f.Imports = append(f.Imports, rest[1:end+1])
+					}
+				}
+			}
+		}
+
+	case "C#":
+		for i, tok := range tokens {
+			if tok.Text != "using" || !startsStatement(tokens, i) || textAt(tokens, i+1) == "(" || textAt(tokens, i+1) == "var" {
+				continue
+			}
+			j := i + 1
+			if textAt(tokens, j) == "static" {
+				j++
+			}
+			if textAt(tokens, j+1) == "=" {
+				j += 2
+			}
+			if name := joinUntil(tokens, j, ";"); name != "" {
+				f.Imports = append(f.Imports, name)
+			}
+		}
+
+	case "PHP":
+		for i, tok := range tokens {
+			switch {
+			case tok.Text == "use" && startsStatement(tokens, i) && textAt(tokens, i-1) != ")":
+				j := i + 1
+				if textAt(tokens, j) == "function" || textAt(tokens, j) == "const" {
+					j++
+				}
+				for j < len(tokens) {
+					name := joinUntil(tokens, j, ",", ";", "as", "{")
+					if name != "" {
+						f.Imports = append(f.Imports, strings.TrimPrefix(name, `\`))
+					}
+					for j < len(tokens) && tokens[j].Text != "," && tokens[j].Text != ";" {
+						j++
+					}
+					if textAt(tokens, j) != "," {
+						break
+					}
+					j++
+				}
+			case tok.Text == "require" || tok.Text == "require_once" || tok.Text == "include" || tok.Text == "include_once":
+				j := i + 1
+				if textAt(tokens, j) == "(" {
+					j++
+				}
+				if j < len(tokens) && tokens[j].Kind == String {
+					f.Imports = append(f.Imports, stringValue(tokens[j]))
+				}
+			}
+		}
+	}
+}
+
+// startsStatement reports whether the token at index i starts a statement
+func startsStatement(tokens []Token, i int) bool {
+	switch textAt(tokens, i-1) {
+	case "", ";", "{", "}":
+		return true
+	}
+	return false
+}
+
+// joinUntil joins the texts of the tokens from index i up to one of the
+// texts of stops
+func joinUntil(tokens []Token, i int, stops ...string) string {
+	var name strings.Builder
+	for ; i < len(tokens); i++ {
+		for _, stop := range stops {
+			if tokens[i].Text == stop {
+				return name.String()
+			}
+		}
+		name.WriteString(tokens[i].Text)
+	}
+	return name.String()
+}
@@ -0,0 +1,239 @@
+package syntax
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goTokens splits Go source code into tokens with the Go scanner, leaving
+// out the semicolons that it inserts at line ends
+func goTokens(content string, lineStarts []int) []Token {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(content))
+	var s scanner.Scanner
+	s.Init(file, []byte(content), nil, scanner.ScanComments)
+
+	var tokens []Token
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		start := file.Offset(pos)
+
+		// The scanner drops carriage returns from comments and raw
+		// strings, so their ends are found in the source
+		end := start + len(lit)
+		if lit == "" {
+			end = start + len(tok.String())
+		}
+		kind, open, close := Punct, 0, 0
+		switch {
+		case tok == token.COMMENT:
+			kind, open = Comment, 2
+			if strings.HasPrefix(content[start:], "/*") {
+				end, close = len(content), 0
+				if n := strings.Index(content[start+2:], "*/"); n >= 0 {
+					end, close = start+2+n+2, 2
+				}
+			} else {
+				end = start + strings.IndexAny(content[start:]+"\n", "\r\n")
+			}
+		case tok == token.STRING || tok == token.CHAR:
+			kind, open, close = String, 1, 1
+			if content[start] == '`' {
+				end, close = len(content), 0
+				if n := strings.IndexByte(content[start+1:], '`'); n >= 0 {
+					end, close = start+1+n+1, 1
+				}
+			}
+		case tok == token.IDENT || tok.IsKeyword():
+			kind = Identifier
+		case tok.IsLiteral():
+			kind = Number
+		}
+		t := newToken(content, lineStarts, kind, start, min(end, len(content)))
+		t.open, t.close = open, close
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// parseGo parses Go source code with the Go parser, which recovers from
+// errors well enough to find the declarations around them
+func parseGo(f *File) {
+	for _, tok := range goTokens(f.content, f.lineStarts) {
+		if tok.Kind == Comment {
+			f.Comments = append(f.Comments, tok)
+		} else {
+			f.Tokens = append(f.Tokens, tok)
+		}
+	}
+
+	fset := token.NewFileSet()
+	file, _ := parser.ParseFile(fset, "", f.content, parser.SkipObjectResolution)
+	if file == nil {
+		return
+	}
+	tokenFile := fset.File(file.Pos())
+	offset := func(pos token.Pos) int {
+		if !pos.IsValid() {
+			return -1
+		}
+		return tokenFile.Offset(pos)
+	}
+	index := func(pos token.Pos) int {
+		return f.tokenAt(offset(pos))
+	}
+
+	if file.Name != nil {
+		f.Package = file.Name.Name
+	}
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			f.Imports = append(f.Imports, path)
+		}
+	}
+
+	// function makes the symbol of a function with a name, or an anonymous
+	// one with nil, from start to end
+	function := func(name *ast.Ident, typ *ast.FuncType, body *ast.BlockStmt, start, end token.Pos) Symbol {
+		s := Symbol{
+			Kind:        KindFunction,
+			Start:       offset(start),
+			End:         offset(end),
+			ParamsOpen:  -1,
+			ParamsClose: -1,
+			BodyOpen:    -1,
+			BodyClose:   -1,
+			Prototype:   body == nil,
+			nameIndex:   -1,
+		}
+		if name != nil {
+			s.Name = name.Name
+			s.nameIndex = index(name.Pos())
+		}
+		if typ.Params != nil {
+			s.ParamsOpen, s.ParamsClose = index(typ.Params.Opening), index(typ.Params.Closing)
+		}
+		if body != nil {
+			s.BodyOpen, s.BodyClose = index(body.Lbrace), index(body.Rbrace)
+		}
+		return s
+	}
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			s := function(n.Name, n.Type, n.Body, n.Pos(), n.End())
+			if n.Recv != nil && len(n.Recv.List) > 0 {
+				s.Kind = KindMethod
+				s.Container = receiverType(n.Recv.List[0].Type)
+			}
+			f.Symbols = append(f.Symbols, s)
+
+		case *ast.FuncLit:
+			f.Symbols = append(f.Symbols, function(nil, n.Type, n.Body, n.Pos(), n.End()))
+
+		case *ast.GenDecl:
+			if n.Tok != token.TYPE {
+				break
+			}
+			for _, spec := range n.Specs {
+				spec := spec.(*ast.TypeSpec)
+				s := Symbol{
+					Name:        spec.Name.Name,
+					Kind:        "type",
+					Start:       offset(spec.Pos()),
+					End:         offset(spec.End()),
+					ParamsOpen:  -1,
+					ParamsClose: -1,
+					BodyOpen:    -1,
+					BodyClose:   -1,
+					nameIndex:   index(spec.Name.Pos()),
+				}
+				if !n.Lparen.IsValid() {
+					s.Start = offset(n.Pos())
+				}
+				switch t := spec.Type.(type) {
+				case *ast.StructType:
+					s.Kind = "struct"
+					s.BodyOpen, s.BodyClose = index(t.Fields.Opening), index(t.Fields.Closing)
+				case *ast.InterfaceType:
+					s.Kind = "interface"
+					s.BodyOpen, s.BodyClose = index(t.Methods.Opening), index(t.Methods.Closing)
+					for _, method := range t.Methods.List {
+						if typ, ok := method.Type.(*ast.FuncType); ok && len(method.Names) > 0 {
+							f.Symbols = append(f.Symbols, function(method.Names[0], typ, nil, method.Pos(), method.End()))
+						}
+					}
+				}
+				f.Symbols = append(f.Symbols, s)
+			}
+
+		case *ast.CallExpr:
+			fun := n.Fun
+			for {
+				if index, ok := fun.(*ast.IndexExpr); ok {
+					fun = index.X
+				} else if index, ok := fun.(*ast.IndexListExpr); ok {
+					fun = index.X
+				} else {
+					break
+				}
+			}
+			var name *ast.Ident
+			switch fun := fun.(type) {
+			case *ast.Ident:
+				name = fun
+			case *ast.SelectorExpr:
+				name = fun.Sel
+			}
+			if name != nil {
+				if i := index(name.Pos()); i >= 0 {
+					f.addCall(i)
+				}
+			}
+		}
+		return true
+	})
+}
+
+// receiverType returns the name of the type of a method receiver, without
+// its pointer and type parameters
+func receiverType(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// tokenAt returns the index in f.Tokens of the token that starts at an
+// offset, or -1
+func (f *File) tokenAt(offset int) int {
+	i := sort.Search(len(f.Tokens), func(i int) bool { return f.Tokens[i].Offset >= offset })
+	if offset < 0 || i == len(f.Tokens) || f.Tokens[i].Offset != offset {
+		return -1
+	}
+	return i
+}
@@ -0,0 +1,170 @@
+package syntax
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Language is a programming language that the package parses
+type Language struct {
+	Name       string   // Such as "TypeScript" or "C++"
+	Family     string   // Name of the languages parsed alike, such as "JavaScript" for TypeScript or "C/C++" for C
+	Extensions []string // File extensions, with the dot
+	keywords   map[string]bool
+}
+
+// languageAliases are other names that the languages go by
+var languageAliases = map[string]string{
+	"golang": "Go", "js": "JavaScript", "ts": "TypeScript", "py": "Python",
+	"c++": "C++", "cpp": "C++", "cs": "C#", "csharp": "C#", "rb": "Ruby",
+}
+
+// jsKeywords are the reserved words of JavaScript, which TypeScript shares
+const jsKeywords = `await break case catch class const continue debugger default delete do else export extends
+	false finally for function if import in instanceof let new null return super switch this throw true try
+	typeof var void while with yield`
+
+// cKeywords are the keywords of C, which C++ shares
+const cKeywords = `auto break case char const continue default do double else enum extern float for goto if
+	inline int long register restrict return short signed sizeof static struct switch typedef union unsigned
+	void volatile while _Bool`
+
+// languages are the languages that the package parses
+var languages = []Language{
+	{
+		Name:       "Go",
+		Family:     "Go",
+		Extensions: []string{".go"},
+		keywords: keywordSet(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var`),
+	},
+	{
+		Name:       "JavaScript",
+		Family:     "JavaScript",
+		Extensions: []string{".js", ".jsx", ".mjs", ".cjs"},
+		keywords:   keywordSet(jsKeywords),
+	},
+	{
+		Name:       "TypeScript",
+		Family:     "JavaScript",
+		Extensions: []string{".ts", ".tsx", ".mts", ".cts"},
+		keywords: keywordSet(jsKeywords + ` abstract any boolean declare enum implements interface keyof never
+			number private protected public readonly static string symbol unknown`),
+	},
+	{
+		Name:       "Python",
+		Family:     "Python",
+		Extensions: []string{".py", ".pyi"},
+		keywords: keywordSet(`False None True and as assert async await break class continue def del elif else
+			except finally for from global if import in is lambda nonlocal not or pass raise return try while with
+			yield`),
+	},
+	{
+		Name:       "Java",
+		Family:     "Java",
+		Extensions: []string{".java"},
+		keywords: keywordSet(`abstract assert boolean break byte case catch char class const continue default do
+			double else enum extends false final finally float for goto if implements import instanceof int
+			interface long native new null package private protected public return short static strictfp super
+			switch synchronized this throw throws transient true try var void volatile while`),
+	},
+	{
+		Name:       "C",
+		Family:     "C/C++",
+		Extensions: []string{".c", ".h"},
+		keywords:   keywordSet(cKeywords),
+	},
+	{
+		Name:       "C++",
+		Family:     "C/C++",
+		Extensions: []string{".cpp", ".cc", ".cxx", ".hpp", ".hh", ".hxx"},
+		keywords: keywordSet(cKeywords + ` alignas alignof and asm bool catch char8_t char16_t char32_t class
+			co_await co_return co_yield concept consteval constexpr constinit const_cast decltype delete
+			dynamic_cast explicit export false friend mutable namespace new noexcept not nullptr operator or
+			private protected public reinterpret_cast requires static_assert static_cast template this
+			thread_local throw true try typeid typename using virtual wchar_t xor`),
+	},
+	{
+		Name:       "C#",
+		Family:     "C#",
+		Extensions: []string{".cs"},
+		keywords: keywordSet(`abstract as async await base bool break byte case catch char checked class const
+			continue decimal default delegate do double else enum event explicit extern false finally fixed float
+			for foreach goto if implicit in int interface internal is lock long namespace new null object operator
+			out override params private protected public readonly ref return sbyte sealed short sizeof stackalloc
+			static string struct switch this throw true try typeof uint ulong unchecked unsafe ushort using var
+			virtual void volatile while`),
+	},
+	{
+		Name:       "Ruby",
+		Family:     "Ruby",
+		Extensions: []string{".rb"},
+		keywords: keywordSet(`BEGIN END __FILE__ __LINE__ alias and begin break case class def defined? do else
+			elsif end ensure false for if in module next nil not or redo rescue retry return self super then true
+			undef unless until when while yield`),
+	},
+	{
+		Name:       "PHP",
+		Family:     "PHP",
+		Extensions: []string{".php"},
+		keywords: keywordSet(`abstract and array as break callable case catch class clone const continue declare
+			default do echo else elseif empty enddeclare endfor endforeach endif endswitch endwhile enum extends
+			false final finally fn for foreach function global goto if implements include include_once instanceof
+			insteadof interface isset list match namespace new null or parent print private protected public
+			readonly require require_once return self static switch throw trait true try unset use var while xor
+			yield`),
+	},
+}
+
+// keywordSet makes a set of the words of a list
+func keywordSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
+
+// Languages returns the languages that the package parses
+func Languages() []Language {
+	return append([]Language(nil), languages...)
+}
+
+// LanguageByName returns a language by its name, an alias such as "cpp",
+// or the name of its family, which returns the first language of the
+// family
+func LanguageByName(name string) (Language, bool) {
+	for _, language := range languages {
+		if language.Is(name) {
+			return language, true
+		}
+	}
+	return Language{}, false
+}
+
+// LanguageForFile returns the language of a file by its extension
+func LanguageForFile(path string) (Language, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, language := range languages {
+		for _, languageExt := range language.Extensions {
+			if ext == languageExt {
+				return language, true
+			}
+		}
+	}
+	return Language{}, false
+}
+
+// Is reports whether name, compared without case, is the name of the
+// language, one of its aliases, or the name of its family
+func (l Language) Is(name string) bool {
+	if alias, exists := languageAliases[strings.ToLower(name)]; exists {
+		name = alias
+	}
+	return strings.EqualFold(name, l.Name) || strings.EqualFold(name, l.Family)
+}
+
+// IsKeyword reports whether a word is a reserved word of the language
+func (l Language) IsKeyword(word string) bool {
+	return l.keywords[word]
+}
@@ -0,0 +1,665 @@
+package syntax
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// operators are the operators of more than one character, longest first.
+// >> is left out so that the ends of nested generic types stay apart.
+var operators = []string{
+	"<<=", "...", "===", "!==", "**=", "??=", "&&=", "||=", "<=>",
+	"::", "->", "=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<", "**", "..",
+}
+
+// regexKeywords are the keywords after which a / starts a regular
+// expression rather than dividing
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true, "in": true, "of": true,
+	"instanceof": true, "new": true, "delete": true, "void": true, "throw": true, "yield": true,
+	"await": true, "if": true, "unless": true, "when": true, "while": true, "until": true,
+	"and": true, "or": true, "not": true, "then": true,
+}
+
+// lexer splits the source code of a language other than Go into tokens
+type lexer struct {
+	src        string
+	family     string
+	lineStarts []int
+	tokens     []Token
+	last       int       // Index of the last code token, or -1
+	heredocs   []heredoc // Ruby heredocs whose bodies start on the next line
+}
+
+// heredoc is a Ruby heredoc whose body hasn't been read yet
+type heredoc struct {
+	id       string
+	indented bool // Whether the terminator may be indented, as in <<~ and <<-
+}
+
+// tokenize splits the source code of a language other than Go into
+// tokens, with its comments and directives
+func tokenize(content string, language Language, lineStarts []int) []Token {
+	l := &lexer{src: content, family: language.Family, lineStarts: lineStarts, last: -1}
+	i := 0
+	if l.family == "PHP" {
+		i = l.skipHTML(0)
+	}
+	for i < len(l.src) {
+		switch c := l.src[i]; {
+		case c == '\n':
+			i++
+			if len(l.heredocs) > 0 {
+				i = l.heredocBodies(i)
+			}
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		default:
+			i = l.next(i)
+		}
+	}
+	return l.tokens
+}
+
+// emit adds a token from start to end, whose delimiters are open and close
+// bytes long
+func (l *lexer) emit(kind Kind, start, end, open, close int) int {
+	end = min(end, len(l.src))
+	tok := newToken(l.src, l.lineStarts, kind, start, end)
+	tok.open, tok.close = open, close
+	l.tokens = append(l.tokens, tok)
+	if kind != Comment && kind != Directive {
+		l.last = len(l.tokens) - 1
+	}
+	return end
+}
+
+// at returns the byte at i, or 0 past the end
+func (l *lexer) at(i int) byte {
+	if i < 0 || i >= len(l.src) {
+		return 0
+	}
+	return l.src[i]
+}
+
+// atLineStart reports whether only spaces come before i on its line
+func (l *lexer) atLineStart(i int) bool {
+	for j := i - 1; j >= 0 && l.src[j] != '\n'; j-- {
+		if l.src[j] != ' ' && l.src[j] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// next reads the token that starts at i and returns the index after it
+func (l *lexer) next(i int) int {
+	src := l.src
+	c := src[i]
+	switch {
+	case l.family == "PHP" && strings.HasPrefix(src[i:], "?>"):
+		return l.skipHTML(i + 2)
+
+	case l.lineComment(i) > 0:
+		end := i
+		for end < len(src) && src[end] != '\n' && !(l.family == "PHP" && strings.HasPrefix(src[end:], "?>")) {
+			end++
+		}
+		for end > i && src[end-1] == '\r' {
+			end--
+		}
+		return l.emit(Comment, i, end, l.lineComment(i), 0)
+
+	case c == '/' && l.at(i+1) == '*' && l.family != "Python" && l.family != "Ruby":
+		open := 2
+		if strings.HasPrefix(src[i:], "/**") && !strings.HasPrefix(src[i:], "/**/") {
+			open = 3
+		}
+		end := strings.Index(src[i+2:], "*/")
+		if end < 0 {
+			return l.emit(Comment, i, len(src), open, 0)
+		}
+		return l.emit(Comment, i, i+2+end+2, open, 2)
+
+	case l.family == "Ruby" && strings.HasPrefix(src[i:], "=begin") && l.atLineStart(i):
+		// An embedded document, up to a line that starts with =end
+		end := strings.Index(src[i:], "\n=end")
+		if end < 0 {
+			return l.emit(Comment, i, len(src), len("=begin"), 0)
+		}
+		closeStart := i + end + 1
+		lineEnd := strings.IndexByte(src[closeStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(src) - closeStart
+		}
+		return l.emit(Comment, i, closeStart+lineEnd, len("=begin"), lineEnd)
+
+	case c == '#' && (l.family == "C/C++" || l.family == "C#") && l.atLineStart(i):
+		// A preprocessor line, continued by trailing backslashes
+		end := i
+		for end < len(src) && src[end] != '\n' {
+			if src[end] == '\\' && (l.at(end+1) == '\n' || l.at(end+1) == '\r' && l.at(end+2) == '\n') {
+				end = strings.IndexByte(src[end:], '\n') + end + 1
+				continue
+			}
+			if strings.HasPrefix(src[end:], "//") || strings.HasPrefix(src[end:], "/*") {
+				break
+			}
+			end++
+		}
+		for end > i && (src[end-1] == ' ' || src[end-1] == '\t' || src[end-1] == '\r') {
+			end--
+		}
+		return l.emit(Directive, i, end, 0, 0)
+
+	case c == '"' || c == '\'' || c == '`' && (l.family == "JavaScript" || l.family == "Ruby" || l.family == "PHP"):
+		return l.quoted(i, 0)
+
+	case l.family == "C#" && (c == '@' || c == '$'):
+		// Verbatim, interpolated, and raw string prefixes, or a verbatim
+		// identifier such as @class
+		n := 0
+		for l.at(i+n) == '@' || l.at(i+n) == '$' {
+			n++
+		}
+		if l.at(i+n) == '"' {
+			return l.quoted(i, n)
+		}
+		if c == '@' && isIdentStart(l.runeAt(i+1)) {
+			return l.identifier(i, i+1)
+		}
+
+	case l.family == "PHP" && c == '<' && strings.HasPrefix(src[i:], "<<<"):
+		if end := l.phpHeredoc(i); end > i {
+			return end
+		}
+
+	case l.family == "Ruby" && c == '<' && strings.HasPrefix(src[i:], "<<"):
+		if end := l.rubyHeredoc(i); end > i {
+			return end
+		}
+
+	case l.family == "Ruby" && c == '%':
+		if end := l.percentLiteral(i); end > i {
+			return end
+		}
+
+	case c == '/' && (l.family == "JavaScript" || l.family == "Ruby") && l.regexAllowed(i):
+		return l.regex(i)
+
+	case c >= '0' && c <= '9' || c == '.' && l.at(i+1) >= '0' && l.at(i+1) <= '9':
+		return l.number(i)
+	}
+
+	if r := l.runeAt(i); isIdentStart(r) || l.identPrefix(i) {
+		return l.identifier(i, i)
+	}
+	return l.punct(i)
+}
+
+// lineComment returns the length of the marker of a line comment that
+// starts at i, with the extra / or ! of a doc comment, or 0
+func (l *lexer) lineComment(i int) int {
+	switch c := l.src[i]; {
+	case c == '/' && l.at(i+1) == '/' && l.family != "Python" && l.family != "Ruby":
+		if l.at(i+2) == '/' || l.at(i+2) == '!' {
+			return 3
+		}
+		return 2
+	case c == '#' && (l.family == "Python" || l.family == "Ruby"):
+		return 1
+	case c == '#' && l.family == "PHP" && l.at(i+1) != '[':
+		return 1
+	}
+	return 0
+}
+
+// runeAt returns the rune at i, or utf8.RuneError past the end
+func (l *lexer) runeAt(i int) rune {
+	if i >= len(l.src) {
+		return utf8.RuneError
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[i:])
+	return r
+}
+
+// isIdentStart reports whether an identifier can start with a rune
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// identPrefix reports whether the byte at i is a sigil that starts an
+// identifier in the language, such as the $ of a PHP variable or the @ of
+// a Ruby instance variable
+func (l *lexer) identPrefix(i int) bool {
+	switch l.src[i] {
+	case '$':
+		switch l.family {
+		case "JavaScript", "Java":
+			return true
+		case "PHP", "Ruby":
+			return isIdentStart(l.runeAt(i + 1))
+		}
+	case '@':
+		if l.family == "Ruby" {
+			j := i + 1
+			if l.at(j) == '@' {
+				j++
+			}
+			return isIdentStart(l.runeAt(j))
+		}
+	}
+	return false
+}
+
+// identifier reads an identifier that starts at i, whose name starts at
+// from after any sigils, or a prefixed string such as r"..." or u8"..."
+func (l *lexer) identifier(i, from int) int {
+	j := from
+	for j < len(l.src) && (l.src[j] == '$' || l.src[j] == '@') {
+		j++
+	}
+	for j < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[j:])
+		if !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || r == '$' && (l.family == "JavaScript" || l.family == "Java")) {
+			break
+		}
+		j += size
+	}
+
+	if q := l.at(j); q == '"' || q == '\'' {
+		if l.isStringPrefix(l.src[i:j], q) {
+			return l.quoted(i, j-i)
+		}
+	}
+	if l.family == "Ruby" && (l.at(j) == '?' || l.at(j) == '!') && l.at(j+1) != '=' && l.at(j+1) != ':' {
+		// A predicate or bang method, such as empty? or save!
+		j++
+	}
+	return l.emit(Identifier, i, j, 0, 0)
+}
+
+// isStringPrefix reports whether a word before a quote is the prefix of a
+// string literal, such as the f of a Python f-string
+func (l *lexer) isStringPrefix(word string, quote byte) bool {
+	switch l.family {
+	case "Python":
+		switch strings.ToLower(word) {
+		case "r", "u", "b", "f", "br", "rb", "fr", "rf":
+			return true
+		}
+	case "C/C++":
+		switch word {
+		case "L", "u", "U", "u8":
+			return true
+		case "R", "LR", "uR", "UR", "u8R":
+			return quote == '"'
+		}
+	}
+	return false
+}
+
+// quoted reads a string or character literal that starts at i, whose
+// quote follows a prefix of prefix bytes
+func (l *lexer) quoted(i, prefix int) int {
+	end, open, close := l.stringEnd(i, prefix)
+	return l.emit(String, i, end, open, close)
+}
+
+// stringEnd returns the end of a string or character literal that starts
+// at i, whose quote follows a prefix of prefix bytes, and the lengths of
+// its delimiters, with the prefix
+func (l *lexer) stringEnd(i, prefix int) (end, open, close int) {
+	src := l.src
+	q := i + prefix
+	quote := src[q]
+	prefixText := src[i:q]
+
+	// Triple-quoted strings, Java text blocks, and C# raw strings
+	triple := quote != '`' && strings.HasPrefix(src[q:], strings.Repeat(string(quote), 3)) &&
+		(l.family == "Python" || quote == '"' && (l.family == "Java" || l.family == "C#"))
+	if triple {
+		n := 3
+		for l.family == "C#" && l.at(q+n) == '"' {
+			n++
+		}
+		delimiter := src[q : q+n]
+		escapes := l.family != "C#"
+		for j := q + n; j < len(src); j++ {
+			if escapes && src[j] == '\\' {
+				j++
+				continue
+			}
+			if strings.HasPrefix(src[j:], delimiter) {
+				return j + n, prefix + n, n
+			}
+		}
+		return len(src), prefix + n, 0
+	}
+
+	// C++ raw strings, R"delimiter(...)delimiter"
+	if l.family == "C/C++" && strings.HasSuffix(prefixText, "R") {
+		paren := strings.IndexByte(src[q+1:], '(')
+		if paren >= 0 && paren <= 16 && !strings.ContainsAny(src[q+1:q+1+paren], " \\)\n") {
+			delimiter := ")" + src[q+1:q+1+paren] + `"`
+			start := q + 2 + paren
+			end := strings.Index(src[start:], delimiter)
+			if end < 0 {
+				return len(src), start - i, 0
+			}
+			return start + end + len(delimiter), start - i, len(delimiter)
+		}
+	}
+
+	verbatim := l.family == "C#" && strings.Contains(prefixText, "@")
+	multiline := quote == '`' || verbatim || l.family == "Ruby" || l.family == "PHP"
+	interpolates := quote == '`' && l.family == "JavaScript" || quote != '\'' && l.family == "Ruby"
+	for j := q + 1; j < len(src); j++ {
+		switch c := src[j]; {
+		case c == '\\' && !verbatim:
+			j++
+		case c == quote:
+			if verbatim && l.at(j+1) == '"' {
+				j++
+				continue
+			}
+			return j + 1, prefix + 1, 1
+		case c == '\n' && !multiline:
+			if j > q+1 && src[j-1] == '\r' {
+				j--
+			}
+			return j, prefix + 1, 0
+		case interpolates && (c == '$' || c == '#') && l.at(j+1) == '{' && (c == '$') == (l.family == "JavaScript"):
+			j = l.skipInterpolation(j+2) - 1
+		}
+	}
+	return len(src), prefix + 1, 0
+}
+
+// skipInterpolation returns the index after the } that closes an
+// interpolation whose code starts at i
+func (l *lexer) skipInterpolation(i int) int {
+	depth := 1
+	for i < len(l.src) {
+		switch l.src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '\'', '`':
+			i, _, _ = l.stringEnd(i, 0)
+			continue
+		}
+		i++
+	}
+	return i
+}
+
+// number reads a numeric literal that starts at i
+func (l *lexer) number(i int) int {
+	src := l.src
+	hex := strings.HasPrefix(src[i:], "0x") || strings.HasPrefix(src[i:], "0X")
+	j := i + 1
+	for j < len(src) {
+		c := src[j]
+		switch {
+		case c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_':
+		case c == '.' && l.at(j+1) >= '0' && l.at(j+1) <= '9':
+		case (c == '+' || c == '-') && (!hex && (src[j-1] == 'e' || src[j-1] == 'E') || src[j-1] == 'p' || src[j-1] == 'P'):
+		case c == '\'' && l.family == "C/C++" && isIdentByte(l.at(j+1)):
+			// A digit separator
+		default:
+			return l.emit(Number, i, j, 0, 0)
+		}
+		j++
+	}
+	return l.emit(Number, i, j, 0, 0)
+}
+
+// punct reads an operator or a punctuation mark
+func (l *lexer) punct(i int) int {
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[i:], op) {
+			if strings.HasPrefix(op, "**") && (l.family == "C/C++" || l.family == "C#" || l.family == "Java") {
+				// Pointers to pointers rather than exponentiation
+				continue
+			}
+			return l.emit(Punct, i, i+len(op), 0, 0)
+		}
+	}
+	_, size := utf8.DecodeRuneInString(l.src[i:])
+	return l.emit(Punct, i, i+size, 0, 0)
+}
+
+// regexAllowed reports whether a / at i starts a regular expression rather
+// than dividing, from the token before it
+func (l *lexer) regexAllowed(i int) bool {
+	if l.last < 0 {
+		return true
+	}
+	prev := l.tokens[l.last]
+	switch prev.Kind {
+	case String, Number:
+		return false
+	case Punct:
+		return prev.Text != ")" && prev.Text != "]" && prev.Text != "}"
+	}
+	if regexKeywords[prev.Text] {
+		return true
+	}
+	// In Ruby, a method called with a regular expression argument, as in
+	// split /,/
+	return l.family == "Ruby" && prev.End < i && l.at(i+1) != ' ' && l.at(i+1) != '='
+}
+
+// regex reads a regular expression literal that starts at i, with its flags
+func (l *lexer) regex(i int) int {
+	src := l.src
+	inClass := false
+	j := i + 1
+	for ; j < len(src) && src[j] != '\n'; j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				end := j + 1
+				for end < len(src) && (src[end] >= 'a' && src[end] <= 'z') {
+					end++
+				}
+				return l.emit(String, i, end, 1, end-j)
+			}
+		}
+	}
+	return l.emit(String, i, j, 1, 0)
+}
+
+// percentLiteral reads a Ruby % literal that starts at i, such as %w[a b]
+// or %q(text), and returns i if there is none
+func (l *lexer) percentLiteral(i int) int {
+	j := i + 1
+	if strings.IndexByte("qQwWiIrsx", l.at(j)) >= 0 {
+		j++
+	}
+	open := l.at(j)
+	if open == 0 || open == ' ' || open == '\n' || open == '=' || unicode.IsLetter(rune(open)) || unicode.IsDigit(rune(open)) {
+		return i
+	}
+	if !l.regexAllowed(i) && (j == i+1 || l.last >= 0 && l.tokens[l.last].End == i) {
+		// The modulo operator
+		return i
+	}
+	close := open
+	switch open {
+	case '(':
+		close = ')'
+	case '[':
+		close = ']'
+	case '{':
+		close = '}'
+	case '<':
+		close = '>'
+	}
+	depth := 1
+	for k := j + 1; k < len(l.src); k++ {
+		switch c := l.src[k]; {
+		case c == '\\':
+			k++
+		case c == close:
+			depth--
+			if depth == 0 {
+				return l.emit(String, i, k+1, j+1-i, 1)
+			}
+		case c == open && open != close:
+			depth++
+		}
+	}
+	return l.emit(String, i, len(l.src), j+1-i, 0)
+}
+
+// rubyHeredoc reads the start of a Ruby heredoc at i, such as <<~SQL,
+// whose body is read at the end of the line, and returns i if there is
+// none
+func (l *lexer) rubyHeredoc(i int) int {
+	j := i + 2
+	indented := l.at(j) == '~' || l.at(j) == '-'
+	if indented {
+		j++
+	}
+	quote := l.at(j)
+	var id string
+	end := j
+	if quote == '\'' || quote == '"' || quote == '`' {
+		k := strings.IndexByte(l.src[j+1:], quote)
+		if k < 0 || strings.Contains(l.src[j+1:j+1+k], "\n") {
+			return i
+		}
+		id = l.src[j+1 : j+1+k]
+		end = j + k + 2
+	} else {
+		for end < len(l.src) && (l.src[end] == '_' || unicode.IsLetter(rune(l.src[end])) || unicode.IsDigit(rune(l.src[end]))) {
+			end++
+		}
+		id = l.src[j:end]
+		if id == "" || !indented && !(id[0] >= 'A' && id[0] <= 'Z' || id[0] == '_') {
+			return i
+		}
+	}
+	if id == "" || !indented && !l.regexAllowed(i) && !(l.last >= 0 && l.tokens[l.last].Kind == Identifier && l.tokens[l.last].End < i) {
+		// A shift or an append, as in list << item
+		return i
+	}
+	l.heredocs = append(l.heredocs, heredoc{id: id, indented: indented})
+	return l.emit(String, i, end, end-i, 0)
+}
+
+// heredocBodies reads the bodies of the pending Ruby heredocs from the
+// start of the line at i, and returns the index of the end of the line of
+// the last terminator
+func (l *lexer) heredocBodies(i int) int {
+	for _, doc := range l.heredocs {
+		start := i
+		for i < len(l.src) {
+			lineEnd := strings.IndexByte(l.src[i:], '\n')
+			if lineEnd < 0 {
+				lineEnd = len(l.src)
+			} else {
+				lineEnd += i
+			}
+			line := strings.TrimRight(l.src[i:lineEnd], "\r")
+			if doc.indented {
+				line = strings.TrimLeft(line, " \t")
+			}
+			if line == doc.id {
+				end := i + len(strings.TrimRight(l.src[i:lineEnd], "\r"))
+				l.emit(String, start, end, 0, end-i)
+				i = lineEnd
+				break
+			}
+			i = lineEnd + 1
+		}
+		if i >= len(l.src) {
+			l.emit(String, start, len(l.src), 0, 0)
+			i = len(l.src)
+			break
+		}
+	}
+	l.heredocs = nil
+	return i
+}
+
+// phpHeredoc reads a PHP heredoc or nowdoc that starts at i, such as
+// <<<EOT, and returns i if there is none
+func (l *lexer) phpHeredoc(i int) int {
+	j := i + 3
+	for l.at(j) == ' ' || l.at(j) == '\t' {
+		j++
+	}
+	quote := l.at(j)
+	if quote == '\'' || quote == '"' {
+		j++
+	}
+	idStart := j
+	for j < len(l.src) && (l.src[j] == '_' || unicode.IsLetter(rune(l.src[j])) || unicode.IsDigit(rune(l.src[j]))) {
+		j++
+	}
+	id := l.src[idStart:j]
+	if quote == '\'' || quote == '"' {
+		if l.at(j) != quote {
+			return i
+		}
+		j++
+	}
+	lineEnd := strings.IndexByte(l.src[j:], '\n')
+	if id == "" || lineEnd < 0 || strings.TrimSpace(l.src[j:j+lineEnd]) != "" {
+		return i
+	}
+	bodyStart := j + lineEnd + 1
+
+	// The terminator is the identifier alone at the start of a line, after
+	// any indentation
+	for k := bodyStart; k < len(l.src); {
+		text := strings.TrimLeft(l.src[k:], " \t")
+		indent := len(l.src[k:]) - len(text)
+		if strings.HasPrefix(text, id) && !isIdentByte(l.at(k+indent+len(id))) {
+			end := k + indent + len(id)
+			return l.emit(String, i, end, bodyStart-i, end-k)
+		}
+		next := strings.IndexByte(l.src[k:], '\n')
+		if next < 0 {
+			break
+		}
+		k += next + 1
+	}
+	return l.emit(String, i, len(l.src), bodyStart-i, 0)
+}
+
+// isIdentByte reports whether a byte can be part of an ASCII identifier
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// skipHTML returns the index after the next PHP opening tag from i, or
+// the end of the file
+func (l *lexer) skipHTML(i int) int {
+	open := strings.Index(l.src[i:], "<?")
+	if open < 0 {
+		return len(l.src)
+	}
+	i += open + 2
+	if strings.HasPrefix(l.src[i:], "php") {
+		i += 3
+	} else if l.at(i) == '=' {
+		i++
+	}
+	return i
+}
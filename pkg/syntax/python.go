@@ -0,0 +1,151 @@
+package syntax
+
+import "strings"
+
+// pythonLine is a logical line of Python, joined across brackets and
+// backslashes
+type pythonLine struct {
+	first, last int // Indexes of its first and last tokens
+	indent      int // Width of the indentation, with tabs to multiples of 8
+}
+
+// parsePython parses the tokens of Python source code
+func parsePython(f *File) {
+	tokens := f.Tokens
+	matches := matchBrackets(tokens)
+	lines := pythonLines(f)
+	defined := make(map[int]bool)
+
+	for n, line := range lines {
+		k := line.first
+		if tokens[k].Text == "async" {
+			k++
+		}
+		if k+1 > line.last || (tokens[k].Text != "def" && tokens[k].Text != "class") || tokens[k+1].Kind != Identifier {
+			continue
+		}
+
+		// The colon that starts the body, after the parameters, the base
+		// classes, or the return annotation
+		colon := -1
+		for j := k + 2; j <= line.last && colon < 0; j++ {
+			switch {
+			case tokens[j].Kind != Punct:
+			case tokens[j].Text == ":":
+				colon = j
+			case tokens[j].Text == "(" || tokens[j].Text == "[":
+				if close, ok := matches[j]; ok {
+					j = close
+				}
+			}
+		}
+		if colon < 0 {
+			continue
+		}
+
+		s := newSymbol(KindFunction)
+		if tokens[k].Text == "class" {
+			s.Kind = "class"
+		}
+		s.Name, s.nameIndex = tokens[k+1].Text, k+1
+		defined[k+1] = true
+		if textAt(tokens, k+2) == "(" {
+			if close, ok := matches[k+2]; ok {
+				s.ParamsOpen, s.ParamsClose = k+2, close
+			}
+		}
+
+		// The body is the rest of the line, or the lines indented under it
+		s.BodyOpen, s.BodyClose = colon, line.last
+		if colon == line.last {
+			for _, next := range lines[n+1:] {
+				if next.indent <= line.indent {
+					break
+				}
+				s.BodyClose = next.last
+			}
+		}
+		s.End = tokens[s.BodyClose].End
+
+		// The decorators above
+		start := line.first
+		for d := n - 1; d >= 0 && lines[d].indent == line.indent && tokens[lines[d].first].Text == "@"; d-- {
+			start = lines[d].first
+		}
+		s.Start = tokens[start].Offset
+		f.Symbols = append(f.Symbols, s)
+	}
+
+	for i, tok := range tokens {
+		if tok.Kind == Identifier && !defined[i] && !f.Language.IsKeyword(tok.Text) && textAt(tokens, i+1) == "(" {
+			f.addCall(i)
+		}
+	}
+
+	for _, line := range lines {
+		switch tokens[line.first].Text {
+		case "import":
+			// import a.b as c, d
+			name := ""
+			for j := line.first + 1; j <= line.last+1; j++ {
+				if j > line.last || tokens[j].Text == "," {
+					if name != "" {
+						f.Imports = append(f.Imports, name)
+					}
+					name = ""
+				} else if tokens[j].Text == "as" {
+					f.Imports = append(f.Imports, name)
+					name = ""
+					for j <= line.last && tokens[j].Text != "," {
+						j++
+					}
+				} else if tokens[j].Kind == Identifier || tokens[j].Text == "." {
+					name += tokens[j].Text
+				}
+			}
+		case "from":
+			// from .a.b import c
+			var name strings.Builder
+			for j := line.first + 1; j <= line.last && tokens[j].Text != "import"; j++ {
+				name.WriteString(tokens[j].Text)
+			}
+			if name.Len() > 0 {
+				f.Imports = append(f.Imports, name.String())
+			}
+		}
+	}
+}
+
+// pythonLines splits the tokens of Python source code into logical lines
+func pythonLines(f *File) []pythonLine {
+	tokens := f.Tokens
+	var lines []pythonLine
+	depth := 0
+	for i, tok := range tokens {
+		continued := i > 0 && (depth > 0 || tokens[i-1].Text == `\` && tokens[i-1].Kind == Punct)
+		if !continued && startsLine(tokens, i) || i == 0 {
+			indent := 0
+			for _, c := range f.Line(tok.Line) {
+				if c == '\t' {
+					indent += 8 - indent%8
+				} else if c == ' ' {
+					indent++
+				} else {
+					break
+				}
+			}
+			lines = append(lines, pythonLine{first: i, indent: indent})
+		}
+		lines[len(lines)-1].last = i
+
+		if tok.Kind == Punct {
+			switch tok.Text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth = max(0, depth-1)
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,264 @@
+package syntax
+
+// rubyVisibility are the method calls that can come before a def on its
+// line
+var rubyVisibility = map[string]bool{
+	"private": true, "protected": true, "public": true, "module_function": true,
+	"private_class_method": true, "public_class_method": true,
+}
+
+// rubyNonValues are the keywords after which if, unless, while, and until
+// start a block rather than modify the statement before them
+var rubyNonValues = map[string]bool{
+	"then": true, "else": true, "do": true, "begin": true, "and": true, "or": true, "not": true,
+	"in": true, "when": true, "elsif": true,
+}
+
+// rubyBlock is a block that an end closes
+type rubyBlock struct {
+	symbol int // Index of its symbol in File.Symbols, or -1
+}
+
+// parseRuby parses the tokens of Ruby source code, matching the keywords
+// that open blocks with their ends
+func parseRuby(f *File) {
+	tokens := f.Tokens
+	matches := matchBrackets(tokens)
+	defined := make(map[int]bool)
+	var blocks []rubyBlock
+	loopLine := 0 // Line of a loop whose do doesn't open a block
+
+	// open opens a block, with the symbol s if it has a name
+	open := func(s *Symbol) {
+		if s == nil {
+			blocks = append(blocks, rubyBlock{symbol: -1})
+			return
+		}
+		s.BodyClose = len(tokens) - 1
+		s.End = tokens[len(tokens)-1].End
+		f.Symbols = append(f.Symbols, *s)
+		blocks = append(blocks, rubyBlock{symbol: len(f.Symbols) - 1})
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.Kind != Identifier || !isRubyKeyword(tokens, i) {
+			continue
+		}
+		switch tok.Text {
+		case "def":
+			s, end, ok := rubyMethod(tokens, matches, i)
+			if !ok {
+				continue
+			}
+			if s.nameIndex >= 0 {
+				defined[s.nameIndex] = true
+			}
+			if prev := i - 1; prev >= 0 && rubyVisibility[tokens[prev].Text] && tokens[prev].Line == tok.Line {
+				s.Start = tokens[prev].Offset
+			}
+			if s.BodyClose >= 0 {
+				// An endless method
+				f.Symbols = append(f.Symbols, s)
+			} else {
+				open(&s)
+			}
+			i = end
+
+		case "class", "module":
+			if textAt(tokens, i+1) == "<<" {
+				// class << self
+				open(nil)
+				continue
+			}
+			j := i + 1
+			if j >= len(tokens) || tokens[j].Kind != Identifier {
+				continue
+			}
+			for textAt(tokens, j+1) == "::" && j+2 < len(tokens) && tokens[j+2].Kind == Identifier {
+				j += 2
+			}
+			s := newSymbol(tok.Text)
+			s.Name, s.nameIndex = tokens[j].Text, j
+			defined[j] = true
+			s.Start = tok.Offset
+			s.BodyOpen = j
+			for k := j + 1; k < len(tokens) && tokens[k].Line == tok.Line && tokens[k].Text != ";"; k++ {
+				s.BodyOpen = k
+			}
+			open(&s)
+			i = s.BodyOpen
+
+		case "do":
+			if tok.Line == loopLine {
+				loopLine = 0
+				continue
+			}
+			open(nil)
+
+		case "begin", "case":
+			open(nil)
+
+		case "for", "while", "until", "if", "unless":
+			if isRubyModifier(tokens, i) {
+				continue
+			}
+			if tok.Text == "for" || tok.Text == "while" || tok.Text == "until" {
+				loopLine = tok.Line
+			}
+			open(nil)
+
+		case "end":
+			if len(blocks) == 0 {
+				continue
+			}
+			block := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			if block.symbol >= 0 {
+				s := &f.Symbols[block.symbol]
+				s.BodyClose, s.End = i, tok.End
+			}
+		}
+	}
+
+	for i, tok := range tokens {
+		if tok.Kind != Identifier || defined[i] {
+			continue
+		}
+		if isRubyKeyword(tokens, i) && (tok.Text == "require" || tok.Text == "require_relative" || tok.Text == "load") {
+			arg := i + 1
+			if textAt(tokens, arg) == "(" {
+				arg++
+			}
+			if arg < len(tokens) && tokens[arg].Kind == String && tokens[arg].Line == tok.Line {
+				f.Imports = append(f.Imports, stringValue(tokens[arg]))
+			}
+		}
+		if isRubyCall(f, i) {
+			f.addCall(i)
+		}
+	}
+}
+
+// rubyMethod reads the method defined by the def at index i, and returns
+// it with the index of the last token of its signature, or of the whole
+// method if it is an endless one
+func rubyMethod(tokens []Token, matches map[int]int, i int) (Symbol, int, bool) {
+	j := i + 1
+	if j+2 < len(tokens) && textAt(tokens, j+1) == "." && tokens[j+2].Line == tokens[i].Line {
+		// def self.name or def Const.name
+		j += 2
+	}
+	if j >= len(tokens) || tokens[j].Line != tokens[i].Line {
+		return Symbol{}, 0, false
+	}
+
+	s := newSymbol(KindFunction)
+	s.Start = tokens[i].Offset
+	s.Line, s.Column = tokens[j].Line, tokens[j].Column
+	last := j
+	switch {
+	case tokens[j].Kind == Identifier:
+		s.Name, s.nameIndex = tokens[j].Text, j
+		if textAt(tokens, j+1) == "=" && tokens[j+1].Offset == tokens[j].End && textAt(tokens, j+2) == "(" {
+			// A setter
+			s.Name += "="
+			last = j + 1
+		}
+	case tokens[j].Text == "[":
+		// def [] and def []=
+		s.Name = "[]"
+		last = j + 1
+		if textAt(tokens, j+2) == "=" && textAt(tokens, j+3) == "(" {
+			s.Name += "="
+			last = j + 2
+		}
+	case tokens[j].Kind == Punct:
+		// An operator
+		s.Name = tokens[j].Text
+	default:
+		return Symbol{}, 0, false
+	}
+
+	// The parameters, in parentheses or up to the end of the line
+	if textAt(tokens, last+1) == "(" {
+		if close, ok := matches[last+1]; ok {
+			s.ParamsOpen, s.ParamsClose = last+1, close
+			last = close
+		}
+	} else {
+		for last+1 < len(tokens) && tokens[last+1].Line == tokens[i].Line && tokens[last+1].Text != ";" &&
+			tokens[last+1].Text != "=" {
+			last++
+		}
+	}
+	s.BodyOpen = last
+
+	if textAt(tokens, last+1) == "=" && tokens[last+1].Line == tokens[i].Line {
+		// def name(args) = expression
+		s.BodyOpen = last + 1
+		end := last + 1
+		for end+1 < len(tokens) && !endsLine(tokens, end) {
+			end++
+		}
+		s.BodyClose, s.End = end, tokens[end].End
+		return s, end, true
+	}
+	return s, last, true
+}
+
+// isRubyKeyword reports whether the identifier at index i is in the place
+// of a keyword, rather than the name of a method called on an object or a
+// key of a hash
+func isRubyKeyword(tokens []Token, i int) bool {
+	switch textAt(tokens, i-1) {
+	case ".", "&.", "::":
+		return false
+	}
+	return !(textAt(tokens, i+1) == ":" && tokens[i+1].Offset == tokens[i].End)
+}
+
+// isRubyModifier reports whether the if, unless, while, until, or for at
+// index i modifies the statement before it on its line
+func isRubyModifier(tokens []Token, i int) bool {
+	if i == 0 || tokens[i-1].EndLine != tokens[i].Line {
+		return false
+	}
+	prev := tokens[i-1]
+	switch prev.Kind {
+	case Identifier:
+		return !rubyNonValues[prev.Text]
+	case String, Number:
+		return true
+	case Punct:
+		return prev.Text == ")" || prev.Text == "]" || prev.Text == "}"
+	}
+	return false
+}
+
+// isRubyCall reports whether the identifier at index i calls a method:
+// with parentheses, on an object, with arguments, or alone on its line
+func isRubyCall(f *File, i int) bool {
+	tokens := f.Tokens
+	tok := tokens[i]
+	if c := tok.Text[0]; !(c >= 'a' && c <= 'z' || c == '_') || f.Language.IsKeyword(tok.Text) {
+		return false
+	}
+	prev, next := textAt(tokens, i-1), textAt(tokens, i+1)
+	if prev == ":" && tokens[i-1].End == tok.Offset || next == ":" && tokens[i+1].Offset == tok.End {
+		// A symbol or a key of a hash
+		return false
+	}
+	switch {
+	case next == "(" && tokens[i+1].Offset == tok.End:
+		return true
+	case prev == "." || prev == "&.":
+		return true
+	case startsLine(tokens, i) && endsLine(tokens, i):
+		return true
+	case i+1 < len(tokens) && tokens[i+1].Line == tok.Line && startsLine(tokens, i):
+		arg := tokens[i+1]
+		return arg.Kind == String || arg.Kind == Number || arg.Kind == Identifier && !f.Language.IsKeyword(arg.Text)
+	}
+	return false
+}
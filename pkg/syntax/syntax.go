@@ -0,0 +1,368 @@
+package syntax
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Kind is the kind of a token
+type Kind int
+
+const (
+	Identifier Kind = iota // Identifiers and keywords
+	String                 // String, character, template, and regular expression literals
+	Number
+	Punct     // Operators, brackets, and other punctuation
+	Comment   // Comments, in File.Comments rather than File.Tokens
+	Directive // Preprocessor lines of C, C++, and C#, in File.Directives
+)
+
+// Token is a token of source code
+type Token struct {
+	Kind    Kind
+	Text    string // Source text
+	Offset  int    // Byte offset of the start
+	End     int    // Byte offset after the end
+	Line    int    // One-based line of the start
+	Column  int    // One-based column of the start, in bytes
+	EndLine int    // Line of the last character
+	open    int    // Length of the opening delimiter of a comment or string, with any prefix
+	close   int    // Length of the closing delimiter, 0 if it is missing
+}
+
+// Symbol kinds other than the keyword that declares a class-like type,
+// such as "class", "interface", "struct", "enum", "trait", or "module"
+const (
+	KindFunction = "function"
+	KindMethod   = "method"
+)
+
+// Symbol is a function, method, or class-like type defined in a file
+type Symbol struct {
+	Name      string // Empty for anonymous functions
+	Kind      string // KindFunction, KindMethod, or the keyword of a type, such as "class"
+	Container string // Name of the enclosing type, or of the type a method is declared for
+	Depth     int    // Number of enclosing symbols
+	Line      int    // Line of the name
+	Column    int    // One-based column of the name, in bytes
+	StartLine int    // First line of the definition, with its modifiers, annotations, or decorators
+	EndLine   int
+	DocLine   int // First line of the comments right above the definition, or StartLine
+	Start     int // Byte offset of the start of the definition
+	End       int // Byte offset after the end of the definition
+	Prototype bool
+
+	// Indexes in File.Tokens of the brackets around the parameters, or -1.
+	// A lambda with a single parameter without parentheses has ParamsOpen
+	// -1 and ParamsClose the index of the parameter.
+	ParamsOpen  int
+	ParamsClose int
+
+	// Indexes in File.Tokens of the tokens that open and close the body, or
+	// -1 without a body: its braces, the arrow and the last token of an
+	// expression body, the colon and the last token in Python, or the last
+	// token of the signature and the end in Ruby
+	BodyOpen  int
+	BodyClose int
+
+	nameIndex int // Index of the name in File.Tokens, or -1
+}
+
+// Call is a call of a function or method by name
+type Call struct {
+	Name   string
+	Line   int
+	Column int // One-based column of the name, in bytes
+}
+
+// File is the parsed source code of a file
+type File struct {
+	Language   Language
+	Package    string  // Package of a Go or Java file
+	Tokens     []Token // Code tokens, without comments and directives
+	Comments   []Token
+	Directives []Token
+	Symbols    []Symbol // In the order of their start
+	Calls      []Call
+	Imports    []string // Imported packages, modules, or files, as written
+
+	content    string
+	lineStarts []int
+}
+
+// IsFunction reports whether a symbol is a function or method
+func (s Symbol) IsFunction() bool {
+	return s.Kind == KindFunction || s.Kind == KindMethod
+}
+
+// Matches reports whether a symbol has a name, either alone or qualified
+// with its container, as in Type.method or Type::method
+func (s Symbol) Matches(name string) bool {
+	if s.Name == "" {
+		return false
+	}
+	return name == s.Name || s.Container != "" && (name == s.Container+"."+s.Name || name == s.Container+"::"+s.Name)
+}
+
+// ParseFile reads and parses a file in the language of its extension
+func ParseFile(path string) (*File, error) {
+	language, ok := LanguageForFile(path)
+	if !ok {
+		return nil, fmt.Errorf("unsupported file type: %s", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(content), language), nil
+}
+
+// Parse parses source code. It never fails: code it doesn't understand
+// just yields fewer symbols.
+func Parse(content string, language Language) *File {
+	f := &File{Language: language, content: content, lineStarts: lineStarts(content)}
+	if language.Name == "Go" {
+		parseGo(f)
+	} else {
+		for _, tok := range tokenize(content, language, f.lineStarts) {
+			switch tok.Kind {
+			case Comment:
+				f.Comments = append(f.Comments, tok)
+			case Directive:
+				f.Directives = append(f.Directives, tok)
+			default:
+				f.Tokens = append(f.Tokens, tok)
+			}
+		}
+		switch language.Name {
+		case "Python":
+			parsePython(f)
+		case "Ruby":
+			parseRuby(f)
+		default:
+			parseBraces(f)
+		}
+	}
+	f.finishSymbols()
+	return f
+}
+
+// Tokenize splits source code into tokens, with its comments and
+// directives
+func Tokenize(content string, language Language) []Token {
+	if language.Name == "Go" {
+		return goTokens(content, lineStarts(content))
+	}
+	return tokenize(content, language, lineStarts(content))
+}
+
+// Strings returns the string literals of the file
+func (f *File) Strings() []Token {
+	var strs []Token
+	for _, tok := range f.Tokens {
+		if tok.Kind == String {
+			strs = append(strs, tok)
+		}
+	}
+	return strs
+}
+
+// Line returns the text of a line, without its line ending
+func (f *File) Line(line int) string {
+	if line < 1 || line > len(f.lineStarts) {
+		return ""
+	}
+	end := len(f.content)
+	if line < len(f.lineStarts) {
+		end = f.lineStarts[line] - 1
+	}
+	return strings.TrimSuffix(f.content[f.lineStarts[line-1]:end], "\r")
+}
+
+// Segment is the text of a comment or string literal on one of its lines
+type Segment struct {
+	Text   string
+	Line   int
+	Column int // One-based column of the start of Text, in bytes
+}
+
+// Segments splits a comment or string literal into its text on each line,
+// without the comment markers, quotes, and prefixes around it, and without
+// the asterisks that start the lines of a block comment. Lines without
+// text are left out.
+func (f *File) Segments(tok Token) []Segment {
+	start, end := tok.Offset+tok.open, tok.End-tok.close
+	if start > end {
+		return nil
+	}
+	starred := tok.Kind == Comment && strings.HasPrefix(tok.Text, "/*")
+
+	var segments []Segment
+	for line := tok.Line; line <= tok.EndLine; line++ {
+		lineStart := f.lineStarts[line-1]
+		lineEnd := len(f.content)
+		if line < len(f.lineStarts) {
+			lineEnd = f.lineStarts[line] - 1
+		}
+		from, to := max(start, lineStart), min(end, lineEnd)
+		if line > tok.Line && starred {
+			// The asterisk that continues a block comment
+			for from < to && (f.content[from] == ' ' || f.content[from] == '\t') {
+				from++
+			}
+			if from < to && f.content[from] == '*' {
+				from++
+			}
+		}
+		text := strings.TrimRight(f.content[from:max(from, to)], "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		segments = append(segments, Segment{Text: text, Line: line, Column: from - lineStart + 1})
+	}
+	return segments
+}
+
+// lineStarts returns the byte offset of the start of each line
+func lineStarts(content string) []int {
+	starts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// position returns the one-based line and byte column of an offset
+func position(lineStarts []int, offset int) (int, int) {
+	line := sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset })
+	return line, offset - lineStarts[line-1] + 1
+}
+
+// newToken makes a token of the source from start to end
+func newToken(content string, lineStarts []int, kind Kind, start, end int) Token {
+	tok := Token{Kind: kind, Text: content[start:end], Offset: start, End: end}
+	tok.Line, tok.Column = position(lineStarts, start)
+	tok.EndLine = tok.Line
+	if end > start {
+		tok.EndLine, _ = position(lineStarts, end-1)
+	}
+	return tok
+}
+
+// finishSymbols sorts the symbols and fills in the lines, the comments
+// above them, and their containers and depths
+func (f *File) finishSymbols() {
+	sort.SliceStable(f.Symbols, func(i, j int) bool { return f.Symbols[i].Start < f.Symbols[j].Start })
+
+	// Lines with code, which end the comments above a definition
+	codeLines := make(map[int]bool)
+	for _, tok := range f.Tokens {
+		codeLines[tok.Line] = true
+		codeLines[tok.EndLine] = true
+	}
+
+	var stack []int
+	for i := range f.Symbols {
+		s := &f.Symbols[i]
+		s.StartLine, _ = position(f.lineStarts, s.Start)
+		s.EndLine, _ = position(f.lineStarts, max(s.Start, s.End-1))
+		if s.nameIndex >= 0 {
+			s.Line, s.Column = f.Tokens[s.nameIndex].Line, f.Tokens[s.nameIndex].Column
+		} else if s.Line == 0 {
+			s.Line, s.Column = position(f.lineStarts, s.Start)
+		}
+
+		// The comments that end on the line above, each alone on its lines
+		s.DocLine = s.StartLine
+		j := sort.Search(len(f.Comments), func(j int) bool { return f.Comments[j].Offset >= s.Start }) - 1
+		for ; j >= 0; j-- {
+			comment := f.Comments[j]
+			if comment.EndLine != s.DocLine-1 && !(comment.EndLine == s.DocLine && s.DocLine != s.StartLine) {
+				break
+			}
+			if codeLines[comment.Line] || codeLines[comment.EndLine] {
+				break
+			}
+			s.DocLine = comment.Line
+		}
+
+		// The enclosing symbols
+		for len(stack) > 0 && f.Symbols[stack[len(stack)-1]].End <= s.Start {
+			stack = stack[:len(stack)-1]
+		}
+		s.Depth = len(stack)
+		for k := len(stack) - 1; k >= 0 && s.Container == ""; k-- {
+			if parent := f.Symbols[stack[k]]; !parent.IsFunction() {
+				s.Container = parent.Name
+			} else {
+				break
+			}
+		}
+		if s.Kind == KindFunction && s.Container != "" {
+			s.Kind = KindMethod
+		}
+		stack = append(stack, i)
+	}
+}
+
+// addCall records a call of the identifier token at index i
+func (f *File) addCall(i int) {
+	tok := f.Tokens[i]
+	f.Calls = append(f.Calls, Call{Name: tok.Text, Line: tok.Line, Column: tok.Column})
+}
+
+// stringValue returns the text of a string literal without its quotes
+func stringValue(tok Token) string {
+	if tok.open+tok.close > len(tok.Text) {
+		return ""
+	}
+	return tok.Text[tok.open : len(tok.Text)-tok.close]
+}
+
+// matchBrackets returns the index of the matching bracket of each (, [,
+// {, ), ], and } token
+func matchBrackets(tokens []Token) map[int]int {
+	matches := make(map[int]int)
+	var stack []int
+	for i, tok := range tokens {
+		if tok.Kind != Punct {
+			continue
+		}
+		switch tok.Text {
+		case "(", "[", "{":
+			stack = append(stack, i)
+		case ")", "]", "}":
+			if len(stack) > 0 {
+				open := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				matches[open] = i
+				matches[i] = open
+			}
+		}
+	}
+	return matches
+}
+
+// startsLine reports whether the token at index i is the first code token
+// on its line
+func startsLine(tokens []Token, i int) bool {
+	return i == 0 || tokens[i].Line > tokens[i-1].EndLine
+}
+
+// endsLine reports whether the token at index i is the last code token on
+// its line
+func endsLine(tokens []Token, i int) bool {
+	return i == len(tokens)-1 || tokens[i+1].Line > tokens[i].EndLine
+}
+
+// textAt returns the text of the token at index i, or "" past the ends
+func textAt(tokens []Token, i int) string {
+	if i < 0 || i >= len(tokens) {
+		return ""
+	}
+	return tokens[i].Text
+}
@@ -0,0 +1,206 @@
+package syntax
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// symbolStrings describes the symbols of a file as kind, container, name,
+// and line, with prototypes marked
+func symbolStrings(f *File) []string {
+	var symbols []string
+	for _, s := range f.Symbols {
+		name := s.Name
+		if s.Container != "" {
+			name = s.Container + "." + name
+		}
+		text := fmt.Sprintf("%s %s:%d", s.Kind, name, s.Line)
+		if s.Prototype {
+			text += " prototype"
+		}
+		symbols = append(symbols, text)
+	}
+	return symbols
+}
+
+func TestParseSymbols(t *testing.T) {
+	tests := []struct {
+		language string
+		source   string
+		symbols  []string
+	}{
+		{
+			language: "Go",
+			source: `package shapes
+
+type Circle struct{ R float64 }
+
+func (c Circle) Area() float64 { return 3 * c.R * c.R }
+
+func New(r float64) Circle {
+	f := func() {}
+	f()
+	return Circle{r}
+}
+`,
+			symbols: []string{"struct Circle:3", "method Circle.Area:5", "function New:7", "function :8"},
+		},
+		{
+			language: "JavaScript",
+			source: `(req, res) => { res.end() }
+class Server {
+  listen(port) { return port }
+}
+const handler = async (x) => x * 2
+function start() {}
+`,
+			symbols: []string{"function :1", "class Server:2", "method Server.listen:3", "function handler:5", "function start:6"},
+		},
+		{
+			language: "TypeScript",
+			source: `interface Shape {
+  area(): number;
+}
+export class Square implements Shape {
+  constructor(private side: number) {}
+  area(): number { return this.side ** 2 }
+}
+const f = (e: Event): void => {}
+`,
+			symbols: []string{"interface Shape:1", "method Shape.area:2 prototype", "class Square:4", "method Square.constructor:5",
+				"method Square.area:6", "function f:8"},
+		},
+		{
+			language: "Python",
+			source: `class Greeter:
+    def greet(self, name):
+        return "hi " + name
+
+@decorator
+def main():
+    pass
+`,
+			symbols: []string{"class Greeter:1", "method Greeter.greet:2", "function main:6"},
+		},
+		{
+			language: "Java",
+			source: `public class App {
+    @Override
+    public String toString() { return "App"; }
+    abstract void run();
+    Runnable r = () -> {};
+}
+`,
+			symbols: []string{"class App:1", "method App.toString:3", "method App.run:4 prototype", "method App.r:5"},
+		},
+		{
+			language: "C",
+			source: `#include <stdio.h>
+struct point { int x, y; };
+int add(int a, int b);
+int add(int a, int b) { return a + b; }
+`,
+			symbols: []string{"struct point:2", "function add:3 prototype", "function add:4"},
+		},
+		{
+			language: "C++",
+			source: `struct V {
+  int operator[](int i) { return i; }
+  operator bool() const { return true; }
+  bool operator==(const V&) const;
+};
+V::operator int() const { return 0; }
+int x = a.operator+(b);
+`,
+			symbols: []string{"struct V:1", "method V.operator[]:2", "method V.operator bool:3", "method V.operator==:4 prototype",
+				"method V.operator int:6"},
+		},
+		{
+			language: "C#",
+			source: `public class Q<T> where T : class {
+    public int Run() { return 1; }
+    public U Map<U>(U u) where U : struct { return u; }
+    public int this[int i] => i;
+    public static implicit operator int(Q<T> q) => 1;
+}
+public class R<A> : Base where A : new() { void M() {} }
+`,
+			symbols: []string{"class Q:1", "method Q.Run:2", "method Q.Map:3", "method Q.this:4", "method Q.operator int:5",
+				"class R:7", "method R.M:7"},
+		},
+		{
+			language: "Ruby",
+			source: `module Util
+  class Parser
+    def parse(text)
+      text.split
+    end
+  end
+end
+`,
+			symbols: []string{"module Util:1", "class Util.Parser:2", "method Parser.parse:3"},
+		},
+		{
+			language: "PHP",
+			source: `<?php
+class User {
+    public function name(): string { return $this->name; }
+}
+$f = fn($x) => $x + 1;
+function helper() {}
+`,
+			symbols: []string{"class User:2", "method User.name:3", "function $f:5", "function helper:6"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.language, func(t *testing.T) {
+			language, ok := LanguageByName(test.language)
+			if !ok {
+				t.Fatalf("unknown language %s", test.language)
+			}
+			symbols := symbolStrings(Parse(test.source, language))
+			if !reflect.DeepEqual(symbols, test.symbols) {
+				t.Errorf("symbols:\n  got  %q\n  want %q", symbols, test.symbols)
+			}
+		})
+	}
+}
+
+func TestParseLeadingArrow(t *testing.T) {
+	// Parameters at the start of a file have no name before them
+	for _, name := range []string{"JavaScript", "TypeScript", "C#"} {
+		language, _ := LanguageByName(name)
+		for _, source := range []string{"(req, res) => {}", "() => 0", "(x) => x * 2", "(x)", "("} {
+			Parse(source, language)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	seeds := []string{
+		"", "(", ")", "{", "}", "=>", "() => 0", "(x) => x * 2", "func (", "def f(:\n", "class A<T> where T : class {",
+		"operator", "operator()(", "this[", "V::operator int() {}", "#define X(a) a\n", "'unterminated", "/* open",
+		"<?php fn($x) => $x;", "def m\n  end\nend\n", "template <typename T> T f(T x) { return x; }",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		for _, language := range languages {
+			file := Parse(source, language)
+			for _, s := range file.Symbols {
+				if s.Start < 0 || s.Start > s.End || s.End > len(source) {
+					t.Fatalf("%s: symbol %q spans %d to %d of %d bytes", language.Name, s.Name, s.Start, s.End, len(source))
+				}
+			}
+			for _, tok := range file.Tokens {
+				if !strings.HasPrefix(source[tok.Offset:], tok.Text) {
+					t.Fatalf("%s: token %q is not at offset %d", language.Name, tok.Text, tok.Offset)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package testrun
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goFramework runs Go tests with go test -json
+var goFramework = &framework{
+	name:    "go",
+	detect:  detectGo,
+	command: goCommand,
+	parse:   parseGoTestOutput,
+	rerun:   goRerunOptions,
+}
+
+// goTestEvent is one line of go test -json output
+type goTestEvent struct {
+	Action     string
+	Package    string
+	ImportPath string // Set on build-output and build-fail events instead of Package
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+// goLocationRegex matches file:line at the start of t.Error output, and the
+// file:line of the frames of a panic's stack trace
+var goLocationRegex = regexp.MustCompile(`^\s+(\S+\.go):(\d+)(?::| \+0x)`)
+
+// detectGo reports whether dir is inside a Go module
+func detectGo(dir string) bool {
+	for {
+		if fileExists(filepath.Join(dir, "go.mod")) {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// goCommand returns the go test command line. The report is read from
+// standard output, so no report file is used.
+func goCommand(dir string, options Options, reportFile string) []string {
+	args := []string{"go", "test", "-json"}
+	if options.Run != "" {
+		args = append(args, "-run", options.Run)
+	}
+	if len(options.Targets) == 0 {
+		return append(args, "./...")
+	}
+	return append(args, options.Targets...)
+}
+
+// parseGoTestOutput collects the test results from go test -json events.
+// Build errors and the output of packages that failed outside of any test
+// are returned separately.
+func parseGoTestOutput(dir string, stdout, stderr, report []byte) ([]TestResult, string, error) {
+	type goTest struct {
+		result TestResult
+		output strings.Builder
+	}
+
+	var tests []*goTest
+	testsByKey := make(map[string]*goTest)
+	packageOutput := make(map[string]*strings.Builder)
+	var failedPackages []string
+	var other strings.Builder
+
+	scanner := bufio.NewScanner(bytes.NewReader(stdout))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var event goTestEvent
+		if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &event) != nil {
+			// Older Go versions print build failures as plain text
+			other.Write(line)
+			other.WriteByte('\n')
+			continue
+		}
+
+		if event.Action == "build-output" {
+			other.WriteString(event.Output)
+			continue
+		}
+		if event.Action == "build-fail" {
+			continue
+		}
+
+		// Events for the package as a whole
+		if event.Test == "" {
+			switch event.Action {
+			case "output":
+				if packageOutput[event.Package] == nil {
+					packageOutput[event.Package] = &strings.Builder{}
+				}
+				packageOutput[event.Package].WriteString(event.Output)
+			case "fail":
+				failedPackages = append(failedPackages, event.Package)
+			}
+			continue
+		}
+
+		key := event.Package + "\x00" + event.Test
+		test := testsByKey[key]
+		if test == nil {
+			test = &goTest{result: TestResult{Name: event.Test, Package: event.Package}}
+			testsByKey[key] = test
+			tests = append(tests, test)
+		}
+
+		switch event.Action {
+		case "output":
+			test.output.WriteString(event.Output)
+		case "pass":
+			test.result.Status = StatusPass
+			test.result.Duration = event.Elapsed
+		case "fail":
+			test.result.Status = StatusFail
+			test.result.Duration = event.Elapsed
+		case "skip":
+			test.result.Status = StatusSkip
+			test.result.Duration = event.Elapsed
+		}
+	}
+
+	// Packages that failed without a failing test, such as from a panic
+	// outside of a test or a failing TestMain, only have package output
+	failedTestPackages := make(map[string]bool)
+	for _, test := range tests {
+		if test.result.Status == StatusFail {
+			failedTestPackages[test.result.Package] = true
+		}
+	}
+	for _, pkg := range failedPackages {
+		if !failedTestPackages[pkg] && packageOutput[pkg] != nil {
+			other.WriteString(packageOutput[pkg].String())
+		}
+	}
+	other.Write(stderr)
+
+	var results []TestResult
+	var packageDirs map[string]string
+	for _, test := range tests {
+		result := test.result
+		if result.Status == "" {
+			// Tests that never finished were cut short by a failure elsewhere
+			result.Status = StatusFail
+		}
+
+		if result.Status != StatusPass {
+			result.Output = cleanGoTestOutput(test.output.String())
+		}
+		if result.Status == StatusFail {
+			if packageDirs == nil {
+				packageDirs = goPackageDirs(dir, failedPackages)
+			}
+			// The locations are found in the output before it is cleaned,
+			// as go test indents the lines of t.Error
+			result.Locations = goLocations(dir, packageDirs[result.Package], test.output.String())
+		}
+		results = append(results, result)
+	}
+
+	return results, other.String(), scanner.Err()
+}
+
+// cleanGoTestOutput removes the lines that go test adds around a test's own
+// output, which repeat what the result already says, and its indentation
+func cleanGoTestOutput(output string) string {
+	var lines []string
+	for _, line := range strings.SplitAfter(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+			continue
+		}
+		// go test indents the output of a test by four spaces
+		lines = append(lines, strings.TrimPrefix(line, "    "))
+	}
+	return strings.TrimRight(strings.Join(lines, ""), "\n")
+}
+
+// goLocations finds the file:line of failed assertions and panics in a
+// test's output. t.Error output names the file without its directory, so it
+// is joined to the package directory.
+func goLocations(dir, packageDir, output string) []string {
+	var locations []string
+	for _, line := range strings.Split(output, "\n") {
+		match := goLocationRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		file := match[1]
+		lineNumber, _ := strconv.Atoi(match[2])
+		if !filepath.IsAbs(file) && packageDir != "" {
+			file = filepath.Join(packageDir, file)
+		}
+		if location, ok := relativeLocation(dir, file, lineNumber); ok {
+			locations = addLocation(locations, location)
+		}
+	}
+	return locations
+}
+
+// goPackageDirs looks up the directories of packages with go list
+func goPackageDirs(dir string, packages []string) map[string]string {
+	dirs := make(map[string]string)
+	packages = uniqueSorted(packages)
+	if len(packages) == 0 {
+		return dirs
+	}
+
+	cmd := exec.Command("go", append([]string{"list", "-f", "{{.ImportPath}}\t{{.Dir}}"}, packages...)...)
+	cmd.Dir = dir
+	output, _ := cmd.Output()
+	for _, line := range strings.Split(string(output), "\n") {
+		if importPath, packageDir, ok := strings.Cut(line, "\t"); ok {
+			dirs[importPath] = packageDir
+		}
+	}
+	return dirs
+}
+
+// goRerunOptions selects the failed tests by package and top-level test
+// name. Subtests are re-run as part of their top-level test.
+func goRerunOptions(failed []TestResult) Options {
+	var packages, names []string
+	for _, test := range failed {
+		packages = append(packages, test.Package)
+		name, _, _ := strings.Cut(test.Name, "/")
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	return Options{
+		Targets: uniqueSorted(packages),
+		Run:     "^(" + strings.Join(uniqueSorted(names), "|") + ")$",
+	}
+}
@@ -0,0 +1,60 @@
+package testrun
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGoTestOutputLocations(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/calc\n\ngo 1.21\n",
+		"calc_test.go": `package calc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	fmt.Println("main.go:3: printed, not an assertion")
+	t.Errorf("sum is %d, want %d", 3, 4)
+}
+
+func TestPass(t *testing.T) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "test", "-json", "./...")
+	cmd.Dir = dir
+	stdout, _ := cmd.Output()
+
+	results, _, err := parseGoTestOutput(dir, stdout, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed *TestResult
+	for i := range results {
+		if results[i].Name == "TestSum" {
+			failed = &results[i]
+		}
+	}
+	if failed == nil || failed.Status != StatusFail {
+		t.Fatalf("TestSum did not fail: %+v", results)
+	}
+	if want := []string{"calc_test.go:10"}; !reflect.DeepEqual(failed.Locations, want) {
+		t.Errorf("locations = %q, want %q", failed.Locations, want)
+	}
+}
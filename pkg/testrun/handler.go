@@ -0,0 +1,253 @@
+package testrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
+	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
+	"github.com/Code-Monger/CodeSpinneret/pkg/workspace"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// HandleTestRun is the handler function for the testrun tool
+func HandleTestRun(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+
+	// Extract session ID (optional, selects the workspace root and its sandbox limits)
+	sessionID, _ := arguments["session_id"].(string)
+
+	// Resolve the directory against the workspace root, which is also the default
+	directory, _ := arguments["directory"].(string)
+	directory, err := filepath.Abs(workspace.ResolveRelativePath(directory, sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory: %v", err)
+	}
+	if info, err := os.Stat(directory); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("directory not found: %s", directory)
+	}
+
+	// Extract output format (optional)
+	format, _ := arguments["format"].(string)
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "json" {
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	// Select the tests, either from the arguments or from the failures of the last run
+	frameworkName, _ := arguments["framework"].(string)
+	var options Options
+	if rerunFailed, _ := arguments["rerun_failed"].(bool); rerunFailed {
+		frameworkName, options, err = RerunOptions(sessionID, directory)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		targets, err := getTargetsArgument(arguments)
+		if err != nil {
+			return nil, err
+		}
+		options.Targets = targets
+		options.Run, _ = arguments["run"].(string)
+	}
+
+	// Extract timeout (optional)
+	timeoutSec, ok := arguments["timeout"].(float64)
+	if !ok {
+		// Default timeout: 10 minutes
+		timeoutSec = 600
+	}
+
+	command, err := newTestCommand(frameworkName, directory, options)
+	if err != nil {
+		return nil, err
+	}
+	defer command.Close()
+
+	// Check the command against the command policy
+	if result, err := policy.Enforce("testrun", sessionID, command.String(), arguments); result != nil || err != nil {
+		return result, err
+	}
+
+	// Apply the sandbox limits, which keep the workspace root writable, or
+	// the server's working directory if there is no workspace
+	limits := sandbox.ConfigForSession(sessionID)
+	writableRoot := workspace.GetRootDir(sessionID)
+
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSec*float64(time.Second)))
+	defer cancel()
+
+	reporter := progress.NewReporter(ctx, "testrun", request)
+	result, err := command.run(runCtx, sessionID, limits, writableRoot, reporter)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultText string
+	if format == "json" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode results: %v", err)
+		}
+		summary := fmt.Sprintf("Test results (JSON): %d passed, %d failed, %d skipped", result.Passed, result.Failed, result.Skipped)
+		resultText = artifact.LimitWhole("testrun", string(data), summary)
+	} else {
+		resultText = formatRunResult(result)
+		if limits.Enabled() {
+			resultText += fmt.Sprintf("\nSandbox: %s\n", limits)
+		}
+		resultText = artifact.Limit("testrun", resultText)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: resultText,
+			},
+		},
+	}, nil
+}
+
+// getTargetsArgument extracts the packages or test paths to run
+func getTargetsArgument(arguments map[string]interface{}) ([]string, error) {
+	values, ok := arguments["packages"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var targets []string
+	for _, value := range values {
+		target, ok := value.(string)
+		if !ok || target == "" {
+			return nil, fmt.Errorf("packages must be an array of non-empty strings")
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// formatRunResult formats a test run with the failures first, since they are
+// what needs attention
+func formatRunResult(result *RunResult) string {
+	status := "PASS"
+	if !result.Success() {
+		status = "FAIL"
+	}
+
+	resultText := fmt.Sprintf("Test Run: %s\n\n", status)
+	resultText += fmt.Sprintf("Framework: %s\n", result.Framework)
+	resultText += fmt.Sprintf("Command: %s\n", result.Command)
+	resultText += fmt.Sprintf("Directory: %s\n", result.Directory)
+	resultText += fmt.Sprintf("Tests: %d passed, %d failed, %d skipped\n", result.Passed, result.Failed, result.Skipped)
+	resultText += fmt.Sprintf("Duration: %.2fs\n", result.Duration)
+	resultText += fmt.Sprintf("Exit Code: %d\n", result.ExitCode)
+
+	if result.Errors != "" {
+		resultText += fmt.Sprintf("\nErrors:\n%s\n", result.Errors)
+	}
+
+	var failed, skipped, passed []TestResult
+	for _, test := range result.Tests {
+		switch test.Status {
+		case StatusFail:
+			failed = append(failed, test)
+		case StatusSkip:
+			skipped = append(skipped, test)
+		default:
+			passed = append(passed, test)
+		}
+	}
+
+	if len(failed) > 0 {
+		resultText += fmt.Sprintf("\nFailed Tests (%d):\n", len(failed))
+		for i, test := range failed {
+			resultText += fmt.Sprintf("\n%d. %s (%.2fs)\n", i+1, test.DisplayName(), test.Duration)
+			for _, location := range test.Locations {
+				resultText += fmt.Sprintf("   Location: %s\n", location)
+			}
+			if test.Output != "" {
+				resultText += fmt.Sprintf("   Output:\n%s\n", indent(test.Output, "      "))
+			}
+		}
+	}
+
+	if len(skipped) > 0 {
+		resultText += fmt.Sprintf("\nSkipped Tests (%d):\n", len(skipped))
+		for _, test := range skipped {
+			resultText += fmt.Sprintf("- %s", test.DisplayName())
+			if reason := firstLine(test.Output); reason != "" {
+				resultText += fmt.Sprintf(": %s", reason)
+			}
+			resultText += "\n"
+		}
+	}
+
+	if len(passed) > 0 {
+		resultText += fmt.Sprintf("\nPassed Tests (%d):\n", len(passed))
+		for _, test := range passed {
+			resultText += fmt.Sprintf("- %s (%.2fs)\n", test.DisplayName(), test.Duration)
+		}
+	}
+
+	if result.Failed > 0 {
+		resultText += "\nRun again with rerun_failed to run only the failed tests.\n"
+	}
+
+	return resultText
+}
+
+// RegisterTestRun registers the testrun tool with the MCP server
+func RegisterTestRun(mcpServer *server.MCPServer) {
+	// Create the tool definition
+	testrunTool := mcp.NewTool("testrun",
+		mcp.WithDescription("Runs tests and returns structured results: the pass, fail or skip status and duration of each test, with the output and file:line of failed assertions. Detects Go (go test -json), pytest and jest projects."),
+		mcp.WithString("session_id",
+			mcp.Description("Workspace session ID, whose root is the default directory and whose sandbox limits apply to the tests (optional)"),
+		),
+		mcp.WithString("directory",
+			mcp.Description("Directory of the project, relative to the workspace root (optional, default: the workspace root)"),
+		),
+		mcp.WithString("framework",
+			mcp.Description("Test framework: 'auto', 'go', 'pytest' or 'jest' (default: 'auto', detected from the project files)"),
+		),
+		mcp.WithArray("packages",
+			mcp.Description("Go packages such as ./pkg/..., or test files and directories for pytest and jest (optional, default: all tests)"),
+		),
+		mcp.WithString("run",
+			mcp.Description("Only run tests whose names match: a go test -run regular expression, a pytest -k expression, or a jest -t pattern (optional)"),
+		),
+		mcp.WithBoolean("rerun_failed",
+			mcp.Description("Run only the tests that failed in the last run in this directory, ignoring packages and run (default: false)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: 'text' or 'json' (default: 'text')"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Timeout in seconds (default: 600)"),
+		),
+		mcp.WithString("confirm",
+			mcp.Description("Confirmation token from an earlier call, for test commands that the command policy requires to be confirmed"),
+		),
+	)
+
+	// Wrap the handler with stats tracking
+	wrappedHandler := stats.WrapHandler("testrun", HandleTestRun)
+
+	// Register the tool with the wrapped handler
+	mcpServer.AddTool(testrunTool, wrappedHandler)
+
+	// Log the registration
+	log.Printf("[TestRun] Registered testrun tool")
+}
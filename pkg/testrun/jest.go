@@ -0,0 +1,160 @@
+package testrun
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jestFramework runs JavaScript tests with jest, reading the results from
+// its JSON report
+var jestFramework = &framework{
+	name:    "jest",
+	detect:  detectJest,
+	command: jestCommand,
+	parse:   parseJestReport,
+	rerun:   jestRerunOptions,
+}
+
+// jestReport is the part of jest's --json report that is used
+type jestReport struct {
+	TestResults []struct {
+		Name             string `json:"name"`    // Absolute path of the test file
+		Message          string `json:"message"` // Why the file failed, such as a syntax error
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Status          string   `json:"status"`
+			Duration        *float64 `json:"duration"` // Milliseconds
+			FailureMessages []string `json:"failureMessages"`
+			Location        *struct {
+				Line int `json:"line"`
+			} `json:"location"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// jestLocationRegex matches the file:line:column of a stack frame
+var jestLocationRegex = regexp.MustCompile(`\(?((?:[A-Za-z]:)?[^\s():]+\.(?:[cm]?[jt]sx?)):(\d+):\d+\)?`)
+
+// ansiRegex matches the color codes in jest's failure messages
+var ansiRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// detectJest reports whether dir holds a package that uses jest
+func detectJest(dir string) bool {
+	return fileContains(filepath.Join(dir, "package.json"), `"jest"`) ||
+		fileExists(filepath.Join(dir, "jest.config.js")) ||
+		fileExists(filepath.Join(dir, "jest.config.ts")) ||
+		fileExists(filepath.Join(dir, "jest.config.mjs")) ||
+		fileExists(filepath.Join(dir, "jest.config.cjs"))
+}
+
+// jestCommand returns the jest command line, preferring the package's own
+// jest over one that npx would download
+func jestCommand(dir string, options Options, reportFile string) []string {
+	args := []string{"npx", "jest"}
+	if local := filepath.Join(dir, "node_modules", ".bin", "jest"); fileExists(local) {
+		args = []string{local}
+	}
+
+	args = append(args, "--json", "--outputFile="+reportFile, "--testLocationInResults", "--ci")
+	if options.Run != "" {
+		args = append(args, "-t", options.Run)
+	}
+	return append(args, options.Targets...)
+}
+
+// parseJestReport collects the test results from a jest JSON report
+func parseJestReport(dir string, stdout, stderr, report []byte) ([]TestResult, string, error) {
+	if len(report) == 0 {
+		// jest failed before writing a report, such as on a configuration error
+		return nil, string(stderr) + string(stdout), nil
+	}
+
+	var parsed jestReport
+	if err := json.Unmarshal(report, &parsed); err != nil {
+		return nil, "", fmt.Errorf("failed to parse jest report: %v", err)
+	}
+
+	var results []TestResult
+	var other strings.Builder
+	for _, file := range parsed.TestResults {
+		testFile := file.Name
+		if rel, err := filepath.Rel(dir, file.Name); err == nil && !strings.HasPrefix(rel, "..") {
+			testFile = filepath.ToSlash(rel)
+		}
+
+		// A file that fails to load has no tests, only a message
+		if len(file.AssertionResults) == 0 && file.Message != "" {
+			other.WriteString(fmt.Sprintf("%s:\n%s\n", testFile, ansiRegex.ReplaceAllString(file.Message, "")))
+		}
+
+		for _, assertion := range file.AssertionResults {
+			result := TestResult{
+				Name:    assertion.FullName,
+				Package: testFile,
+			}
+			if assertion.Duration != nil {
+				result.Duration = *assertion.Duration / 1000
+			}
+
+			switch assertion.Status {
+			case "passed":
+				result.Status = StatusPass
+			case "failed":
+				result.Status = StatusFail
+				result.Output = ansiRegex.ReplaceAllString(strings.Join(assertion.FailureMessages, "\n"), "")
+				result.Locations = jestLocations(dir, file.Name, result.Output)
+				if len(result.Locations) == 0 && assertion.Location != nil {
+					if location, ok := relativeLocation(dir, file.Name, assertion.Location.Line); ok {
+						result.Locations = []string{location}
+					}
+				}
+			default:
+				// pending, skipped, todo and disabled tests
+				result.Status = StatusSkip
+				result.Output = assertion.Status
+			}
+			results = append(results, result)
+		}
+	}
+
+	return results, other.String(), nil
+}
+
+// jestLocations finds the file:line of a failure in its stack trace. Frames
+// in the test file come first, since that is where the assertion is.
+func jestLocations(dir, testFile, output string) []string {
+	var inTestFile, elsewhere []string
+	for _, match := range jestLocationRegex.FindAllStringSubmatch(output, -1) {
+		line, _ := strconv.Atoi(match[2])
+		location, ok := relativeLocation(dir, match[1], line)
+		if !ok {
+			continue
+		}
+		if match[1] == testFile {
+			inTestFile = addLocation(inTestFile, location)
+		} else {
+			elsewhere = addLocation(elsewhere, location)
+		}
+	}
+	for _, location := range elsewhere {
+		inTestFile = addLocation(inTestFile, location)
+	}
+	return inTestFile
+}
+
+// jestRerunOptions selects the failed tests by file and full name
+func jestRerunOptions(failed []TestResult) Options {
+	var files, names []string
+	for _, test := range failed {
+		files = append(files, test.Package)
+		names = append(names, regexp.QuoteMeta(test.Name))
+	}
+	return Options{
+		Targets: uniqueSorted(files),
+		Run:     "^(" + strings.Join(uniqueSorted(names), "|") + ")$",
+	}
+}
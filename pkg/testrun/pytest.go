@@ -0,0 +1,194 @@
+package testrun
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pytestFramework runs Python tests with pytest, reading the results from
+// its JUnit XML report
+var pytestFramework = &framework{
+	name:    "pytest",
+	detect:  detectPytest,
+	command: pytestCommand,
+	parse:   parsePytestReport,
+	rerun:   pytestRerunOptions,
+}
+
+// junitReport is the root of a JUnit XML report, which is either a
+// <testsuites> element or a single <testsuite>
+type junitReport struct {
+	XMLName xml.Name
+	Suites  []junitSuite    `xml:"testsuite"`
+	Cases   []junitTestCase `xml:"testcase"`
+}
+
+// junitSuite is a <testsuite> element
+type junitSuite struct {
+	Cases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a <testcase> element. The file and line attributes are
+// only written by the xunit1 report format.
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr"`
+	Line      string        `xml:"line,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitOutcome `xml:"failure"`
+	Error     *junitOutcome `xml:"error"`
+	Skipped   *junitOutcome `xml:"skipped"`
+}
+
+// junitOutcome is a <failure>, <error> or <skipped> element
+type junitOutcome struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// pytestLocationRegex matches the file:line lines of a pytest traceback
+var pytestLocationRegex = regexp.MustCompile(`(?m)^([^\s:]+\.py):(\d+): `)
+
+// detectPytest reports whether dir holds a pytest configuration or Python
+// test files
+func detectPytest(dir string) bool {
+	if fileExists(filepath.Join(dir, "pytest.ini")) ||
+		fileExists(filepath.Join(dir, "conftest.py")) ||
+		fileContains(filepath.Join(dir, "pyproject.toml"), "[tool.pytest") ||
+		fileContains(filepath.Join(dir, "setup.cfg"), "[tool:pytest]") ||
+		fileContains(filepath.Join(dir, "tox.ini"), "[pytest]") {
+		return true
+	}
+
+	for _, pattern := range []string{"test_*.py", "*_test.py", "tests/test_*.py", "tests/*_test.py"} {
+		if matches, _ := filepath.Glob(filepath.Join(dir, pattern)); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// pytestCommand returns the pytest command line. The xunit1 report format
+// is used because it includes the file and line of each test.
+func pytestCommand(dir string, options Options, reportFile string) []string {
+	args := []string{"python3", "-m", "pytest"}
+	if _, err := exec.LookPath("pytest"); err == nil {
+		args = []string{"pytest"}
+	}
+
+	args = append(args, "-q", "--junitxml="+reportFile, "-o", "junit_family=xunit1")
+	if options.Run != "" {
+		args = append(args, "-k", options.Run)
+	}
+	return append(args, options.Targets...)
+}
+
+// parsePytestReport collects the test results from a pytest JUnit XML report
+func parsePytestReport(dir string, stdout, stderr, report []byte) ([]TestResult, string, error) {
+	if len(report) == 0 {
+		// pytest failed before writing a report, such as on a usage error
+		return nil, string(stderr) + string(stdout), nil
+	}
+
+	var root junitReport
+	if err := xml.Unmarshal(report, &root); err != nil {
+		return nil, "", fmt.Errorf("failed to parse pytest report: %v", err)
+	}
+
+	cases := root.Cases
+	for _, suite := range root.Suites {
+		cases = append(cases, suite.Cases...)
+	}
+
+	var results []TestResult
+	for _, testCase := range cases {
+		result := TestResult{
+			Name:   pytestNodeID(testCase),
+			Status: StatusPass,
+		}
+		result.Duration, _ = strconv.ParseFloat(testCase.Time, 64)
+
+		// Errors in fixtures and collection count as failures
+		outcome := testCase.Failure
+		if outcome == nil {
+			outcome = testCase.Error
+		}
+
+		switch {
+		case outcome != nil:
+			result.Status = StatusFail
+			result.Output = strings.TrimSpace(outcome.Text)
+			if result.Output == "" {
+				result.Output = outcome.Message
+			}
+			result.Locations = pytestLocations(dir, testCase, result.Output)
+		case testCase.Skipped != nil:
+			result.Status = StatusSkip
+			result.Output = testCase.Skipped.Message
+		}
+		results = append(results, result)
+	}
+
+	// The report has everything about the tests; stderr may still explain
+	// problems outside of them
+	return results, string(stderr), nil
+}
+
+// pytestNodeID rebuilds the pytest node ID of a test, such as
+// tests/test_math.py::TestAdd::test_negative, from its report entry
+func pytestNodeID(testCase junitTestCase) string {
+	if testCase.File == "" {
+		if testCase.ClassName == "" {
+			return testCase.Name
+		}
+		return testCase.ClassName + "::" + testCase.Name
+	}
+
+	// The class name is the module path followed by the test classes
+	file := filepath.ToSlash(testCase.File)
+	module := strings.ReplaceAll(strings.TrimSuffix(file, ".py"), "/", ".")
+	classes := strings.TrimPrefix(strings.TrimPrefix(testCase.ClassName, module), ".")
+
+	id := file
+	if classes != "" && classes != testCase.ClassName {
+		id += "::" + strings.ReplaceAll(classes, ".", "::")
+	}
+	return id + "::" + testCase.Name
+}
+
+// pytestLocations finds the file:line of a failure in its traceback, or
+// falls back to the test's definition
+func pytestLocations(dir string, testCase junitTestCase, output string) []string {
+	var locations []string
+	for _, match := range pytestLocationRegex.FindAllStringSubmatch(output, -1) {
+		line, _ := strconv.Atoi(match[2])
+		if location, ok := relativeLocation(dir, match[1], line); ok {
+			locations = addLocation(locations, location)
+		}
+	}
+
+	if len(locations) == 0 && testCase.File != "" {
+		// The report's line numbers start at 0
+		if line, err := strconv.Atoi(testCase.Line); err == nil {
+			if location, ok := relativeLocation(dir, testCase.File, line+1); ok {
+				locations = append(locations, location)
+			}
+		}
+	}
+	return locations
+}
+
+// pytestRerunOptions selects the failed tests by node ID
+func pytestRerunOptions(failed []TestResult) Options {
+	var ids []string
+	for _, test := range failed {
+		ids = append(ids, test.Name)
+	}
+	return Options{Targets: uniqueSorted(ids)}
+}
@@ -0,0 +1,370 @@
+package testrun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Code-Monger/CodeSpinneret/pkg/policy"
+	"github.com/Code-Monger/CodeSpinneret/pkg/progress"
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
+)
+
+// Status is the outcome of a single test
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// TestResult is the outcome of a single test
+type TestResult struct {
+	Name      string   `json:"name"`                // Test name: Go test name, pytest node ID, or jest full name
+	Package   string   `json:"package,omitempty"`   // Go package or jest test file
+	Status    Status   `json:"status"`              // pass, fail or skip
+	Duration  float64  `json:"duration_seconds"`    // Run time in seconds
+	Output    string   `json:"output,omitempty"`    // Failure output, or the reason a test was skipped
+	Locations []string `json:"locations,omitempty"` // file:line of failed assertions, relative to the test directory
+}
+
+// DisplayName returns the test name together with its package, if any
+func (t TestResult) DisplayName() string {
+	if t.Package == "" {
+		return t.Name
+	}
+	return t.Package + " " + t.Name
+}
+
+// RunResult is the outcome of a test run
+type RunResult struct {
+	Framework string       `json:"framework"`
+	Command   string       `json:"command"`
+	Directory string       `json:"directory"`
+	ExitCode  int          `json:"exit_code"`
+	Duration  float64      `json:"duration_seconds"`
+	Passed    int          `json:"passed"`
+	Failed    int          `json:"failed"`
+	Skipped   int          `json:"skipped"`
+	Tests     []TestResult `json:"tests"`
+	Errors    string       `json:"errors,omitempty"` // Output not tied to a test, such as build errors
+}
+
+// Success reports whether the run had no failed tests and no errors
+func (r *RunResult) Success() bool {
+	return r.Failed == 0 && r.Errors == "" && r.ExitCode == 0
+}
+
+// FailedTests returns the tests that failed
+func (r *RunResult) FailedTests() []TestResult {
+	var failed []TestResult
+	for _, test := range r.Tests {
+		if test.Status == StatusFail {
+			failed = append(failed, test)
+		}
+	}
+	return failed
+}
+
+// Options selects the tests to run
+type Options struct {
+	Targets []string // Go packages, or test files and directories for pytest and jest
+	Run     string   // Test name filter: go test -run, pytest -k, or jest -t
+}
+
+// framework runs the tests of one kind of project
+type framework struct {
+	name string
+	// detect reports whether a directory holds a project of this kind
+	detect func(dir string) bool
+	// command returns the command line, which writes its report to reportFile
+	// if the framework needs one
+	command func(dir string, options Options, reportFile string) []string
+	// parse turns the output of the command and its report into test results,
+	// and returns the output that is not tied to a test
+	parse func(dir string, stdout, stderr, report []byte) ([]TestResult, string, error)
+	// rerun returns the options that select the given failed tests
+	rerun func(failed []TestResult) Options
+}
+
+// frameworks in detection order
+var frameworks = []*framework{goFramework, pytestFramework, jestFramework}
+
+// FrameworkNames lists the supported frameworks
+func FrameworkNames() []string {
+	var names []string
+	for _, f := range frameworks {
+		names = append(names, f.name)
+	}
+	return names
+}
+
+// findFramework returns the framework with the given name, or detects it
+// from the files in dir if the name is empty or "auto"
+func findFramework(name, dir string) (*framework, error) {
+	if name == "" || name == "auto" {
+		for _, f := range frameworks {
+			if f.detect(dir) {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("no supported test framework found in %s (supported: %s)", dir, strings.Join(FrameworkNames(), ", "))
+	}
+
+	for _, f := range frameworks {
+		if f.name == name {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported framework: %s (supported: %s)", name, strings.Join(FrameworkNames(), ", "))
+}
+
+// Last runs per session and directory, for re-running the failed tests
+var (
+	lastRuns   = make(map[string]*RunResult)
+	lastMutex  sync.Mutex
+	runOptions = make(map[string]Options)
+)
+
+// runKey identifies the last run of a session in a directory
+func runKey(sessionID, dir string) string {
+	return sessionID + "\x00" + dir
+}
+
+// saveRun remembers a run so that its failed tests can be re-run
+func saveRun(sessionID string, result *RunResult, options Options) {
+	lastMutex.Lock()
+	defer lastMutex.Unlock()
+
+	key := runKey(sessionID, result.Directory)
+	lastRuns[key] = result
+	runOptions[key] = options
+}
+
+// LastRun returns the last run of a session in a directory
+func LastRun(sessionID, dir string) (*RunResult, bool) {
+	lastMutex.Lock()
+	defer lastMutex.Unlock()
+
+	result, exists := lastRuns[runKey(sessionID, dir)]
+	return result, exists
+}
+
+// RerunOptions returns the framework name and the options that run the
+// tests that failed in the last run of a session in a directory
+func RerunOptions(sessionID, dir string) (string, Options, error) {
+	last, exists := LastRun(sessionID, dir)
+	if !exists {
+		return "", Options{}, fmt.Errorf("no previous test run in %s", dir)
+	}
+
+	f, err := findFramework(last.Framework, dir)
+	if err != nil {
+		return "", Options{}, err
+	}
+
+	failed := last.FailedTests()
+	if len(failed) == 0 {
+		if last.Errors == "" {
+			return "", Options{}, fmt.Errorf("no tests failed in the previous run in %s", dir)
+		}
+		// Nothing to narrow down to when the tests didn't build or load, so
+		// the whole previous run is repeated
+		lastMutex.Lock()
+		defer lastMutex.Unlock()
+		return f.name, runOptions[runKey(sessionID, dir)], nil
+	}
+
+	return f.name, f.rerun(failed), nil
+}
+
+// testCommand is a test run that is ready to start
+type testCommand struct {
+	framework *framework
+	args      []string
+	display   []string // args with a placeholder for the report file, which changes on every run
+	dir       string
+	reportDir string
+	options   Options
+}
+
+// newTestCommand builds the command line for a test run, so that it can be
+// checked before it is run. The framework writes its report, if it needs one,
+// to a new temporary directory, which Close removes.
+func newTestCommand(frameworkName, dir string, options Options) (*testCommand, error) {
+	f, err := findFramework(frameworkName, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	reportDir, err := os.MkdirTemp("", "testrun-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report directory: %v", err)
+	}
+
+	return &testCommand{
+		framework: f,
+		args:      f.command(dir, options, filepath.Join(reportDir, "report")),
+		display:   f.command(dir, options, "<report>"),
+		dir:       dir,
+		reportDir: reportDir,
+		options:   options,
+	}, nil
+}
+
+// String returns the command line, which stays the same between runs so that
+// policy confirmations apply to it
+func (c *testCommand) String() string {
+	return policy.QuoteCommandLine(c.display)
+}
+
+// Close removes the report directory
+func (c *testCommand) Close() {
+	os.RemoveAll(c.reportDir)
+}
+
+// run runs the tests with the given sandbox limits and collects the results.
+// Standard error is streamed to the client as partial output.
+func (c *testCommand) run(ctx context.Context, sessionID string, limits sandbox.Config, writableRoot string, reporter *progress.Reporter) (*RunResult, error) {
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Dir = c.dir
+
+	// The report is written outside of the workspace, so it must stay
+	// writable in a sandbox with a read-only filesystem
+	limits.WritablePaths = append(append([]string(nil), limits.WritablePaths...), c.reportDir)
+	if err := sandbox.Wrap(cmd, limits, writableRoot); err != nil {
+		return nil, fmt.Errorf("failed to set up sandbox: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = io.MultiWriter(&stderr, reporter.Writer("stderr"))
+
+	startTime := time.Now()
+	err := cmd.Run()
+	result := &RunResult{
+		Framework: c.framework.name,
+		Command:   c.String(),
+		Directory: c.dir,
+		Duration:  time.Since(startTime).Seconds(),
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("test run timed out after %v", time.Since(startTime).Round(time.Second))
+	}
+
+	// Test failures give a non-zero exit code, which isn't an error here
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil, fmt.Errorf("failed to run %s: %v", c.args[0], err)
+		}
+		result.ExitCode = exitErr.ExitCode()
+	}
+
+	// A missing report is left to the parser, which falls back to the output
+	report, _ := os.ReadFile(filepath.Join(c.reportDir, "report"))
+
+	tests, errors, err := c.framework.parse(c.dir, stdout.Bytes(), stderr.Bytes(), report)
+	if err != nil {
+		return nil, err
+	}
+	result.Tests = tests
+	result.Errors = strings.TrimSpace(errors)
+
+	for _, test := range tests {
+		switch test.Status {
+		case StatusPass:
+			result.Passed++
+		case StatusFail:
+			result.Failed++
+		case StatusSkip:
+			result.Skipped++
+		}
+	}
+
+	// A failing command without failed tests or other output still needs
+	// explaining
+	if result.ExitCode != 0 && result.Failed == 0 && result.Errors == "" {
+		result.Errors = strings.TrimSpace(stderr.String() + "\n" + stdout.String())
+		if result.Errors == "" {
+			result.Errors = fmt.Sprintf("%s exited with code %d", c.args[0], result.ExitCode)
+		}
+	}
+
+	saveRun(sessionID, result, c.options)
+	return result, nil
+}
+
+// relativeLocation returns file:line with the file relative to dir where
+// possible. Files outside of dir, such as those of the standard library or
+// dependencies, are skipped.
+func relativeLocation(dir, file string, line int) (string, bool) {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		file = rel
+	}
+	if strings.Contains(filepath.ToSlash(file), "node_modules/") {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%d", filepath.ToSlash(file), line), true
+}
+
+// addLocation appends a location unless it is already in the list
+func addLocation(locations []string, location string) []string {
+	for _, existing := range locations {
+		if existing == location {
+			return locations
+		}
+	}
+	return append(locations, location)
+}
+
+// fileExists reports whether a regular file exists
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// fileContains reports whether a file exists and contains a string
+func fileContains(path, s string) bool {
+	content, err := os.ReadFile(path)
+	return err == nil && bytes.Contains(content, []byte(s))
+}
+
+// uniqueSorted returns the distinct strings in sorted order
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// indent prefixes each line of text
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
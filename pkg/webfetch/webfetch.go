@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Code-Monger/CodeSpinneret/pkg/artifact"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -87,7 +88,7 @@ func Handler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolRes
 		resultText = fmt.Sprintf("Error fetching %s: %s", response.URL, response.Error)
 	} else {
 		resultText = fmt.Sprintf("Successfully fetched %s\n\nStatus Code: %d\nContent Type: %s\n\nContent:\n%s",
-			response.URL, response.StatusCode, response.ContentType, truncateContent(response.Content, 5000))
+			response.URL, response.StatusCode, response.ContentType, artifact.Limit("webfetch", response.Content))
 	}
 
 	return &mcp.CallToolResult{
@@ -100,14 +101,6 @@ func Handler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolRes
 	}, nil
 }
 
-// truncateContent truncates content to a maximum length
-func truncateContent(content string, maxLength int) string {
-	if len(content) <= maxLength {
-		return content
-	}
-	return content[:maxLength] + "... [truncated]"
-}
-
 // FetchWebPage fetches a web page and returns its content
 func FetchWebPage(request WebFetchRequest) (*WebFetchResponse, error) {
 	config := GetConfig()
@@ -0,0 +1,51 @@
+package workspace
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EditRecord is the set of files changed by the most recent editing tool
+// call in a session
+type EditRecord struct {
+	Tool  string    // Name of the tool that made the edit
+	Files []string  // Absolute paths of the changed files
+	Time  time.Time // When the edit was made
+}
+
+// Most recent edit per session
+var (
+	lastEdits = make(map[string]EditRecord)
+	editMutex sync.RWMutex
+)
+
+// RecordEdit remembers the files changed by an editing tool, so that other
+// tools can limit their work to them. Calls that changed nothing are ignored.
+func RecordEdit(sessionID, tool string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+
+	record := EditRecord{Tool: tool, Time: time.Now()}
+	for _, file := range files {
+		if absPath, err := filepath.Abs(file); err == nil {
+			file = absPath
+		}
+		record.Files = append(record.Files, file)
+	}
+
+	editMutex.Lock()
+	defer editMutex.Unlock()
+
+	lastEdits[sessionID] = record
+}
+
+// LastEdit returns the most recent edit in a session
+func LastEdit(sessionID string) (EditRecord, bool) {
+	editMutex.RLock()
+	defer editMutex.RUnlock()
+
+	record, exists := lastEdits[sessionID]
+	return record, exists
+}
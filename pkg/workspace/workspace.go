@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Code-Monger/CodeSpinneret/pkg/sandbox"
 	"github.com/Code-Monger/CodeSpinneret/pkg/stats"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -135,6 +136,18 @@ func HandleWorkspace(ctx context.Context, request mcp.CallToolRequest) (*mcp.Cal
 			sessionID = fmt.Sprintf("session-%d", time.Now().Unix())
 		}
 
+		// Extract sandbox overrides (optional, default: the server's limits),
+		// which can only tighten them
+		limits := sandbox.DefaultConfig()
+		if overrides, ok := arguments["sandbox"].(map[string]interface{}); ok {
+			var err error
+			limits, err = sandbox.ApplyOverrides(limits, overrides, rootDir)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sandbox.SetSessionConfig(sessionID, limits)
+
 		// Set workspace info
 		SetWorkspaceInfo(WorkspaceInfo{
 			RootDir:   rootDir,
@@ -147,6 +160,7 @@ func HandleWorkspace(ctx context.Context, request mcp.CallToolRequest) (*mcp.Cal
 		resultText += fmt.Sprintf("Root directory: %s\n", rootDir)
 		resultText += fmt.Sprintf("User task: %s\n", userTask)
 		resultText += fmt.Sprintf("Session ID: %s\n", sessionID)
+		resultText += fmt.Sprintf("Sandbox: %s\n", limits)
 
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -177,6 +191,7 @@ func HandleWorkspace(ctx context.Context, request mcp.CallToolRequest) (*mcp.Cal
 		resultText += fmt.Sprintf("Session ID: %s\n", info.SessionID)
 		resultText += fmt.Sprintf("Initialized: %s\n", info.InitTime.Format(time.RFC3339))
 		resultText += fmt.Sprintf("Last accessed: %s\n", info.LastAccess.Format(time.RFC3339))
+		resultText += fmt.Sprintf("Sandbox: %s\n", sandbox.ConfigForSession(sessionID))
 
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -293,6 +308,9 @@ func RegisterWorkspace(mcpServer *server.MCPServer) {
 		mcp.WithString("session_id",
 			mcp.Description("Session ID (required for 'get' operation, optional for 'initialize' operation)"),
 		),
+		mcp.WithObject("sandbox",
+			mcp.Description("Tighter sandbox limits than the server's for commands run in this workspace on Linux (for 'initialize' operation). Keys: cpu_seconds, memory_mb, max_processes (positive, no higher than the server's limits), no_network, read_only_fs (booleans, can only be turned on), writable_paths (array of paths inside the workspace root)"),
+		),
 	)
 
 	// Wrap the handler with stats tracking